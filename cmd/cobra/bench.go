@@ -0,0 +1,486 @@
+package cobra
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"chatgpt-adapter/core/common/inited"
+	"chatgpt-adapter/core/common/toolcall"
+	"chatgpt-adapter/core/config"
+	"chatgpt-adapter/core/gin/model"
+	"chatgpt-adapter/core/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/iocgo/sdk/cobra"
+	"github.com/iocgo/sdk/env"
+)
+
+type BenchCommand struct {
+	env    *env.Environment
+	engine *gin.Engine
+
+	Models      string `cobra:"models" short:"m" usage:"测试的模型，逗号分隔"`
+	Token       string `cobra:"token" short:"t" usage:"请求凭证，等价于 Authorization: Bearer <token>"`
+	Output      string `cobra:"output" short:"o" usage:"结果报告输出路径 (json)"`
+	Baseline    string `cobra:"baseline" short:"b" usage:"与之前的结果报告对比"`
+	Concurrency int    `cobra:"concurrency" short:"c" usage:"并发数"`
+	LogLevel    string `cobra:"log" short:"L" usage:"日志级别: trace|debug|info|warn|error"`
+}
+
+// 数据集中的一条用例
+//
+//	{"id": "weather", "messages": [...], "tools": [...], "expected": {"tool": "get_weather", "arguments": {"city": "杭州"}}}
+type benchCase struct {
+	Id         string                    `json:"id"`
+	Messages   []model.Keyv[interface{}] `json:"messages"`
+	Tools      []model.Keyv[interface{}] `json:"tools"`
+	ToolChoice interface{}               `json:"tool_choice,omitempty"`
+	Expected   struct {
+		Tool      string                 `json:"tool"` // 为空表示不应调用工具
+		Arguments map[string]interface{} `json:"arguments"`
+	} `json:"expected"`
+}
+
+type benchResult struct {
+	Id        string   `json:"id"`
+	Model     string   `json:"model"`
+	Expected  string   `json:"expected"`
+	Tool      string   `json:"tool"`
+	Arguments string   `json:"arguments,omitempty"`
+	Correct   bool     `json:"correct"`           // 工具选择正确
+	Valid     *bool    `json:"valid,omitempty"`   // 参数符合工具定义，未调用工具时为空
+	Matched   *bool    `json:"matched,omitempty"` // 参数与期望一致，未指定期望参数时为空
+	Problems  []string `json:"problems,omitempty"`
+	Latency   int64    `json:"latency_ms"`
+	Usage     Usage    `json:"usage"`
+	Error     string   `json:"error,omitempty"`
+}
+
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type benchSummary struct {
+	Model     string  `json:"model"`
+	Cases     int     `json:"cases"`
+	Errors    int     `json:"errors"`
+	Accuracy  float64 `json:"accuracy"` // 工具选择正确的比例
+	Validity  float64 `json:"validity"` // 调用工具时参数合法的比例
+	Matching  float64 `json:"matching"` // 指定期望参数时参数一致的比例
+	LatencyMs struct {
+		Avg int64 `json:"avg"`
+		P50 int64 `json:"p50"`
+		P95 int64 `json:"p95"`
+	} `json:"latency_ms"`
+	Usage Usage `json:"usage"`
+}
+
+// 结果按模型与数据集顺序排列，便于对比两次运行
+type benchReport struct {
+	Dataset string         `json:"dataset"`
+	Models  []benchSummary `json:"models"`
+	Cases   []benchResult  `json:"cases"`
+}
+
+// 工具选择基准测试: bench [dataset.jsonl] -m {models}
+func newBenchCommand(environment *env.Environment, engine *gin.Engine) cobra.ICobra {
+	return cobra.ICobraWrapper(&BenchCommand{
+		env:         environment,
+		engine:      engine,
+		Concurrency: 1,
+		LogLevel:    "warn",
+	}, `{
+		"Use":   "bench [dataset.jsonl]",
+		"Short": "工具选择基准测试",
+		"Run":   "Run"
+	}`)
+}
+
+func (bc *BenchCommand) Run(cmd *cobra.Command, args []string) {
+	if len(args) == 0 {
+		fmt.Println("dataset is required")
+		os.Exit(1)
+	}
+
+	var models []string
+	for _, mod := range strings.Split(bc.Models, ",") {
+		if mod = strings.TrimSpace(mod); mod != "" {
+			models = append(models, mod)
+		}
+	}
+	if len(models) == 0 {
+		fmt.Println("--models is required")
+		os.Exit(1)
+	}
+
+	cases, err := loadCases(args[0])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// 与启动服务相同的初始化，请求经过完整的处理流程
+	config.MustApplyEnv(bc.env)
+	logger.InitLogger("log", LogLevel(bc.LogLevel), "text")
+	config.MustValidate(bc.env)
+	inited.Initialized(bc.env)
+
+	results := bc.run(models, cases)
+	report := benchReport{Dataset: args[0], Cases: results}
+	for _, mod := range models {
+		report.Models = append(report.Models, summarize(mod, results))
+	}
+	printReport(report)
+
+	if bc.Baseline != "" {
+		if err = compareReport(bc.Baseline, report); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+
+	if bc.Output != "" {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		if err = os.WriteFile(bc.Output, append(data, '\n'), 0644); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Println("report written to " + bc.Output)
+	}
+}
+
+func loadCases(path string) (cases []benchCase, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 || text[0] == '#' {
+			continue
+		}
+
+		var c benchCase
+		if err = json.Unmarshal(text, &c); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, line, err)
+		}
+		if len(c.Messages) == 0 {
+			return nil, fmt.Errorf("%s:%d: messages is empty", path, line)
+		}
+		if c.Id == "" {
+			c.Id = fmt.Sprintf("#%d", line)
+		}
+		cases = append(cases, c)
+	}
+	if err = scanner.Err(); err == nil && len(cases) == 0 {
+		err = errors.New("dataset is empty")
+	}
+	return
+}
+
+func (bc *BenchCommand) run(models []string, cases []benchCase) []benchResult {
+	var (
+		results = make([]benchResult, len(models)*len(cases))
+		queue   = make(chan int)
+		wg      sync.WaitGroup
+	)
+	for range max(bc.Concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				results[i] = bc.exec(models[i/len(cases)], cases[i%len(cases)])
+			}
+		}()
+	}
+	for i := range results {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+	return results
+}
+
+func (bc *BenchCommand) exec(mod string, c benchCase) (result benchResult) {
+	result = benchResult{Id: c.Id, Model: mod, Expected: c.Expected.Tool}
+	body, _ := json.Marshal(map[string]interface{}{
+		"model":       mod,
+		"messages":    c.Messages,
+		"tools":       c.Tools,
+		"tool_choice": c.ToolChoice,
+		"stream":      false,
+	})
+
+	request := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	if bc.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+bc.Token)
+	}
+	w := httptest.NewRecorder()
+
+	now := time.Now()
+	bc.engine.ServeHTTP(w, request)
+	result.Latency = time.Since(now).Milliseconds()
+
+	var resp struct {
+		model.Response
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		result.Error = fmt.Sprintf("%d: %v", w.Code, err)
+		return
+	}
+	if resp.Error != nil {
+		result.Error = fmt.Sprintf("%d: %s", w.Code, resp.Error.Message)
+		return
+	}
+	if w.Code >= http.StatusBadRequest {
+		result.Error = fmt.Sprintf("%d: %s", w.Code, http.StatusText(w.Code))
+		return
+	}
+	result.Usage = usageOf(resp.Usage)
+
+	if len(resp.Choices) == 0 || resp.Choices[0].Message == nil {
+		result.Error = "empty response"
+		return
+	}
+	if calls := resp.Choices[0].Message.ToolCalls; len(calls) > 0 {
+		var fn struct {
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		}
+		marshal, _ := json.Marshal(calls[0]["function"])
+		_ = json.Unmarshal(marshal, &fn)
+		result.Tool, result.Arguments = fn.Name, fn.Arguments
+	}
+	result.Correct = result.Tool == c.Expected.Tool
+	if result.Tool == "" {
+		return
+	}
+
+	var args interface{} = map[string]interface{}{}
+	if result.Arguments != "" {
+		if err := json.Unmarshal([]byte(result.Arguments), &args); err != nil {
+			result.Problems = []string{"arguments is not json: " + err.Error()}
+		}
+	}
+	if result.Problems == nil {
+		if schema, ok := parameters(c.Tools, result.Tool); ok {
+			result.Problems = toolcall.Validate(schema, args)
+		} else {
+			result.Problems = []string{fmt.Sprintf("tool `%s` does not exist", result.Tool)}
+		}
+	}
+	valid := len(result.Problems) == 0
+	result.Valid = &valid
+
+	if result.Correct && c.Expected.Arguments != nil {
+		matched := matchArgs(c.Expected.Arguments, args)
+		result.Matched = &matched
+	}
+	return
+}
+
+// 请求中对应工具的 parameters
+func parameters(tools []model.Keyv[interface{}], name string) (map[string]interface{}, bool) {
+	for _, t := range tools {
+		fn := t.GetKeyv("function")
+		if fn.GetString("name") == name {
+			schema, _ := fn.Get("parameters")
+			value, _ := schema.(map[string]interface{})
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// 期望的参数均出现且一致，字符串忽略首尾空白与大小写
+func matchArgs(expected map[string]interface{}, args interface{}) bool {
+	actual, ok := args.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for key, value := range expected {
+		v, exists := actual[key]
+		if !exists {
+			return false
+		}
+		if s1, isStr := value.(string); isStr {
+			s2, _ := v.(string)
+			if !strings.EqualFold(strings.TrimSpace(s1), strings.TrimSpace(s2)) {
+				return false
+			}
+			continue
+		}
+		m1, _ := json.Marshal(value)
+		m2, _ := json.Marshal(v)
+		if !bytes.Equal(m1, m2) {
+			return false
+		}
+	}
+	return true
+}
+
+func usageOf(value map[string]interface{}) (usage Usage) {
+	marshal, _ := json.Marshal(value)
+	_ = json.Unmarshal(marshal, &usage)
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	return
+}
+
+func summarize(mod string, results []benchResult) (summary benchSummary) {
+	summary.Model = mod
+	var (
+		latencies        []int64
+		called, valid    int
+		expected, match  int
+		correct, latency int64
+	)
+	for _, result := range results {
+		if result.Model != mod {
+			continue
+		}
+		summary.Cases++
+		if result.Error != "" {
+			summary.Errors++
+		}
+		if result.Correct {
+			correct++
+		}
+		if result.Valid != nil {
+			called++
+			if *result.Valid {
+				valid++
+			}
+		}
+		if result.Matched != nil {
+			expected++
+			if *result.Matched {
+				match++
+			}
+		}
+		latency += result.Latency
+		latencies = append(latencies, result.Latency)
+		summary.Usage.PromptTokens += result.Usage.PromptTokens
+		summary.Usage.CompletionTokens += result.Usage.CompletionTokens
+		summary.Usage.TotalTokens += result.Usage.TotalTokens
+	}
+	if summary.Cases == 0 {
+		return
+	}
+
+	summary.Accuracy = ratio(int(correct), summary.Cases)
+	summary.Validity = ratio(valid, called)
+	summary.Matching = ratio(match, expected)
+	slices.Sort(latencies)
+	summary.LatencyMs.Avg = latency / int64(summary.Cases)
+	summary.LatencyMs.P50 = percentile(latencies, 0.50)
+	summary.LatencyMs.P95 = percentile(latencies, 0.95)
+	return
+}
+
+func ratio(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(n)/float64(total)*10000) / 10000
+}
+
+func percentile(sorted []int64, p float64) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(math.Ceil(p*float64(len(sorted))))-1]
+}
+
+func printReport(report benchReport) {
+	for _, result := range report.Cases {
+		if passed(result) {
+			continue
+		}
+		reason := result.Error
+		switch {
+		case reason != "":
+		case !result.Correct:
+			reason = fmt.Sprintf("expected `%s`, got `%s`", result.Expected, result.Tool)
+		case len(result.Problems) > 0:
+			reason = strings.Join(result.Problems, "; ")
+		default:
+			reason = "arguments mismatch: " + result.Arguments
+		}
+		fmt.Printf("FAIL %s [%s] %s\n", result.Model, result.Id, reason)
+	}
+
+	fmt.Printf("%-32s %6s %6s %9s %9s %9s %8s %8s %8s %10s\n",
+		"model", "cases", "errors", "accuracy", "validity", "matching", "avg(ms)", "p50(ms)", "p95(ms)", "tokens")
+	for _, s := range report.Models {
+		fmt.Printf("%-32s %6d %6d %9.4f %9.4f %9.4f %8d %8d %8d %10d\n",
+			s.Model, s.Cases, s.Errors, s.Accuracy, s.Validity, s.Matching, s.LatencyMs.Avg, s.LatencyMs.P50, s.LatencyMs.P95, s.Usage.TotalTokens)
+	}
+}
+
+// 与之前的报告对比：指标变化与结果发生变化的用例
+func compareReport(path string, report benchReport) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var baseline benchReport
+	if err = json.Unmarshal(data, &baseline); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	fmt.Println("compared with " + path)
+	for _, s := range report.Models {
+		i := slices.IndexFunc(baseline.Models, func(b benchSummary) bool { return b.Model == s.Model })
+		if i < 0 {
+			fmt.Printf("  %s: not in baseline\n", s.Model)
+			continue
+		}
+		b := baseline.Models[i]
+		fmt.Printf("  %s: accuracy %+.4f, validity %+.4f, matching %+.4f, p50 %+dms, tokens %+d\n", s.Model,
+			s.Accuracy-b.Accuracy, s.Validity-b.Validity, s.Matching-b.Matching, s.LatencyMs.P50-b.LatencyMs.P50, s.Usage.TotalTokens-b.Usage.TotalTokens)
+	}
+
+	for _, result := range report.Cases {
+		i := slices.IndexFunc(baseline.Cases, func(b benchResult) bool { return b.Model == result.Model && b.Id == result.Id })
+		if i < 0 {
+			continue
+		}
+		if before, after := passed(baseline.Cases[i]), passed(result); before != after {
+			fmt.Printf("  %s [%s]: %s -> %s\n", result.Model, result.Id, status(before), status(after))
+		}
+	}
+	return nil
+}
+
+func passed(result benchResult) bool {
+	return result.Error == "" && result.Correct && (result.Valid == nil || *result.Valid) && (result.Matched == nil || *result.Matched)
+}
+
+func status(pass bool) string {
+	if pass {
+		return "pass"
+	}
+	return "fail"
+}
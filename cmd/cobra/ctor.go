@@ -2,6 +2,7 @@ package cobra
 
 import (
 	"chatgpt-adapter/core/common/inited"
+	"chatgpt-adapter/core/config"
 	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/logger"
 	"github.com/gin-gonic/gin"
@@ -9,6 +10,7 @@ import (
 	"github.com/iocgo/sdk/cobra"
 	"github.com/iocgo/sdk/env"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
 	"os"
 	"strings"
 )
@@ -21,8 +23,11 @@ type RootCommand struct {
 	Port     int    `cobra:"port" short:"p" usage:"服务端口 port"`
 	LogLevel string `cobra:"log" short:"L" usage:"日志级别: trace|debug|info|warn|error"`
 	LogPath  string `cobra:"log-path" usage:"日志路径 log path"`
+	LogFmt   string `cobra:"log-format" usage:"日志格式: text|json"`
 	Proxied  string `cobra:"proxies" short:"P" usage:"本地代理 proxies"`
 	MView    bool   `cobra:"models" short:"M" usage:"展示模型列表"`
+
+	flags *pflag.FlagSet
 }
 
 // @Cobra(name="cobra"
@@ -48,7 +53,7 @@ func New(container *sdk.Container, engine *gin.Engine, config string) (rc cobra.
 		Port:     8080,
 		LogLevel: "info",
 		LogPath:  "log",
-	}, config)
+	}, config, newValidateCommand(environment), newToolcallCommand(environment), newBenchCommand(environment, engine))
 	return
 }
 
@@ -69,10 +74,17 @@ func (rc *RootCommand) Run(cmd *cobra.Command, args []string) {
 	}
 
 	// init
+	rc.flags = cmd.Flags()
+	config.MustApplyEnv(rc.env)
+	if rc.LogFmt == "" {
+		rc.LogFmt = rc.env.GetString("server.log-format")
+	}
 	logger.InitLogger(
 		rc.LogPath,
 		LogLevel(rc.LogLevel),
+		rc.LogFmt,
 	)
+	config.MustValidate(rc.env)
 	Initialized(rc)
 	inited.Initialized(rc.env)
 
@@ -85,25 +97,36 @@ func (rc *RootCommand) Run(cmd *cobra.Command, args []string) {
 }
 
 func Initialized(rc *RootCommand) {
-	if rc.env.GetInt("server.port") == 0 {
-		rc.env.Set("server.port", rc.Port)
-	}
-	if rc.Proxied != "" {
-		rc.env.Set("server.proxied", rc.Proxied)
-	}
+	// 优先级：命令行参数 > 环境变量 > 配置文件
+	override := func(env *env.Environment) {
+		if rc.changed("port") || env.GetInt("server.port") == 0 {
+			env.Set("server.port", rc.Port)
+		}
+		if rc.Proxied != "" {
+			env.Set("server.proxied", rc.Proxied)
+		}
 
-	if rc.env.GetString("server.password") == "" {
-		for _, item := range os.Environ() {
-			if len(item) > 9 && item[:9] == "PASSWORD=" {
-				rc.env.Set("server.password", item[9:])
-				break
+		if env.GetString("server.password") == "" {
+			for _, item := range os.Environ() {
+				if len(item) > 9 && item[:9] == "PASSWORD=" {
+					env.Set("server.password", item[9:])
+					break
+				}
 			}
 		}
 	}
 
+	override(rc.env)
+	// 热重载时对新配置重新应用启动参数
+	inited.AddOverride(override)
 	initFile(rc.env)
 }
 
+// 命令行是否显式指定了该参数
+func (rc *RootCommand) changed(name string) bool {
+	return rc.flags != nil && rc.flags.Changed(name)
+}
+
 func LogLevel(lv string) logrus.Level {
 	switch lv {
 	case "trace":
@@ -0,0 +1,137 @@
+package cobra
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"chatgpt-adapter/core/common/agent"
+	"chatgpt-adapter/core/common/toolcall"
+	"chatgpt-adapter/core/gin/model"
+	"github.com/iocgo/sdk/cobra"
+	"github.com/iocgo/sdk/env"
+)
+
+type ToolcallListCommand struct {
+	env *env.Environment
+}
+
+type ToolcallPreviewCommand struct {
+	env *env.Environment
+
+	Model string `cobra:"model" short:"m" usage:"按模型选择模版"`
+	Tasks bool   `cobra:"tasks" usage:"渲染任务拆解模版"`
+}
+
+// 工具调用模版: toolcall list | toolcall preview [request.json]
+func newToolcallCommand(environment *env.Environment) cobra.ICobra {
+	list := cobra.ICobraWrapper(&ToolcallListCommand{environment}, `{
+		"Use":   "list",
+		"Short": "列出工具调用模版",
+		"Run":   "Run"
+	}`)
+	preview := cobra.ICobraWrapper(&ToolcallPreviewCommand{env: environment}, `{
+		"Use":   "preview [request.json]",
+		"Short": "以示例请求渲染工具调用模版",
+		"Run":   "Run"
+	}`)
+	return cobra.ICobraWrapper(&struct{}{}, `{
+		"Use":   "toolcall",
+		"Short": "工具调用模版"
+	}`, list, preview)
+}
+
+func (lc *ToolcallListCommand) Run(cmd *cobra.Command, args []string) {
+	sets, err := toolcall.LoadTemplates(lc.env)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	language := lc.env.GetString("toolcall.language")
+	if language == "" {
+		language = "zh"
+	}
+
+	var languages []string
+	for name := range agent.Languages {
+		languages = append(languages, name)
+	}
+	slices.Sort(languages)
+	fmt.Printf("built-in languages: %s (default: %s)\n", strings.Join(languages, ", "), language)
+
+	for i, set := range sets {
+		fmt.Printf("[%d] models: %s, language: %s\n", i, strings.Join(set.Models, ", "), set.Language)
+		for _, it := range [][2]string{
+			{"toolcall", set.ToolCall},
+			{"tasks", set.ToolTasks},
+			{"recommend", set.Recommend},
+			{"executed", set.Executed},
+			{"repair", set.Repair},
+		} {
+			if it[1] != "" {
+				fmt.Printf("    %-9s %s\n", it[0], it[1])
+			}
+		}
+	}
+}
+
+func (pc *ToolcallPreviewCommand) Run(cmd *cobra.Command, args []string) {
+	if _, err := toolcall.LoadTemplates(pc.env); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	completion := sampleCompletion()
+	if len(args) > 0 {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		completion = model.Completion{}
+		if err = json.Unmarshal(data, &completion); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	if pc.Model != "" {
+		completion.Model = pc.Model
+	}
+
+	message, err := toolcall.Preview(completion, pc.Tasks)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Println(message)
+}
+
+// 未指定请求文件时使用的示例
+func sampleCompletion() (completion model.Completion) {
+	_ = json.Unmarshal([]byte(`{
+		"model": "custom/sample",
+		"messages": [
+			{ "role": "system", "content": "You are a helpful assistant." },
+			{ "role": "user", "content": "How is the weather in Hangzhou today?" }
+		],
+		"tools": [{
+			"type": "function",
+			"function": {
+				"name": "get_weather",
+				"description": "Get the current weather of a city",
+				"parameters": {
+					"type": "object",
+					"properties": {
+						"city": { "type": "string", "description": "City name" },
+						"unit": { "type": "string", "description": "Temperature unit", "enum": [ "celsius", "fahrenheit" ] }
+					},
+					"required": [ "city" ]
+				}
+			}
+		}]
+	}`), &completion)
+	return
+}
@@ -0,0 +1,55 @@
+package cobra
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"chatgpt-adapter/core/common/inited"
+	"chatgpt-adapter/core/config"
+	"github.com/iocgo/sdk/cobra"
+	"github.com/iocgo/sdk/env"
+)
+
+type ValidateCommand struct {
+	env *env.Environment
+}
+
+// 校验配置文件: validate [config.yaml]
+func newValidateCommand(environment *env.Environment) cobra.ICobra {
+	return cobra.ICobraWrapper(&ValidateCommand{environment}, `{
+		"Use":   "validate [config.yaml]",
+		"Short": "校验配置文件",
+		"Run":   "Run"
+	}`)
+}
+
+func (vc *ValidateCommand) Run(cmd *cobra.Command, args []string) {
+	path := inited.ConfigPath()
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	result, err := config.ValidateFile(path)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println(path)
+	for _, issue := range result.Errors {
+		fmt.Println("  error   " + issue.String())
+	}
+	for _, issue := range result.Warnings {
+		fmt.Println("  warning " + issue.String())
+	}
+
+	summary := []string{
+		fmt.Sprintf("%d error(s)", len(result.Errors)),
+		fmt.Sprintf("%d warning(s)", len(result.Warnings)),
+	}
+	fmt.Println(strings.Join(summary, ", "))
+	if len(result.Errors) > 0 {
+		os.Exit(1)
+	}
+}
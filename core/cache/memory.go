@@ -2,16 +2,19 @@ package cache
 
 import (
 	"context"
+	"math"
 	"strings"
 	"time"
 
 	"chatgpt-adapter/core/common/inited"
 	"chatgpt-adapter/core/gin/model"
+	"github.com/dgraph-io/ristretto"
 	"github.com/eko/gocache/lib/v4/cache"
 	"github.com/eko/gocache/lib/v4/store"
 	"github.com/iocgo/sdk/env"
 
 	gocacheStore "github.com/eko/gocache/store/go_cache/v4"
+	ristrettoStore "github.com/eko/gocache/store/ristretto/v4"
 	gocache "github.com/patrickmn/go-cache"
 )
 
@@ -62,6 +65,31 @@ func init() {
 	})
 }
 
+// 响应缓存的 memory 后端：以字节数为 cost 限制容量，超出时按访问频率淘汰
+func newMemoryStore(env *env.Environment) (store.StoreInterface, func(), error) {
+	maxEntries, maxBytes := 1000, 64*1024*1024
+	if env.IsSet("response-cache.max-entries") {
+		maxEntries = env.GetInt("response-cache.max-entries")
+	}
+	if env.IsSet("response-cache.max-bytes") {
+		maxBytes = env.GetInt("response-cache.max-bytes")
+	}
+
+	if maxBytes <= 0 {
+		maxBytes = math.MaxInt64
+	}
+
+	client, err := ristretto.NewCache(&ristretto.Config{
+		NumCounters: int64(max(maxEntries, 1)) * 10, // 建议为条数的 10 倍
+		MaxCost:     int64(maxBytes),
+		BufferItems: 64,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return ristrettoStore.NewRistretto(client), client.Close, nil
+}
+
 func ToolTasksCacheManager() *Manager[[]model.Keyv[string]] {
 	return toolTasksCacheManager
 }
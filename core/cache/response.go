@@ -0,0 +1,270 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/common/inited"
+	"chatgpt-adapter/core/gin/model"
+	"chatgpt-adapter/core/logger"
+	"github.com/eko/gocache/lib/v4/cache"
+	"github.com/eko/gocache/lib/v4/store"
+	"github.com/iocgo/sdk/env"
+)
+
+// 完整响应的缓存后端：返回 gocache 的 store，close 在 store 停用后调用，可为 nil。
+// 以 RegisterStore 注册后通过 response-cache.backend 选择
+type StoreFactory func(env *env.Environment) (s store.StoreInterface, close func(), err error)
+
+var stores = map[string]StoreFactory{
+	"memory": newMemoryStore,
+}
+
+// 注册缓存后端，需在 init 中调用
+func RegisterStore(name string, factory StoreFactory) {
+	stores[name] = factory
+}
+
+// 响应缓存
+//
+//	response-cache:
+//	  enabled: true
+//	  backend: memory        # 默认 memory
+//	  ttl: 600               # s，默认 600
+//	  max-entries: 1000      # memory 后端的预计条数
+//	  max-bytes: 67108864    # memory 后端的容量上限，默认 64MB
+//	  max-temperature: 0     # 只缓存 temperature 不高于该值的请求，未设置的按 1 计
+//	  models: [ "custom/*" ] # 为空则全部模型
+type ResponseCache struct {
+	ttl            time.Duration
+	maxTemperature float32
+	models         []string
+	store          *responseStore
+
+	backend string       // 后端及其配置，未变化时沿用当前的 store
+	factory StoreFactory // 配置生效时创建 store
+}
+
+// 后端配置未变化的各版本配置共用同一个 store，被替换后等正在使用的请求结束再关闭
+type responseStore struct {
+	cache *cache.Cache[string]
+	close func()
+
+	mu      sync.Mutex
+	refs    int  // 正在使用的请求数
+	retired bool // 已被新的后端替换
+}
+
+var responses atomic.Pointer[ResponseCache]
+
+func init() {
+	inited.AddInitialized(func(env *env.Environment) {
+		rc, err := loadResponseCache(env)
+		if err == nil {
+			err = rc.open(env, nil)
+		}
+		if err != nil {
+			logger.Fatal(err)
+		}
+		responses.Store(rc)
+	})
+
+	// 校验阶段只解析配置，生效时才创建 store；旧配置等正在执行的请求结束后再释放
+	inited.AddReloaded(func(env *env.Environment) (func(), error) {
+		rc, err := loadResponseCache(env)
+		if err != nil {
+			return nil, err
+		}
+		return func() {
+			current := responses.Load()
+			if err := rc.open(env, current); err != nil {
+				logger.Error(err)
+				return
+			}
+			if old := responses.Swap(rc); old != nil && (rc == nil || rc.store != old.store) {
+				old.store.retire()
+			}
+		}, nil
+	})
+}
+
+func loadResponseCache(env *env.Environment) (*ResponseCache, error) {
+	if !env.GetBool("response-cache.enabled") {
+		return nil, nil
+	}
+
+	backend := env.GetString("response-cache.backend")
+	if backend == "" {
+		backend = "memory"
+	}
+	factory, ok := stores[backend]
+	if !ok {
+		return nil, fmt.Errorf("response-cache.backend: unknown backend '%s'", backend)
+	}
+
+	models := env.GetStringSlice("response-cache.models")
+	for _, pattern := range models {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("response-cache.models: bad pattern '%s'", pattern)
+		}
+	}
+
+	// 与 store 无关的配置不参与比较；GetStringMap 返回的是配置本身，需复制
+	settings := make(map[string]interface{})
+	for key, value := range env.GetStringMap("response-cache") {
+		switch key {
+		case "enabled", "ttl", "max-temperature", "models":
+		default:
+			settings[key] = value
+		}
+	}
+	settings["backend"] = backend
+	fingerprint, err := json.Marshal(settings)
+	if err != nil {
+		return nil, fmt.Errorf("response-cache: %v", err)
+	}
+
+	rc := &ResponseCache{
+		ttl:            600 * time.Second,
+		maxTemperature: float32(env.GetFloat64("response-cache.max-temperature")),
+		models:         models,
+		backend:        string(fingerprint),
+		factory:        factory,
+	}
+	if env.IsSet("response-cache.ttl") {
+		rc.ttl = time.Duration(env.GetInt("response-cache.ttl")) * time.Second
+	}
+	return rc, nil
+}
+
+// 创建 store，后端配置未变化时沿用当前的 store 及其中的缓存
+func (rc *ResponseCache) open(env *env.Environment, current *ResponseCache) error {
+	if rc == nil {
+		return nil
+	}
+	if current != nil && current.backend == rc.backend {
+		rc.store = current.store
+		return nil
+	}
+	s, closeFunc, err := rc.factory(env)
+	if err != nil {
+		return fmt.Errorf("response-cache: %v", err)
+	}
+	rc.store = &responseStore{cache: cache.New[string](s), close: closeFunc}
+	return nil
+}
+
+// 不再分配给新请求，最后一个请求结束后关闭
+func (s *responseStore) retire() {
+	s.mu.Lock()
+	s.retired = true
+	idle := s.refs == 0
+	s.mu.Unlock()
+	if idle && s.close != nil {
+		s.close()
+	}
+}
+
+// 请求结束，与 Responses 成对调用
+func (rc *ResponseCache) Release() {
+	s := rc.store
+	s.mu.Lock()
+	s.refs--
+	idle := s.retired && s.refs == 0
+	s.mu.Unlock()
+	if idle && s.close != nil {
+		s.close()
+	}
+}
+
+// 当前配置并增加 store 的引用，未开启时返回 nil；使用完毕后须调用 Release
+func Responses() *ResponseCache {
+	for {
+		rc := responses.Load()
+		if rc == nil {
+			return nil
+		}
+		s := rc.store
+		s.mu.Lock()
+		if !s.retired {
+			s.refs++
+			s.mu.Unlock()
+			return rc
+		}
+		s.mu.Unlock()
+	}
+}
+
+// 是否缓存该请求；未设置 temperature 时上游按默认值采样，按 OpenAI 的默认值 1 计
+func (rc *ResponseCache) Match(completion model.Completion) bool {
+	if completion.TemperatureOr(1) > rc.maxTemperature {
+		return false
+	}
+	if len(rc.models) == 0 {
+		return true
+	}
+	for _, pattern := range rc.models {
+		if ok, _ := path.Match(pattern, completion.Model); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (rc *ResponseCache) Get(key string) (resp model.Response, ok bool) {
+	timeout, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	data, err := rc.store.cache.Get(timeout, key)
+	if err != nil {
+		if !errors.Is(err, store.NotFound{}) {
+			logger.Error("response cache: ", err)
+		}
+		return resp, false
+	}
+	if data == "" || json.Unmarshal([]byte(data), &resp) != nil {
+		return resp, false
+	}
+	return resp, true
+}
+
+func (rc *ResponseCache) Set(key string, resp model.Response) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+
+	timeout, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err = rc.store.cache.Set(timeout, key, string(data), store.WithExpiration(rc.ttl), store.WithCost(int64(len(data))))
+	if err != nil {
+		logger.Error("response cache: ", err)
+	}
+}
+
+// 请求的规范化哈希：请求凭证、模型、消息、工具与采样参数，不含 stream。
+// 不同凭证可能对应不同的上游账号与权限，缓存互不共享
+func ResponseKey(completion model.Completion, clientKey string) string {
+	// map 序列化时按键排序，相同内容得到相同的结果
+	data, _ := json.Marshal(map[string]interface{}{
+		"key":         clientKey,
+		"model":       completion.Model,
+		"system":      completion.System,
+		"messages":    completion.Messages,
+		"tools":       completion.Tools,
+		"tool_choice": completion.ToolChoice,
+		"max_tokens":  completion.MaxTokens,
+		"stop":        completion.StopSequences,
+		"temperature": completion.Temperature,
+		"top_k":       completion.TopK,
+		"top_p":       completion.TopP,
+	})
+	return common.CalcHex(string(data))
+}
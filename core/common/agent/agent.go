@@ -118,9 +118,9 @@ TOOL_CALL:
 toolId将作为用户调用工具的依据，当需要执行工具时尽量携带此参数。
 
 请你根据工具描述，决定回答问题或是使用工具。在完成任务过程中，USER代表用户的输入，TOOL_RESPONSE代表工具运行结果。ASSISTANT 代表你的输出。
-{{- if eq .toolDef "-1" }}
+{{- if and (eq .toolDef "-1") (not .required) }}
 你的每次输出都必须以0,1开头，代表是否需要调用工具：
-0: 不使用工具。
+0: 不使用工具{{ if .stream }}，紧接着直接回答用户{{ end }}。
 1: 使用工具，返回工具调用的参数。
 {{- else }}
 你的本次输必须以1开头，代表是否需要调用工具：
@@ -130,10 +130,10 @@ toolId将作为用户调用工具的依据，当需要执行工具时尽量携
 例如：
 
 USER: 你好呀 <|end|>
-{{- if eq .toolDef "-1" }}
-ANSWER: 0: <|end|>
+{{- if and (eq .toolDef "-1") (not .required) }}
+ANSWER: 0: {{ if .stream }}你好！有什么可以帮你的吗？ {{ end }}<|end|>
 {{- else }}
-ANSWER: 1: {"toolId":"{{.toolDef}}","arguments":{}} <|end|>
+ANSWER: 1: {"toolId":"{{ if eq .toolDef "-1" }}testToolId{{ else }}{{.toolDef}}{{ end }}","arguments":{}} <|end|>
 {{- end }}
 
 USER: 今天杭州的天气如何 <|end|>
@@ -147,10 +147,10 @@ ANSWER: 1: {"toolId":"testToolId2","arguments":{"query": "杭州 天气 去哪
 TOOL_RESPONSE: """
 晴天. 西湖、灵隐寺、千岛湖……
 """
-{{- if eq .toolDef "-1" }}
-ANSWER: 0: <|end|>
+{{- if and (eq .toolDef "-1") (not .required) }}
+ANSWER: 0: {{ if .stream }}今天杭州是晴天，适合去西湖、灵隐寺或千岛湖游玩。 {{ end }}<|end|>
 {{- else }}
-ANSWER: 1: {"toolId":"{{.toolDef}}","arguments":{}} <|end|>
+ANSWER: 1: {"toolId":"{{ if eq .toolDef "-1" }}testToolId{{ else }}{{.toolDef}}{{ end }}","arguments":{}} <|end|>
 {{- end }}
 
 
@@ -0,0 +1,30 @@
+package agent
+
+// 一种语言的工具调用模版
+type Templates struct {
+	ToolCall  string // 工具选择
+	ToolTasks string // 任务拆解
+	Recommend string // 追加的工具推荐，变量：.task .toolId
+	Executed  string // 已执行任务的说明，变量：.task .toolId
+	Repair    string // 参数不合法时重新询问的完整提示，变量：.prompt 上一次的提示 .content 上一次的输出 .errors
+	Separator string // 多个已执行任务的分隔符
+}
+
+var Languages = map[string]Templates{
+	"zh": {
+		ToolCall:  ToolCall,
+		ToolTasks: ToolTasks,
+		Recommend: "{{.task}}。 工具推荐： toolId = {{.toolId}}",
+		Executed:  "工具[{{.toolId}}]{{.task}}已执行",
+		Repair:    "{{.prompt}} {{.content}} <|end|>\nUSER: 上一次的输出不符合工具定义：{{.errors}}。请修正后重新输出，格式为 1: {\"toolId\":\"...\",\"arguments\":{...}}\nANSWER:",
+		Separator: "，",
+	},
+	"en": {
+		ToolCall:  ToolCallEn,
+		ToolTasks: ToolTasksEn,
+		Recommend: "{{.task}}. Recommended tool: toolId = {{.toolId}}",
+		Executed:  "tool [{{.toolId}}] {{.task}} has been executed",
+		Repair:    "{{.prompt}} {{.content}} <|end|>\nUSER: The previous output does not match the tool definition: {{.errors}}. Please fix it and answer again in the format 1: {\"toolId\":\"...\",\"arguments\":{...}}\nANSWER:",
+		Separator: "; ",
+	},
+}
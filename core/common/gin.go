@@ -4,30 +4,64 @@ import (
 	"chatgpt-adapter/core/common/vars"
 	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
-	"github.com/gin-gonic/gin"
 )
 
-func GetGinCompletion(ctx *gin.Context) (value model.Completion) {
+func GetGinCompletion(ctx *inter.Context) (value model.Completion) {
 	value, _ = GetGinValue[model.Completion](ctx, vars.GinCompletion)
 	return
 }
 
-func GetGinEmbedding(ctx *gin.Context) (value model.Embed) {
+func SetGinCompletion(ctx *inter.Context, completion model.Completion) {
+	ctx.Set(vars.GinCompletion, completion)
+}
+
+// 客户端携带的凭证（Authorization / X-Api-Key），账号池会替换为实际使用的账号
+func GetGinToken(ctx *inter.Context) string {
+	return ctx.GetString(vars.GinToken)
+}
+
+func SetGinToken(ctx *inter.Context, token string) {
+	ctx.Set(vars.GinToken, token)
+}
+
+// 请求消息的 token 数，用于计算 usage
+func GetGinTokens(ctx *inter.Context) int {
+	return ctx.GetInt(vars.GinTokens)
+}
+
+func SetGinTokens(ctx *inter.Context, tokens int) {
+	ctx.Set(vars.GinTokens, tokens)
+}
+
+// 请求使用了已废弃的 functions 接口，响应以 function_call 返回
+func IsGinLegacyFunctions(ctx *inter.Context) bool {
+	return ctx.GetBool(vars.GinLegacyFunctions)
+}
+
+func SetGinLegacyFunctions(ctx *inter.Context, legacy bool) {
+	ctx.Set(vars.GinLegacyFunctions, legacy)
+}
+
+func GetGinEmbedding(ctx *inter.Context) (value model.Embed) {
 	value, _ = GetGinValue[model.Embed](ctx, vars.GinEmbedding)
 	return
 }
 
-func GetGinGeneration(ctx *gin.Context) (value model.Generation) {
+func GetGinGeneration(ctx *inter.Context) (value model.Generation) {
 	value, _ = GetGinValue[model.Generation](ctx, vars.GinGeneration)
 	return
 }
 
-func GetGinMatchers(ctx *gin.Context) (values []inter.Matcher) {
+func GetGinMatchers(ctx *inter.Context) (values []inter.Matcher) {
 	values, _ = GetGinValues[inter.Matcher](ctx, vars.GinMatchers)
 	return
 }
 
-func GetGinCompletionUsage(ctx *gin.Context) map[string]interface{} {
+func SetGinMatchers(ctx *inter.Context, matchers []inter.Matcher) {
+	ctx.Set(vars.GinMatchers, matchers)
+}
+
+func GetGinCompletionUsage(ctx *inter.Context) map[string]interface{} {
 	obj, exists := ctx.Get(vars.GinCompletionUsage)
 	if exists {
 		return obj.(map[string]interface{})
@@ -35,7 +69,7 @@ func GetGinCompletionUsage(ctx *gin.Context) map[string]interface{} {
 	return nil
 }
 
-func GetGinToolValue(ctx *gin.Context) model.Keyv[interface{}] {
+func GetGinToolValue(ctx *inter.Context) model.Keyv[interface{}] {
 	tool, ok := GetGinValue[model.Keyv[interface{}]](ctx, vars.GinTool)
 	if !ok {
 		tool = model.Keyv[interface{}]{
@@ -47,11 +81,11 @@ func GetGinToolValue(ctx *gin.Context) model.Keyv[interface{}] {
 	return tool
 }
 
-func IsGinCozeWebsdk(ctx *gin.Context) bool {
+func IsGinCozeWebsdk(ctx *inter.Context) bool {
 	return ctx.GetBool(vars.GinCozeWebsdk)
 }
 
-func GetGinValue[T any](ctx *gin.Context, key string) (t T, ok bool) {
+func GetGinValue[T any](ctx *inter.Context, key string) (t T, ok bool) {
 	value, exists := ctx.Get(key)
 	if !exists {
 		return
@@ -61,7 +95,7 @@ func GetGinValue[T any](ctx *gin.Context, key string) (t T, ok bool) {
 	return
 }
 
-func GetGinValues[T any](ctx *gin.Context, key string) ([]T, bool) {
+func GetGinValues[T any](ctx *inter.Context, key string) ([]T, bool) {
 	value, exists := ctx.Get(key)
 	if !exists {
 		return nil, false
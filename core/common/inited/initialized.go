@@ -15,9 +15,11 @@ var (
 func AddInitialized(apply func(env *env.Environment)) { inits = append(inits, apply) }
 func AddExited(apply func(env *env.Environment))      { exits = append(exits, apply) }
 func Initialized(env *env.Environment) {
+	current.Store(env)
 	for _, apply := range inits {
 		apply(env)
 	}
+	watch(env)
 
 	osSignal := make(chan os.Signal, 1)
 	signal.Notify(osSignal, syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL)
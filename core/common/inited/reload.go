@@ -0,0 +1,146 @@
+package inited
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/iocgo/sdk/env"
+	"github.com/spf13/viper"
+)
+
+var (
+	overrides = make([]func(env *env.Environment), 0)
+	reloads   = make([]func(env *env.Environment) (commit func(), err error), 0)
+
+	rmu sync.Mutex
+
+	// 当前生效的配置，重载时整体替换
+	current atomic.Pointer[env.Environment]
+	// 配置文件路径，启动时确定
+	configPath = resolveConfigPath(os.Args, os.Environ())
+)
+
+// 当前生效的配置。重载会替换为新的 Environment 而不修改旧值，
+// 请求期间读取配置应通过 Env() 而非持有启动时注入的 Environment
+func Env() *env.Environment {
+	if environment := current.Load(); environment != nil {
+		return environment
+	}
+	return env.Env
+}
+
+// 启动参数等运行时覆盖项，重载时对新配置重新应用
+func AddOverride(apply func(env *env.Environment)) { overrides = append(overrides, apply) }
+
+// 两阶段重载：apply 只做解析校验，返回的 commit 在全部校验通过后才执行
+//
+//	inited.AddReloaded(func(env *env.Environment) (func(), error) {
+//		value, err := parse(env)
+//		if err != nil {
+//			return nil, err
+//		}
+//		return func() { current.Store(value) }, nil
+//	})
+func AddReloaded(apply func(env *env.Environment) (commit func(), err error)) {
+	reloads = append(reloads, apply)
+}
+
+// 重新读取配置文件；任一阶段失败都不会应用任何变更
+func Reload() (err error) {
+	rmu.Lock()
+	defer rmu.Unlock()
+
+	environment := Env()
+	path := ConfigPath()
+	config, err := ReadConfig(path)
+	if err != nil {
+		return fmt.Errorf("read config `%s` failed: %v", path, err)
+	}
+
+	vip := viper.New()
+	vip.SetConfigType("yaml")
+	if err = vip.ReadConfig(bytes.NewReader(config)); err != nil {
+		return fmt.Errorf("parse config `%s` failed: %v", path, err)
+	}
+
+	next := &env.Environment{
+		Viper: vip,
+		Args:  environment.Args,
+		Env:   environment.Env,
+	}
+	for _, apply := range overrides {
+		apply(next)
+	}
+
+	var errs []error
+	commits := make([]func(), 0, len(reloads))
+	for _, apply := range reloads {
+		commit, e := apply(next)
+		if e != nil {
+			errs = append(errs, e)
+			continue
+		}
+		if commit != nil {
+			commits = append(commits, commit)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	current.Store(next)
+	for _, commit := range commits {
+		commit()
+	}
+	return
+}
+
+// 配置文件路径
+func ConfigPath() string {
+	return configPath
+}
+
+// 与 env.New 的取值规则一致：最后一个参数以 .yaml 结尾时取该参数，其次取环境变量 CONFIG_PATH
+func resolveConfigPath(args, environ []string) string {
+	if n := len(args); n > 0 && strings.HasSuffix(args[n-1], ".yaml") {
+		return args[n-1]
+	}
+	for _, item := range environ {
+		if strings.HasPrefix(item, "CONFIG_PATH=") && len(item) > 12 {
+			return item[12:]
+		}
+	}
+	return "config.yaml"
+}
+
+// 读取配置内容，支持 http(s) 远程地址
+func ReadConfig(path string) (config []byte, err error) {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		var response *http.Response
+		client := &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					InsecureSkipVerify: true,
+				},
+			},
+		}
+		response, err = client.Get(path)
+		if err != nil {
+			return
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return nil, errors.New(response.Status)
+		}
+		return io.ReadAll(response.Body)
+	}
+	return os.ReadFile(path)
+}
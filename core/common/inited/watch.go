@@ -0,0 +1,96 @@
+package inited
+
+import (
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"chatgpt-adapter/core/logger"
+	"github.com/fsnotify/fsnotify"
+	"github.com/iocgo/sdk/env"
+)
+
+// 监听配置文件变更与 SIGHUP 信号，触发重载
+//
+//	server:
+//	  hot-reload: false # 关闭文件监听，默认开启
+func watch(environment *env.Environment) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			logger.Info("received SIGHUP, reloading config")
+			reload()
+		}
+	}()
+
+	if environment.IsSet("server.hot-reload") && !environment.GetBool("server.hot-reload") {
+		return
+	}
+
+	path := ConfigPath()
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return
+	}
+
+	path, err := filepath.Abs(path)
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	// 监听目录：编辑器保存时通常是重命名替换，直接监听文件会丢失事件
+	if err = watcher.Add(filepath.Dir(path)); err != nil {
+		logger.Error(err)
+		_ = watcher.Close()
+		return
+	}
+
+	go func() {
+		defer watcher.Close()
+		var debounce *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != path {
+					continue
+				}
+				if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) {
+					continue
+				}
+				if debounce != nil {
+					debounce.Stop()
+				}
+				debounce = time.AfterFunc(500*time.Millisecond, func() {
+					logger.Infof("config file changed: %s", path)
+					reload()
+				})
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Error(err)
+			}
+		}
+	}()
+}
+
+func reload() {
+	if err := Reload(); err != nil {
+		logger.Errorf("reload config failed, keep the current config: %v", err)
+		return
+	}
+	logger.Info("reload config success")
+}
@@ -9,7 +9,9 @@ import (
 	"time"
 
 	"chatgpt-adapter/core/logger"
+	"chatgpt-adapter/core/tracer"
 	"github.com/iocgo/sdk/lock"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -96,12 +98,28 @@ func timer[T interface{}](container *PollContainer[T], resetTime time.Duration)
 	}
 }
 
-func (container *PollContainer[T]) Poll(argv ...interface{}) (T, error) {
+func (container *PollContainer[T]) Poll(argv ...interface{}) (value T, err error) {
 	var zero T
 	if container == nil || len(container.slice) == 0 {
 		return zero, errors.New("no elements in slice")
 	}
 
+	var parent context.Context
+	for _, arg := range argv {
+		if ctx, ok := arg.(context.Context); ok {
+			parent = ctx
+			break
+		}
+	}
+
+	_, span := tracer.Start(parent, "pool.poll", attribute.String("pool", container.name))
+	defer func() {
+		if err == nil {
+			span.SetAttributes(attribute.String("pool.entry", container.Id(value)))
+		}
+		tracer.End(span, err)
+	}()
+
 	if container.Condition == nil {
 		return zero, errors.New("condition is nil")
 	}
@@ -127,10 +145,10 @@ func (container *PollContainer[T]) Poll(argv ...interface{}) (T, error) {
 			curr = curr - sliceL
 		}
 
-		value := container.slice[curr]
+		value = container.slice[curr]
 		if container.Condition(value, argv...) {
 			container.pos = curr + 1
-			err := container.MarkTo(value, 1)
+			err = container.MarkTo(value, 1)
 			if err != nil {
 				return zero, err
 			}
@@ -163,6 +181,46 @@ func (container *PollContainer[T]) Remove(value T) (err error) {
 	return
 }
 
+// 替换全部元素，保留未变更元素的标记状态（冷却中的仍在冷却）
+func (container *PollContainer[T]) Reset(slice []T) (err error) {
+	timeout, cancel := context.WithTimeout(context.Background(), waitTimeout)
+	defer cancel()
+
+	if !container.cmu.Lock(timeout) {
+		return errors.New("lock timeout")
+	}
+	defer container.cmu.Unlock()
+
+	if !container.mu.Lock(timeout) {
+		return errors.New("lock timeout")
+	}
+	defer container.mu.Unlock()
+
+	keys := make(map[interface{}]bool)
+	for _, value := range slice {
+		var obj interface{} = value
+		if s, ok := obj.(string); ok {
+			keys[s] = true
+		} else {
+			data, _ := json.Marshal(obj)
+			keys[string(data)] = true
+		}
+	}
+
+	for key := range container.markers {
+		if !keys[key] {
+			delete(container.markers, key)
+		}
+	}
+
+	container.slice = slice
+	if container.pos >= len(slice) {
+		container.pos = 0
+	}
+	logger.Infof("[%s] PollContainer 重置元素: %d", container.name, len(slice))
+	return
+}
+
 func (container *PollContainer[T]) Add(value T) {
 	container.slice = append(container.slice, value)
 }
@@ -219,6 +277,22 @@ func (container *PollContainer[T]) Marked(key interface{}) (byte, error) {
 	}
 }
 
+// 元素的短标识，用于日志等展示，避免暴露原始值
+func (container *PollContainer[T]) Id(value T) string {
+	var obj interface{} = value
+	str, ok := obj.(string)
+	if !ok {
+		data, _ := json.Marshal(obj)
+		str = string(data)
+	}
+
+	hash := CalcHex(str)
+	if len(hash) > 8 {
+		hash = hash[:8]
+	}
+	return container.name + "-" + hash
+}
+
 func (container *PollContainer[T]) Len() int {
 	return len(container.slice)
 }
@@ -0,0 +1,57 @@
+package toolcall
+
+import (
+	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/gin/inter"
+	"chatgpt-adapter/core/gin/model"
+)
+
+const (
+	ChoiceAuto     = "auto"
+	ChoiceNone     = "none"
+	ChoiceRequired = "required"
+	ChoiceFunction = "function"
+)
+
+var forced_tool = "__forced-tool__"
+
+// 解析 tool_choice：auto（默认）、none、required，或 {"type": "function", "function": {"name": ...}} 指定的工具
+func ChoiceOf(completion model.Completion) (mode, name string) {
+	switch value := completion.ToolChoice.(type) {
+	case string:
+		switch value {
+		case ChoiceNone, ChoiceRequired:
+			return value, ""
+		}
+	case map[string]interface{}:
+		name = model.Keyv[interface{}](value).GetKeyv("function").GetString("name")
+		if name != "" {
+			return ChoiceFunction, name
+		}
+	}
+	return ChoiceAuto, ""
+}
+
+// 是否必须调用工具
+func isRequired(completion model.Completion) bool {
+	mode, _ := ChoiceOf(completion)
+	return mode == ChoiceRequired || mode == ChoiceFunction
+}
+
+// 默认调用的工具名：tool_choice 指定的工具优先，"-1" 为无
+func defaultTool(ctx *inter.Context, tools []model.Keyv[interface{}]) string {
+	if name := ctx.GetString(forced_tool); name != "" {
+		return name
+	}
+	return Query(common.GetGinToolValue(ctx).GetString("id"), tools)
+}
+
+// 仅保留指定的工具
+func onlyTool(tools []model.Keyv[interface{}], name string) []model.Keyv[interface{}] {
+	for _, t := range tools {
+		if t.GetKeyv("function").GetString("name") == name {
+			return []model.Keyv[interface{}]{t}
+		}
+	}
+	return nil
+}
@@ -1,23 +1,24 @@
 package toolcall
 
 import (
+	"chatgpt-adapter/core/gin/inter"
 	"encoding/json"
 	"fmt"
 	"regexp"
 	"slices"
 	"strconv"
 	"strings"
+	"text/template"
 	"time"
 
 	"chatgpt-adapter/core/cache"
 	"chatgpt-adapter/core/common"
-	"chatgpt-adapter/core/common/agent"
 	"chatgpt-adapter/core/common/vars"
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
+	"chatgpt-adapter/core/tracer"
 	"github.com/dlclark/regexp2"
-	"github.com/gin-gonic/gin"
 )
 
 var (
@@ -26,7 +27,30 @@ var (
 	MaxMessages           = 20
 )
 
-func NeedExec(ctx *gin.Context) bool {
+// 每次工具选择的上游调用单独记录 span
+func traceCallback(ctx *inter.Context, callback func(message string) (string, error)) func(message string) (string, error) {
+	return func(message string) (result string, err error) {
+		_, end := tracer.Span(ctx, "toolcall.callback")
+		defer func() { end(err) }()
+		return callback(message)
+	}
+}
+
+func NeedExec(ctx *inter.Context) bool {
+	completion := common.GetGinCompletion(ctx)
+	messageL := len(completion.Messages)
+	if messageL == 0 || len(completion.Tools) == 0 {
+		return false
+	}
+
+	// none 不调用工具；required、指定工具时必须调用
+	switch mode, _ := ChoiceOf(completion); mode {
+	case ChoiceNone:
+		return false
+	case ChoiceRequired, ChoiceFunction:
+		return true
+	}
+
 	var tool = "-1"
 	{
 		t := common.GetGinToolValue(ctx)
@@ -40,48 +64,16 @@ func NeedExec(ctx *gin.Context) bool {
 		}
 	}
 
-	completion := common.GetGinCompletion(ctx)
-	messageL := len(completion.Messages)
-	if messageL == 0 {
-		return false
-	}
-
-	if len(completion.Tools) == 0 {
-		return false
-	}
-
 	role := completion.Messages[messageL-1]["role"]
 	return (role != "function" && role != "tool") || tool != "-1"
 }
 
 func Cancel(str string) bool {
 	str = strings.TrimSpace(str)
-	if strings.Contains(str, "<|tool|>") {
-		return true
-	}
-	if strings.Contains(str, "<|assistant|>") {
-		return true
-	}
-	if strings.Contains(str, "<|user|>") {
-		return true
-	}
-	if strings.Contains(str, "<|system|>") {
-		return true
-	}
-	if strings.Contains(str, "<|tool_response|>") {
-		return true
-	}
-	if strings.Contains(str, "<|end|>") {
-		return true
-	}
-	if strings.Contains(str, "USER: ") {
-		return true
-	}
-	if strings.Contains(str, "ANSWER: ") {
-		return true
-	}
-	if strings.Contains(str, "TOOL_RESPONSE: ") {
-		return true
+	for _, stop := range stops {
+		if strings.Contains(str, stop) {
+			return true
+		}
 	}
 	// return len(str) > 1 && !strings.HasPrefix(str, "1:")
 	return false
@@ -120,10 +112,11 @@ func Query(key string, tools []model.Keyv[interface{}]) (value string) {
 //	return:
 //	bool  > 是否执行了工具
 //	error > 执行异常
-func ToolChoice(ctx *gin.Context, completion model.Completion, callback func(message string) (string, error)) (bool, error) {
+func ToolChoice(ctx *inter.Context, completion model.Completion, callback func(message string) (string, error)) (bool, error) {
 	cacheManager := cache.ToolTasksCacheManager()
 	ctx.Set(exclude_task_contents, "")
-	defer logger.Info("completeToolCalls called")
+	defer logger.Ctx(ctx).Info("completeToolCalls called")
+	callback = traceCallback(ctx, callback)
 
 	// 是否开启任务拆解
 	if tasksIsEnabled(ctx) {
@@ -131,7 +124,7 @@ func ToolChoice(ctx *gin.Context, completion model.Completion, callback func(mes
 		toolCache := hex(completion)
 		if completion.Messages, hasTasks = taskComplete(ctx, completion, callback); !hasTasks {
 			// 非-1值则为有默认选项
-			valueDef := Query(common.GetGinToolValue(ctx).GetString("id"), completion.Tools)
+			valueDef := defaultTool(ctx, completion.Tools)
 			if valueDef != "-1" {
 				return toolCallResponse(ctx, completion, valueDef, "{}", time.Now().Unix()), nil
 			}
@@ -141,7 +134,7 @@ func ToolChoice(ctx *gin.Context, completion model.Completion, callback func(mes
 		// 无参数task跳过提示词收集
 		tasks, err := cacheManager.GetValue(toolCache)
 		if err != nil {
-			logger.Error(err)
+			logger.Ctx(ctx).Error(err)
 		}
 
 		for _, task := range tasks {
@@ -151,7 +144,7 @@ func ToolChoice(ctx *gin.Context, completion model.Completion, callback func(mes
 					value := "{}"
 					if q != "" { // 提供特殊字段
 						value = q
-						logger.Infof("$query: %s", value)
+						logger.Ctx(ctx).Infof("$query: %s", value)
 					}
 					return toolCallResponse(ctx, completion, name, value, time.Now().Unix()), nil
 				}
@@ -161,79 +154,120 @@ func ToolChoice(ctx *gin.Context, completion model.Completion, callback func(mes
 		}
 	}
 
-	// toolChoice自推荐toolId处理
-	if completion.ToolChoice != "" && completion.ToolChoice != "auto" {
-		var (
-			keyv       model.Keyv[interface{}]
-			toolChoice model.Keyv[interface{}]
-			ok         = false
-		)
-		toolChoice, ok = completion.ToolChoice.(map[string]interface{})
-		if !ok || !toolChoice.Is("type", "function") {
-			goto label
-		}
-
-		keyv = toolChoice.GetKeyv("function")
-		if !keyv.Has("name") {
-			goto label
+	// 指定工具时只提供该工具，仅生成参数
+	if mode, name := ChoiceOf(completion); mode == ChoiceFunction {
+		value := Query(name, completion.Tools)
+		if value == "-1" {
+			return false, fmt.Errorf("tool_choice function `%s` is not found in tools", name)
 		}
-
-		if toolId := toolIdWithTools(keyv.GetString("name"), completion.Tools); toolId != "-1" {
-			completion.Messages = append(completion.Messages, model.Keyv[interface{}]{
-				"role": "user", "content": "continue。 工具推荐： toolId = " + toolId,
-			})
-		}
-	label:
+		completion.Tools = onlyTool(completion.Tools, value)
+		ctx.Set(forced_tool, value)
 	}
 
-	message, err := buildTemplate(ctx, completion, agent.ToolCall)
+	message, err := buildTemplate(ctx, completion, templatesOf(completion.Model).ToolCall)
 	if err != nil {
 		return false, err
 	}
 
-	content, err := callback(message)
-	if err != nil {
-		return false, err
+	var (
+		prompt         = message
+		promptTokens   = 0
+		completeTokens = 0
+		retries        = retriesOf()
+		result         decision
+	)
+
+	// 流式决策只用于首次询问
+	var streaming *decider
+	if streamable(ctx, completion) {
+		streaming = newDecider(ctx, completion)
+		ctx.Set(streaming_decider, streaming)
 	}
 
-	previousTokens := response.CalcTokens(message)
-	ctx.Set(vars.GinCompletionUsage, response.CalcUsageTokens(content, previousTokens))
+	// 参数不符合工具定义时，携带校验结果重新询问
+	for attempt := 0; ; attempt++ {
+		content, err := callback(prompt)
+		ctx.Set(streaming_decider, (*decider)(nil))
+		if err != nil {
+			return false, err
+		}
+
+		promptTokens += response.CalcTokens(prompt)
+		completeTokens += response.CalcTokens(content)
+		ctx.Set(vars.GinCompletionUsage, map[string]interface{}{
+			"completion_tokens": completeTokens,
+			"prompt_tokens":     promptTokens,
+			"total_tokens":      promptTokens + completeTokens,
+		})
+		if attempt == 0 && streaming != nil && streaming.mode == answering {
+			if streaming.finish(content) {
+				logger.Ctx(ctx).Debugf("completeTools decision: tool=<none>, reason=streamed answer")
+				return true, nil
+			}
+		}
 
-	// 解析参数
-	return parseToTC(ctx, content, completion), nil
+		result = parseToTC(ctx, content, completion)
+		if len(result.problems) == 0 || attempt >= retries {
+			break
+		}
+
+		logger.Ctx(ctx).Infof("completeTools invalid response, retry %d/%d: %s", attempt+1, retries, strings.Join(result.problems, "; "))
+		prompt = phrase(templatesOf(completion.Model).Repair,
+			"prompt", prompt,
+			"content", strings.TrimSpace(content),
+			"errors", strings.Join(result.problems, "; "))
+	}
+
+	// 重试后仍不合法：有默认工具时使用默认工具，否则不调用
+	if len(result.problems) > 0 {
+		valueDef := defaultTool(ctx, completion.Tools)
+		result = decision{reason: "invalid response: " + strings.Join(result.problems, "; ")}
+		if valueDef != "-1" {
+			result = decision{name: valueDef, args: "{}", reason: "default tool, " + result.reason}
+		} else if isRequired(completion) {
+			return false, fmt.Errorf("tool_choice requires a tool call, %s", result.reason)
+		}
+	}
+
+	logger.Ctx(ctx).Debugf("completeTools decision: tool=%s, arguments=%s, reason=%s", elseOf(result.name == "", "<none>", result.name), result.args, result.reason)
+	if result.name == "" {
+		return false, nil
+	}
+	return toolCallResponse(ctx, completion, result.name, result.args, time.Now().Unix()), nil
 }
 
 // 拆解任务, 组装任务提示并返回上下文 (包含缓存已执行的任务逻辑)
-func taskComplete(ctx *gin.Context, completion model.Completion, callback func(message string) (string, error)) (messages []model.Keyv[interface{}], hasTasks bool) {
+func taskComplete(ctx *inter.Context, completion model.Completion, callback func(message string) (string, error)) (messages []model.Keyv[interface{}], hasTasks bool) {
 	cacheManager := cache.ToolTasksCacheManager()
 	messages = completion.Messages
-	message, err := buildTemplate(ctx, completion, agent.ToolTasks)
+	templates := templatesOf(completion.Model)
+	message, err := buildTemplate(ctx, completion, templates.ToolTasks)
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return
 	}
 
 	toolCache := hex(completion)
-	logger.Infof("completeTasks calc hash - %s", toolCache)
+	logger.Ctx(ctx).Infof("completeTasks calc hash - %s", toolCache)
 	tasks, err := cacheManager.GetValue(toolCache)
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 	}
 
 	if tasks != nil {
 		excludeTasks(completion, tasks)
-		logger.Infof("completeTasks response: <cached> %s", tasks)
+		logger.Ctx(ctx).Infof("completeTasks response: <cached> %s", tasks)
 		// 刷新缓存时间
 		if err = cacheManager.SetValue(toolCache, tasks); err != nil {
-			logger.Error(err)
+			logger.Ctx(ctx).Error(err)
 		}
 	} else {
 		content, e := callback(message)
 		if e != nil {
-			logger.Error(e)
+			logger.Ctx(ctx).Error(e)
 			return
 		}
-		logger.Infof("completeTasks response: \n%s", content)
+		logger.Ctx(ctx).Infof("completeTasks response: \n%s", content)
 
 		// 解析参数
 		tasks = parseToTT(content, completion)
@@ -244,7 +278,7 @@ func taskComplete(ctx *gin.Context, completion model.Completion, callback func(m
 		excludeTasks(completion, tasks)
 		// 刷新缓存时间
 		if err = cacheManager.SetValue(toolCache, tasks); err != nil {
-			logger.Error(err)
+			logger.Ctx(ctx).Error(err)
 		}
 	}
 
@@ -255,9 +289,9 @@ func taskComplete(ctx *gin.Context, completion model.Completion, callback func(m
 		task := tasks[pos]
 		toolId := task.GetString("toolId")
 		if task.Is("exclude", "true") {
-			excTasks = append(excTasks, fmt.Sprintf("工具[%s]%s已执行", toolIdWithTools(toolId, completion.Tools), task.GetString("task")))
+			excTasks = append(excTasks, phrase(templates.Executed, "task", task.GetString("task"), "toolId", toolIdWithTools(toolId, completion.Tools)))
 		} else {
-			contents = append(contents, task.GetString("task")+"。 工具推荐： toolId = "+toolIdWithTools(toolId, completion.Tools))
+			contents = append(contents, phrase(templates.Recommend, "task", task.GetString("task"), "toolId", toolIdWithTools(toolId, completion.Tools)))
 		}
 	}
 
@@ -266,9 +300,9 @@ func taskComplete(ctx *gin.Context, completion model.Completion, callback func(m
 	}
 
 	hasTasks = true
-	logger.Infof("completeTasks excludeTasks: %s", excTasks)
-	logger.Infof("completeTasks nextTask: %s", contents[0])
-	ctx.Set(exclude_task_contents, strings.Join(excTasks, "，"))
+	logger.Ctx(ctx).Infof("completeTasks excludeTasks: %s", excTasks)
+	logger.Ctx(ctx).Infof("completeTasks nextTask: %s", contents[0])
+	ctx.Set(exclude_task_contents, strings.Join(excTasks, templates.Separator))
 
 	// 拼接任务信息
 	for pos := len(messages) - 1; pos > 0; pos-- {
@@ -321,7 +355,7 @@ func hex(completion model.Completion) (hash string) {
 	return common.CalcHex(mod + hash)
 }
 
-func buildTemplate(ctx *gin.Context, completion model.Completion, template string) (message string, err error) {
+func buildTemplate(ctx *inter.Context, completion model.Completion, template string) (message string, err error) {
 	pMessages := completion.Messages
 	messageL := len(pMessages)
 	content := "continue"
@@ -366,14 +400,41 @@ func buildTemplate(ctx *gin.Context, completion model.Completion, template strin
 	value, _ := ctx.Get(exclude_task_contents)
 	str, err := newBuilder("tool").
 		Vars("toolDef", getToolId(ctx, completion.Tools)).
+		Vars("required", isRequired(completion)).
+		Vars("stream", streamable(ctx, completion)).
 		Vars("tools", completion.Tools).
 		Vars("pMessages", pMessages).
 		Vars("excludeTaskContents", value).
 		Vars("content", content).
-		Func("ToolId", func(str string) string {
+		Funcs(templateFuncs(completion)).
+		String(template)
+	if err != nil {
+		return
+	}
+
+	regMap := map[*regexp.Regexp]string{
+		regexp.MustCompile(`<\|system\|>[\n|\s]+<\|end\|>`):           "",
+		regexp.MustCompile(`<\|user\|>[\n|\s]+<\|end\|>`):             "",
+		regexp.MustCompile(`<\|assistant\|>[\n|\s]+<\|end\|>`):        "",
+		regexp.MustCompile(`<\|<no value>\|>\n<no value>\n<\|end\|>`): "",
+		regexp.MustCompile(`\n{3}`):                                   "\n",
+		regexp.MustCompile(`\n{2,}<\|end\|>`):                         "\n<|end|>",
+	}
+	for reg, v := range regMap {
+		str = reg.ReplaceAllString(str, v)
+	}
+
+	message = strings.TrimSpace(str)
+	return
+}
+
+// 模版可用的函数
+func templateFuncs(completion model.Completion) template.FuncMap {
+	return template.FuncMap{
+		"ToolId": func(str string) string {
 			return toolIdWithTools(str, completion.Tools)
-		}).
-		Func("Join", func(slice []interface{}, sep string) string {
+		},
+		"Join": func(slice []interface{}, sep string) string {
 			if len(slice) == 0 {
 				return ""
 			}
@@ -382,18 +443,18 @@ func buildTemplate(ctx *gin.Context, completion model.Completion, template strin
 				result = append(result, fmt.Sprintf("\"%v\"", v))
 			}
 			return strings.Join(result, sep)
-		}).
-		Func("Has", func(obj map[string]interface{}, key string) bool {
+		},
+		"Has": func(obj map[string]interface{}, key string) bool {
 			_, exists := obj[key]
 			return exists
-		}).
-		Func("Len", func(slice []interface{}) int {
+		},
+		"Len": func(slice []interface{}) int {
 			return len(slice)
-		}).
-		Func("Enc", func(value interface{}) string {
+		},
+		"Enc": func(value interface{}) string {
 			return strings.ReplaceAll(fmt.Sprintf("%s", value), "\n", "\\n")
-		}).
-		Func("ToolDesc", func(value string) string {
+		},
+		"ToolDesc": func(value string) string {
 			for _, t := range completion.Tools {
 				fn := t.GetKeyv("function")
 				if !fn.Has("name") {
@@ -404,118 +465,124 @@ func buildTemplate(ctx *gin.Context, completion model.Completion, template strin
 				}
 			}
 			return ""
-		}).String(template)
-	if err != nil {
-		return
+		},
 	}
-
-	regMap := map[*regexp.Regexp]string{
-		regexp.MustCompile(`<\|system\|>[\n|\s]+<\|end\|>`):           "",
-		regexp.MustCompile(`<\|user\|>[\n|\s]+<\|end\|>`):             "",
-		regexp.MustCompile(`<\|assistant\|>[\n|\s]+<\|end\|>`):        "",
-		regexp.MustCompile(`<\|<no value>\|>\n<no value>\n<\|end\|>`): "",
-		regexp.MustCompile(`\n{3}`):                                   "\n",
-		regexp.MustCompile(`\n{2,}<\|end\|>`):                         "\n<|end|>",
-	}
-	for reg, v := range regMap {
-		str = reg.ReplaceAllString(str, v)
-	}
-
-	message = strings.TrimSpace(str)
-	return
 }
 
-// 工具参数解析
-//
-//	return:
-//	bool  > 是否执行了工具
-func parseToTC(ctx *gin.Context, content string, completion model.Completion) bool {
-	j := ""
-	created := time.Now().Unix()
-	slice := strings.Split(content, "TOOL_RESPONSE")
-
-	for _, value := range slice {
-		left := strings.Index(value, "{")
-		right := strings.LastIndex(value, "}")
-		if left >= 0 && right > left {
-			j = value[left : right+1]
-			break
-		}
-	}
+// 工具选择的解析结果
+type decision struct {
+	name     string   // 调用的工具，为空则不调用
+	args     string   // 调用参数
+	reason   string   // 判断依据
+	problems []string // 不符合工具定义的描述，非空时可重新询问
+}
 
+// 工具参数解析，toolId 须与工具的 id 或 name 一致，参数按 parameters 校验
+func parseToTC(ctx *inter.Context, content string, completion model.Completion) (result decision) {
 	// 非-1值则为有默认选项
-	valueDef := Query(common.GetGinToolValue(ctx).GetString("id"), completion.Tools)
-
-	// 没有解析出 JSON
-	if j == "" {
+	var (
+		valueDef = defaultTool(ctx, completion.Tools)
+		forced   = ctx.GetString(forced_tool)
+		required = isRequired(completion)
+	)
+	fallback := func(reason string) decision {
 		if valueDef != "-1" {
-			return toolCallResponse(ctx, completion, valueDef, "{}", created)
+			return decision{name: valueDef, args: "{}", reason: "default tool, " + reason}
 		}
-		logger.Infof("completeTools response failed: \n%s", content)
-		return false
+		if required {
+			return decision{problems: []string{"a tool call is required, answer with 1: {\"toolId\": ..., \"arguments\": {...}}"}}
+		}
+		return decision{reason: reason}
 	}
 
-	var fn model.Keyv[interface{}]
-	name := ""
-	for _, t := range completion.Tools {
-		fn = t.GetKeyv("function")
-		n := fn.GetString("name")
-		// id 匹配
-		if strings.Contains(j, fn.GetString("id")) {
-			name = n
+	// 模型可能自行续写工具结果，只解析之前的内容
+	if pos := strings.Index(content, "TOOL_RESPONSE"); pos > 0 {
+		content = content[:pos]
+	}
+
+	var (
+		js      map[string]interface{}
+		objects = extractObjects(content)
+	)
+	for _, obj := range objects {
+		if _, ok := obj["toolId"]; ok {
+			js = obj
 			break
 		}
-		// name 匹配
-		if strings.Contains(j, n) {
-			name = n
-			break
+		if _, ok := obj["name"]; ok && js == nil {
+			js = obj
 		}
 	}
 
-	// 没有匹配到工具
-	if name == "" {
-		if valueDef != "-1" {
-			return toolCallResponse(ctx, completion, valueDef, "{}", created)
+	// 指定工具时可能只输出了参数
+	if js == nil && forced != "" && len(objects) > 0 {
+		js = map[string]interface{}{"arguments": objects[0]}
+	}
+
+	// 没有解析出 JSON
+	if js == nil {
+		logger.Ctx(ctx).Infof("completeTools response: \n%s", content)
+		return fallback("no tool call")
+	}
+
+	toolId := fmt.Sprint(elseOf(js["toolId"] != nil, js["toolId"], js["name"]))
+	name := elseOf(forced != "", forced, Query(toolId, completion.Tools))
+	if name == "-1" {
+		var names []string
+		for _, t := range completion.Tools {
+			names = append(names, t.GetKeyv("function").GetString("name"))
 		}
-		logger.Infof("completeTools response failed: \n%s", content)
-		return false
+		return decision{problems: []string{fmt.Sprintf("unknown toolId `%s`, available: %s", toolId, strings.Join(names, ", "))}}
 	}
 
-	// 避免AI重复选择相同的工具
-	if names, ok := common.GetGinValues[string](ctx, exclude_tool_names); ok {
+	// 避免AI重复选择相同的工具，必须调用时不排除
+	if names, ok := common.GetGinValues[string](ctx, exclude_tool_names); ok && !required {
 		if slices.Contains(names, name) {
-			return valueDef != "-1" && toolCallResponse(ctx, completion, valueDef, "{}", created)
+			return fallback(fmt.Sprintf("tool `%s` already executed", name))
 		}
 	}
 
-	// 解析参数
-	var js model.Keyv[interface{}]
-	if err := json.Unmarshal([]byte(j), &js); err != nil {
-		logger.Error(err)
-		if valueDef != "-1" {
-			return toolCallResponse(ctx, completion, valueDef, "{}", created)
+	var fn model.Keyv[interface{}]
+	for _, t := range completion.Tools {
+		if f := t.GetKeyv("function"); f.GetString("name") == name {
+			fn = f
+			break
 		}
-		logger.Infof("completeTools response failed: \n%s", content)
-		return false
 	}
 
-	logger.Infof("completeTools response: \n%s", j)
+	schema := map[string]interface{}(fn.GetKeyv("parameters"))
 	obj, ok := js["arguments"]
 	if !ok {
 		// 尽可能解析，AI貌似十分喜欢将参数改为parameters
-		if js.Has("parameters") &&
-			!fn.GetKeyv("parameters").
-				GetKeyv("properties").
-				Has("parameters") {
-			obj = js.GetKeyv("parameters")
+		properties, _ := schema["properties"].(map[string]interface{})
+		if _, exists := properties["parameters"]; js["parameters"] != nil && !exists {
+			obj = js["parameters"]
 		} else {
-			delete(js, "toolId")
-			obj = js
+			args := make(map[string]interface{})
+			for k, v := range js {
+				if k != "toolId" && k != "name" {
+					args[k] = v
+				}
+			}
+			obj = args
+		}
+	}
+	if str, isStr := obj.(string); isStr {
+		if value, parsed := parseLoose(str); parsed {
+			obj = value
 		}
 	}
+	if obj == nil {
+		obj = map[string]interface{}{}
+	}
+
+	if problems := validateSchema(schema, obj, "arguments"); len(problems) > 0 {
+		return decision{name: name, problems: problems}
+	}
 
 	bytes, _ := json.Marshal(obj)
-	return toolCallResponse(ctx, completion, name, string(bytes), created)
+	logger.Ctx(ctx).Infof("completeTools response: \n%s", bytes)
+	return decision{name: name, args: string(bytes), reason: "valid arguments"}
 }
 
 // 解析任务
@@ -599,7 +666,7 @@ func extractToolNames(messages []model.Keyv[interface{}]) (slice []string) {
 	return
 }
 
-func toolCallResponse(ctx *gin.Context, completion model.Completion, name string, value string, created int64) bool {
+func toolCallResponse(ctx *inter.Context, completion model.Completion, name string, value string, created int64) bool {
 	if completion.Stream {
 		response.SSEToolCallResponse(ctx, completion.Model, name, value, created)
 		return true
@@ -610,8 +677,8 @@ func toolCallResponse(ctx *gin.Context, completion model.Completion, name string
 }
 
 // 获取默认的toolId
-func getToolId(ctx *gin.Context, tools []model.Keyv[interface{}]) (value string) {
-	value = common.GetGinToolValue(ctx).GetString("id")
+func getToolId(ctx *inter.Context, tools []model.Keyv[interface{}]) (value string) {
+	value = defaultTool(ctx, tools)
 	if value == "-1" {
 		return
 	}
@@ -712,7 +779,7 @@ func nameWithToolsNotArgs(task model.Keyv[string], tools []model.Keyv[interface{
 	return "-1", ""
 }
 
-func tasksIsEnabled(ctx *gin.Context) bool {
+func tasksIsEnabled(ctx *inter.Context) bool {
 	completion := common.GetGinCompletion(ctx)
 	if completion.ToolChoice != "" && completion.ToolChoice != "auto" {
 		return false
@@ -721,3 +788,10 @@ func tasksIsEnabled(ctx *gin.Context) bool {
 	t := common.GetGinToolValue(ctx)
 	return t.Is("tasks", true)
 }
+
+func elseOf[T any](condition bool, t1, t2 T) T {
+	if condition {
+		return t1
+	}
+	return t2
+}
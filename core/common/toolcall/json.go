@@ -0,0 +1,166 @@
+package toolcall
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// 从模型输出中提取 JSON 对象，按括号配对截取（代码块标记、前后的说明文字自然被忽略），
+// 并修复常见的格式问题：尾随逗号、单引号字符串、Python 风格的 True/False/None。
+// 外层对象解析失败时继续尝试其内部的对象
+func extractObjects(content string) (objects []map[string]interface{}) {
+	runes := []rune(content)
+	for start := 0; start < len(runes); start++ {
+		if runes[start] != '{' {
+			continue
+		}
+
+		end := closing(runes, start)
+		if end < 0 {
+			continue
+		}
+
+		var obj map[string]interface{}
+		if err := json.Unmarshal([]byte(repairJSON(string(runes[start:end+1]))), &obj); err != nil {
+			continue
+		}
+		objects = append(objects, obj)
+		start = end
+	}
+	return
+}
+
+// 容错解析 JSON 值，arguments 可能是字符串形式的对象
+func parseLoose(str string) (value interface{}, ok bool) {
+	if err := json.Unmarshal([]byte(repairJSON(str)), &value); err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+// 与 start 处 '{' 配对的 '}' 下标，忽略字符串内的括号
+func closing(runes []rune, start int) int {
+	var (
+		depth  = 0
+		quote  rune
+		escape = false
+	)
+	for i := start; i < len(runes); i++ {
+		r := runes[i]
+		if quote != 0 {
+			switch {
+			case escape:
+				escape = false
+			case r == '\\':
+				escape = true
+			case r == quote:
+				quote = 0
+			}
+			continue
+		}
+
+		switch r {
+		case '"', '\'':
+			quote = r
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				if r == '}' {
+					return i
+				}
+				return -1
+			}
+		}
+	}
+	return -1
+}
+
+func repairJSON(str string) string {
+	var (
+		buffer strings.Builder
+		runes  = []rune(strings.TrimSpace(str))
+		quote  rune
+		escape = false
+	)
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if quote != 0 {
+			switch {
+			case escape:
+				escape = false
+				// 单引号字符串中的 \' 无需转义
+				if r == '\'' && quote == '\'' {
+					trimLast(&buffer)
+				}
+			case r == '\\':
+				escape = true
+			case r == quote:
+				quote = 0
+				r = '"'
+			case r == '"' && quote == '\'':
+				buffer.WriteRune('\\')
+			case r == '\n':
+				buffer.WriteString("\\n")
+				continue
+			}
+			buffer.WriteRune(r)
+			continue
+		}
+
+		switch r {
+		case '"', '\'':
+			quote = r
+			r = '"'
+		case ',':
+			// 尾随逗号
+			if next := nextRune(runes, i+1); next == '}' || next == ']' {
+				continue
+			}
+		default:
+			if word, value := literal(runes, i); word != "" {
+				buffer.WriteString(value)
+				i += len(word) - 1
+				continue
+			}
+		}
+		buffer.WriteRune(r)
+	}
+	return buffer.String()
+}
+
+func trimLast(buffer *strings.Builder) {
+	str := buffer.String()
+	buffer.Reset()
+	buffer.WriteString(str[:len(str)-1])
+}
+
+func nextRune(runes []rune, i int) rune {
+	for ; i < len(runes); i++ {
+		switch runes[i] {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return runes[i]
+	}
+	return 0
+}
+
+func literal(runes []rune, i int) (word, value string) {
+	if i > 0 && isWord(runes[i-1]) {
+		return
+	}
+	for key, v := range map[string]string{"True": "true", "False": "false", "None": "null"} {
+		end := i + len(key)
+		if end <= len(runes) && string(runes[i:end]) == key && (end == len(runes) || !isWord(runes[end])) {
+			return key, v
+		}
+	}
+	return
+}
+
+func isWord(r rune) bool {
+	return r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
@@ -0,0 +1,61 @@
+package toolcall
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRepairJSON(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"valid", `{"a": 1, "b": [1, 2]}`, `{"a": 1, "b": [1, 2]}`},
+		{"trailing comma in object", `{"a": 1, }`, `{"a": 1 }`},
+		{"trailing comma in array", "{\"a\": [1, 2,\n]}", "{\"a\": [1, 2\n]}"},
+		{"single quotes", `{'a': 'x'}`, `{"a": "x"}`},
+		{"double quote inside single quotes", `{'a': 'say "hi"'}`, `{"a": "say \"hi\""}`},
+		{"escaped single quote", `{'a': 'it\'s'}`, `{"a": "it's"}`},
+		{"python literals", `{"a": True, "b": False, "c": None}`, `{"a": true, "b": false, "c": null}`},
+		{"literal inside word", `{"a": NoneType}`, `{"a": NoneType}`},
+		{"literal inside string", `{"a": "True"}`, `{"a": "True"}`},
+		{"comma inside string", `{"a": "x, }"}`, `{"a": "x, }"}`},
+		{"newline inside string", "{\"a\": \"x\ny\"}", `{"a": "x\ny"}`},
+		{"surrounding spaces", "  {\"a\": 1}\n", `{"a": 1}`},
+	} {
+		if got := repairJSON(c.in); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestExtractObjects(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		in   string
+		want string // 提取结果的 json
+	}{
+		{"plain", `1: {"toolId": "a", "arguments": {}}`, `[{"toolId": "a", "arguments": {}}]`},
+		{"code block", "1: ```json\n{\"toolId\": \"a\", \"arguments\": {\"q\": 1}}\n```", `[{"toolId": "a", "arguments": {"q": 1}}]`},
+		{"text around", `I will call {"toolId": "a"} now`, `[{"toolId": "a"}]`},
+		{"multiple", `{"toolId": "a"} and {"toolId": "b"}`, `[{"toolId": "a"}, {"toolId": "b"}]`},
+		{"brace in string", `{"toolId": "a", "arguments": {"q": "}{"}}`, `[{"toolId": "a", "arguments": {"q": "}{"}}]`},
+		{"repaired", `{'toolId': 'a', 'arguments': {'ok': True,},}`, `[{"toolId": "a", "arguments": {"ok": true}}]`},
+		{"broken outer keeps inner", `{"x": oops, "y": {"toolId": "a"}}`, `[{"toolId": "a"}]`},
+		{"unclosed", `{"toolId": "a"`, `null`},
+		{"mismatched", `{"toolId": ["a"}`, `null`},
+		{"none", `0: no tools`, `null`},
+	} {
+		got, _ := json.Marshal(extractObjects(c.in))
+		var g, w interface{}
+		_ = json.Unmarshal(got, &g)
+		if err := json.Unmarshal([]byte(c.want), &w); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("%s: got %s, want %s", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package toolcall
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// 校验工具调用的参数，返回不符合的描述
+func Validate(parameters map[string]interface{}, args interface{}) []string {
+	return validateSchema(parameters, args, "arguments")
+}
+
+// 按工具 parameters 的 JSON Schema 校验参数，返回不符合的描述。
+// 支持 type、enum、properties、required、additionalProperties、items
+func validateSchema(schema map[string]interface{}, value interface{}, path string) (problems []string) {
+	if len(schema) == 0 {
+		return
+	}
+
+	if types := schemaTypes(schema["type"]); len(types) > 0 && !slices.ContainsFunc(types, func(t string) bool { return isType(value, t) }) {
+		return []string{fmt.Sprintf("`%s` must be %s, got %s", path, strings.Join(types, " or "), typeOf(value))}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		if !slices.ContainsFunc(enum, func(it interface{}) bool { return fmt.Sprint(it) == fmt.Sprint(value) }) {
+			problems = append(problems, fmt.Sprintf("`%s` must be one of %v", path, enum))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, key := range required {
+				if _, exists := v[fmt.Sprint(key)]; !exists {
+					problems = append(problems, fmt.Sprintf("`%s.%v` is required", path, key))
+				}
+			}
+		}
+
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			sub, ok := properties[key].(map[string]interface{})
+			if !ok {
+				if additional, isBool := schema["additionalProperties"].(bool); isBool && !additional {
+					problems = append(problems, fmt.Sprintf("`%s.%s` is not allowed", path, key))
+				}
+				continue
+			}
+			problems = append(problems, validateSchema(sub, v[key], path+"."+key)...)
+		}
+
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, it := range v {
+				problems = append(problems, validateSchema(items, it, fmt.Sprintf("%s[%d]", path, i))...)
+			}
+		}
+	}
+	return
+}
+
+func schemaTypes(value interface{}) (types []string) {
+	switch t := value.(type) {
+	case string:
+		types = append(types, t)
+	case []interface{}:
+		for _, it := range t {
+			types = append(types, fmt.Sprint(it))
+		}
+	}
+	return
+}
+
+func isType(value interface{}, t string) bool {
+	switch t {
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	default:
+		return typeOf(value) == t
+	}
+}
+
+func typeOf(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
@@ -0,0 +1,57 @@
+package toolcall
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestValidateSchema(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"city":  {"type": "string"},
+			"days":  {"type": "integer"},
+			"unit":  {"type": "string", "enum": ["c", "f"]},
+			"tags":  {"type": "array", "items": {"type": "string"}},
+			"geo":   {"type": "object", "properties": {"lat": {"type": "number"}}, "required": ["lat"]},
+			"note":  {"type": ["string", "null"]}
+		},
+		"required": ["city"],
+		"additionalProperties": false
+	}`
+
+	for _, c := range []struct {
+		name string
+		args string
+		want []string
+	}{
+		{"valid", `{"city": "Paris", "days": 3, "unit": "c", "tags": ["a"], "geo": {"lat": 1.5}, "note": null}`, nil},
+		{"missing required", `{}`, []string{"`arguments.city` is required"}},
+		{"wrong type", `{"city": 1}`, []string{"`arguments.city` must be string, got number"}},
+		{"integer", `{"city": "x", "days": 1.5}`, []string{"`arguments.days` must be integer, got number"}},
+		{"enum", `{"city": "x", "unit": "k"}`, []string{"`arguments.unit` must be one of [c f]"}},
+		{"array items", `{"city": "x", "tags": ["a", 2]}`, []string{"`arguments.tags[1]` must be string, got number"}},
+		{"nested required", `{"city": "x", "geo": {}}`, []string{"`arguments.geo.lat` is required"}},
+		{"union type", `{"city": "x", "note": true}`, []string{"`arguments.note` must be string or null, got boolean"}},
+		{"additional", `{"city": "x", "extra": 1}`, []string{"`arguments.extra` is not allowed"}},
+		{"not object", `"Paris"`, []string{"`arguments` must be object, got string"}},
+	} {
+		var s map[string]interface{}
+		var args interface{}
+		if err := json.Unmarshal([]byte(schema), &s); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal([]byte(c.args), &args); err != nil {
+			t.Fatal(err)
+		}
+		if got := validateSchema(s, args, "arguments"); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+
+	// 未声明 parameters 时不校验
+	if got := validateSchema(nil, map[string]interface{}{"a": 1.0}, "arguments"); got != nil {
+		t.Errorf("empty schema: got %q", got)
+	}
+}
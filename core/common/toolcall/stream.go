@@ -0,0 +1,170 @@
+package toolcall
+
+import (
+	"strings"
+	"time"
+
+	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/gin/inter"
+	"chatgpt-adapter/core/gin/model"
+	"chatgpt-adapter/core/gin/response"
+)
+
+var streaming_decider = "__streaming-decider__"
+
+// 工具选择输出中的结束标记
+var stops = []string{
+	"<|tool|>", "<|assistant|>", "<|user|>", "<|system|>", "<|tool_response|>", "<|end|>",
+	"USER: ", "ANSWER: ", "TOOL_RESPONSE: ",
+}
+
+const (
+	undecided = iota
+	answering // 0: 直接回答
+	calling   // 1: 调用工具
+)
+
+// 流式决策：边读取边判断，以 0: 开头时把之后的内容作为回答直接输出给客户端，省去第二次上游请求；
+// 以 1: 开头时在 JSON 对象闭合后立即结束读取
+type decider struct {
+	ctx      *inter.Context
+	model    string
+	sse      bool
+	created  int64
+	matchers []inter.Matcher
+
+	mode    int
+	sent    int    // 已输出的回答长度
+	content string // 已输出的回答
+	done    bool
+}
+
+// 取代 Cancel 传给 waitMessage，开启流式决策时由其接管输出
+func CancelOf(ctx *inter.Context) func(str string) bool {
+	if value, ok := ctx.Get(streaming_decider); ok {
+		if d, isD := value.(*decider); isD && d != nil {
+			return d.observe
+		}
+	}
+	return Cancel
+}
+
+// 是否可以流式决策：开启了 toolcall.stream，且允许不调用工具
+func streamable(ctx *inter.Context, completion model.Completion) bool {
+	return streamOf() && defaultTool(ctx, completion.Tools) == "-1" && !isRequired(completion)
+}
+
+func newDecider(ctx *inter.Context, completion model.Completion) *decider {
+	return &decider{
+		ctx:      ctx,
+		model:    completion.Model,
+		sse:      completion.Stream,
+		created:  time.Now().Unix(),
+		matchers: common.GetGinMatchers(ctx),
+	}
+}
+
+func (d *decider) observe(str string) bool {
+	text := strings.TrimLeft(str, " \t\r\n")
+	if d.mode == undecided {
+		switch {
+		case len(text) < 2:
+			return false
+		case strings.HasPrefix(text, "0:"):
+			d.mode = answering
+		default:
+			// 1: 开头、直接输出 JSON 或无法识别的内容都按原方式解析
+			d.mode = calling
+		}
+	}
+
+	// 参数不增量输出：修复与校验之后才能确定最终的工具与参数，已发出的片段无法撤回
+	if d.mode == calling {
+		if Cancel(str) {
+			return true
+		}
+		runes := []rune(text)
+		start := strings.IndexRune(text, '{')
+		return start >= 0 && closing(runes, len([]rune(text[:start]))) >= 0
+	}
+
+	return d.emit(text, false)
+}
+
+// 输出新增的回答，finished 为 true 时输出剩余内容
+func (d *decider) emit(text string, finished bool) bool {
+	if d.done {
+		return true
+	}
+
+	body := strings.TrimLeft(strings.TrimPrefix(text, "0:"), " \t\r\n")
+	end := len(body)
+	for _, stop := range stops {
+		if pos := strings.Index(body, stop); pos >= 0 && pos < end {
+			end = pos
+			finished = true
+		}
+	}
+	if !finished {
+		end = holdback(body[:end])
+	}
+	end = len(strings.TrimRight(body[:end], " \t\r\n"))
+
+	if end > d.sent {
+		raw := response.ExecMatchers(d.matchers, body[d.sent:end], false)
+		d.sent = end
+		if raw == response.EOF {
+			finished = true
+		} else if raw != "" {
+			d.write(raw)
+		}
+	}
+
+	if finished {
+		if raw := response.ExecMatchers(d.matchers, "", true); raw != "" && raw != response.EOF {
+			d.write(raw)
+		}
+		d.done = true
+	}
+	return finished
+}
+
+func (d *decider) write(raw string) {
+	if d.sse {
+		response.SSEResponse(d.ctx, d.model, raw, d.created)
+	}
+	d.content += raw
+}
+
+// 结束回答，没有输出任何内容时返回 false，由原流程继续处理
+func (d *decider) finish(content string) bool {
+	if d.mode != answering {
+		return false
+	}
+
+	d.emit(strings.TrimLeft(content, " \t\r\n"), true)
+	if d.content == "" {
+		return false
+	}
+
+	if d.sse {
+		response.SSEResponse(d.ctx, d.model, "[DONE]", d.created)
+	} else {
+		response.Response(d.ctx, d.model, d.content)
+	}
+	return true
+}
+
+// 末尾可能是结束标记的前半部分，暂不输出
+func holdback(body string) int {
+	end := len(body)
+	for _, stop := range stops {
+		for n := min(len(stop)-1, len(body)); n > 0; n-- {
+			if strings.HasSuffix(body, stop[:n]) {
+				end = min(end, len(body)-n)
+				break
+			}
+		}
+	}
+	return end
+}
@@ -0,0 +1,222 @@
+package toolcall
+
+import (
+	"chatgpt-adapter/core/gin/inter"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"sync/atomic"
+	"text/template"
+
+	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/common/agent"
+	"chatgpt-adapter/core/common/inited"
+	"chatgpt-adapter/core/gin/model"
+	"chatgpt-adapter/core/logger"
+	"github.com/iocgo/sdk/env"
+)
+
+// 按模型选择工具调用模版，未指定的部分使用所选语言的内置模版
+//
+//	toolcall:
+//	  language: zh                 # 默认语言：zh、en
+//	  templates:
+//	    - models: [ "custom/*" ]   # 模型，glob
+//	      language: en
+//	      toolcall: tpl/call.tpl   # 工具选择模版文件
+//	      tasks: tpl/tasks.tpl     # 任务拆解模版文件
+//	      recommend: "..."         # 工具推荐，变量 .task .toolId
+//	      executed: "..."          # 已执行任务的说明，变量 .task .toolId
+//	      repair: "..."            # 参数不合法时重新询问的完整提示，变量 .prompt .content .errors
+//	  retries: 2                   # 参数不合法时重新询问的次数，默认 2
+//	  stream: false                # 流式决策，不调用工具时直接输出回答
+type TemplateSet struct {
+	Models    []string `mapstructure:"models"`
+	Language  string   `mapstructure:"language"`
+	ToolCall  string   `mapstructure:"toolcall"`
+	ToolTasks string   `mapstructure:"tasks"`
+	Recommend string   `mapstructure:"recommend"`
+	Executed  string   `mapstructure:"executed"`
+	Repair    string   `mapstructure:"repair"`
+
+	templates agent.Templates
+}
+
+type templateTable struct {
+	language string
+	retries  int
+	stream   bool
+	sets     []TemplateSet
+}
+
+var tables atomic.Pointer[templateTable]
+
+func init() {
+	inited.AddInitialized(func(env *env.Environment) {
+		table, err := loadTemplates(env)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		tables.Store(table)
+	})
+
+	inited.AddReloaded(func(env *env.Environment) (func(), error) {
+		table, err := loadTemplates(env)
+		if err != nil {
+			return nil, err
+		}
+		return func() {
+			tables.Store(table)
+			logger.Infof("reload toolcall templates: %d", len(table.sets))
+		}, nil
+	})
+}
+
+// 读取配置中的模版，供 `toolcall` 子命令在未启动服务时使用
+func LoadTemplates(env *env.Environment) ([]TemplateSet, error) {
+	table, err := loadTemplates(env)
+	if err != nil {
+		return nil, err
+	}
+	tables.Store(table)
+	return table.sets, nil
+}
+
+func loadTemplates(env *env.Environment) (*templateTable, error) {
+	table := &templateTable{language: env.GetString("toolcall.language")}
+	if table.language == "" {
+		table.language = "zh"
+	}
+	if _, ok := agent.Languages[table.language]; !ok {
+		return nil, fmt.Errorf("toolcall.language: unknown language '%s'", table.language)
+	}
+
+	table.retries = 2
+	if env.IsSet("toolcall.retries") {
+		table.retries = env.GetInt("toolcall.retries")
+	}
+
+	table.stream = env.GetBool("toolcall.stream")
+	if err := env.UnmarshalKey("toolcall.templates", &table.sets); err != nil {
+		return nil, fmt.Errorf("toolcall.templates: %v", err)
+	}
+
+	for i := range table.sets {
+		set := &table.sets[i]
+		if set.Language == "" {
+			set.Language = table.language
+		}
+		builtin, ok := agent.Languages[set.Language]
+		if !ok {
+			return nil, fmt.Errorf("toolcall.templates[%d]: unknown language '%s'", i, set.Language)
+		}
+		for _, pattern := range set.Models {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("toolcall.templates[%d]: bad pattern '%s'", i, pattern)
+			}
+		}
+
+		set.templates = builtin
+		for _, it := range []struct {
+			file  string
+			value *string
+		}{
+			{set.ToolCall, &set.templates.ToolCall},
+			{set.ToolTasks, &set.templates.ToolTasks},
+		} {
+			if it.file == "" {
+				continue
+			}
+			data, err := os.ReadFile(it.file)
+			if err != nil {
+				return nil, fmt.Errorf("toolcall.templates[%d]: %v", i, err)
+			}
+			*it.value = string(data)
+		}
+		if set.Recommend != "" {
+			set.templates.Recommend = set.Recommend
+		}
+		if set.Executed != "" {
+			set.templates.Executed = set.Executed
+		}
+		if set.Repair != "" {
+			set.templates.Repair = set.Repair
+		}
+
+		if err := checkTemplates(set.templates); err != nil {
+			return nil, fmt.Errorf("toolcall.templates[%d]: %v", i, err)
+		}
+	}
+	return table, nil
+}
+
+// 以空请求的函数集解析，提前暴露语法错误
+func checkTemplates(t agent.Templates) error {
+	funcM := templateFuncs(model.Completion{})
+	for _, value := range []string{t.ToolCall, t.ToolTasks, t.Recommend, t.Executed, t.Repair} {
+		if _, err := template.New("check").Funcs(funcM).Parse(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 模型对应的模版
+func templatesOf(mod string) agent.Templates {
+	table := tables.Load()
+	if table == nil {
+		return agent.Languages["zh"]
+	}
+
+	for _, set := range table.sets {
+		for _, pattern := range set.Models {
+			if ok, _ := path.Match(pattern, mod); ok {
+				return set.templates
+			}
+		}
+	}
+	return agent.Languages[table.language]
+}
+
+// 参数不合法时重新询问的次数
+func retriesOf() int {
+	if table := tables.Load(); table != nil {
+		return table.retries
+	}
+	return 2
+}
+
+// 是否开启流式决策
+func streamOf() bool {
+	if table := tables.Load(); table != nil {
+		return table.stream
+	}
+	return false
+}
+
+// 渲染 Recommend、Executed、Repair，kv 为变量名、值交替
+func phrase(value string, kv ...string) string {
+	builder := newBuilder("phrase")
+	for i := 0; i+1 < len(kv); i += 2 {
+		builder.Vars(kv[i], kv[i+1])
+	}
+	str, err := builder.String(value)
+	if err != nil {
+		logger.Error(err)
+	}
+	return str
+}
+
+// 以示例请求渲染模版，tasks 为 true 时渲染任务拆解模版
+func Preview(completion model.Completion, tasks bool) (string, error) {
+	ctx := inter.NewContext(context.Background(), nil, nil)
+	common.SetGinCompletion(ctx, completion)
+	ctx.Set(exclude_task_contents, "")
+
+	t := templatesOf(completion.Model)
+	if tasks {
+		return buildTemplate(ctx, completion, t.ToolTasks)
+	}
+	return buildTemplate(ctx, completion, t.ToolCall)
+}
@@ -25,6 +25,12 @@ func newBuilder(name string) *Builder {
 
 func (bdr *Builder) Vars(key string, value interface{}) *Builder { bdr.ctx[key] = value; return bdr }
 func (bdr *Builder) Func(key string, fun interface{}) *Builder   { bdr.funcM[key] = fun; return bdr }
+func (bdr *Builder) Funcs(funcM template.FuncMap) *Builder {
+	for key, fun := range funcM {
+		bdr.funcM[key] = fun
+	}
+	return bdr
+}
 func (bdr *Builder) String(template string) (result string, err error) {
 	bdr.instance.Funcs(bdr.funcM)
 	t, err := bdr.instance.Parse(template)
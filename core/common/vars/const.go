@@ -15,4 +15,11 @@ var (
 	GinCancelFunc      = "__cancelFunc__"
 	GinClaudeMessages  = "__claude_messages__"
 	GinThinkReason     = "__think_reason__"
+	GinRequestId       = "__request-id__"
+	GinAdapter         = "__adapter__"
+	GinPoolEntry       = "__pool-entry__"
+	GinToken           = "token"
+	GinTokens          = "__tokens__"
+	GinLegacyFunctions = "__legacy-functions__"
+	GinStatus          = "__status__"
 )
@@ -0,0 +1,373 @@
+package wasm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wasmerio/wasmer-go/wasmer"
+)
+
+// 插件 ABI v1
+//
+// 模块需导出:
+//
+//	memory                         线性内存
+//	alloc(len i32) -> i32          分配 len 字节，宿主写入入参
+//	dealloc(ptr i32, len i32)      可选，调用结束后宿主释放入参与返回值
+//	abi_version() -> i32           可选，存在时必须返回 1
+//	<hook>(ptr i32, len i32) -> i64 钩子函数，入参与返回值均为 utf8 json；
+//	                               返回值为 ptr<<32 | len，len 为 0 表示不做修改
+//
+// 宿主提供 (import module "env"):
+//
+//	host_log(level i32, ptr i32, len i32)  level: 0 debug 1 info 2 warn 3 error
+const ABIVersion = 1
+
+// 单核每秒可消耗的燃料，偏大估计，超时换算用
+const fuelPerSecond = 1e9
+
+// wasm 内存页大小
+const pageSize = 64 << 10
+
+var (
+	ErrTimeout   = errors.New("wasm: invocation timeout")
+	ErrExhausted = errors.New("wasm: fuel exhausted")
+	ErrMemory    = errors.New("wasm: memory limit exceeded")
+
+	// 只对分支与调用计费，足以限制死循环与深递归
+	meteringOps = map[wasmer.Opcode]uint32{
+		wasmer.Loop:         1,
+		wasmer.Br:           1,
+		wasmer.BrIf:         1,
+		wasmer.BrTable:      1,
+		wasmer.Call:         1,
+		wasmer.CallIndirect: 1,
+	}
+)
+
+// 实例的资源限制，零值为不限制
+type Limits struct {
+	Memory  uint64        // 线性内存上限，字节；按模块声明的 maximum 在实例化前校验
+	Timeout time.Duration // 调用超时
+	Fuel    uint64        // 分支与调用次数上限
+}
+
+// 实际生效的燃料上限，设置了超时时不超过超时换算的燃料
+//
+// wasmer-go 不支持 epoch 中断，编译后的循环会把剩余燃料留在寄存器里，
+// 跨线程 SetRemainingPoints 无法打断执行，只能预先限定燃料
+func (l Limits) fuel() uint64 {
+	fuel := l.Fuel
+	if fuel == 0 {
+		fuel = ^uint64(0)
+	}
+	if l.Timeout > 0 {
+		fuel = min(fuel, uint64(l.Timeout.Seconds()*fuelPerSecond)+1)
+	}
+	return fuel
+}
+
+// 已编译的插件模块，可重复实例化
+type Module struct {
+	engine     *wasmer.Engine
+	serialized []byte
+	exports    map[string]bool
+	maxMemory  uint64 // 导出内存声明的上限，字节；0 为未声明
+}
+
+// 实例，非并发安全，需由调用方保证同一时间只有一个调用
+type Guest struct {
+	instance *wasmer.Instance
+	memory   *wasmer.Memory
+	alloc    wasmer.NativeFunction
+	dealloc  wasmer.NativeFunction
+	limits   Limits
+	broken   atomic.Bool
+	detached atomic.Bool // 超时后由后台 goroutine 负责释放
+}
+
+type hostEnv struct {
+	memory *wasmer.Memory
+	limit  uint64
+	log    func(level int32, message string)
+}
+
+func Compile(path string) (*Module, error) {
+	wasmBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return CompileBytes(wasmBytes)
+}
+
+func CompileBytes(wasmBytes []byte) (*Module, error) {
+	engine := wasmer.NewEngineWithConfig(meteringConfig())
+	module, err := wasmer.NewModule(wasmer.NewStore(engine), wasmBytes)
+	if err != nil {
+		return nil, err
+	}
+	defer module.Close()
+
+	var maxMemory uint64
+	exports := make(map[string]bool)
+	for _, export := range module.Exports() {
+		exports[export.Name()] = true
+		if export.Name() != "memory" {
+			continue
+		}
+		memoryType := export.Type().IntoMemoryType()
+		if memoryType == nil {
+			return nil, fmt.Errorf("wasm: export `memory` is not a memory")
+		}
+		if maximum := memoryType.Limits().Maximum(); maximum != wasmer.LimitMaxUnbound() {
+			maxMemory = uint64(maximum) * pageSize
+		}
+	}
+	if !exports["memory"] || !exports["alloc"] {
+		return nil, fmt.Errorf("wasm: module must export `memory` and `alloc`")
+	}
+
+	serialized, err := module.Serialize()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Module{engine, serialized, exports, maxMemory}
+	if exports["abi_version"] {
+		guest, err := m.Instantiate(Limits{Timeout: time.Second}, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer guest.Close()
+
+		f, err := guest.instance.Exports.GetFunction("abi_version")
+		if err != nil {
+			return nil, err
+		}
+		version, err := f()
+		if err != nil {
+			return nil, err
+		}
+		if v, _ := version.(int32); v != ABIVersion {
+			return nil, fmt.Errorf("wasm: unsupported abi version %v, expected %d", version, ABIVersion)
+		}
+	}
+	return m, nil
+}
+
+func meteringConfig() *wasmer.Config {
+	// 计费表为 wasmer 全局变量，只在首次设置时生效
+	return wasmer.NewConfig().PushMeteringMiddleware(^uint64(0), meteringOps)
+}
+
+func (m *Module) Has(name string) bool {
+	return m.exports[name]
+}
+
+// 校验内存上限：wasmer-go 无法在调用中拦截 memory.grow，
+// 因此要求模块为导出内存声明不超过 limits.Memory 的 maximum，由运行时保证不会超出
+func (m *Module) Check(limits Limits) error {
+	if limits.Memory == 0 {
+		return nil
+	}
+	if m.maxMemory == 0 {
+		return fmt.Errorf("%w: `memory` declares no maximum, expected at most %d pages", ErrMemory, limits.Memory/pageSize)
+	}
+	if m.maxMemory > limits.Memory {
+		return fmt.Errorf("%w: `memory` maximum is %d pages, expected at most %d", ErrMemory, m.maxMemory/pageSize, limits.Memory/pageSize)
+	}
+	return nil
+}
+
+// 每个实例独立的 store，可在不同 goroutine 中并行使用
+func (m *Module) Instantiate(limits Limits, log func(level int32, message string)) (*Guest, error) {
+	if err := m.Check(limits); err != nil {
+		return nil, err
+	}
+
+	store := wasmer.NewStore(m.engine)
+	module, err := wasmer.DeserializeModule(store, m.serialized)
+	if err != nil {
+		return nil, err
+	}
+
+	env := &hostEnv{limit: limits.Memory, log: log}
+	imports := wasmer.NewImportObject()
+	imports.Register("env", map[string]wasmer.IntoExtern{
+		"host_log": wasmer.NewFunctionWithEnvironment(store,
+			wasmer.NewFunctionType(wasmer.NewValueTypes(wasmer.I32, wasmer.I32, wasmer.I32), wasmer.NewValueTypes()),
+			env, hostLog),
+	})
+
+	instance, err := wasmer.NewInstance(module, imports)
+	if err != nil {
+		return nil, err
+	}
+
+	guest := &Guest{instance: instance, limits: limits}
+	if guest.memory, err = instance.Exports.GetMemory("memory"); err != nil {
+		instance.Close()
+		return nil, err
+	}
+	if guest.alloc, err = instance.Exports.GetFunction("alloc"); err != nil {
+		instance.Close()
+		return nil, err
+	}
+	if m.exports["dealloc"] {
+		guest.dealloc, _ = instance.Exports.GetFunction("dealloc")
+	}
+
+	env.memory = guest.memory
+	return guest, nil
+}
+
+func hostLog(userEnv interface{}, args []wasmer.Value) ([]wasmer.Value, error) {
+	env := userEnv.(*hostEnv)
+	if env.memory == nil {
+		return []wasmer.Value{}, nil
+	}
+
+	// 内存上限已由 Module.Check 按声明的 maximum 保证，此处仅作兜底
+	if env.limit > 0 && uint64(env.memory.DataSize()) > env.limit {
+		return nil, ErrMemory
+	}
+	if env.log == nil {
+		return []wasmer.Value{}, nil
+	}
+
+	data := env.memory.Data()
+	ptr, length := uint64(uint32(args[1].I32())), uint64(uint32(args[2].I32()))
+	if ptr+length > uint64(len(data)) {
+		return nil, fmt.Errorf("host_log: out of bounds memory access")
+	}
+	env.log(args[0].I32(), string(data[ptr:ptr+length]))
+	return []wasmer.Value{}, nil
+}
+
+// 调用钩子函数，返回 nil 表示不做修改
+//
+// 超时后实例被标记为不可用，后台调用在燃料耗尽时中断并自动释放
+func (g *Guest) Call(name string, input []byte) (output []byte, err error) {
+	if g.broken.Load() {
+		return nil, errors.New("wasm: instance is broken")
+	}
+
+	if g.limits.Timeout <= 0 {
+		return g.call(name, input)
+	}
+
+	type result struct {
+		output []byte
+		err    error
+	}
+
+	ch := make(chan result, 1)
+	var mu sync.Mutex
+	timedOut := false
+	go func() {
+		o, e := g.call(name, input)
+		mu.Lock()
+		defer mu.Unlock()
+		if timedOut {
+			g.instance.Close()
+			return
+		}
+		ch <- result{o, e}
+	}()
+
+	timer := time.NewTimer(g.limits.Timeout)
+	defer timer.Stop()
+	select {
+	case r := <-ch:
+		return r.output, r.err
+	case <-timer.C:
+		mu.Lock()
+		defer mu.Unlock()
+		select {
+		case r := <-ch:
+			return r.output, r.err
+		default:
+		}
+		timedOut = true
+		g.detached.Store(true)
+		g.broken.Store(true)
+		return nil, ErrTimeout
+	}
+}
+
+func (g *Guest) call(name string, input []byte) (output []byte, err error) {
+	f, err := g.instance.Exports.GetFunction(name)
+	if err != nil {
+		return nil, err
+	}
+
+	g.instance.SetRemainingPoints(g.limits.fuel())
+	defer func() {
+		if err != nil && g.instance.MeteringPointsExhausted() {
+			g.broken.Store(true)
+			err = ErrExhausted
+		}
+		if g.limits.Memory > 0 && uint64(g.memory.DataSize()) > g.limits.Memory {
+			g.broken.Store(true)
+			output, err = nil, ErrMemory
+		}
+	}()
+
+	if g.limits.Memory > 0 && uint64(g.memory.DataSize())+uint64(len(input)) > g.limits.Memory {
+		g.broken.Store(true)
+		return nil, ErrMemory
+	}
+
+	ptr, err := g.alloc(int32(len(input)))
+	if err != nil {
+		return nil, err
+	}
+	inPtr, _ := ptr.(int32)
+	data := g.memory.Data()
+	if uint64(uint32(inPtr))+uint64(len(input)) > uint64(len(data)) {
+		return nil, fmt.Errorf("wasm: alloc returned out of bounds pointer")
+	}
+	copy(data[uint32(inPtr):], input)
+
+	ret, err := f(inPtr, int32(len(input)))
+	if g.dealloc != nil {
+		defer g.dealloc(inPtr, int32(len(input)))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	packed, _ := ret.(int64)
+	outPtr, outLen := uint64(uint32(packed>>32)), uint64(uint32(packed))
+	if outLen == 0 {
+		return nil, nil
+	}
+
+	// 调用期间内存可能增长，需重新获取
+	data = g.memory.Data()
+	if outPtr+outLen > uint64(len(data)) {
+		return nil, fmt.Errorf("wasm: `%s` returned out of bounds memory", name)
+	}
+	output = make([]byte, outLen)
+	copy(output, data[outPtr:outPtr+outLen])
+	if g.dealloc != nil {
+		_, _ = g.dealloc(int32(outPtr), int32(outLen))
+	}
+	return
+}
+
+// 超时或超出限制后不可复用
+func (g *Guest) Broken() bool {
+	return g.broken.Load()
+}
+
+func (g *Guest) Close() {
+	if g.detached.Load() {
+		return
+	}
+	g.instance.Close()
+}
@@ -0,0 +1,230 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"chatgpt-adapter/core/common/inited"
+	"chatgpt-adapter/core/logger"
+	"github.com/iocgo/sdk/env"
+	"gopkg.in/yaml.v3"
+)
+
+// 环境变量覆盖配置项，`__` 分隔层级，数字段为列表下标，`_FILE` 后缀读取文件内容
+//
+//	ADAPTER_SERVER__PORT=8080                       => server.port
+//	ADAPTER_CURSOR__MODEL=claude-3.7,gpt-4o         => cursor.model
+//	ADAPTER_CUSTOM_LLM__0__PREFIX=openai            => custom-llm[0].prefix
+//	ADAPTER_CUSTOM_LLM='[{"prefix": "openai", ...}]' => custom-llm
+//	ADAPTER_SERVER__PASSWORD_FILE=/run/secrets/pwd  => server.password
+const EnvPrefix = "ADAPTER_"
+
+func init() {
+	// 重载时同样生效，需先于命令行参数应用
+	inited.AddOverride(func(env *env.Environment) {
+		if err := ApplyEnv(env); err != nil {
+			logger.Error(err)
+		}
+	})
+}
+
+// 启动时应用，存在错误直接退出
+func MustApplyEnv(env *env.Environment) {
+	if err := ApplyEnv(env); err != nil {
+		logger.Fatal(err)
+	}
+}
+
+func ApplyEnv(env *env.Environment) error {
+	environ := env.Env
+	if environ == nil {
+		environ = os.Environ()
+	}
+
+	// 保证同一路径下父级先于下标项应用
+	environ = slices.Clone(environ)
+	sort.Strings(environ)
+
+	var errs []error
+	for _, item := range environ {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok || !strings.HasPrefix(name, EnvPrefix) || len(name) == len(EnvPrefix) {
+			continue
+		}
+
+		name = name[len(EnvPrefix):]
+		if strings.HasSuffix(name, "_FILE") {
+			data, err := os.ReadFile(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: %v", EnvPrefix, name, err))
+				continue
+			}
+			name = strings.TrimSuffix(name, "_FILE")
+			value = strings.TrimRight(string(data), "\r\n")
+		}
+
+		segments, node := resolve(name)
+		converted, err := convert(node, value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s%s => %s: %v", EnvPrefix, name, pathOf(segments), err))
+			continue
+		}
+		if node == nil {
+			logger.Warnf("%s%s => %s: unknown key", EnvPrefix, name, pathOf(segments))
+		}
+
+		// 列表之前的部分直接按路径写入，viper 不支持在覆盖值的 map 中查找带 `.` 的键
+		idx := slices.IndexFunc(segments[1:], isIndex) + 1
+		if idx == 0 {
+			env.Set(strings.Join(segments, "."), converted)
+			continue
+		}
+		key := strings.Join(segments[:idx], ".")
+		env.Set(key, assign(env.Get(key), segments[idx:], converted))
+	}
+	return errors.Join(errs...)
+}
+
+var envReplacer = strings.NewReplacer("-", "_", ".", "_")
+
+// 按 schema 还原键名：环境变量中的 `_` 可对应配置中的 `-`、`.` 或 `_`
+func resolve(name string) (segments []string, node *Node) {
+	node = Schema
+	for _, seg := range strings.Split(name, "__") {
+		seg = strings.ToLower(seg)
+		if node != nil {
+			switch node.Kind {
+			case List:
+				if isIndex(seg) {
+					segments = append(segments, seg)
+					node = node.Elem
+					continue
+				}
+				node = nil
+			case Map:
+				segments = append(segments, seg)
+				node = node.Elem
+				continue
+			case Object:
+				found := false
+				for k, child := range node.Fields {
+					if envReplacer.Replace(k) == seg {
+						segments = append(segments, k)
+						node, found = child, true
+						break
+					}
+				}
+				if found {
+					continue
+				}
+				node = nil
+			default:
+				node = nil
+			}
+		}
+		segments = append(segments, seg)
+	}
+	return
+}
+
+func convert(node *Node, value string) (interface{}, error) {
+	if node == nil {
+		if strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{") {
+			return parse(value)
+		}
+		return value, nil
+	}
+
+	switch node.Kind {
+	case Bool:
+		return strconv.ParseBool(value)
+	case Int:
+		return strconv.Atoi(value)
+	case Float:
+		return strconv.ParseFloat(value, 64)
+	case List:
+		if strings.HasPrefix(value, "[") || node.Elem.Kind == Object {
+			return parse(value)
+		}
+		slice := make([]interface{}, 0)
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			v, err := convert(node.Elem, item)
+			if err != nil {
+				return nil, err
+			}
+			slice = append(slice, v)
+		}
+		return slice, nil
+	case Object, Map:
+		return parse(value)
+	default:
+		return value, nil
+	}
+}
+
+// json 与 yaml 均可
+func parse(value string) (result interface{}, err error) {
+	err = yaml.Unmarshal([]byte(value), &result)
+	return
+}
+
+// 写入嵌套结构，已有的 map / slice 会被复制，不修改原配置
+func assign(current interface{}, segments []string, value interface{}) interface{} {
+	if len(segments) == 0 {
+		return value
+	}
+
+	if isIndex(segments[0]) {
+		idx, _ := strconv.Atoi(segments[0])
+		older, _ := current.([]interface{})
+		slice := make([]interface{}, max(len(older), idx+1))
+		copy(slice, older)
+		slice[idx] = assign(slice[idx], segments[1:], value)
+		return slice
+	}
+
+	obj := make(map[string]interface{})
+	if older, ok := current.(map[string]interface{}); ok {
+		for k, v := range older {
+			obj[k] = v
+		}
+	}
+
+	key := segments[0]
+	for k := range obj {
+		if strings.EqualFold(k, key) {
+			key = k
+			break
+		}
+	}
+	obj[key] = assign(obj[key], segments[1:], value)
+	return obj
+}
+
+func isIndex(seg string) bool {
+	_, err := strconv.Atoi(seg)
+	return err == nil
+}
+
+func pathOf(segments []string) string {
+	var sb strings.Builder
+	for i, seg := range segments {
+		if isIndex(seg) && i > 0 {
+			sb.WriteString("[" + seg + "]")
+			continue
+		}
+		if i > 0 {
+			sb.WriteByte('.')
+		}
+		sb.WriteString(seg)
+	}
+	return sb.String()
+}
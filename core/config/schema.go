@@ -0,0 +1,310 @@
+package config
+
+import (
+	"fmt"
+
+	regexp "github.com/dlclark/regexp2"
+)
+
+type Kind uint8
+
+const (
+	String Kind = iota
+	Bool
+	Int
+	Float
+	List   // Elem 为元素类型
+	Object // Fields 为已知键
+	Map    // 任意键，Elem 为值类型
+)
+
+// 配置项节点
+type Node struct {
+	Kind     Kind
+	Elem     *Node
+	Fields   map[string]*Node
+	Enum     []string
+	Required bool
+	Strict   bool // Int 不接受数字字符串（代码中直接做了类型断言）
+	Check    func(value interface{}) error
+}
+
+func str() *Node                           { return &Node{Kind: String} }
+func boolean() *Node                       { return &Node{Kind: Bool} }
+func integer() *Node                       { return &Node{Kind: Int} }
+func float() *Node                         { return &Node{Kind: Float} }
+func list(elem *Node) *Node                { return &Node{Kind: List, Elem: elem} }
+func mapOf(elem *Node) *Node               { return &Node{Kind: Map, Elem: elem} }
+func object(fields map[string]*Node) *Node { return &Node{Kind: Object, Fields: fields} }
+func enum(values ...string) *Node          { return &Node{Kind: String, Enum: values} }
+
+func (n *Node) required() *Node { n.Required = true; return n }
+func (n *Node) strict() *Node   { n.Strict = true; return n }
+func (n *Node) check(f func(value interface{}) error) *Node {
+	n.Check = f
+	return n
+}
+
+// 项目读取的全部配置项，键名不区分大小写
+var Schema = object(map[string]*Node{
+	"server": object(map[string]*Node{
+		"port":         integer(),
+		"proxied":      str(),
+		"password":     str(),
+		"debug":        boolean(),
+		"think_reason": boolean(),
+		"no-usage":     boolean(),
+		"log-format":   enum("text", "json"),
+		"hot-reload":   boolean(),
+	}),
+	"server-conn": object(map[string]*Node{
+		"conntimeout":           integer().strict(),
+		"idleconntimeout":       integer().strict(),
+		"responseheadertimeout": integer().strict(),
+		"expectcontinuetimeout": integer().strict(),
+	}),
+	"tracing": object(map[string]*Node{
+		"enabled":      boolean(),
+		"endpoint":     str(),
+		"service-name": str(),
+		"sample-ratio": float(),
+		"headers":      mapOf(str()),
+	}),
+	"browser-less": object(map[string]*Node{
+		"enabled":      boolean(),
+		"port":         integer(),
+		"disabled-gpu": str(),
+		"headless":     str(),
+		"reversal":     str(),
+	}),
+
+	"ppl":     str(),
+	"domain":  str(),
+	"proxied": str(),
+	"separator": object(map[string]*Node{
+		"claude": str(),
+	}),
+
+	"matcher": list(object(map[string]*Node{
+		"match":        str().required(),
+		"over":         str(),
+		"notice":       str(),
+		"regex":        str().required().check(matcherRegex),
+		"think_reason": boolean(),
+		"max":          integer(),
+	})),
+	"toolcall": object(map[string]*Node{
+		"language": enum("zh", "en"),
+		"retries":  integer(),
+		"stream":   boolean(),
+		"templates": list(object(map[string]*Node{
+			"models":    list(str()),
+			"language":  enum("zh", "en"),
+			"toolcall":  str(),
+			"tasks":     str(),
+			"recommend": str(),
+			"executed":  str(),
+			"repair":    str(),
+		})),
+	}),
+	"prompt-format": list(object(map[string]*Node{
+		"name":     str().required(),
+		"models":   list(str()),
+		"adapters": list(str()),
+		"prefix":   mapOf(str()),
+		"suffix":   mapOf(str()),
+		"system":   enum("keep", "user"),
+		"stop":     list(str()),
+	})),
+	"custom-llm": list(object(map[string]*Node{
+		"prefix":   str().required(),
+		"reversal": str().required(),
+		"proxied":  boolean(),
+		"tc":       boolean(),
+		"protocol": enum("openai", "azure", "anthropic", "gemini", "ollama"),
+		"token":    str(),
+		"models":   list(str()),
+		"exclude":  list(str()),
+		"refresh":  integer(),
+		"strict":   boolean(),
+
+		"api-version":    str(),
+		"context-window": integer(),
+		"max-output":     integer(),
+	})),
+
+	"agent": object(map[string]*Node{
+		"models":         list(str()),
+		"tools":          list(str()),
+		"max-iterations": integer(),
+		"timeout":        integer(),
+		"steps":          enum("none", "reasoning", "event"),
+		"http": object(map[string]*Node{
+			"hosts":     list(str()),
+			"timeout":   integer(),
+			"max-bytes": integer(),
+		}),
+		"sql": object(map[string]*Node{
+			"driver":      str(),
+			"dsn":         str(),
+			"max-rows":    integer(),
+			"description": str(),
+		}),
+		"mcp": list(object(map[string]*Node{
+			"name":    str(),
+			"command": str(),
+			"args":    list(str()),
+			"env":     list(str()),
+			"dir":     str(),
+			"url":     str(),
+			"headers": mapOf(str()),
+			"models":  list(str()),
+			"tools":   list(str()),
+			"prefix":  str(),
+			"timeout": integer(),
+		})),
+	}),
+
+	"response-cache": object(map[string]*Node{
+		"enabled":         boolean(),
+		"backend":         str(),
+		"ttl":             integer(),
+		"max-entries":     integer(),
+		"max-bytes":       integer(),
+		"max-temperature": float(),
+		"models":          list(str()),
+	}),
+
+	"coalesce": object(map[string]*Node{
+		"models": list(str()),
+	}),
+
+	"usage": object(map[string]*Node{
+		"enabled":   boolean(),
+		"path":      str(),
+		"retention": integer(),
+	}),
+
+	"plugins": list(object(map[string]*Node{
+		"name":    str(),
+		"path":    str().required(),
+		"models":  list(str()),
+		"memory":  integer(),
+		"timeout": integer(),
+		"fuel":    integer(),
+	})),
+
+	"rpc": list(object(map[string]*Node{
+		"name":    str(),
+		"command": str().required(),
+		"args":    list(str()),
+		"env":     list(str()),
+		"dir":     str(),
+		"health":  integer(),
+		"timeout": integer(),
+	})),
+
+	"coze": object(map[string]*Node{
+		"websdk": object(map[string]*Node{
+			"bot":    str(),
+			"model":  str(),
+			"system": str(),
+			"accounts": list(object(map[string]*Node{
+				"email":    str().required(),
+				"password": str().required(),
+				"validate": str(),
+			})),
+		}),
+	}),
+	"hf": object(map[string]*Node{
+		"rmbg":             str(),
+		"dalle-4k":         hfModel(),
+		"dalle-3-xl":       hfModel(),
+		"animagine-xl-3.1": hfModel(),
+		"animagine-xl-4.0": hfModel(),
+	}),
+	"llm": object(map[string]*Node{
+		"model":    str(),
+		"reversal": str(),
+		"token":    str(),
+	}),
+	"bing": object(map[string]*Node{
+		"proxied": boolean(),
+		"cookies": list(object(map[string]*Node{
+			"scopeid": str().required(),
+			"idtoken": str().required(),
+			"cookie":  str().required(),
+		})),
+	}),
+	"blackbox": object(map[string]*Node{
+		"model": list(str()),
+		"token": str(),
+	}),
+	"cursor": object(map[string]*Node{
+		"checksum": str(),
+		"model":    list(str()),
+	}),
+	"grok": object(map[string]*Node{
+		"cookies":        list(str()),
+		"disable_search": boolean(),
+		"think_reason":   boolean(),
+	}),
+	"lmsys": object(map[string]*Node{
+		"model": list(str()),
+		"token": str(),
+	}),
+	"lmsys-chat": object(map[string]*Node{
+		"model": mapOf(str()),
+	}),
+	"qodo": object(map[string]*Node{
+		"key":   str(),
+		"model": list(str()),
+	}),
+	"windsurf": object(map[string]*Node{
+		"proxied": boolean(),
+	}),
+	"you": object(map[string]*Node{
+		"cookies": list(str()),
+		"custom":  boolean(),
+		"model":   list(str()),
+		"notice":  str(),
+		"task":    boolean(),
+	}),
+})
+
+func hfModel() *Node {
+	return object(map[string]*Node{
+		"reversal": str(),
+		"fn":       list(integer()),
+		"data":     str(),
+	})
+}
+
+// 跨字段校验
+var rules = []func(root map[string]interface{}, result *Result){
+	func(root map[string]interface{}, result *Result) {
+		accounts, _ := lookup(root, "coze.websdk.accounts").([]interface{})
+		if len(accounts) == 0 {
+			return
+		}
+		enabled, _ := toBool(lookup(root, "browser-less.enabled"))
+		reversal, _ := lookup(root, "browser-less.reversal").(string)
+		if !enabled && reversal == "" {
+			result.errorf("coze.websdk.accounts", "requires `browser-less.enabled` or `browser-less.reversal`")
+		}
+	},
+}
+
+// 与 response.initMatchers 的解析方式一致
+func matcherRegex(value interface{}) error {
+	str, _ := value.(string)
+	compile := regexp.MustCompile(`"(.+)" *: *"(.*)"`, regexp.ECMAScript)
+	matched, err := compile.FindStringMatch(str)
+	if err != nil || matched == nil {
+		return fmt.Errorf(`expected format "regex": "replacement"`)
+	}
+	if _, err = regexp.Compile(matched.GroupByNumber(1).String(), regexp.ECMAScript); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"chatgpt-adapter/core/common/inited"
+	"chatgpt-adapter/core/logger"
+	"github.com/iocgo/sdk/env"
+	"gopkg.in/yaml.v3"
+)
+
+type Issue struct {
+	Path    string
+	Message string
+}
+
+func (i Issue) String() string {
+	if i.Path == "" {
+		return i.Message
+	}
+	return i.Path + ": " + i.Message
+}
+
+type Result struct {
+	Errors   []Issue
+	Warnings []Issue
+}
+
+func (r *Result) errorf(path, format string, args ...interface{}) {
+	r.Errors = append(r.Errors, Issue{path, fmt.Sprintf(format, args...)})
+}
+
+func (r *Result) warnf(path, format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, Issue{path, fmt.Sprintf(format, args...)})
+}
+
+func (r *Result) Err() error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	slice := make([]string, 0, len(r.Errors))
+	for _, issue := range r.Errors {
+		slice = append(slice, issue.String())
+	}
+	return fmt.Errorf("invalid config:\n  %s", strings.Join(slice, "\n  "))
+}
+
+func init() {
+	inited.AddReloaded(func(env *env.Environment) (func(), error) {
+		result, err := ValidateEnv(env)
+		if err != nil {
+			return nil, err
+		}
+		for _, issue := range result.Warnings {
+			logger.Warnf("config %s", issue)
+		}
+		return nil, result.Err()
+	})
+}
+
+// 启动时校验，存在错误直接退出；需在 MustApplyEnv 之后调用
+func MustValidate(env *env.Environment) {
+	result, err := ValidateEnv(env)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	for _, issue := range result.Warnings {
+		logger.Warnf("config %s", issue)
+	}
+	if err = result.Err(); err != nil {
+		logger.Fatal(err)
+	}
+}
+
+func ValidateFile(path string) (result *Result, err error) {
+	data, err := inited.ReadConfig(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config `%s` failed: %v", path, err)
+	}
+	return Validate(data)
+}
+
+// 校验合并后的配置：配置文件 + 环境变量 + 命令行参数
+func ValidateEnv(env *env.Environment) (result *Result, err error) {
+	keys := env.AllKeys()
+	root, _ := merged(env, keys, "", Schema).(map[string]interface{})
+
+	// 统一为 yaml 解码后的类型，环境变量可能写入 []string 等
+	data, err := yaml.Marshal(root)
+	if err != nil {
+		return nil, fmt.Errorf("encode config failed: %v", err)
+	}
+	return Validate(data)
+}
+
+func Validate(data []byte) (result *Result, err error) {
+	var root map[string]interface{}
+	if err = yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("parse config failed: %v", err)
+	}
+
+	result = new(Result)
+	if root == nil {
+		return
+	}
+
+	walk("", Schema, root, result)
+	for _, rule := range rules {
+		rule(root, result)
+	}
+	return
+}
+
+// 按 Schema 逐层取值。不直接用 AllSettings：它会把 Map 节点下
+// 含 `.` 的键（如 claude-3.5-sonnet）拆成多层对象
+func merged(env *env.Environment, keys []string, path string, node *Node) interface{} {
+	if node == nil || node.Kind != Object {
+		return env.Get(path)
+	}
+
+	if path != "" {
+		// 类型不符的值原样返回，交由 walk 报错
+		if value := env.Get(path); value != nil {
+			if _, ok := value.(map[string]interface{}); !ok {
+				return value
+			}
+		}
+	}
+
+	prefix := ""
+	if path != "" {
+		prefix = path + "."
+	}
+
+	obj := make(map[string]interface{})
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		name, _, _ := strings.Cut(key[len(prefix):], ".")
+		if _, ok := obj[name]; ok {
+			continue
+		}
+		if value := merged(env, keys, prefix+name, node.Fields[name]); value != nil {
+			obj[name] = value
+		}
+	}
+
+	if len(obj) == 0 && path != "" {
+		return nil
+	}
+	return obj
+}
+
+func walk(path string, node *Node, value interface{}, result *Result) {
+	if value == nil {
+		return
+	}
+
+	switch node.Kind {
+	case Object, Map:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			result.errorf(path, "expected object, got %s", typeOf(value))
+			return
+		}
+
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		if node.Kind == Map {
+			for _, k := range keys {
+				walk(join(path, k), node.Elem, obj[k], result)
+			}
+			return
+		}
+
+		seen := make(map[string]bool)
+		for _, k := range keys {
+			lower := strings.ToLower(k)
+			seen[lower] = true
+			child, ok := node.Fields[lower]
+			if !ok {
+				result.warnf(join(path, k), "unknown key")
+				continue
+			}
+			walk(join(path, k), child, obj[k], result)
+		}
+
+		names := make([]string, 0, len(node.Fields))
+		for k := range node.Fields {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			if node.Fields[k].Required && !seen[k] {
+				result.errorf(join(path, k), "is required")
+			}
+		}
+
+	case List:
+		slice, ok := value.([]interface{})
+		if !ok {
+			result.errorf(path, "expected list, got %s", typeOf(value))
+			return
+		}
+		for i, item := range slice {
+			walk(fmt.Sprintf("%s[%d]", path, i), node.Elem, item, result)
+		}
+
+	case Bool:
+		if _, ok := toBool(value); !ok {
+			result.errorf(path, "expected bool, got %s", typeOf(value))
+		}
+
+	case Int:
+		switch v := value.(type) {
+		case int:
+		case string:
+			if _, err := strconv.Atoi(v); node.Strict || err != nil {
+				result.errorf(path, "expected int, got %s", typeOf(value))
+			}
+		default:
+			result.errorf(path, "expected int, got %s", typeOf(value))
+		}
+
+	case Float:
+		switch value.(type) {
+		case int, float64:
+		default:
+			result.errorf(path, "expected float, got %s", typeOf(value))
+		}
+
+	default:
+		switch value.(type) {
+		case string, int, float64, bool:
+		default:
+			result.errorf(path, "expected string, got %s", typeOf(value))
+			return
+		}
+		if len(node.Enum) > 0 {
+			str := fmt.Sprintf("%v", value)
+			found := false
+			for _, e := range node.Enum {
+				found = found || e == str
+			}
+			if !found {
+				result.errorf(path, "must be one of [%s], got `%s`", strings.Join(node.Enum, "|"), str)
+			}
+		}
+	}
+
+	if node.Check != nil {
+		if err := node.Check(value); err != nil {
+			result.errorf(path, "%v", err)
+		}
+	}
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func lookup(root map[string]interface{}, path string) interface{} {
+	var value interface{} = root
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = nil
+		for k, v := range obj {
+			if strings.EqualFold(k, key) {
+				value = v
+				break
+			}
+		}
+	}
+	return value
+}
+
+func toBool(value interface{}) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		b, err := strconv.ParseBool(v)
+		return b, err == nil
+	}
+	return false, false
+}
+
+func typeOf(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case int:
+		return "int"
+	case float64:
+		return "float"
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
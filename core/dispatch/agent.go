@@ -0,0 +1,361 @@
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/gin/inter"
+	"chatgpt-adapter/core/gin/model"
+	"chatgpt-adapter/core/gin/response"
+	"chatgpt-adapter/core/logger"
+	"chatgpt-adapter/core/tools"
+	"chatgpt-adapter/core/tracer"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// 网关执行工具：每一轮以派生的上下文完成一次分发，输出写入 step；
+// 模型只调用了网关工具时在本地执行，结果追加到对话后继续下一轮，否则把这一轮的输出转发给客户端
+func agentLoop(gtx *inter.Context, adapters []inter.Adapter, completion model.Completion, config *tools.Config) {
+	ctx, cancel := context.WithTimeout(gtx, config.Timeout)
+	defer cancel()
+
+	var (
+		base      = gtx.Fork(ctx, nil)
+		clientL   = len(completion.Tools)
+		server    = make(map[string]bool)
+		created   = time.Now().Unix()
+		reasoning strings.Builder
+	)
+	for _, t := range config.Tools(completion.Model, completion.Tools) {
+		server[t.GetKeyv("function").GetString("name")] = true
+		completion.Tools = append(completion.Tools, t)
+	}
+
+	for iteration := 0; ; iteration++ {
+		// 达到上限时不再提供网关工具，要求模型直接回答
+		last := iteration >= config.MaxIterations
+		if last {
+			completion.Tools = completion.Tools[:clientL]
+		}
+
+		s := &step{parent: gtx}
+		sub, end := tracer.Span(base.Fork(ctx, s), "agent.step", attribute.Int("iteration", iteration))
+		completions(sub, adapters, completion)
+		end(nil)
+		// 适配器、账号等信息带回原请求
+		gtx.Merge(sub)
+
+		if err := ctx.Err(); err != nil {
+			agentError(gtx, err, config)
+			return
+		}
+
+		calls := s.toolCalls()
+		if last || s.code >= http.StatusBadRequest || len(calls) == 0 || !isServer(server, calls) {
+			// 同时调用了客户端工具时只返回客户端工具的调用
+			if len(calls) > 0 && !isServer(server, calls) {
+				s.drop = server
+			}
+			s.forward(completion.Stream, reasoning.String())
+			return
+		}
+
+		assistant := model.Keyv[interface{}]{"role": "assistant", "content": ""}
+		var toolCalls []interface{}
+		var results []model.Keyv[interface{}]
+		for _, call := range calls {
+			toolCalls = append(toolCalls, map[string]interface{}{
+				"id":       call.id,
+				"type":     "function",
+				"function": map[string]interface{}{"name": call.name, "arguments": call.arguments},
+			})
+
+			result := execTool(ctx, config, call)
+			if err := ctx.Err(); err != nil {
+				agentError(gtx, err, config)
+				return
+			}
+			results = append(results, model.Keyv[interface{}]{
+				"role": "tool", "tool_call_id": call.id, "name": call.name, "content": result,
+			})
+
+			text := fmt.Sprintf("> %s(%s)\n%s\n\n", call.name, call.arguments, abbreviate(result, 500))
+			switch config.Steps {
+			case "reasoning":
+				if completion.Stream {
+					response.ReasonSSEResponse(gtx, completion.Model, "", text, created)
+				} else {
+					reasoning.WriteString(text)
+				}
+			case "event":
+				// 自定义事件只有 HTTP 的 SSE 响应接收
+				if completion.Stream {
+					response.Event(gtx, "agent.step", map[string]interface{}{
+						"iteration": iteration,
+						"tool":      call.name,
+						"arguments": call.arguments,
+						"result":    result,
+					})
+				}
+			}
+		}
+		assistant["tool_calls"] = toolCalls
+		completion.Messages = append(completion.Messages, assistant)
+		completion.Messages = append(completion.Messages, results...)
+		logger.Ctx(gtx).Infof("agent iteration %d executed %d tool(s)", iteration, len(calls))
+	}
+}
+
+func execTool(ctx context.Context, config *tools.Config, call agentCall) (result string) {
+	provider, _ := config.Lookup(call.name)
+	ctx, span := tracer.Start(ctx, "agent.tool", attribute.String("tool", call.name))
+
+	var args map[string]interface{}
+	err := json.Unmarshal([]byte(elseOf(call.arguments == "", "{}", call.arguments)), &args)
+	if err == nil {
+		result, err = provider.Call(ctx, args)
+	}
+	tracer.End(span, err)
+
+	if err != nil {
+		logger.Ctx(ctx).Errorf("agent tool `%s` failed: %v", call.name, err)
+		return "error: " + err.Error()
+	}
+	return
+}
+
+func agentError(gtx *inter.Context, err error, config *tools.Config) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		response.Error(gtx, http.StatusGatewayTimeout, fmt.Sprintf("agent loop timed out after %s", config.Timeout))
+		return
+	}
+	logger.Ctx(gtx).Error(err)
+}
+
+// 只有全部是网关工具时才在本地执行，否则交给客户端
+func isServer(server map[string]bool, calls []agentCall) bool {
+	for _, call := range calls {
+		if !server[call.name] {
+			return false
+		}
+	}
+	return true
+}
+
+func abbreviate(str string, n int) string {
+	runes := []rune(str)
+	if len(runes) > n {
+		return string(runes[:n]) + "..."
+	}
+	return str
+}
+
+func elseOf[T any](condition bool, t1, t2 T) T {
+	if condition {
+		return t1
+	}
+	return t2
+}
+
+type agentCall struct {
+	id        string
+	name      string
+	arguments string
+}
+
+// 截获一轮的输出：流式的文本片段直接转发，工具调用与结束片段暂存到判断之后
+type step struct {
+	parent *inter.Context
+	held   []model.Response
+	calls  []agentCall
+	done   bool
+	code   int
+	body   interface{}
+	drop   map[string]bool // 转发时去掉的工具调用
+	slots  map[string]int  // 流式片段中工具调用的编号到 calls 下标
+
+	indexes map[string]int // 流式片段中工具调用的原编号到新编号，-1 为去掉
+	kept    int
+}
+
+func (s *step) Chunk(chunk model.Response) error {
+	if len(chunk.Choices) > 0 {
+		choice := chunk.Choices[0]
+		if delta := choice.Delta; delta != nil && len(delta.ToolCalls) > 0 {
+			s.merge(delta.ToolCalls)
+		} else if delta != nil && choice.FinishReason == nil && (delta.Content != "" || delta.ReasoningContent != "") {
+			response.Event(s.parent, "", chunk)
+			return nil
+		}
+	}
+	s.held = append(s.held, chunk)
+	return nil
+}
+
+func (s *step) Done() error {
+	s.done = true
+	return nil
+}
+
+func (s *step) Complete(code int, body interface{}) error {
+	s.code, s.body = code, body
+	return nil
+}
+
+// 流式输出的工具调用参数分多个片段，按编号合并，没有编号时合并到最后一个
+func (s *step) merge(deltas []model.Keyv[interface{}]) {
+	if s.slots == nil {
+		s.slots = make(map[string]int)
+	}
+	for _, delta := range deltas {
+		key := fmt.Sprint(delta["index"])
+		call := parseCall(delta)
+		if call.name != "" {
+			if call.id == "" {
+				call.id = "call_" + common.Hex(5)
+			}
+			s.slots[key] = len(s.calls)
+			s.calls = append(s.calls, call)
+			continue
+		}
+		if i, ok := s.slots[key]; ok {
+			s.calls[i].arguments += call.arguments
+		} else if len(s.calls) > 0 {
+			s.calls[len(s.calls)-1].arguments += call.arguments
+		}
+	}
+}
+
+// 本轮的工具调用
+func (s *step) toolCalls() []agentCall {
+	if s.body == nil {
+		return s.calls
+	}
+
+	var resp model.Response
+	if marshal, err := json.Marshal(s.body); err == nil {
+		_ = json.Unmarshal(marshal, &resp)
+	}
+	if len(resp.Choices) == 0 || resp.Choices[0].Message == nil {
+		return nil
+	}
+
+	var calls []agentCall
+	for _, value := range resp.Choices[0].Message.ToolCalls {
+		call := parseCall(value)
+		if call.id == "" {
+			call.id = "call_" + common.Hex(5)
+		}
+		calls = append(calls, call)
+	}
+	return calls
+}
+
+// 本轮作为最终结果转发给客户端
+func (s *step) forward(stream bool, reasoning string) {
+	switch {
+	case s.body != nil:
+		if s.code < http.StatusBadRequest {
+			s.body = s.rewrite(s.body, reasoning)
+		}
+		response.Forward(s.parent, s.code, s.body)
+
+	case len(s.held) > 0 || s.done:
+		for _, chunk := range s.held {
+			if chunk, ok := s.filter(chunk); ok {
+				response.Event(s.parent, "", chunk)
+			}
+		}
+		if s.done {
+			response.Event(s.parent, "", "[DONE]")
+		}
+
+	default:
+		if !stream || response.NotSSEHeader(s.parent) {
+			response.Error(s.parent, -1, "empty response")
+		}
+	}
+}
+
+// 非流式响应：以 reasoning_content 附上执行过的工具，并去掉网关工具的调用
+func (s *step) rewrite(body interface{}, reasoning string) interface{} {
+	resp, ok := body.(model.Response)
+	if !ok || len(resp.Choices) == 0 || resp.Choices[0].Message == nil {
+		return body
+	}
+	message := *resp.Choices[0].Message
+	message.ReasoningContent = reasoning + message.ReasoningContent
+
+	var toolCalls []model.Keyv[interface{}]
+	for _, value := range message.ToolCalls {
+		if !s.drop[parseCall(value).name] {
+			toolCalls = append(toolCalls, value)
+		}
+	}
+	message.ToolCalls = toolCalls
+	resp.Choices[0].Message = &message
+	return resp
+}
+
+// 流式片段去掉网关工具的调用，保留的调用重新编号
+func (s *step) filter(chunk model.Response) (model.Response, bool) {
+	if len(s.drop) == 0 || len(chunk.Choices) == 0 || chunk.Choices[0].Delta == nil || len(chunk.Choices[0].Delta.ToolCalls) == 0 {
+		return chunk, true
+	}
+
+	delta := *chunk.Choices[0].Delta
+	var toolCalls []model.Keyv[interface{}]
+	for _, value := range delta.ToolCalls {
+		key := fmt.Sprint(value["index"])
+		if name := parseCall(value).name; name != "" {
+			if s.indexes == nil {
+				s.indexes = make(map[string]int)
+			}
+			s.indexes[key] = -1
+			if !s.drop[name] {
+				s.indexes[key] = s.kept
+				s.kept++
+			}
+		}
+		if index, ok := s.indexes[key]; ok && index >= 0 {
+			value = value.Clone()
+			value["index"] = index
+			toolCalls = append(toolCalls, value)
+		}
+	}
+	if len(toolCalls) == 0 && delta.Content == "" && delta.ReasoningContent == "" {
+		return chunk, false
+	}
+
+	delta.ToolCalls = toolCalls
+	choices := slices.Clone(chunk.Choices)
+	choices[0].Delta = &delta
+	chunk.Choices = choices
+	return chunk, true
+}
+
+// 适配器输出的 function 字段类型不一，统一解析
+func parseCall(value model.Keyv[interface{}]) (call agentCall) {
+	call.id = value.GetString("id")
+	fn, _ := value.Get("function")
+	marshal, _ := json.Marshal(fn)
+	var f struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if json.Unmarshal(marshal, &f) != nil {
+		return
+	}
+	call.name = f.Name
+	if err := json.Unmarshal(f.Arguments, &call.arguments); err != nil {
+		call.arguments = string(f.Arguments)
+	}
+	return
+}
@@ -0,0 +1,244 @@
+package dispatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"chatgpt-adapter/core/cache"
+	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/gin/inter"
+	"chatgpt-adapter/core/gin/model"
+	"chatgpt-adapter/core/gin/response"
+	"chatgpt-adapter/core/usage"
+)
+
+// 响应缓存：命中时按请求的 stream 以 json 或模拟的 SSE 返回；
+// 未命中时截获本次输出并照常转发，成功完成后写入缓存。
+// 请求头 `Cache-Control: no-cache` 跳过读取并刷新缓存，`no-store` 既不读取也不写入
+func cachedCompletions(gtx *inter.Context, adapters []inter.Adapter, completion model.Completion, rc *cache.ResponseCache) {
+	var (
+		control = strings.ToLower(gtx.GetHeader("Cache-Control"))
+		noCache = strings.Contains(control, "no-cache")
+		noStore = strings.Contains(control, "no-store")
+		key     = cache.ResponseKey(completion, common.GetGinToken(gtx))
+	)
+
+	if !noCache && !noStore {
+		if resp, ok := rc.Get(key); ok {
+			gtx.Response.Set("X-Cache", "HIT")
+			gtx.Set(usageSource, usage.SourceCache)
+			common.SetGinCompletion(gtx, completion)
+			replay(gtx, completion, resp)
+			return
+		}
+	}
+
+	if noCache || noStore {
+		gtx.Response.Set("X-Cache", "BYPASS")
+	} else {
+		gtx.Response.Set("X-Cache", "MISS")
+	}
+	if noStore {
+		upstream(gtx, adapters, completion)
+		return
+	}
+
+	t := &tee{parent: gtx}
+	sub := gtx.Fork(gtx.Context, t)
+	upstream(sub, adapters, completion)
+
+	// 适配器、账号等信息带回原请求，供访问日志使用
+	gtx.Merge(sub)
+	if resp, ok := t.response(); ok {
+		rc.Set(key, resp)
+	}
+}
+
+// 以缓存的完整响应作答
+func replay(gtx *inter.Context, completion model.Completion, resp model.Response) {
+	created := time.Now().Unix()
+	resp.Id = "chatcmpl-" + common.Hex(12)
+	resp.Created = created
+	if !completion.Stream {
+		response.Forward(gtx, http.StatusOK, resp)
+		return
+	}
+
+	if len(resp.Choices) == 0 || resp.Choices[0].Message == nil {
+		response.Event(gtx, "", "[DONE]")
+		return
+	}
+	message := resp.Choices[0].Message
+	chunk := func(delta map[string]interface{}, finish interface{}, usage map[string]interface{}) {
+		var value model.Response
+		marshal, _ := json.Marshal(map[string]interface{}{
+			"id":      resp.Id,
+			"object":  "chat.completion.chunk",
+			"created": created,
+			"model":   resp.Model,
+			"choices": []interface{}{map[string]interface{}{"index": 0, "delta": delta, "finish_reason": finish}},
+			"usage":   usage,
+		})
+		_ = json.Unmarshal(marshal, &value)
+		response.Event(gtx, "", value)
+	}
+
+	if message.ReasoningContent != "" {
+		chunk(map[string]interface{}{"role": "assistant", "reasoning_content": message.ReasoningContent}, nil, nil)
+	}
+	if message.Content != "" {
+		chunk(map[string]interface{}{"role": "assistant", "content": message.Content}, nil, nil)
+	}
+	if len(message.ToolCalls) > 0 {
+		var toolCalls []interface{}
+		for i, call := range message.ToolCalls {
+			call = call.Clone()
+			call["index"] = i
+			toolCalls = append(toolCalls, call)
+		}
+		chunk(map[string]interface{}{"role": "assistant", "tool_calls": toolCalls}, nil, nil)
+	}
+
+	finish := "stop"
+	if reason := resp.Choices[0].FinishReason; reason != nil {
+		finish = *reason
+	}
+	chunk(map[string]interface{}{}, finish, resp.Usage)
+	response.Event(gtx, "", "[DONE]")
+}
+
+// 转发输出的同时汇总为完整响应
+type tee struct {
+	parent *inter.Context
+	failed bool
+
+	id, model string
+	content   strings.Builder
+	reasoning strings.Builder
+	toolCalls []agentCall
+	slots     map[string]int // 流式片段中工具调用的编号到 toolCalls 下标
+	finish    *string
+	usage     map[string]interface{}
+	done      bool
+	body      interface{}
+}
+
+func (t *tee) Chunk(chunk model.Response) error {
+	t.merge(chunk)
+	response.Event(t.parent, "", chunk)
+	return nil
+}
+
+func (t *tee) Done() error {
+	t.done = true
+	response.Event(t.parent, "", "[DONE]")
+	return nil
+}
+
+func (t *tee) Complete(code int, body interface{}) error {
+	if code >= http.StatusBadRequest {
+		t.failed = true
+	} else {
+		t.body = body
+	}
+	response.Forward(t.parent, code, body)
+	return nil
+}
+
+func (t *tee) merge(chunk model.Response) {
+	if chunk.Error != nil {
+		t.failed = true
+	}
+	if chunk.Id != "" {
+		t.id = chunk.Id
+	}
+	if chunk.Model != "" {
+		t.model = chunk.Model
+	}
+	if chunk.Usage != nil {
+		t.usage = chunk.Usage
+	}
+	if len(chunk.Choices) == 0 {
+		return
+	}
+
+	choice := chunk.Choices[0]
+	if choice.FinishReason != nil {
+		t.finish = choice.FinishReason
+	}
+	delta := choice.Delta
+	if delta == nil {
+		return
+	}
+	t.content.WriteString(delta.Content)
+	t.reasoning.WriteString(delta.ReasoningContent)
+
+	if t.slots == nil {
+		t.slots = make(map[string]int)
+	}
+	for _, value := range delta.ToolCalls {
+		key := fmt.Sprint(value["index"])
+		call := parseCall(value)
+		if i, ok := t.slots[key]; ok {
+			t.toolCalls[i].arguments += call.arguments
+			continue
+		}
+		t.slots[key] = len(t.toolCalls)
+		t.toolCalls = append(t.toolCalls, call)
+	}
+}
+
+// 成功完成的输出，流式输出须以 [DONE] 结束
+func (t *tee) response() (resp model.Response, ok bool) {
+	if t.failed {
+		return
+	}
+
+	if t.body != nil {
+		marshal, err := json.Marshal(t.body)
+		if err != nil || json.Unmarshal(marshal, &resp) != nil {
+			return
+		}
+		return resp, len(resp.Choices) > 0 && resp.Choices[0].Message != nil
+	}
+	if !t.done || (t.content.Len() == 0 && t.reasoning.Len() == 0 && len(t.toolCalls) == 0) {
+		return
+	}
+
+	var toolCalls []interface{}
+	for _, call := range t.toolCalls {
+		toolCalls = append(toolCalls, map[string]interface{}{
+			"id":       call.id,
+			"type":     "function",
+			"function": map[string]interface{}{"name": call.name, "arguments": call.arguments},
+		})
+	}
+
+	finish := "stop"
+	if len(toolCalls) > 0 {
+		finish = "tool_calls"
+	}
+	if t.finish != nil {
+		finish = *t.finish
+	}
+	marshal, _ := json.Marshal(map[string]interface{}{
+		"id":     t.id,
+		"object": "chat.completion",
+		"model":  t.model,
+		"choices": []interface{}{map[string]interface{}{
+			"index": 0,
+			"message": map[string]interface{}{
+				"role":              "assistant",
+				"content":           t.content.String(),
+				"reasoning_content": t.reasoning.String(),
+				"tool_calls":        toolCalls,
+			},
+			"finish_reason": finish,
+		}},
+		"usage": t.usage,
+	})
+	return resp, json.Unmarshal(marshal, &resp) == nil
+}
@@ -0,0 +1,92 @@
+package dispatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"chatgpt-adapter/core/gin/inter"
+	"chatgpt-adapter/core/gin/model"
+	"chatgpt-adapter/core/gin/response"
+)
+
+func capabilities(extension inter.Adapter, mod string) *model.Capabilities {
+	if capable, ok := extension.(inter.Capable); ok {
+		return capable.Capabilities(mod)
+	}
+	return nil
+}
+
+// 按能力声明提前拒绝不支持的请求，避免转发后才失败
+func checkCompletion(caps *model.Capabilities, completion model.Completion) error {
+	if caps == nil {
+		return nil
+	}
+
+	if len(caps.Output) > 0 && !caps.Produces(model.ModalityText) {
+		return fmt.Errorf("model '%s' does not support chat completions", completion.Model)
+	}
+
+	if caps.Tools == "" && len(completion.Tools) > 0 && completion.ToolChoice != "none" {
+		return fmt.Errorf("model '%s' does not support tools", completion.Model)
+	}
+
+	if len(caps.Input) > 0 && !caps.Accepts(model.ModalityImage) && hasImage(completion.Messages) {
+		return fmt.Errorf("model '%s' does not support image input", completion.Model)
+	}
+
+	if caps.MaxOutput > 0 && completion.MaxTokens > caps.MaxOutput {
+		return fmt.Errorf("max_tokens %d exceeds the limit of model '%s': %d", completion.MaxTokens, completion.Model, caps.MaxOutput)
+	}
+
+	if caps.ContextWindow > 0 {
+		if tokens := promptTokens(completion); tokens+completion.MaxTokens > caps.ContextWindow {
+			return fmt.Errorf("prompt (~%d tokens) plus max_tokens %d exceeds the context window of model '%s': %d",
+				tokens, completion.MaxTokens, completion.Model, caps.ContextWindow)
+		}
+	}
+	return nil
+}
+
+// 估算提示词的 token 数：消息文本与工具定义
+func promptTokens(completion model.Completion) int {
+	var buf strings.Builder
+	for _, message := range completion.Messages {
+		if message.IsString("content") {
+			buf.WriteString(message.GetString("content"))
+		}
+		for _, it := range message.GetSlice("content") {
+			if part, ok := it.(map[string]interface{}); ok && part["type"] == "text" {
+				text, _ := part["text"].(string)
+				buf.WriteString(text)
+			}
+		}
+		buf.WriteByte('\n')
+	}
+	if len(completion.Tools) > 0 {
+		data, _ := json.Marshal(completion.Tools)
+		buf.Write(data)
+	}
+	return response.CalcTokens(buf.String())
+}
+
+func checkOutput(caps *model.Capabilities, mod, modality, feature string) error {
+	if caps == nil || len(caps.Output) == 0 || caps.Produces(modality) {
+		return nil
+	}
+	return fmt.Errorf("model '%s' does not support %s", mod, feature)
+}
+
+func hasImage(messages []model.Keyv[interface{}]) bool {
+	for _, message := range messages {
+		if !message.IsSlice("content") {
+			continue
+		}
+		for _, it := range message.GetSlice("content") {
+			if part, ok := it.(map[string]interface{}); ok && part["type"] == "image_url" {
+				return true
+			}
+		}
+	}
+	return false
+}
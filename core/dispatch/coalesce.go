@@ -0,0 +1,288 @@
+package dispatch
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+	"sync/atomic"
+
+	"chatgpt-adapter/core/cache"
+	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/common/inited"
+	"chatgpt-adapter/core/common/vars"
+	"chatgpt-adapter/core/gin/inter"
+	"chatgpt-adapter/core/gin/model"
+	"chatgpt-adapter/core/gin/response"
+	"chatgpt-adapter/core/logger"
+	"chatgpt-adapter/core/usage"
+	"github.com/iocgo/sdk/env"
+)
+
+// 合并并发的相同请求：同一请求在上游执行期间，后到的请求不再请求上游，而是接收同一份输出（包括已错过的部分）
+//
+//	coalesce:
+//	  models: [ "custom/*" ] # 启用的模型，glob
+var (
+	coalesceModels atomic.Pointer[[]string]
+
+	flightsMu sync.Mutex
+	flights   = make(map[string]*flight)
+
+	statsMu sync.Mutex
+	stats   = make(map[string]*CoalesceStat)
+)
+
+// 按模型统计
+type CoalesceStat struct {
+	Requests int64 `json:"requests"` // 启用合并的请求数
+	Upstream int64 `json:"upstream"` // 实际请求上游的次数
+	Saved    int64 `json:"saved"`    // 合并后省去的上游请求
+}
+
+// 一次正在执行的上游请求
+type flight struct {
+	mu      sync.Mutex
+	events  []flightEvent
+	done    bool
+	wake    chan struct{} // 有新的输出时关闭并替换
+	refs    int           // 仍在等待输出的请求数
+	ctx     context.Context
+	cancel  context.CancelFunc
+	adapter string
+	key     string
+}
+
+type flightEvent struct {
+	chunk *model.Response
+	done  bool // [DONE]
+	code  int  // 完整响应
+	body  interface{}
+}
+
+func init() {
+	load := func(env *env.Environment) ([]string, error) {
+		models := env.GetStringSlice("coalesce.models")
+		for _, pattern := range models {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("coalesce.models: bad pattern '%s'", pattern)
+			}
+		}
+		return models, nil
+	}
+
+	inited.AddInitialized(func(env *env.Environment) {
+		models, err := load(env)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		coalesceModels.Store(&models)
+	})
+	inited.AddReloaded(func(env *env.Environment) (func(), error) {
+		models, err := load(env)
+		if err != nil {
+			return nil, err
+		}
+		return func() { coalesceModels.Store(&models) }, nil
+	})
+}
+
+// 各模型的合并统计
+func CoalesceStats() map[string]CoalesceStat {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	result := make(map[string]CoalesceStat, len(stats))
+	for mod, stat := range stats {
+		result[mod] = *stat
+	}
+	return result
+}
+
+func coalesceOf(mod string) bool {
+	models := coalesceModels.Load()
+	if models == nil {
+		return false
+	}
+	for _, pattern := range *models {
+		if ok, _ := path.Match(pattern, mod); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func count(mod string, f func(stat *CoalesceStat)) {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	stat, ok := stats[mod]
+	if !ok {
+		stat = new(CoalesceStat)
+		stats[mod] = stat
+	}
+	f(stat)
+}
+
+// 请求上游，模型启用了合并时与进行中的相同请求共用输出
+func upstream(gtx *inter.Context, adapters []inter.Adapter, completion model.Completion) {
+	if !coalesceOf(completion.Model) {
+		completions(gtx, adapters, completion)
+		return
+	}
+
+	// 不同凭证可能对应不同的上游账号，不合并
+	key := fmt.Sprintf("%s:%v", cache.ResponseKey(completion, common.GetGinToken(gtx)), completion.Stream)
+
+	flightsMu.Lock()
+	f, exists := flights[key]
+	if exists {
+		// 已被取消的请求不再加入，重新发起
+		f.mu.Lock()
+		if exists = f.refs > 0; exists {
+			f.refs++
+		}
+		f.mu.Unlock()
+	}
+	if !exists {
+		// 发起请求的客户端断开后，仍有其它请求等待时继续执行；cancel 在发布前设置，follower 的 leave 可能先于 lead 执行
+		ctx, cancel := context.WithCancel(context.WithoutCancel(gtx.Context))
+		f = &flight{ctx: ctx, cancel: cancel, wake: make(chan struct{}), refs: 1, key: key}
+		flights[key] = f
+	}
+	flightsMu.Unlock()
+
+	count(completion.Model, func(stat *CoalesceStat) {
+		stat.Requests++
+		if exists {
+			stat.Saved++
+		} else {
+			stat.Upstream++
+		}
+	})
+
+	if exists {
+		logger.Ctx(gtx).Infof("coalesced with an in-flight request: %s", completion.Model)
+		gtx.Response.Set("X-Coalesced", "true")
+		gtx.Set(usageSource, usage.SourceCoalesced)
+		common.SetGinCompletion(gtx, completion)
+		f.follow(gtx)
+		return
+	}
+
+	defer f.remove()
+	f.lead(gtx, adapters, completion)
+}
+
+// 从进行中的请求中移除，已被新的请求替换时不处理
+func (f *flight) remove() {
+	flightsMu.Lock()
+	defer flightsMu.Unlock()
+	if flights[f.key] == f {
+		delete(flights, f.key)
+	}
+}
+
+// 执行上游请求，输出转发给自己的同时记录下来供其它请求读取
+func (f *flight) lead(gtx *inter.Context, adapters []inter.Adapter, completion model.Completion) {
+	defer f.cancel()
+	stop := context.AfterFunc(gtx.Context, f.leave)
+	defer stop()
+
+	// 上游 panic 时同样结束，避免等待的请求一直阻塞
+	var adapter string
+	defer func() {
+		f.mu.Lock()
+		f.done = true
+		f.adapter = adapter
+		close(f.wake)
+		f.mu.Unlock()
+	}()
+
+	sub := gtx.Fork(f.ctx, &flightSink{f, gtx})
+	completions(sub, adapters, completion)
+	gtx.Merge(sub)
+	adapter = sub.GetString(vars.GinAdapter)
+}
+
+// 依次输出已记录的内容，等待后续输出直到上游请求结束
+func (f *flight) follow(gtx *inter.Context) {
+	defer f.leave()
+	ctx := gtx
+	for i := 0; ; {
+		f.mu.Lock()
+		events, done, wake := f.events[i:], f.done, f.wake
+		f.mu.Unlock()
+
+		for _, event := range events {
+			event.emit(gtx)
+		}
+		i += len(events)
+		if done {
+			gtx.Set(vars.GinAdapter, f.adapter)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		}
+	}
+}
+
+// 没有请求在等待输出时取消上游请求
+func (f *flight) leave() {
+	f.mu.Lock()
+	f.refs--
+	cancelled := f.refs <= 0 && !f.done
+	f.mu.Unlock()
+	if cancelled {
+		f.cancel()
+		// 取消后不再接受新的请求加入
+		f.remove()
+	}
+}
+
+func (f *flight) append(event flightEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, event)
+	close(f.wake)
+	f.wake = make(chan struct{})
+}
+
+func (event flightEvent) emit(gtx *inter.Context) {
+	switch {
+	case event.chunk != nil:
+		response.Event(gtx, "", *event.chunk)
+	case event.done:
+		response.Event(gtx, "", "[DONE]")
+	default:
+		response.Forward(gtx, event.code, event.body)
+	}
+}
+
+type flightSink struct {
+	f      *flight
+	parent *inter.Context
+}
+
+func (s *flightSink) Chunk(chunk model.Response) error {
+	event := flightEvent{chunk: &chunk}
+	s.f.append(event)
+	event.emit(s.parent)
+	return nil
+}
+
+func (s *flightSink) Done() error {
+	event := flightEvent{done: true}
+	s.f.append(event)
+	event.emit(s.parent)
+	return nil
+}
+
+func (s *flightSink) Complete(code int, body interface{}) error {
+	event := flightEvent{code: code, body: body}
+	s.f.append(event)
+	event.emit(s.parent)
+	return nil
+}
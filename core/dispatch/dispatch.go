@@ -0,0 +1,212 @@
+package dispatch
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"reflect"
+	"time"
+
+	"chatgpt-adapter/core/cache"
+	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/common/toolcall"
+	"chatgpt-adapter/core/common/vars"
+	"chatgpt-adapter/core/gin/inter"
+	"chatgpt-adapter/core/gin/model"
+	"chatgpt-adapter/core/gin/response"
+	"chatgpt-adapter/core/logger"
+	"chatgpt-adapter/core/plugin"
+	"chatgpt-adapter/core/tools"
+	"chatgpt-adapter/core/tracer"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// 请求分发流程，HTTP 路由与库调用（Dispatcher）共用
+//
+// 适配器只通过 response 包输出到 gtx.Sink：HTTP 路由的 sink 写入响应，库调用的 sink 由调用方提供
+func Completions(gtx *inter.Context, adapters []inter.Adapter, completion model.Completion) {
+	defer record(gtx, completion.Model)()
+	if completion.NormalizeFunctions() {
+		common.SetGinLegacyFunctions(gtx, true)
+	}
+
+	// 模型启用了网关执行的工具
+	if config := tools.Of(completion.Model); config != nil {
+		defer config.Release()
+		agentLoop(gtx, adapters, completion, config)
+		return
+	}
+
+	// 开启了响应缓存，网关执行工具的请求不缓存
+	if rc := cache.Responses(); rc != nil {
+		defer rc.Release()
+		if rc.Match(completion) {
+			cachedCompletions(gtx, adapters, completion, rc)
+			return
+		}
+	}
+	upstream(gtx, adapters, completion)
+}
+
+// 单次请求的分发
+func completions(gtx *inter.Context, adapters []inter.Adapter, completion model.Completion) {
+	completion, err := plugin.Request(gtx, completion)
+	if err != nil {
+		response.Error(gtx, http.StatusBadRequest, err)
+		return
+	}
+
+	common.SetGinCompletion(gtx, completion)
+	logger.Ctx(gtx).Infof("curr model: %s", completion.Model)
+	if !response.MessageValidator(gtx) {
+		return
+	}
+
+	for _, extension := range adapters {
+		ok, err := extension.Match(gtx, completion.Model)
+		if err != nil {
+			response.Error(gtx, -1, err)
+			return
+		}
+		if !ok {
+			continue
+		}
+
+		if err = checkCompletion(capabilities(extension, completion.Model), completion); err != nil {
+			response.Error(gtx, http.StatusBadRequest, err)
+			return
+		}
+
+		name := AdapterName(extension)
+		gtx.Set(vars.GinAdapter, name)
+		common.SetGinMatchers(gtx, response.NewMatchers(gtx, func(t byte, str string) {
+			if completion.Stream && t == 0 {
+				response.SSEResponse(gtx, "matcher", str, time.Now().Unix())
+			}
+			if completion.Stream && t == 1 {
+				response.ReasonSSEResponse(gtx, "matcher", "", str, time.Now().Unix())
+			}
+		}))
+
+		sub, end := tracer.Span(gtx, "adapter.messages", attribute.String("adapter", name), attribute.String("model", completion.Model))
+		messages, err := extension.HandleMessages(sub, completion)
+		end(err)
+		if err != nil {
+			logger.Ctx(gtx).Error("Error handling messages: ", err)
+			response.Error(gtx, 500, err)
+			return
+		}
+
+		if common.GetGinTokens(gtx) == 0 {
+			calcTokens(gtx, messages)
+		}
+
+		completion.Messages = messages
+		common.SetGinCompletion(gtx, completion)
+
+		if toolcall.NeedExec(gtx) {
+			sub, end = tracer.Span(gtx, "adapter.toolchoice", attribute.String("adapter", name))
+			ok, err = extension.ToolChoice(sub)
+			end(err)
+			if err != nil {
+				response.Error(gtx, -1, err)
+				return
+			}
+			if ok {
+				return
+			}
+		}
+
+		sub, end = tracer.Span(gtx, "adapter.completion", attribute.String("adapter", name), attribute.Bool("stream", completion.Stream))
+		err = extension.Completion(sub)
+		end(err)
+		if err != nil {
+			response.Error(gtx, -1, err)
+		}
+		return
+	}
+	response.Error(gtx, -1, fmt.Sprintf("model '%s' is not not yet supported", completion.Model))
+}
+
+func Embeddings(gtx *inter.Context, adapters []inter.Adapter, embed model.Embed) {
+	defer record(gtx, embed.Model)()
+	gtx.Set(vars.GinEmbedding, embed)
+	logger.Ctx(gtx).Infof("curr model: %s", embed.Model)
+	for _, extension := range adapters {
+		ok, err := extension.Match(gtx, embed.Model)
+		if err != nil {
+			response.Error(gtx, -1, err)
+			return
+		}
+		if ok {
+			if err = checkOutput(capabilities(extension, embed.Model), embed.Model, model.ModalityEmbedding, "embeddings"); err != nil {
+				response.Error(gtx, http.StatusBadRequest, err)
+				return
+			}
+			gtx.Set(vars.GinAdapter, AdapterName(extension))
+			if err = extension.Embedding(gtx); err != nil {
+				response.Error(gtx, -1, err)
+			}
+			return
+		}
+	}
+	response.Error(gtx, -1, fmt.Sprintf("model '%s' is not not yet supported", embed.Model))
+}
+
+func Generations(gtx *inter.Context, adapters []inter.Adapter, generation model.Generation) {
+	defer record(gtx, generation.Model)()
+	gtx.Set(vars.GinGeneration, generation)
+	for _, extension := range adapters {
+		ok, err := extension.Match(gtx, generation.Model)
+		if err != nil {
+			response.Error(gtx, 500, err)
+			return
+		}
+		if ok {
+			if err = checkOutput(capabilities(extension, generation.Model), generation.Model, model.ModalityImage, "image generation"); err != nil {
+				response.Error(gtx, http.StatusBadRequest, err)
+				return
+			}
+			gtx.Set(vars.GinAdapter, AdapterName(extension))
+			if err = extension.Generation(gtx); err != nil {
+				response.Error(gtx, -1, err)
+			}
+			return
+		}
+	}
+	response.Error(gtx, -1, fmt.Sprintf("model '%s' is not not yet supported", generation.Model))
+}
+
+func Models(adapters []inter.Adapter) []model.Model {
+	models := make([]model.Model, 0)
+	for _, extension := range adapters {
+		for _, mod := range extension.Models() {
+			if mod.Capabilities == nil {
+				mod.Capabilities = capabilities(extension, mod.Id)
+			}
+			models = append(models, mod)
+		}
+	}
+	return models
+}
+
+// 适配器名称，取实现所在的包名
+func AdapterName(extension inter.Adapter) string {
+	t := reflect.TypeOf(extension)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return path.Base(t.PkgPath())
+}
+
+func calcTokens(gtx *inter.Context, messages []model.Keyv[interface{}]) {
+	tokens := 0
+	for _, message := range messages {
+		if !message.IsString("content") {
+			continue
+		}
+		value := message.GetString("content")
+		tokens += response.CalcTokens(value)
+	}
+	common.SetGinTokens(gtx, tokens)
+}
@@ -0,0 +1,175 @@
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/gin/inter"
+	"chatgpt-adapter/core/gin/model"
+)
+
+// 以库的形式调用适配器，无需启动 HTTP 服务
+//
+//	inited.Initialized(env.Env) // 加载账号池等配置
+//	d := dispatch.New(grok.New(env.Env), v1.New(env.Env))
+//	resp, err := d.Complete(dispatch.Request{Context: ctx, Token: "sk-xxx"}, completion)
+type Dispatcher struct {
+	adapters []inter.Adapter
+}
+
+// 与传输方式无关的请求参数
+type Request struct {
+	Context context.Context
+	Token   string      // 等价于 `Authorization: Bearer <Token>`
+	Header  http.Header // 可选，部分适配器读取请求头
+}
+
+// 适配器返回的错误响应
+type Error struct {
+	Code    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d: %s", e.Code, e.Message)
+}
+
+func New(adapters ...inter.Adapter) *Dispatcher {
+	return &Dispatcher{adapters}
+}
+
+func (d *Dispatcher) Models() []model.Model {
+	return Models(d.adapters)
+}
+
+// 流式调用，片段依次写入 sink
+func (d *Dispatcher) Stream(req Request, completion model.Completion, sink inter.Sink) error {
+	completion.Stream = true
+	c := &capture{sink: sink}
+	Completions(d.context(req, c), d.adapters, completion)
+	if err := c.err(); err != nil {
+		return err
+	}
+	if !c.streamed && c.body == nil {
+		return errors.New("empty response")
+	}
+	return nil
+}
+
+// 非流式调用
+func (d *Dispatcher) Complete(req Request, completion model.Completion) (*model.Response, error) {
+	completion.Stream = false
+	c := new(capture)
+	Completions(d.context(req, c), d.adapters, completion)
+	if err := c.err(); err != nil {
+		return nil, err
+	}
+
+	var resp model.Response
+	if err := c.decode(&resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// 返回适配器原始响应体
+func (d *Dispatcher) Embedding(req Request, embed model.Embed) (json.RawMessage, error) {
+	c := new(capture)
+	Embeddings(d.context(req, c), d.adapters, embed)
+	if err := c.err(); err != nil {
+		return nil, err
+	}
+
+	var raw json.RawMessage
+	return raw, c.decode(&raw)
+}
+
+func (d *Dispatcher) Generation(req Request, generation model.Generation) (json.RawMessage, error) {
+	c := new(capture)
+	Generations(d.context(req, c), d.adapters, generation)
+	if err := c.err(); err != nil {
+		return nil, err
+	}
+
+	var raw json.RawMessage
+	return raw, c.decode(&raw)
+}
+
+// 构造适配器上下文，请求头与凭证与 HTTP 路由一致
+func (d *Dispatcher) context(req Request, sink inter.Sink) *inter.Context {
+	header := make(http.Header)
+	if req.Header != nil {
+		header = req.Header.Clone()
+	}
+	if req.Token != "" {
+		header.Set("Authorization", "Bearer "+req.Token)
+	}
+
+	ctx := inter.NewContext(req.Context, header, sink)
+	common.SetGinToken(ctx, req.Token)
+	return ctx
+}
+
+// 截获完整响应与错误，流式片段转发给调用方
+type capture struct {
+	sink     inter.Sink
+	streamed bool
+	code     int
+	body     interface{}
+}
+
+func (c *capture) Chunk(chunk model.Response) error {
+	c.streamed = true
+	if c.sink == nil {
+		return nil
+	}
+	return c.sink.Chunk(chunk)
+}
+
+func (c *capture) Done() error {
+	c.streamed = true
+	if c.sink == nil {
+		return nil
+	}
+	return c.sink.Done()
+}
+
+func (c *capture) Complete(code int, body interface{}) error {
+	c.code, c.body = code, body
+	return nil
+}
+
+func (c *capture) err() error {
+	code, body := c.code, c.body
+	if body == nil || code < http.StatusBadRequest {
+		return nil
+	}
+
+	var value struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if marshal, err := json.Marshal(body); err == nil {
+		_ = json.Unmarshal(marshal, &value)
+	}
+	if value.Error.Message == "" {
+		value.Error.Message = http.StatusText(code)
+	}
+	return &Error{code, value.Error.Message}
+}
+
+func (c *capture) decode(v interface{}) error {
+	if c.body == nil {
+		return errors.New("empty response")
+	}
+	marshal, err := json.Marshal(c.body)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(marshal, v)
+}
@@ -0,0 +1,77 @@
+package dispatch
+
+import (
+	"time"
+
+	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/common/vars"
+	"chatgpt-adapter/core/gin/inter"
+	"chatgpt-adapter/core/gin/model"
+	"chatgpt-adapter/core/logger"
+	"chatgpt-adapter/core/usage"
+)
+
+// 记录的来源，缺省为 upstream
+const usageSource = "__usage-source__"
+
+// 写入用量账本，未开启时不记录；测试时替换
+var addUsage = usage.Add
+
+// 在分发入口记录用量，HTTP 路由与库调用均会记录；返回的函数在请求结束时调用
+func record(gtx *inter.Context, mod string) func() {
+	start := time.Now()
+	// 适配器取号时会把令牌替换为账号凭证，在请求开始时记下客户端的密钥
+	key := usage.KeyLabel(common.GetGinToken(gtx))
+	return func() {
+		// 插件可能改写模型
+		if completion, ok := common.GetGinValue[model.Completion](gtx, vars.GinCompletion); ok {
+			mod = completion.Model
+		}
+
+		status := gtx.GetInt(vars.GinStatus)
+		if status == 0 {
+			status = 200
+		}
+		source := gtx.GetString(usageSource)
+		if source == "" {
+			source = usage.SourceUpstream
+		}
+
+		value, _ := gtx.Get(vars.GinCompletionUsage)
+		tokens, _ := value.(map[string]interface{})
+		addUsage(usage.Record{
+			Time:             start,
+			RequestId:        logger.RequestId(gtx),
+			Key:              key,
+			Model:            mod,
+			Adapter:          gtx.GetString(vars.GinAdapter),
+			Account:          gtx.GetString(vars.GinPoolEntry),
+			PromptTokens:     tokenOf(tokens, "prompt_tokens"),
+			CompletionTokens: tokenOf(tokens, "completion_tokens"),
+			ReasoningTokens:  reasoningTokens(tokens),
+			TotalTokens:      tokenOf(tokens, "total_tokens"),
+			Latency:          time.Since(start).Milliseconds(),
+			Status:           status,
+			Source:           source,
+		})
+	}
+}
+
+func reasoningTokens(tokens map[string]interface{}) int {
+	if details, ok := tokens["completion_tokens_details"].(map[string]interface{}); ok {
+		return tokenOf(details, "reasoning_tokens")
+	}
+	return tokenOf(tokens, "reasoning_tokens")
+}
+
+func tokenOf(tokens map[string]interface{}, key string) int {
+	switch value := tokens[key].(type) {
+	case int:
+		return value
+	case int64:
+		return int(value)
+	case float64:
+		return int(value)
+	}
+	return 0
+}
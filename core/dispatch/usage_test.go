@@ -0,0 +1,52 @@
+package dispatch
+
+import (
+	"context"
+	"testing"
+
+	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/gin/inter"
+	"chatgpt-adapter/core/gin/model"
+	"chatgpt-adapter/core/gin/response"
+	"chatgpt-adapter/core/usage"
+	"github.com/iocgo/sdk/env"
+	"github.com/spf13/viper"
+)
+
+// 模拟从账号池取号：请求期间把令牌替换为账号凭证
+type poolAdapter struct{ inter.BaseAdapter }
+
+func (poolAdapter) Match(*inter.Context, string) (bool, error) { return true, nil }
+
+func (poolAdapter) HandleMessages(_ *inter.Context, completion model.Completion) ([]model.Keyv[interface{}], error) {
+	return completion.Messages, nil
+}
+
+func (poolAdapter) Completion(ctx *inter.Context) error {
+	common.SetGinToken(ctx, "pool-cookie")
+	response.Response(ctx, "pool/m", "hello")
+	return nil
+}
+
+func TestRecordClientKey(t *testing.T) {
+	env.Env = &env.Environment{Viper: viper.New()}
+
+	var records []usage.Record
+	addUsage = func(record usage.Record) { records = append(records, record) }
+	defer func() { addUsage = usage.Add }()
+
+	completion := model.Completion{
+		Model:    "pool/m",
+		Messages: []model.Keyv[interface{}]{{"role": "user", "content": "hi"}},
+	}
+	if _, err := New(poolAdapter{}).Complete(Request{Context: context.Background(), Token: "sk-client"}, completion); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 1 {
+		t.Fatalf("records = %d, want 1", len(records))
+	}
+	if want := usage.KeyLabel("sk-client"); records[0].Key != want {
+		t.Errorf("key = %s, want %s", records[0].Key, want)
+	}
+}
@@ -0,0 +1,94 @@
+package gin
+
+import (
+	"time"
+
+	"chatgpt-adapter/core/common/vars"
+	"chatgpt-adapter/core/gin/model"
+	"chatgpt-adapter/core/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// 记录首字节写出时间
+type ttfbWriter struct {
+	gin.ResponseWriter
+	first time.Time
+}
+
+func (w *ttfbWriter) Write(data []byte) (int, error) {
+	if w.first.IsZero() {
+		w.first = time.Now()
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *ttfbWriter) WriteString(s string) (int, error) {
+	if w.first.IsZero() {
+		w.first = time.Now()
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+// 请求id & 访问日志
+func access(gtx *gin.Context) {
+	id := gtx.Request.Header.Get("X-Request-Id")
+	if id == "" || len(id) > 64 {
+		id = uuid.NewString()
+	}
+
+	gtx.Set(vars.GinRequestId, id)
+	gtx.Header("X-Request-Id", id)
+	gtx.Request = gtx.Request.WithContext(logger.WithRequestId(gtx.Request.Context(), id))
+
+	start := time.Now()
+	writer := &ttfbWriter{ResponseWriter: gtx.Writer}
+	gtx.Writer = writer
+
+	gtx.Next()
+
+	if gtx.Request.Method == "OPTIONS" {
+		return
+	}
+
+	fields := map[string]interface{}{
+		"request_id": id,
+		"method":     gtx.Request.Method,
+		"path":       gtx.Request.URL.Path,
+		"key":        logger.Label(clientKey(gtx)),
+		"model":      requestModel(gtx),
+		"adapter":    gtx.GetString(vars.GinAdapter),
+		"pool_entry": gtx.GetString(vars.GinPoolEntry),
+		"status":     gtx.Writer.Status(),
+		"latency_ms": time.Since(start).Milliseconds(),
+	}
+
+	if !writer.first.IsZero() {
+		fields["ttft_ms"] = writer.first.Sub(start).Milliseconds()
+	}
+
+	tokens, _ := gtx.Value(vars.GinCompletionUsage).(map[string]interface{})
+	if tokens != nil {
+		fields["prompt_tokens"] = tokens["prompt_tokens"]
+		fields["completion_tokens"] = tokens["completion_tokens"]
+		fields["total_tokens"] = tokens["total_tokens"]
+	}
+	logger.Access(fields)
+}
+
+// 分发结束后带回 gin 的请求参数
+func requestModel(gtx *gin.Context) string {
+	switch value := gtx.Value(vars.GinCompletion).(type) {
+	case model.Completion:
+		return value.Model
+	}
+	switch value := gtx.Value(vars.GinEmbedding).(type) {
+	case model.Embed:
+		return value.Model
+	}
+	switch value := gtx.Value(vars.GinGeneration).(type) {
+	case model.Generation:
+		return value.Model
+	}
+	return ""
+}
@@ -0,0 +1,161 @@
+package gin
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"chatgpt-adapter/core/common/inited"
+	"chatgpt-adapter/core/dispatch"
+	"chatgpt-adapter/core/logger"
+	"chatgpt-adapter/core/usage"
+	"github.com/gin-gonic/gin"
+)
+
+// 管理接口鉴权，使用 server.password；未配置密码时禁用
+func adminAuth(gtx *gin.Context) bool {
+	password := inited.Env().GetString("server.password")
+	if password == "" {
+		failed(gtx, http.StatusForbidden, "admin api is disabled, please setting `server.password`")
+		return false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(clientKey(gtx)), []byte(password)) != 1 {
+		failed(gtx, http.StatusUnauthorized, "password is incorrect")
+		return false
+	}
+	return true
+}
+
+// @POST(path = "admin/reload")
+func (h *Handler) reload(gtx *gin.Context) {
+	if !adminAuth(gtx) {
+		return
+	}
+
+	if err := inited.Reload(); err != nil {
+		logger.Ctx(gtx.Request.Context()).Errorf("reload config failed, keep the current config: %v", err)
+		failed(gtx, http.StatusBadRequest, err)
+		return
+	}
+
+	logger.Ctx(gtx.Request.Context()).Info("reload config success")
+	gtx.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// @GET(path = "admin/coalesce")
+func (h *Handler) coalesce(gtx *gin.Context) {
+	if !adminAuth(gtx) {
+		return
+	}
+	gtx.JSON(http.StatusOK, dispatch.CoalesceStats())
+}
+
+// 用量查询：from / to 为时间范围，默认最近 24 小时；group_by 逗号分隔；
+// key、model、adapter、account、status 为过滤条件；format=csv 时导出 csv
+// @GET(path = "admin/usage")
+func (h *Handler) usage(gtx *gin.Context) {
+	if !adminAuth(gtx) {
+		return
+	}
+
+	ledger := usage.Current()
+	if ledger == nil {
+		failed(gtx, http.StatusNotFound, "usage ledger is disabled, please setting `usage.enabled`")
+		return
+	}
+
+	query, err := usageQuery(gtx)
+	if err != nil {
+		failed(gtx, http.StatusBadRequest, err)
+		return
+	}
+
+	csv := gtx.Query("format") == "csv"
+	if csv {
+		gtx.Header("Content-Type", "text/csv; charset=utf-8")
+		gtx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=usage-%s.csv", time.Now().Format("20060102150405")))
+	}
+
+	if len(query.GroupBy) == 0 {
+		records, err := ledger.Records(query)
+		if err != nil {
+			failed(gtx, http.StatusInternalServerError, err)
+			return
+		}
+		if csv {
+			_ = usage.WriteRecordsCSV(gtx.Writer, records)
+			return
+		}
+		gtx.JSON(http.StatusOK, gin.H{"from": query.From, "to": query.To, "records": records})
+		return
+	}
+
+	summaries, err := ledger.Summarize(query)
+	if err != nil {
+		failed(gtx, http.StatusInternalServerError, err)
+		return
+	}
+	if csv {
+		_ = usage.WriteSummariesCSV(gtx.Writer, query.GroupBy, summaries)
+		return
+	}
+	gtx.JSON(http.StatusOK, gin.H{"from": query.From, "to": query.To, "group_by": query.GroupBy, "rows": summaries})
+}
+
+func usageQuery(gtx *gin.Context) (query usage.Query, err error) {
+	now := time.Now()
+	query.From, query.To = now.Add(-24*time.Hour), now
+	if value := gtx.Query("from"); value != "" {
+		if query.From, err = parseTime(value); err != nil {
+			return
+		}
+	}
+	if value := gtx.Query("to"); value != "" {
+		if query.To, err = parseTime(value); err != nil {
+			return
+		}
+	}
+
+	if value := gtx.Query("group_by"); value != "" {
+		for _, group := range strings.Split(value, ",") {
+			if group = strings.TrimSpace(group); group != "" {
+				query.GroupBy = append(query.GroupBy, group)
+			}
+		}
+	}
+
+	query.Filter = make(map[string]string)
+	for _, name := range []string{"key", "model", "adapter", "account", "status", "source"} {
+		if value, ok := gtx.GetQuery(name); ok {
+			query.Filter[name] = value
+		}
+	}
+
+	query.Limit = 1000
+	if value := gtx.Query("limit"); value != "" {
+		if query.Limit, err = strconv.Atoi(value); err != nil {
+			err = fmt.Errorf("invalid limit '%s'", value)
+			return
+		}
+	}
+	err = query.Check()
+	return
+}
+
+// 支持 RFC3339、日期（本地时区）与 unix 秒
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+	if sec, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time '%s', expected RFC3339, yyyy-mm-dd or unix seconds", value)
+}
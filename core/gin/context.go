@@ -0,0 +1,98 @@
+package gin
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"chatgpt-adapter/core/gin/inter"
+	"chatgpt-adapter/core/gin/model"
+	"chatgpt-adapter/core/gin/response"
+	"chatgpt-adapter/core/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// gin 只用于 HTTP 路由：以 gin 请求构造适配器上下文，中间件设置的值（凭证、请求id 等）一并带入
+func contextOf(gtx *gin.Context, sink inter.Sink) *inter.Context {
+	ctx := inter.NewContext(gtx.Request.Context(), gtx.Request.Header, sink)
+	ctx.Response = gtx.Writer.Header()
+	for key, value := range gtx.Keys {
+		ctx.Set(key, value)
+	}
+	return ctx
+}
+
+// 执行分发，结束后将上下文中的值（模型、适配器、账号、用量等）带回 gin，供访问日志使用
+func serve(gtx *gin.Context, sink inter.Sink, handle func(ctx *inter.Context)) {
+	ctx := contextOf(gtx, sink)
+	handle(ctx)
+	for key, value := range ctx.Keys() {
+		gtx.Set(key, value)
+	}
+}
+
+// openai 格式的 HTTP 输出
+func openai(gtx *gin.Context, handle func(ctx *inter.Context)) {
+	sink := &openaiSink{gtx: gtx}
+	serve(gtx, sink, func(ctx *inter.Context) {
+		sink.ctx = ctx
+		handle(ctx)
+	})
+}
+
+// 以 openai 格式返回错误
+func failed(gtx *gin.Context, code int, err interface{}) {
+	openai(gtx, func(ctx *inter.Context) { response.Error(ctx, code, err) })
+}
+
+// 流式输出为 SSE，完整响应为 json；请求使用旧的 functions 接口时转换 tool_calls
+type openaiSink struct {
+	gtx *gin.Context
+	ctx *inter.Context
+}
+
+func (s *openaiSink) Chunk(chunk model.Response) error {
+	marshal, err := json.Marshal(response.LegacyFunctions(s.ctx, chunk))
+	if err != nil {
+		return err
+	}
+	return s.write("", marshal)
+}
+
+func (s *openaiSink) Done() error {
+	return s.write("", []byte("[DONE]"))
+}
+
+func (s *openaiSink) Event(name string, data interface{}) error {
+	marshal, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return s.write(name, marshal)
+}
+
+func (s *openaiSink) Complete(code int, body interface{}) error {
+	s.gtx.JSON(code, response.LegacyFunctions(s.ctx, body))
+	return nil
+}
+
+func (s *openaiSink) write(event string, data []byte) error {
+	w := s.gtx.Writer
+	if h := w.Header(); h.Get("Content-Type") == "" {
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Transfer-Encoding", "chunked")
+		h.Set("Cache-Control", "no-cache")
+		h.Set("Connection", "keep-alive")
+		h.Set("X-Accel-Buffering", "no")
+	}
+
+	layout := "data: %s\n\n"
+	if event != "" {
+		layout = "event: " + event + "\n" + layout
+	}
+	if _, err := fmt.Fprintf(w, layout, data); err != nil {
+		logger.Error(err)
+		return err
+	}
+	w.Flush()
+	return nil
+}
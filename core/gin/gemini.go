@@ -0,0 +1,454 @@
+package gin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"chatgpt-adapter/core/dispatch"
+	"chatgpt-adapter/core/gin/inter"
+	"chatgpt-adapter/core/gin/model"
+	"chatgpt-adapter/core/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// Gemini generateContent 兼容接口，供 Google GenAI SDK 调用
+//
+//	https://ai.google.dev/api/generate-content
+type geminiPart struct {
+	Text       string `json:"text,omitempty"`
+	Thought    bool   `json:"thought,omitempty"`
+	InlineData *struct {
+		MimeType string `json:"mimeType"`
+		Data     string `json:"data"`
+	} `json:"inlineData,omitempty"`
+	FileData *struct {
+		MimeType string `json:"mimeType"`
+		FileUri  string `json:"fileUri"`
+	} `json:"fileData,omitempty"`
+	FunctionCall *struct {
+		Id   string      `json:"id,omitempty"`
+		Name string      `json:"name"`
+		Args interface{} `json:"args"`
+	} `json:"functionCall,omitempty"`
+	FunctionResponse *struct {
+		Id       string      `json:"id,omitempty"`
+		Name     string      `json:"name"`
+		Response interface{} `json:"response"`
+	} `json:"functionResponse,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiRequest struct {
+	Contents          []geminiContent `json:"contents"`
+	SystemInstruction *geminiContent  `json:"systemInstruction"`
+	Tools             []struct {
+		FunctionDeclarations []struct {
+			Name        string      `json:"name"`
+			Description string      `json:"description"`
+			Parameters  interface{} `json:"parameters"`
+		} `json:"functionDeclarations"`
+	} `json:"tools"`
+	ToolConfig *struct {
+		FunctionCallingConfig struct {
+			Mode                 string   `json:"mode"`
+			AllowedFunctionNames []string `json:"allowedFunctionNames"`
+		} `json:"functionCallingConfig"`
+	} `json:"toolConfig"`
+	GenerationConfig struct {
+		Temperature     *float32 `json:"temperature"`
+		TopP            float32  `json:"topP"`
+		TopK            int      `json:"topK"`
+		MaxOutputTokens int      `json:"maxOutputTokens"`
+		StopSequences   []string `json:"stopSequences"`
+	} `json:"generationConfig"`
+}
+
+// @POST(path = "v1beta/models/*action")
+func (h *Handler) gemini(gtx *gin.Context) {
+	// 路径形如 `gemini-2.0-flash:generateContent`，模型 id 可能含 `/` 与 `:`
+	action := strings.TrimPrefix(gtx.Param("action"), "/")
+	var mod, method string
+	if i := strings.LastIndex(action, ":"); i >= 0 {
+		mod, method = action[:i], action[i+1:]
+	}
+	if method != "generateContent" && method != "streamGenerateContent" {
+		geminiError(gtx, http.StatusNotFound, fmt.Sprintf("method '%s' is not supported", method))
+		return
+	}
+
+	var req geminiRequest
+	if err := gtx.BindJSON(&req); err != nil {
+		geminiError(gtx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	completion := req.completion()
+	completion.Model = mod
+	completion.Stream = method == "streamGenerateContent"
+
+	sink := &geminiSink{
+		gtx:   gtx,
+		model: mod,
+		sse:   gtx.Query("alt") == "sse",
+	}
+	serve(gtx, sink, func(ctx *inter.Context) { dispatch.Completions(ctx, h.extensions, completion) })
+}
+
+func (req geminiRequest) completion() (completion model.Completion) {
+	config := req.GenerationConfig
+	completion.TopP = config.TopP
+	completion.TopK = config.TopK
+	completion.MaxTokens = config.MaxOutputTokens
+	completion.StopSequences = config.StopSequences
+	completion.Temperature = config.Temperature
+
+	if req.SystemInstruction != nil {
+		var texts []string
+		for _, part := range req.SystemInstruction.Parts {
+			texts = append(texts, part.Text)
+		}
+		completion.Messages = append(completion.Messages, model.Keyv[interface{}]{
+			"role":    "system",
+			"content": strings.Join(texts, "\n"),
+		})
+	}
+
+	// gemini 的 functionResponse 按名称对应此前的 functionCall
+	ids := make(map[string][]string)
+	for i, content := range req.Contents {
+		var (
+			contents []interface{}
+			calls    []interface{}
+			text     = true
+		)
+		for j, part := range content.Parts {
+			switch {
+			case part.FunctionCall != nil:
+				id := part.FunctionCall.Id
+				if id == "" {
+					id = fmt.Sprintf("call_%d_%d", i, j)
+				}
+				ids[part.FunctionCall.Name] = append(ids[part.FunctionCall.Name], id)
+				args, _ := json.Marshal(part.FunctionCall.Args)
+				calls = append(calls, map[string]interface{}{
+					"id":   id,
+					"type": "function",
+					"function": map[string]interface{}{
+						"name":      part.FunctionCall.Name,
+						"arguments": string(args),
+					},
+				})
+
+			case part.FunctionResponse != nil:
+				id := part.FunctionResponse.Id
+				if queue := ids[part.FunctionResponse.Name]; id == "" && len(queue) > 0 {
+					id, ids[part.FunctionResponse.Name] = queue[0], queue[1:]
+				}
+				result, _ := json.Marshal(part.FunctionResponse.Response)
+				completion.Messages = append(completion.Messages, model.Keyv[interface{}]{
+					"role":         "tool",
+					"name":         part.FunctionResponse.Name,
+					"tool_call_id": id,
+					"content":      string(result),
+				})
+
+			case part.InlineData != nil:
+				text = false
+				contents = append(contents, map[string]interface{}{
+					"type": "image_url",
+					"image_url": map[string]interface{}{
+						"url": "data:" + part.InlineData.MimeType + ";base64," + part.InlineData.Data,
+					},
+				})
+
+			case part.FileData != nil:
+				text = false
+				contents = append(contents, map[string]interface{}{
+					"type":      "image_url",
+					"image_url": map[string]interface{}{"url": part.FileData.FileUri},
+				})
+
+			case part.Thought:
+				// 历史中的思考内容不再回传
+
+			default:
+				contents = append(contents, map[string]interface{}{"type": "text", "text": part.Text})
+			}
+		}
+
+		if len(contents) == 0 && len(calls) == 0 {
+			continue
+		}
+
+		role := "user"
+		if content.Role == "model" {
+			role = "assistant"
+		}
+		message := model.Keyv[interface{}]{"role": role}
+		if text {
+			var texts []string
+			for _, c := range contents {
+				texts = append(texts, c.(map[string]interface{})["text"].(string))
+			}
+			message["content"] = strings.Join(texts, "")
+		} else {
+			message["content"] = contents
+		}
+		if len(calls) > 0 {
+			message["tool_calls"] = calls
+		}
+		completion.Messages = append(completion.Messages, message)
+	}
+
+	for _, tool := range req.Tools {
+		for _, decl := range tool.FunctionDeclarations {
+			parameters := decl.Parameters
+			if parameters == nil {
+				parameters = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+			}
+			completion.Tools = append(completion.Tools, model.Keyv[interface{}]{
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":        decl.Name,
+					"description": decl.Description,
+					"parameters":  lowerTypes(parameters),
+				},
+			})
+		}
+	}
+
+	if req.ToolConfig != nil {
+		config := req.ToolConfig.FunctionCallingConfig
+		switch strings.ToUpper(config.Mode) {
+		case "NONE":
+			completion.ToolChoice = "none"
+		case "ANY":
+			completion.ToolChoice = "required"
+			if len(config.AllowedFunctionNames) == 1 {
+				completion.ToolChoice = map[string]interface{}{
+					"type":     "function",
+					"function": map[string]interface{}{"name": config.AllowedFunctionNames[0]},
+				}
+			}
+		case "AUTO":
+			completion.ToolChoice = "auto"
+		}
+	}
+	return
+}
+
+// gemini schema 的 type 为大写（OBJECT、STRING），转为 json schema 写法
+func lowerTypes(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, val := range v {
+			if str, ok := val.(string); ok && key == "type" {
+				v[key] = strings.ToLower(str)
+				continue
+			}
+			v[key] = lowerTypes(val)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = lowerTypes(v[i])
+		}
+	}
+	return value
+}
+
+func geminiError(gtx *gin.Context, code int, message string) {
+	gtx.JSON(code, geminiErrorBody(code, message))
+}
+
+func geminiErrorBody(code int, message string) gin.H {
+	status := "INTERNAL"
+	switch code {
+	case http.StatusBadRequest:
+		status = "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		status = "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		status = "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		status = "NOT_FOUND"
+	case http.StatusTooManyRequests:
+		status = "RESOURCE_EXHAUSTED"
+	}
+	return gin.H{"error": gin.H{"code": code, "message": message, "status": status}}
+}
+
+// 将适配器输出转换为 gemini candidates；
+// alt=sse 时以 SSE 输出，否则按 JSON 数组逐个输出
+type geminiSink struct {
+	gtx   *gin.Context
+	model string
+	sse   bool
+
+	written   bool
+	done      bool
+	usage     map[string]interface{}
+	reason    string
+	toolCalls []toolCall
+}
+
+func (g *geminiSink) Chunk(chunk model.Response) error {
+	if chunk.Usage != nil {
+		g.usage = chunk.Usage
+	}
+	if len(chunk.Choices) == 0 {
+		return nil
+	}
+
+	choice := chunk.Choices[0]
+	if choice.FinishReason != nil && *choice.FinishReason != "" {
+		g.reason = *choice.FinishReason
+	}
+	if choice.Delta == nil {
+		return nil
+	}
+
+	g.toolCalls = mergeCalls(g.toolCalls, choice.Delta.ToolCalls)
+	parts := geminiParts(choice.Delta.Content, choice.Delta.ReasoningContent, nil)
+	if len(parts) == 0 {
+		return nil
+	}
+	return g.write(g.response(parts, false))
+}
+
+func (g *geminiSink) Done() error {
+	if g.done {
+		return nil
+	}
+	g.done = true
+	parts := geminiParts("", "", g.toolCalls)
+	if len(parts) == 0 {
+		parts = []gin.H{{"text": ""}}
+	}
+	if err := g.write(g.response(parts, true)); err != nil {
+		return err
+	}
+	if !g.sse {
+		_, err := g.gtx.Writer.WriteString("]")
+		return err
+	}
+	return nil
+}
+
+func (g *geminiSink) Complete(code int, body interface{}) error {
+	if code >= http.StatusBadRequest {
+		errorBody := geminiErrorBody(code, errorMessage(code, body))
+		if g.written {
+			// 已开始输出，只能在流中返回错误
+			if err := g.write(errorBody); err != nil || g.sse {
+				return err
+			}
+			_, err := g.gtx.Writer.WriteString("]")
+			return err
+		}
+		g.gtx.JSON(code, errorBody)
+		return nil
+	}
+
+	resp, ok := body.(model.Response)
+	if !ok {
+		g.gtx.JSON(code, body)
+		return nil
+	}
+
+	content, reasoning := "", ""
+	if len(resp.Choices) > 0 && resp.Choices[0].Message != nil {
+		message := resp.Choices[0].Message
+		content, reasoning = message.Content, message.ReasoningContent
+		for _, call := range message.ToolCalls {
+			g.toolCalls = append(g.toolCalls, parseCall(call))
+		}
+		if reason := resp.Choices[0].FinishReason; reason != nil {
+			g.reason = *reason
+		}
+	}
+	g.usage = resp.Usage
+
+	parts := geminiParts(content, reasoning, g.toolCalls)
+	if len(parts) == 0 {
+		parts = []gin.H{{"text": ""}}
+	}
+	g.gtx.JSON(http.StatusOK, g.response(parts, true))
+	return nil
+}
+
+func geminiParts(content, reasoning string, calls []toolCall) (parts []gin.H) {
+	if reasoning != "" {
+		parts = append(parts, gin.H{"text": reasoning, "thought": true})
+	}
+	if content != "" {
+		parts = append(parts, gin.H{"text": content})
+	}
+	for _, call := range calls {
+		parts = append(parts, gin.H{"functionCall": gin.H{"name": call.Name, "args": call.args()}})
+	}
+	return
+}
+
+func (g *geminiSink) response(parts []gin.H, done bool) gin.H {
+	candidate := gin.H{
+		"index":   0,
+		"content": gin.H{"role": "model", "parts": parts},
+	}
+	obj := gin.H{
+		"candidates":   []gin.H{candidate},
+		"modelVersion": g.model,
+	}
+	if !done {
+		return obj
+	}
+
+	reason := "STOP"
+	if g.reason == "length" {
+		reason = "MAX_TOKENS"
+	}
+	candidate["finishReason"] = reason
+
+	prompt, completion := usageInt(g.usage, "prompt_tokens"), usageInt(g.usage, "completion_tokens")
+	obj["usageMetadata"] = gin.H{
+		"promptTokenCount":     prompt,
+		"candidatesTokenCount": completion,
+		"totalTokenCount":      prompt + completion,
+	}
+	return obj
+}
+
+func (g *geminiSink) write(obj interface{}) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+
+	w := g.gtx.Writer
+	switch {
+	case g.sse:
+		if !g.written {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Header().Set("Cache-Control", "no-cache")
+		}
+		_, err = w.WriteString("data: " + string(data) + "\r\n\r\n")
+	case !g.written:
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Del("Transfer-Encoding")
+		_, err = w.WriteString("[" + string(data))
+	default:
+		_, err = w.WriteString(",\r\n" + string(data))
+	}
+	if err != nil {
+		return err
+	}
+	g.written = true
+	w.Flush()
+	return nil
+}
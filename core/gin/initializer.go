@@ -1,9 +1,10 @@
 package gin
 
 import (
+	"chatgpt-adapter/core/common/vars"
 	"chatgpt-adapter/core/logger"
+	"chatgpt-adapter/core/tracer"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"github.com/iocgo/sdk"
 	"github.com/iocgo/sdk/env"
 	"github.com/iocgo/sdk/router"
@@ -28,6 +29,8 @@ func Initialized(env *env.Environment) sdk.Initializer {
 			engine = gin.Default()
 			{
 				engine.Use(gin.Recovery())
+				engine.Use(access)
+				engine.Use(tracer.Middleware)
 				engine.Use(cros)
 				engine.Use(token)
 			}
@@ -44,11 +47,22 @@ func Initialized(env *env.Environment) sdk.Initializer {
 }
 
 func token(gtx *gin.Context) {
+	gtx.Set(vars.GinToken, clientKey(gtx))
+}
+
+func clientKey(gtx *gin.Context) string {
 	str := gtx.Request.Header.Get("X-Api-Key")
 	if str == "" {
 		str = strings.TrimPrefix(gtx.Request.Header.Get("Authorization"), "Bearer ")
 	}
-	gtx.Set("token", str)
+	// Google GenAI SDK
+	if str == "" {
+		str = gtx.Request.Header.Get("X-Goog-Api-Key")
+	}
+	if str == "" {
+		str = gtx.Query("key")
+	}
+	return str
 }
 
 func cros(gtx *gin.Context) {
@@ -70,21 +84,27 @@ func cros(gtx *gin.Context) {
 	if gtx.Request.RequestURI == "/" ||
 		gtx.Request.RequestURI == "/favicon.ico" ||
 		strings.Contains(gtx.Request.URL.Path, "/v1/models") ||
+		strings.HasPrefix(gtx.Request.URL.Path, "/api/tags") ||
 		strings.HasPrefix(gtx.Request.URL.Path, "/file/") {
 		// 处理请求
 		gtx.Next()
 		return
 	}
 
-	uid := uuid.NewString()
-	// 请求打印
-	data, _ := httputil.DumpRequest(gtx.Request, debug)
-	logger.Infof("------ START REQUEST %s ---------", uid)
-	println(string(data))
+	if logger.IsJSON() {
+		// json 模式下由 access 输出访问日志
+		gtx.Next()
+		return
+	}
+
+	uid := gtx.GetString(vars.GinRequestId)
+	// 请求打印，只输出请求行与请求头（经日志脱敏），不输出包含完整提示的请求体
+	data, _ := httputil.DumpRequest(gtx.Request, false)
+	logger.Ctx(gtx.Request.Context()).Infof("------ START REQUEST %s ---------\n%s", uid, data)
 
 	// 处理请求
 	gtx.Next()
 
 	// 结束处理
-	logger.Infof("------ END REQUEST %s ---------", uid)
+	logger.Ctx(gtx.Request.Context()).Infof("------ END REQUEST %s ---------", uid)
 }
@@ -0,0 +1,9 @@
+package inter
+
+import "chatgpt-adapter/core/gin/model"
+
+// 可选实现：声明模型能力，用于 /v1/models 展示与请求的前置校验
+type Capable interface {
+	// 返回 nil 表示未知，不做校验
+	Capabilities(model string) *model.Capabilities
+}
@@ -0,0 +1,118 @@
+package inter
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"sync"
+)
+
+// 适配器的请求上下文，不依赖 HTTP 框架：
+// HTTP 路由以 gin 请求构造，库调用（dispatch.Dispatcher）直接构造；适配器的输出只写入 Sink
+type Context struct {
+	context.Context // 请求的 context，取消时适配器应停止请求上游
+
+	Header   http.Header // 请求头
+	Response http.Header // 响应头，HTTP 路由下即为实际的响应头
+	Sink     Sink
+
+	values *values // WithContext 派生的上下文共享
+}
+
+type values struct {
+	mu   sync.RWMutex
+	keys map[string]interface{}
+}
+
+func NewContext(ctx context.Context, header http.Header, sink Sink) *Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &Context{
+		Context:  ctx,
+		Header:   header,
+		Response: make(http.Header),
+		Sink:     sink,
+		values:   &values{keys: make(map[string]interface{})},
+	}
+}
+
+// 替换 context（如携带 span），共享值与输出
+func (c *Context) WithContext(ctx context.Context) *Context {
+	clone := *c
+	clone.Context = ctx
+	return &clone
+}
+
+// 派生子请求：复制当前的值，使用新的 context 与输出，子请求的修改不影响当前上下文
+func (c *Context) Fork(ctx context.Context, sink Sink) *Context {
+	return &Context{
+		Context:  ctx,
+		Header:   c.Header,
+		Response: c.Response,
+		Sink:     sink,
+		values:   &values{keys: c.Keys()},
+	}
+}
+
+// 带回子请求的值，如适配器、账号等
+func (c *Context) Merge(sub *Context) {
+	for key, value := range sub.Keys() {
+		c.Set(key, value)
+	}
+}
+
+// 当前所有值的副本
+func (c *Context) Keys() map[string]interface{} {
+	c.values.mu.RLock()
+	defer c.values.mu.RUnlock()
+	return maps.Clone(c.values.keys)
+}
+
+func (c *Context) Set(key string, value interface{}) {
+	c.values.mu.Lock()
+	defer c.values.mu.Unlock()
+	c.values.keys[key] = value
+}
+
+func (c *Context) Get(key string) (value interface{}, exists bool) {
+	c.values.mu.RLock()
+	defer c.values.mu.RUnlock()
+	value, exists = c.values.keys[key]
+	return
+}
+
+func (c *Context) MustGet(key string) interface{} {
+	if value, exists := c.Get(key); exists {
+		return value
+	}
+	panic("key \"" + key + "\" does not exist")
+}
+
+func (c *Context) GetString(key string) (s string) {
+	if value, ok := c.Get(key); ok && value != nil {
+		s, _ = value.(string)
+	}
+	return
+}
+
+func (c *Context) GetBool(key string) (b bool) {
+	if value, ok := c.Get(key); ok && value != nil {
+		b, _ = value.(bool)
+	}
+	return
+}
+
+func (c *Context) GetInt(key string) (i int) {
+	if value, ok := c.Get(key); ok && value != nil {
+		i, _ = value.(int)
+	}
+	return
+}
+
+func (c *Context) GetHeader(key string) string {
+	return c.Header.Get(key)
+}
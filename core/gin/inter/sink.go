@@ -0,0 +1,18 @@
+package inter
+
+import "chatgpt-adapter/core/gin/model"
+
+// 响应接收端，与传输方式无关；HTTP 之外的前端实现该接口接收适配器输出
+type Sink interface {
+	// 流式片段
+	Chunk(chunk model.Response) error
+	// 流式结束
+	Done() error
+	// 非流式响应，code 为 http 状态码；出错时 body 为 {"error": {"message": "..."}}
+	Complete(code int, body interface{}) error
+}
+
+// 可选实现：接收自定义事件（如 agent.step），只有 HTTP 的 SSE 响应使用
+type EventSink interface {
+	Event(name string, data interface{}) error
+}
@@ -0,0 +1,66 @@
+package model
+
+import "fmt"
+
+// 将已废弃的 functions、function_call 以及消息中的 function_call、function 角色转换为 tools 格式，
+// 返回请求是否使用了旧格式
+func (c *Completion) NormalizeFunctions() (legacy bool) {
+	if len(c.Functions) > 0 {
+		legacy = true
+		for _, fn := range c.Functions {
+			c.Tools = append(c.Tools, Keyv[interface{}]{"type": "function", "function": map[string]interface{}(fn)})
+		}
+		c.Functions = nil
+	}
+
+	if c.FunctionCall != nil {
+		legacy = true
+		switch value := c.FunctionCall.(type) {
+		case string:
+			c.ToolChoice = value
+		case map[string]interface{}:
+			c.ToolChoice = map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": value["name"]}}
+		}
+		c.FunctionCall = nil
+	}
+
+	// function 结果与最近一次同名调用关联
+	calls := make(map[string]string)
+	for i, message := range c.Messages {
+		switch {
+		case message.Is("role", "assistant") && message.Has("function_call"):
+			legacy = true
+			call := message.GetKeyv("function_call")
+			id := fmt.Sprintf("call_%d", i)
+			calls[call.GetString("name")] = id
+
+			message = message.Clone()
+			delete(message, "function_call")
+			message["tool_calls"] = []interface{}{
+				map[string]interface{}{
+					"id":   id,
+					"type": "function",
+					"function": map[string]interface{}{
+						"name":      call.GetString("name"),
+						"arguments": call.GetString("arguments"),
+					},
+				},
+			}
+			c.Messages[i] = message
+
+		case message.Is("role", "function"):
+			legacy = true
+			name := message.GetString("name")
+			id, ok := calls[name]
+			if !ok {
+				id = fmt.Sprintf("call_%d", i)
+			}
+
+			message = message.Clone()
+			message["role"] = "tool"
+			message["tool_call_id"] = id
+			c.Messages[i] = message
+		}
+	}
+	return
+}
@@ -0,0 +1,91 @@
+package model
+
+import "strings"
+
+// 常见模型的上下文窗口与最大输出，按前缀匹配，靠前的优先；0 为未知
+//
+// 模型名先经 normalize 统一：小写，`.`、`_`、空格视为 `-`；
+// 前缀需匹配完整的段，`gpt-4-1` 不匹配 `gpt-4-1106-preview`
+var limits = []struct {
+	prefix        string
+	contextWindow int
+	maxOutput     int
+}{
+	{"gpt-5-chat", 128000, 16384},
+	{"gpt-5", 400000, 128000},
+	{"gpt-4-1", 1047576, 32768},
+	{"gpt-4-1106", 128000, 4096},
+	{"gpt-4-0125", 128000, 4096},
+	{"gpt-4o", 128000, 16384},
+	{"chatgpt-4o", 128000, 16384},
+	{"gpt-4-turbo", 128000, 4096},
+	{"gpt-4-32k", 32768, 8192},
+	{"gpt-4", 8192, 8192},
+	{"gpt-3-5-turbo", 16385, 4096},
+	{"o1-mini", 128000, 65536},
+	{"o1-preview", 128000, 32768},
+	{"o1", 200000, 100000},
+	{"o3-mini", 200000, 100000},
+
+	{"claude-opus-4", 200000, 32000},
+	{"claude-sonnet-4", 200000, 64000},
+	{"claude-3-7-sonnet", 200000, 64000},
+	{"claude-3-5-sonnet", 200000, 8192},
+	{"claude-3-5-haiku", 200000, 8192},
+	{"claude-3-", 200000, 4096},
+	{"claude-2", 100000, 4096},
+
+	{"gemini-2-5-pro", 1048576, 65536},
+	{"gemini-2-0-flash", 1048576, 8192},
+	{"gemini-1-5-pro", 2097152, 8192},
+	{"gemini-1-5-flash", 1048576, 8192},
+
+	{"deepseek-chat", 64000, 8192},
+	{"deepseek-v3", 64000, 8192},
+	{"deepseek-reasoner", 64000, 8192},
+	{"deepseek-r1", 64000, 8192},
+
+	{"grok-2", 131072, 0},
+	{"grok-3", 131072, 0},
+	{"llama-3-1", 131072, 0},
+	{"llama-3-2", 131072, 0},
+	{"mistral-large", 131072, 0},
+}
+
+// 各适配器对同一模型的不同写法
+var aliases = map[string]string{
+	"gpt4o":             "gpt-4o",
+	"gpt4-o3-mini":      "o3-mini",
+	"claude-sonnet-3-5": "claude-3-5-sonnet",
+	"claude-sonnet-3-7": "claude-3-7-sonnet",
+}
+
+// 查询已知的上下文窗口与最大输出，mod 可带适配器前缀（如 `cursor/gpt-4o`），未知返回 0
+func LimitsOf(mod string) (contextWindow, maxOutput int) {
+	name := normalize(mod[strings.LastIndex(mod, "/")+1:])
+	name = strings.TrimPrefix(name, "openai-")
+	for alias, value := range aliases {
+		if hasSegments(name, alias) {
+			name = value + name[len(alias):]
+			break
+		}
+	}
+
+	for _, limit := range limits {
+		if hasSegments(name, limit.prefix) {
+			return limit.contextWindow, limit.maxOutput
+		}
+	}
+	return
+}
+
+func hasSegments(name, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	return len(name) == len(prefix) || strings.HasSuffix(prefix, "-") || name[len(prefix)] == '-'
+}
+
+func normalize(mod string) string {
+	return strings.NewReplacer(".", "-", "_", "-", " ", "-").Replace(strings.ToLower(mod))
+}
@@ -0,0 +1,32 @@
+package model
+
+import "testing"
+
+func TestLimitsOf(t *testing.T) {
+	for _, c := range []struct {
+		mod           string
+		contextWindow int
+		maxOutput     int
+	}{
+		{"cursor/claude-3.7-sonnet-thinking", 200000, 64000},
+		{"lmsys/claude-3-5-sonnet-20241022", 200000, 8192},
+		{"you/claude_3_opus", 200000, 4096},
+		{"you/openai_o1_mini", 128000, 65536},
+		{"blackbox/Claude-Sonnet-3.7", 200000, 64000},
+		{"windsurf/gpt4o", 128000, 16384},
+		{"windsurf/gpt4-o3-mini", 200000, 100000},
+		{"gpt-4-1106-preview", 128000, 4096},
+		{"gpt-4.1-2025-04-14", 1047576, 32768},
+		{"gpt-4-32k", 32768, 8192},
+		{"gpt-4", 8192, 8192},
+		{"deepseek-reasoner", 64000, 8192},
+		{"openrouter/anthropic/claude-opus-4", 200000, 32000},
+		{"coze", 0, 0},
+		{"o1x", 0, 0},
+	} {
+		contextWindow, maxOutput := LimitsOf(c.mod)
+		if contextWindow != c.contextWindow || maxOutput != c.maxOutput {
+			t.Errorf("%s: got (%d, %d), want (%d, %d)", c.mod, contextWindow, maxOutput, c.contextWindow, c.maxOutput)
+		}
+	}
+}
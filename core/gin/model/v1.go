@@ -1,10 +1,41 @@
 package model
 
+import "slices"
+
 type Model struct {
 	Id      string `json:"id"`
 	Object  string `json:"object"`
 	Created int    `json:"created"`
 	By      string `json:"owned_by"`
+
+	*Capabilities
+}
+
+// 模型能力声明，未声明的模型不做校验
+type Capabilities struct {
+	ContextWindow int      `json:"context_window,omitempty"`
+	MaxOutput     int      `json:"max_output_tokens,omitempty"`
+	Input         []string `json:"input_modalities,omitempty"`  // text、image
+	Output        []string `json:"output_modalities,omitempty"` // text、image、embedding
+	Tools         string   `json:"tools,omitempty"`             // native 原生支持；emulated 提示词模拟；为空不支持
+	Reasoning     bool     `json:"reasoning,omitempty"`
+}
+
+const (
+	ModalityText      = "text"
+	ModalityImage     = "image"
+	ModalityEmbedding = "embedding"
+
+	ToolsNative   = "native"
+	ToolsEmulated = "emulated"
+)
+
+func (c *Capabilities) Accepts(modality string) bool {
+	return slices.Contains(c.Input, modality)
+}
+
+func (c *Capabilities) Produces(modality string) bool {
+	return slices.Contains(c.Output, modality)
 }
 
 type Completion struct {
@@ -14,11 +45,23 @@ type Completion struct {
 	Model         string              `json:"model,omitempty"`
 	MaxTokens     int                 `json:"max_tokens"`
 	StopSequences []string            `json:"stop,omitempty"`
-	Temperature   float32             `json:"temperature"`
+	Temperature   *float32            `json:"temperature,omitempty"` // 未设置时为 nil
 	TopK          int                 `json:"top_k,omitempty"`
 	TopP          float32             `json:"top_p,omitempty"`
 	Stream        bool                `json:"stream,omitempty"`
 	ToolChoice    interface{}         `json:"tool_choice,omitempty"`
+
+	// 已废弃的 functions 接口，由 NormalizeFunctions 转换为 Tools、ToolChoice
+	Functions    []Keyv[interface{}] `json:"functions,omitempty"`
+	FunctionCall interface{}         `json:"function_call,omitempty"`
+}
+
+// 未设置 temperature 时返回 value
+func (c Completion) TemperatureOr(value float32) float32 {
+	if c.Temperature == nil {
+		return value
+	}
+	return *c.Temperature
 }
 
 type Generation struct {
@@ -0,0 +1,434 @@
+package gin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"chatgpt-adapter/core/dispatch"
+	"chatgpt-adapter/core/gin/inter"
+	"chatgpt-adapter/core/gin/model"
+	"chatgpt-adapter/core/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// Ollama 兼容接口，流式响应为 NDJSON
+//
+//	https://github.com/ollama/ollama/blob/main/docs/api.md
+const ollamaVersion = "0.6.0"
+
+type ollamaOptions struct {
+	Temperature *float32 `json:"temperature"`
+	TopP        float32  `json:"top_p"`
+	TopK        int      `json:"top_k"`
+	NumPredict  int      `json:"num_predict"`
+	Stop        []string `json:"stop"`
+}
+
+type ollamaMessage struct {
+	Role      string                    `json:"role"`
+	Content   string                    `json:"content"`
+	Thinking  string                    `json:"thinking,omitempty"`
+	Images    []string                  `json:"images,omitempty"`
+	ToolCalls []model.Keyv[interface{}] `json:"tool_calls,omitempty"`
+	ToolName  string                    `json:"tool_name,omitempty"`
+}
+
+type ollamaChat struct {
+	Model    string                    `json:"model"`
+	Messages []ollamaMessage           `json:"messages"`
+	Tools    []model.Keyv[interface{}] `json:"tools"`
+	Stream   *bool                     `json:"stream"`
+	Options  ollamaOptions             `json:"options"`
+}
+
+type ollamaGenerate struct {
+	Model   string        `json:"model"`
+	Prompt  string        `json:"prompt"`
+	System  string        `json:"system"`
+	Images  []string      `json:"images"`
+	Stream  *bool         `json:"stream"`
+	Options ollamaOptions `json:"options"`
+}
+
+// @POST(path = "api/chat")
+func (h *Handler) ollamaChat(gtx *gin.Context) {
+	var chat ollamaChat
+	if err := gtx.BindJSON(&chat); err != nil {
+		ollamaError(gtx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	completion := chat.Options.completion(chat.Model, toMessages(chat.Messages), chat.Stream)
+	completion.Tools = chat.Tools
+	h.ollama(gtx, completion, false)
+}
+
+// @POST(path = "api/generate")
+func (h *Handler) ollamaGenerate(gtx *gin.Context) {
+	var generate ollamaGenerate
+	if err := gtx.BindJSON(&generate); err != nil {
+		ollamaError(gtx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// 空 prompt 用于预加载模型
+	if generate.Prompt == "" {
+		gtx.JSON(http.StatusOK, gin.H{
+			"model":       generate.Model,
+			"created_at":  time.Now().Format(time.RFC3339Nano),
+			"response":    "",
+			"done":        true,
+			"done_reason": "load",
+		})
+		return
+	}
+
+	messages := make([]model.Keyv[interface{}], 0, 2)
+	if generate.System != "" {
+		messages = append(messages, model.Keyv[interface{}]{"role": "system", "content": generate.System})
+	}
+	messages = append(messages, toMessage("user", generate.Prompt, generate.Images))
+	h.ollama(gtx, generate.Options.completion(generate.Model, messages, generate.Stream), true)
+}
+
+// @GET(path = "api/tags")
+func (h *Handler) ollamaTags(gtx *gin.Context) {
+	models := make([]gin.H, 0)
+	for _, mod := range dispatch.Models(h.extensions) {
+		models = append(models, ollamaModel(mod))
+	}
+	gtx.JSON(http.StatusOK, gin.H{"models": models})
+}
+
+// @POST(path = "api/show")
+func (h *Handler) ollamaShow(gtx *gin.Context) {
+	var req struct {
+		Model string `json:"model"`
+		Name  string `json:"name"`
+	}
+	if err := gtx.BindJSON(&req); err != nil {
+		ollamaError(gtx, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Model == "" {
+		req.Model = req.Name
+	}
+
+	for _, mod := range dispatch.Models(h.extensions) {
+		if mod.Id != req.Model && mod.Id+":latest" != req.Model {
+			continue
+		}
+		gtx.JSON(http.StatusOK, gin.H{
+			"modelfile":    "",
+			"parameters":   "",
+			"template":     "",
+			"details":      ollamaModel(mod)["details"],
+			"model_info":   gin.H{"general.architecture": mod.By},
+			"capabilities": ollamaCapabilities(mod.Capabilities),
+			"modified_at":  ollamaModel(mod)["modified_at"],
+		})
+		return
+	}
+	ollamaError(gtx, http.StatusNotFound, fmt.Sprintf("model '%s' not found", req.Model))
+}
+
+// @GET(path = "api/version")
+func (h *Handler) ollamaVersion(gtx *gin.Context) {
+	gtx.JSON(http.StatusOK, gin.H{"version": ollamaVersion})
+}
+
+func (h *Handler) ollama(gtx *gin.Context, completion model.Completion, generate bool) {
+	// 兼容 `model:latest` 写法
+	completion.Model = strings.TrimSuffix(completion.Model, ":latest")
+	sink := &ollamaSink{
+		gtx:      gtx,
+		model:    completion.Model,
+		generate: generate,
+		started:  time.Now(),
+	}
+	serve(gtx, sink, func(ctx *inter.Context) { dispatch.Completions(ctx, h.extensions, completion) })
+}
+
+func (o ollamaOptions) completion(mod string, messages []model.Keyv[interface{}], stream *bool) (completion model.Completion) {
+	completion.Model = mod
+	completion.Messages = messages
+	completion.Stream = stream == nil || *stream // ollama 默认流式
+	completion.TopP = o.TopP
+	completion.TopK = o.TopK
+	completion.MaxTokens = o.NumPredict
+	completion.StopSequences = o.Stop
+	completion.Temperature = o.Temperature
+	return
+}
+
+// 转换为 openai 格式的消息
+//
+// ollama 的工具调用与结果都不带 id：为调用生成 id，结果按 tool_name（未提供时按顺序）对应此前未配对的调用
+func toMessages(messages []ollamaMessage) []model.Keyv[interface{}] {
+	type pendingCall struct{ id, name string }
+	var (
+		pending []pendingCall
+		slice   = make([]model.Keyv[interface{}], 0, len(messages))
+	)
+
+	for i, message := range messages {
+		msg := toMessage(message.Role, message.Content, message.Images)
+		if len(message.ToolCalls) > 0 {
+			calls := make([]interface{}, 0, len(message.ToolCalls))
+			for j, call := range message.ToolCalls {
+				fn := call.GetKeyv("function")
+				value, _ := fn.Get("arguments")
+				args, _ := json.Marshal(value)
+				id := fmt.Sprintf("call_%d_%d", i, j)
+				pending = append(pending, pendingCall{id, fn.GetString("name")})
+				calls = append(calls, map[string]interface{}{
+					"id":   id,
+					"type": "function",
+					"function": map[string]interface{}{
+						"name":      fn.GetString("name"),
+						"arguments": string(args),
+					},
+				})
+			}
+			msg["tool_calls"] = calls
+		}
+
+		if message.Role == "tool" {
+			idx := slices.IndexFunc(pending, func(call pendingCall) bool {
+				return message.ToolName == "" || call.name == message.ToolName
+			})
+			if idx >= 0 {
+				msg["tool_call_id"] = pending[idx].id
+				msg["name"] = pending[idx].name
+				pending = slices.Delete(pending, idx, idx+1)
+			}
+		}
+		slice = append(slice, msg)
+	}
+	return slice
+}
+
+func toMessage(role, content string, images []string) model.Keyv[interface{}] {
+	message := model.Keyv[interface{}]{"role": role, "content": content}
+	if len(images) > 0 {
+		contents := []interface{}{map[string]interface{}{"type": "text", "text": content}}
+		for _, image := range images {
+			contents = append(contents, map[string]interface{}{
+				"type":      "image_url",
+				"image_url": map[string]interface{}{"url": "data:image/jpeg;base64," + image},
+			})
+		}
+		message["content"] = contents
+	}
+	return message
+}
+
+// 按适配器的能力声明生成 ollama 的 capabilities，未声明时视为支持对话与工具
+func ollamaCapabilities(caps *model.Capabilities) []string {
+	if caps == nil {
+		return []string{"completion", "tools"}
+	}
+
+	slice := make([]string, 0)
+	if len(caps.Output) == 0 || caps.Produces(model.ModalityText) {
+		slice = append(slice, "completion")
+	}
+	if caps.Produces(model.ModalityEmbedding) {
+		slice = append(slice, "embedding")
+	}
+	if caps.Tools != "" {
+		slice = append(slice, "tools")
+	}
+	if caps.Accepts(model.ModalityImage) {
+		slice = append(slice, "vision")
+	}
+	if caps.Reasoning {
+		slice = append(slice, "thinking")
+	}
+	return slice
+}
+
+func ollamaModel(mod model.Model) gin.H {
+	return gin.H{
+		"name":        mod.Id,
+		"model":       mod.Id,
+		"modified_at": time.Unix(int64(mod.Created), 0).Format(time.RFC3339),
+		"size":        0,
+		"digest":      "",
+		"details": gin.H{
+			"format":             "api",
+			"family":             mod.By,
+			"families":           []string{mod.By},
+			"parameter_size":     "",
+			"quantization_level": "",
+		},
+	}
+}
+
+func ollamaError(gtx *gin.Context, code int, message string) {
+	gtx.JSON(code, gin.H{"error": message})
+}
+
+// 将适配器输出转换为 Ollama 响应
+type ollamaSink struct {
+	gtx      *gin.Context
+	model    string
+	generate bool
+
+	started   time.Time
+	first     time.Time
+	written   bool
+	done      bool
+	usage     map[string]interface{}
+	reason    string
+	toolCalls []toolCall
+}
+
+func (o *ollamaSink) Chunk(chunk model.Response) error {
+	if chunk.Usage != nil {
+		o.usage = chunk.Usage
+	}
+	if len(chunk.Choices) == 0 {
+		return nil
+	}
+
+	choice := chunk.Choices[0]
+	if choice.FinishReason != nil && *choice.FinishReason != "" {
+		o.reason = *choice.FinishReason
+	}
+	if choice.Delta == nil {
+		return nil
+	}
+
+	// 工具调用结束时一并输出
+	o.toolCalls = mergeCalls(o.toolCalls, choice.Delta.ToolCalls)
+
+	if choice.Delta.Content == "" && choice.Delta.ReasoningContent == "" {
+		return nil
+	}
+	return o.write(o.message(choice.Delta.Content, choice.Delta.ReasoningContent, nil))
+}
+
+func (o *ollamaSink) Done() error {
+	if o.done {
+		return nil
+	}
+	o.done = true
+	obj := o.message("", "", o.calls())
+	o.stats(obj)
+	return o.write(obj)
+}
+
+func (o *ollamaSink) Complete(code int, body interface{}) error {
+	if code >= http.StatusBadRequest {
+		message := errorMessage(code, body)
+		if o.written {
+			return o.write(gin.H{"error": message})
+		}
+		ollamaError(o.gtx, code, message)
+		return nil
+	}
+
+	resp, ok := body.(model.Response)
+	if !ok {
+		o.gtx.JSON(code, body)
+		return nil
+	}
+
+	content, reasoning := "", ""
+	if len(resp.Choices) > 0 && resp.Choices[0].Message != nil {
+		message := resp.Choices[0].Message
+		content, reasoning = message.Content, message.ReasoningContent
+		for _, call := range message.ToolCalls {
+			o.toolCalls = append(o.toolCalls, parseCall(call))
+		}
+		if reason := resp.Choices[0].FinishReason; reason != nil {
+			o.reason = *reason
+		}
+	}
+	o.usage = resp.Usage
+	o.first = time.Now()
+
+	obj := o.message(content, reasoning, o.calls())
+	o.stats(obj)
+	o.gtx.JSON(http.StatusOK, obj)
+	return nil
+}
+
+func (o *ollamaSink) message(content, reasoning string, toolCalls []model.Keyv[interface{}]) gin.H {
+	obj := gin.H{
+		"model":      o.model,
+		"created_at": time.Now().Format(time.RFC3339Nano),
+		"done":       false,
+	}
+	if o.generate {
+		obj["response"] = content
+		if reasoning != "" {
+			obj["thinking"] = reasoning
+		}
+		return obj
+	}
+
+	message := ollamaMessage{Role: "assistant", Content: content, Thinking: reasoning, ToolCalls: toolCalls}
+	obj["message"] = message
+	return obj
+}
+
+// 转换为 ollama 的参数格式（对象而非字符串）
+func (o *ollamaSink) calls() (slice []model.Keyv[interface{}]) {
+	for _, call := range o.toolCalls {
+		slice = append(slice, model.Keyv[interface{}]{
+			"function": map[string]interface{}{"name": call.Name, "arguments": call.args()},
+		})
+	}
+	return
+}
+
+func (o *ollamaSink) stats(obj gin.H) {
+	now := time.Now()
+	if o.first.IsZero() {
+		o.first = now
+	}
+
+	reason := o.reason
+	if reason == "" || reason == "tool_calls" {
+		reason = "stop"
+	}
+
+	obj["done"] = true
+	obj["done_reason"] = reason
+	obj["total_duration"] = now.Sub(o.started).Nanoseconds()
+	obj["load_duration"] = 0
+	obj["prompt_eval_count"] = usageInt(o.usage, "prompt_tokens")
+	obj["prompt_eval_duration"] = o.first.Sub(o.started).Nanoseconds()
+	obj["eval_count"] = usageInt(o.usage, "completion_tokens")
+	obj["eval_duration"] = now.Sub(o.first).Nanoseconds()
+	if o.generate {
+		obj["context"] = []int{}
+	}
+}
+
+func (o *ollamaSink) write(obj interface{}) error {
+	if !o.written {
+		o.written = true
+		o.first = time.Now()
+		h := o.gtx.Writer.Header()
+		h.Set("Content-Type", "application/x-ndjson")
+		h.Del("Transfer-Encoding")
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		logger.Error(err)
+		return err
+	}
+	if _, err = o.gtx.Writer.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	o.gtx.Writer.Flush()
+	return nil
+}
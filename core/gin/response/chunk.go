@@ -1,11 +1,11 @@
 package response
 
 import (
+	"chatgpt-adapter/core/common/inited"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/bincooo/emit.io"
-	"github.com/iocgo/sdk/env"
 	"math/rand"
 	"net/http"
 	"strings"
@@ -13,15 +13,17 @@ import (
 
 	"chatgpt-adapter/core/common"
 	"chatgpt-adapter/core/common/vars"
+	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/logger"
-	"github.com/gin-gonic/gin"
+	"chatgpt-adapter/core/plugin"
 )
 
 var (
 	stop        = "stop"
 	toolCalls   = "tool_calls"
 	canResponse = "__can-response__"
+	streaming   = "__streaming__"
 
 	EOF = "<CHAR_trun>"
 
@@ -47,7 +49,7 @@ var (
 	}
 )
 
-func MessageValidator(ctx *gin.Context) bool {
+func MessageValidator(ctx *inter.Context) bool {
 	completion := common.GetGinCompletion(ctx)
 	messageL := len(completion.Messages)
 	if messageL == 0 {
@@ -90,12 +92,12 @@ func MessageValidator(ctx *gin.Context) bool {
 //	code 401 http.StatusUnauthorized
 //	err.Type ...
 
-func Error(ctx *gin.Context, code int, err interface{}) {
+func Error(ctx *inter.Context, code int, err interface{}) {
 	ctx.Set(canResponse, "No!")
 	code = errorToCode(code, err)
 
 	if str, ok := err.(string); ok {
-		ctx.JSON(code, gin.H{
+		writeJSON(ctx, code, map[string]interface{}{
 			"error": map[string]string{
 				"message": str,
 			},
@@ -104,7 +106,7 @@ func Error(ctx *gin.Context, code int, err interface{}) {
 	}
 
 	if e, ok := err.(error); ok {
-		ctx.JSON(code, gin.H{
+		writeJSON(ctx, code, map[string]interface{}{
 			"error": map[string]string{
 				"message": e.Error(),
 			},
@@ -112,7 +114,7 @@ func Error(ctx *gin.Context, code int, err interface{}) {
 		return
 	}
 
-	ctx.JSON(code, gin.H{
+	writeJSON(ctx, code, map[string]interface{}{
 		"error": map[string]string{
 			"message": fmt.Sprintf("%v", err),
 		},
@@ -153,23 +155,24 @@ label:
 	return code
 }
 
-func Response(ctx *gin.Context, mod, content string) {
+func Response(ctx *inter.Context, mod, content string) {
 	ReasonResponse(ctx, mod, content, "")
 }
 
-func ReasonResponse(ctx *gin.Context, mod, content, reasoningContent string) {
+func ReasonResponse(ctx *inter.Context, mod, content, reasoningContent string) {
 	if reasoningContent == "" {
 		reasoningContent = ctx.GetString(vars.GinThinkReason)
 	}
 
 	ctx.Set(canResponse, "No!")
+	content = plugin.Response(ctx, content)
 	created := time.Now().Unix()
 	usage := common.GetGinCompletionUsage(ctx)
-	if env.Env.GetBool("server.no-usage") {
+	if inited.Env().GetBool("server.no-usage") {
 		usage = DefaultUsage
 	}
 
-	ctx.JSON(http.StatusOK, model.Response{
+	writeJSON(ctx, http.StatusOK, model.Response{
 		Model:   "LLM",
 		Created: created,
 		Id:      fmt.Sprintf("chatcmpl-%d", created),
@@ -191,7 +194,7 @@ func ReasonResponse(ctx *gin.Context, mod, content, reasoningContent string) {
 	})
 }
 
-func Echo(ctx *gin.Context, mode, content string, sse bool) {
+func Echo(ctx *inter.Context, mode, content string, sse bool) {
 	if !sse {
 		Response(ctx, mode, content)
 	} else {
@@ -218,22 +221,24 @@ func Echo(ctx *gin.Context, mode, content string, sse bool) {
 	}
 }
 
-func SSEResponse(ctx *gin.Context, mod, content string, created int64) {
+func SSEResponse(ctx *inter.Context, mod, content string, created int64) {
 	ReasonSSEResponse(ctx, mod, content, "", created)
 }
 
-func ReasonSSEResponse(ctx *gin.Context, mod, content, reasoningContent string, created int64) {
+func ReasonSSEResponse(ctx *inter.Context, mod, content, reasoningContent string, created int64) {
 	ctx.Set(canResponse, "No!")
-	setSSEHeader(ctx)
 
 	done := false
 	finishReason := ""
 	usage := common.GetGinCompletionUsage(ctx)
-	if env.Env.GetBool("server.no-usage") {
+	if inited.Env().GetBool("server.no-usage") {
 		usage = DefaultUsage
 	}
 
 	if content == "[DONE]" {
+		if appended := plugin.Append(ctx); appended != "" {
+			ReasonSSEResponse(ctx, mod, appended, "", created)
+		}
 		done = true
 		content = ""
 		finishReason = "stop"
@@ -268,6 +273,7 @@ func ReasonSSEResponse(ctx *gin.Context, mod, content, reasoningContent string,
 	}
 
 	if content != "" {
+		plugin.Collect(ctx, content)
 		splitEach(content, func(value string) {
 			response := model.Response{
 				Model:   "LLM",
@@ -321,12 +327,12 @@ label:
 	}
 }
 
-func ToolCallResponse(ctx *gin.Context, mod, name, args string) {
+func ToolCallResponse(ctx *inter.Context, mod, name, args string) {
 	ctx.Set(canResponse, "No!")
 	created := time.Now().Unix()
 	usage := common.GetGinCompletionUsage(ctx)
 
-	ctx.JSON(http.StatusOK, model.Response{
+	writeJSON(ctx, http.StatusOK, model.Response{
 		Model:   "LLM",
 		Created: created,
 		Id:      fmt.Sprintf("chatcmpl-%d", created),
@@ -360,9 +366,8 @@ func ToolCallResponse(ctx *gin.Context, mod, name, args string) {
 	})
 }
 
-func SSEToolCallResponse(ctx *gin.Context, mod, name, args string, created int64) {
+func SSEToolCallResponse(ctx *inter.Context, mod, name, args string, created int64) {
 	ctx.Set(canResponse, "No!")
-	setSSEHeader(ctx)
 	usage := common.GetGinCompletionUsage(ctx)
 
 	response := model.Response{
@@ -409,82 +414,93 @@ func SSEToolCallResponse(ctx *gin.Context, mod, name, args string, created int64
 	Event(ctx, "", "[DONE]")
 }
 
-func NotResponse(ctx *gin.Context) bool {
+func NotResponse(ctx *inter.Context) bool {
 	return ctx.GetString(canResponse) == "" && NotSSEHeader(ctx)
 }
 
-func NotSSEHeader(ctx *gin.Context) bool {
-	return notHeader(ctx, "text/event-stream")
+// 尚未开始流式输出
+func NotSSEHeader(ctx *inter.Context) bool {
+	return !ctx.GetBool(streaming)
 }
 
-func notHeader(ctx *gin.Context, types ...string) bool {
-	h := ctx.Writer.Header()
-	t := h.Get("Content-Type")
-	if t == "" {
-		return true
-	}
-	for _, mine := range types {
-		if strings.Contains(t, mine) {
-			return false
-		}
+// 输出流式片段；event 不为空时为自定义事件，只有实现了 inter.EventSink 的接收端才会收到
+func Event(ctx *inter.Context, event string, data interface{}) {
+	ctx.Set(canResponse, "No!")
+	ctx.Set(streaming, true)
+	if ctx.Sink == nil {
+		logger.Ctx(ctx).Error("response sink is not set")
+		return
 	}
-	return true
-}
 
-func setSSEHeader(ctx *gin.Context) {
-	h := ctx.Writer.Header()
-	if h.Get("Content-Type") == "" {
-		h.Set("Content-Type", "text/event-stream")
-		h.Set("Transfer-Encoding", "chunked")
-		h.Set("Cache-Control", "no-cache")
-		h.Set("Connection", "keep-alive")
-		h.Set("X-Accel-Buffering", "no")
+	if event != "" {
+		if sink, ok := ctx.Sink.(inter.EventSink); ok {
+			if err := sink.Event(event, data); err != nil {
+				logger.Ctx(ctx).Error(err)
+				ctx.Set(vars.GinClose, true)
+			}
+		}
+		return
 	}
+	emitSink(ctx, ctx.Sink, data)
 }
 
-func Event(ctx *gin.Context, event string, data interface{}) {
+// 转发已生成的完整响应，如网关执行工具后模型的最终回答
+func Forward(ctx *inter.Context, code int, body interface{}) {
 	ctx.Set(canResponse, "No!")
-	setSSEHeader(ctx)
-
-	w := ctx.Writer
-	str, ok := data.(string)
-	if ok {
-		layout := ""
-		if event != "" {
-			layout = "event: " + event + "\n"
-		}
-
-		layout = "data: %s\n\n"
-		_, err := fmt.Fprintf(w, layout, str)
-		if err != nil {
-			logger.Error(err)
-			ctx.Set(vars.GinClose, true)
-			return
-		}
+	writeJSON(ctx, code, body)
+}
 
-		w.Flush()
+// 完整响应
+func writeJSON(ctx *inter.Context, code int, body interface{}) {
+	if ctx.Sink == nil {
+		logger.Ctx(ctx).Error("response sink is not set")
 		return
 	}
-
-	marshal, err := json.Marshal(data)
-	if err != nil {
-		logger.Error(err)
+	ctx.Set(vars.GinStatus, code)
+	if err := ctx.Sink.Complete(code, body); err != nil {
+		logger.Ctx(ctx).Error(err)
 		ctx.Set(vars.GinClose, true)
-		return
 	}
-
-	layout := ""
-	if event != "" {
-		layout = "event: " + event + "\n"
+}
+func emitSink(ctx *inter.Context, sink inter.Sink, data interface{}) {
+	var err error
+	switch value := data.(type) {
+	case string:
+		if value == "[DONE]" {
+			err = sink.Done()
+			break
+		}
+		// 部分适配器直接输出文本片段
+		var chunk model.Response
+		chunk.Object = "chat.completion.chunk"
+		chunk.Choices = []model.Choice{{Delta: &struct {
+			Type             string `json:"type,omitempty"`
+			Role             string `json:"role,omitempty"`
+			Content          string `json:"content,omitempty"`
+			ReasoningContent string `json:"reasoning_content,omitempty"`
+
+			ToolCalls []model.Keyv[interface{}] `json:"tool_calls,omitempty"`
+		}{Type: "text", Role: "assistant", Content: value}}}
+		err = sink.Chunk(chunk)
+	case model.Response:
+		err = sink.Chunk(value)
+	default:
+		var chunk model.Response
+		marshal, e := json.Marshal(value)
+		if e == nil {
+			e = json.Unmarshal(marshal, &chunk)
+		}
+		if e != nil {
+			err = e
+			break
+		}
+		err = sink.Chunk(chunk)
 	}
-	layout += "data: %s\n\n"
-	_, err = fmt.Fprintf(w, layout, marshal)
+
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		ctx.Set(vars.GinClose, true)
-		return
 	}
-	w.Flush()
 }
 
 func splitEach(content string, cb func(value string)) {
@@ -0,0 +1,275 @@
+package response
+
+import (
+	"bytes"
+	"chatgpt-adapter/core/gin/inter"
+	"fmt"
+	"path"
+	"slices"
+	"sync/atomic"
+	"text/template"
+
+	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/common/inited"
+	"chatgpt-adapter/core/common/vars"
+	"chatgpt-adapter/core/logger"
+	"github.com/iocgo/sdk/env"
+)
+
+// 消息扁平化为文本时使用的角色格式，可按模型或适配器选择
+//
+//	prompt-format:
+//	  - name: chatml
+//	    models: [ "custom/qwen*" ]   # 模型，glob
+//	    adapters: [ "grok" ]         # 适配器
+//	    prefix:                      # 角色前缀模版，* 为其它角色
+//	      "*": "<|im_start|>{{.role}}\n"
+//	    suffix:
+//	      "*": "<|im_end|>\n"
+//	    system: user                 # system 消息的处理：keep 保留（默认）、user 作为 user 消息
+//	    stop: [ "<|im_end|>" ]
+//
+// 与内置格式（default、gpt、deepseek、claude、bing）同名且未定义 prefix 时，仅追加选择条件
+type promptFormat struct {
+	Name     string            `mapstructure:"name"`
+	Models   []string          `mapstructure:"models"`
+	Adapters []string          `mapstructure:"adapters"`
+	Prefix   map[string]string `mapstructure:"prefix"`
+	Suffix   map[string]string `mapstructure:"suffix"`
+	System   string            `mapstructure:"system"`
+	Stop     []string          `mapstructure:"stop"`
+
+	prefix map[string]*template.Template
+	suffix map[string]*template.Template
+}
+
+type formatTable struct {
+	list  []*promptFormat          // 配置的选择条件，按顺序匹配
+	named map[string]*promptFormat // 内置及配置的格式
+}
+
+const formatKey = "__prompt-format__"
+
+var (
+	formats atomic.Pointer[formatTable]
+
+	builtinFormats = []promptFormat{
+		{
+			Name:   "default",
+			Prefix: map[string]string{"*": "<|{{.role}}|>\n"},
+			Suffix: map[string]string{"*": END},
+		},
+		{
+			Name: "gpt",
+			Prefix: map[string]string{
+				"user":      "<|start|>user\n",
+				"assistant": "<|start|>assistant\n",
+				"*":         "<|start|>system\n",
+			},
+			Suffix: map[string]string{"*": END},
+		},
+		{
+			Name:   "deepseek",
+			Prefix: map[string]string{"*": "<{{.role}}>\n"},
+			Suffix: map[string]string{"*": "\n</{{.role}}>\n\n"},
+			Stop:   []string{"</assistant>"},
+		},
+		{
+			Name: "claude",
+			Prefix: map[string]string{
+				"user":      "\n{{or (env \"separator.claude\") \"\\n\"}}\nHuman: ",
+				"assistant": "\n{{or (env \"separator.claude\") \"\\n\"}}\nAssistant: ",
+				"*":         "\n{{or (env \"separator.claude\") \"\\n\"}}\nSYSTEM: ",
+			},
+		},
+		{
+			Name: "bing",
+			Prefix: map[string]string{
+				"user":      "Q: ",
+				"assistant": "A: ",
+				"*":         "Ins: \n",
+			},
+		},
+	}
+)
+
+func init() {
+	inited.AddInitialized(func(env *env.Environment) {
+		table, err := loadFormats(env)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		formats.Store(table)
+	})
+
+	inited.AddReloaded(func(env *env.Environment) (func(), error) {
+		table, err := loadFormats(env)
+		if err != nil {
+			return nil, err
+		}
+		return func() {
+			formats.Store(table)
+			logger.Infof("reload prompt formats: %d", len(table.list))
+		}, nil
+	})
+}
+
+func loadFormats(env *env.Environment) (*formatTable, error) {
+	var objs []promptFormat
+	if err := env.UnmarshalKey("prompt-format", &objs); err != nil {
+		return nil, fmt.Errorf("prompt-format: %v", err)
+	}
+
+	table := builtinTable()
+	for i := range objs {
+		f := &objs[i]
+		if f.Name == "" {
+			return nil, fmt.Errorf("prompt-format[%d]: name is required", i)
+		}
+		if f.System != "" && f.System != "keep" && f.System != "user" {
+			return nil, fmt.Errorf("prompt-format[%d]: unknown system policy '%s'", i, f.System)
+		}
+		for _, pattern := range f.Models {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("prompt-format[%d]: bad pattern '%s'", i, pattern)
+			}
+		}
+
+		if len(f.Prefix) == 0 {
+			builtin, ok := table.named[f.Name]
+			if !ok {
+				return nil, fmt.Errorf("prompt-format[%d]: `%s` requires prefix", i, f.Name)
+			}
+			f.Prefix, f.Suffix = builtin.Prefix, builtin.Suffix
+			f.Stop = append(slices.Clone(builtin.Stop), f.Stop...)
+			if f.System == "" {
+				f.System = builtin.System
+			}
+		}
+		if err := f.compile(); err != nil {
+			return nil, fmt.Errorf("prompt-format[%d]: %v", i, err)
+		}
+
+		table.named[f.Name] = f
+		if len(f.Models) > 0 || len(f.Adapters) > 0 {
+			table.list = append(table.list, f)
+		}
+	}
+	return table, nil
+}
+
+func builtinTable() *formatTable {
+	table := &formatTable{named: make(map[string]*promptFormat)}
+	for i := range builtinFormats {
+		f := builtinFormats[i]
+		if err := f.compile(); err != nil {
+			panic(err)
+		}
+		table.named[f.Name] = &f
+	}
+	return table
+}
+
+func (f *promptFormat) compile() (err error) {
+	compile := func(values map[string]string) (map[string]*template.Template, error) {
+		templates := make(map[string]*template.Template, len(values))
+		for role, value := range values {
+			t, err := template.New(f.Name + "." + role).
+				Funcs(template.FuncMap{"env": envString}).
+				Parse(value)
+			if err != nil {
+				return nil, err
+			}
+			templates[role] = t
+		}
+		return templates, nil
+	}
+
+	if f.prefix, err = compile(f.Prefix); err != nil {
+		return
+	}
+	f.suffix, err = compile(f.Suffix)
+	return
+}
+
+func envString(key string) string {
+	if inited.Env() == nil {
+		return ""
+	}
+	return inited.Env().GetString(key)
+}
+
+// 渲染角色的前缀和后缀
+func (f *promptFormat) render(role string) (prefix, suffix string) {
+	if f.System == "user" && (role == "system" || role == "developer") {
+		role = "user"
+	}
+	return execute(f.prefix, role), execute(f.suffix, role)
+}
+
+func execute(templates map[string]*template.Template, role string) string {
+	t, ok := templates[role]
+	if !ok {
+		if t, ok = templates["*"]; !ok {
+			return ""
+		}
+	}
+
+	var buffer bytes.Buffer
+	if err := t.Execute(&buffer, map[string]interface{}{"role": role}); err != nil {
+		logger.Warnf("prompt-format `%s` execute failed: %v", t.Name(), err)
+		return ""
+	}
+	return buffer.String()
+}
+
+// 当前请求使用的格式：配置的选择条件优先，其次按模型名推断内置格式
+func formatOf(ctx *inter.Context) *promptFormat {
+	if f, ok := common.GetGinValue[*promptFormat](ctx, formatKey); ok {
+		return f
+	}
+
+	table := formats.Load()
+	if table == nil {
+		formats.CompareAndSwap(nil, builtinTable())
+		table = formats.Load()
+	}
+
+	completion := common.GetGinCompletion(ctx)
+	adapter := ctx.GetString(vars.GinAdapter)
+
+	var f *promptFormat
+	for _, it := range table.list {
+		if slices.Contains(it.Adapters, adapter) || matchModel(it.Models, completion.Model) {
+			f = it
+			break
+		}
+	}
+
+	if f == nil {
+		name := "default"
+		switch {
+		case IsClaude(ctx, completion.Model):
+			name = "claude"
+		case IsBing(completion.Model):
+			name = "bing"
+		case IsGPT(completion.Model):
+			name = "gpt"
+		case IsDeepseek(completion.Model):
+			name = "deepseek"
+		}
+		f = table.named[name]
+	}
+
+	ctx.Set(formatKey, f)
+	return f
+}
+
+func matchModel(patterns []string, model string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, model); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package response
+
+import (
+	"chatgpt-adapter/core/gin/inter"
+	"encoding/json"
+
+	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/gin/model"
+)
+
+// 请求使用旧的 functions 接口时，将响应中的 tool_calls 转换为 function_call，
+// 旧接口只有一个调用，并行调用仅保留第一个；仅用于 openai 格式的 HTTP 响应
+func LegacyFunctions(ctx *inter.Context, data interface{}) interface{} {
+	if !common.IsGinLegacyFunctions(ctx) {
+		return data
+	}
+	if _, ok := data.(model.Response); !ok {
+		return data
+	}
+
+	var obj map[string]interface{}
+	bytes, err := json.Marshal(data)
+	if err != nil || json.Unmarshal(bytes, &obj) != nil {
+		return data
+	}
+
+	choices, _ := obj["choices"].([]interface{})
+	for _, it := range choices {
+		choice, ok := it.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, key := range []string{"message", "delta"} {
+			message, isMap := choice[key].(map[string]interface{})
+			if !isMap {
+				continue
+			}
+			calls, _ := message["tool_calls"].([]interface{})
+			if len(calls) == 0 {
+				continue
+			}
+
+			delete(message, "tool_calls")
+			call, _ := calls[0].(map[string]interface{})
+			if index, exists := call["index"]; exists && index != float64(0) {
+				continue
+			}
+			message["function_call"] = call["function"]
+			if key == "message" {
+				choice["finish_reason"] = "function_call"
+			}
+		}
+
+		if choice["finish_reason"] == toolCalls {
+			choice["finish_reason"] = "function_call"
+		}
+	}
+	return obj
+}
@@ -3,13 +3,16 @@ package response
 import (
 	"chatgpt-adapter/core/common"
 	"chatgpt-adapter/core/common/vars"
-	"github.com/gin-gonic/gin"
+	"fmt"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"chatgpt-adapter/core/common/inited"
 	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/logger"
+	"chatgpt-adapter/core/plugin"
 	"github.com/iocgo/sdk/env"
 
 	regexp "github.com/dlclark/regexp2"
@@ -21,8 +24,10 @@ const (
 	MatMatched             // 匹配器命中，不再执行下一个
 )
 
+type matchersFunc func(gtx *inter.Context, cb func(t byte, str string)) []inter.Matcher
+
 var (
-	globalMatchers func(gtx *gin.Context, cb func(t byte, str string)) []inter.Matcher
+	globalMatchers atomic.Pointer[matchersFunc]
 )
 
 type obj struct {
@@ -50,17 +55,49 @@ func init() {
 			logger.Fatal(err)
 		}
 		if len(objs) != 0 {
-			initMatchers(objs)
+			globalMatchers.Store(initMatchers(objs))
 		}
 	})
+
+	inited.AddReloaded(func(env *env.Environment) (func(), error) {
+		var objs []obj
+		if err := env.UnmarshalKey("matcher", &objs); err != nil {
+			return nil, fmt.Errorf("matcher: %v", err)
+		}
+		if err := validateMatchers(objs); err != nil {
+			return nil, err
+		}
+		return func() {
+			globalMatchers.Store(initMatchers(objs))
+			logger.Infof("reload matchers: %d", len(objs))
+		}, nil
+	})
+}
+
+// 重载前校验，避免请求期间 MustCompile panic
+func validateMatchers(objs []obj) error {
+	compile := regexp.MustCompile(`"(.+)" *: *"(.*)"`, regexp.ECMAScript)
+	for i, o := range objs {
+		if o.Regex == "" {
+			return fmt.Errorf("no regular processing is configured: matcher[%d].regex", i)
+		}
+		matched, err := compile.FindStringMatch(o.Regex)
+		if err != nil || matched == nil {
+			return fmt.Errorf("the format has not been written correctly: matcher[%d].regex", i)
+		}
+		if _, err = regexp.Compile(matched.GroupByNumber(1).String(), regexp.ECMAScript); err != nil {
+			return fmt.Errorf("matcher[%d].regex compile failed: %v", i, err)
+		}
+	}
+	return nil
 }
 
-func initMatchers(objs []obj) {
+func initMatchers(objs []obj) *matchersFunc {
 	if len(objs) == 0 {
-		return
+		return nil
 	}
 
-	globalMatchers = func(gtx *gin.Context, cb func(t byte, str string)) (matchers []inter.Matcher) {
+	var h matchersFunc = func(gtx *inter.Context, cb func(t byte, str string)) (matchers []inter.Matcher) {
 		for i, o := range objs {
 			match, over := o.Match, o.Over
 			maxLen := o.Max
@@ -69,14 +106,14 @@ func initMatchers(objs []obj) {
 			}
 
 			if o.Regex == "" {
-				logger.Errorf("no regular processing is configured: matcher[%d].regex", i)
+				logger.Ctx(gtx).Errorf("no regular processing is configured: matcher[%d].regex", i)
 				continue
 			}
 
 			compile := regexp.MustCompile(`"(.+)" *: *"(.*)"`, regexp.ECMAScript)
 			matched, err := compile.FindStringMatch(o.Regex)
 			if err != nil {
-				logger.Errorf("the format has not been written correctly: matcher[%d].regex ==> %v", i, err)
+				logger.Ctx(gtx).Errorf("the format has not been written correctly: matcher[%d].regex ==> %v", i, err)
 				continue
 			}
 
@@ -108,7 +145,7 @@ func initMatchers(objs []obj) {
 						}
 					}
 
-					logger.Infof("execute matcher[%s] content:\n%s", matcher.Find, content)
+					logger.Ctx(gtx).Infof("execute matcher[%s] content:\n%s", matcher.Find, content)
 					result, err = c.Replace(content, replacement, 0, 1)
 					if o.ThinkReason && content != "" {
 						gtx.Set(vars.GinThinkReason, result)
@@ -117,7 +154,7 @@ func initMatchers(objs []obj) {
 					}
 
 					if err != nil {
-						logger.Warn("compile failed: "+regex, err)
+						logger.Ctx(gtx).Warn("compile failed: "+regex, err)
 						return MatMatched, cache, content
 					}
 					return MatMatched, cache, result
@@ -127,13 +164,15 @@ func initMatchers(objs []obj) {
 		}
 		return
 	}
+	return &h
 }
 
-func NewMatchers(ctx *gin.Context, cb func(t byte, str string)) (slice []inter.Matcher) {
+func NewMatchers(ctx *inter.Context, cb func(t byte, str string)) (slice []inter.Matcher) {
 	slice = make([]inter.Matcher, 0)
-	if globalMatchers != nil {
-		slice = append(slice, globalMatchers(ctx, cb)...)
+	if h := globalMatchers.Load(); h != nil {
+		slice = append(slice, (*h)(ctx, cb)...)
 	}
+	slice = append(slice, plugin.Matchers(ctx)...)
 	slice = append(slice, newCancel(ctx)...)
 	return
 }
@@ -145,16 +184,13 @@ func NewMatcher(find string, h func(index int, content string) (state int, cache
 	}
 }
 
-func newCancel(ctx *gin.Context) (slice []inter.Matcher) {
+func newCancel(ctx *inter.Context) (slice []inter.Matcher) {
 	convertRole1, _ := ConvertRole(ctx, "user")
 	convertRole2, _ := ConvertRole(ctx, "system")
 	convertRole3, _ := ConvertRole(ctx, "assistant")
 
 	completion := common.GetGinCompletion(ctx)
-	sequences := completion.StopSequences
-	if IsDeepseek(completion.Model) {
-		sequences = append(sequences, strings.TrimSpace(deepseekEnd("assistant")))
-	}
+	sequences := append(slices.Clone(completion.StopSequences), formatOf(ctx).Stop...)
 
 	once := true
 	for _, match := range append(sequences,
@@ -179,7 +215,7 @@ func newCancel(ctx *gin.Context) (slice []inter.Matcher) {
 
 				state = MatMatched
 				result = EOF
-				logger.Infof("matched block [%s], will response stop ...", match)
+				logger.Ctx(ctx).Infof("matched block [%s], will response stop ...", match)
 				return
 			},
 		})
@@ -0,0 +1,87 @@
+package gin
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"chatgpt-adapter/core/gin/model"
+)
+
+// 兼容协议（Ollama、Gemini）的 inter.Sink 共用的转换函数
+
+type toolCall struct {
+	Name      string
+	Arguments string
+}
+
+// 适配器输出的 function 字段类型不一，统一解析
+func parseCall(call model.Keyv[interface{}]) (c toolCall) {
+	value, _ := call.Get("function")
+	marshal, _ := json.Marshal(value)
+	var fn struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if json.Unmarshal(marshal, &fn) != nil {
+		return
+	}
+	c.Name = fn.Name
+	if err := json.Unmarshal(fn.Arguments, &c.Arguments); err != nil {
+		c.Arguments = string(fn.Arguments)
+	}
+	return
+}
+
+// 流式输出的工具调用参数分多个片段，按序合并
+func mergeCalls(calls []toolCall, deltas []model.Keyv[interface{}]) []toolCall {
+	for _, delta := range deltas {
+		c := parseCall(delta)
+		if c.Name != "" {
+			calls = append(calls, c)
+			continue
+		}
+		if len(calls) > 0 {
+			calls[len(calls)-1].Arguments += c.Arguments
+		}
+	}
+	return calls
+}
+
+// 参数转为对象，解析失败时保留原字符串
+func (c toolCall) args() (args interface{}) {
+	args = map[string]interface{}{}
+	if c.Arguments != "" {
+		if err := json.Unmarshal([]byte(c.Arguments), &args); err != nil {
+			args = c.Arguments
+		}
+	}
+	return
+}
+
+// 适配器可能透传上游 json 解析后的 float64
+func usageInt(usage map[string]interface{}, key string) int {
+	switch value := usage[key].(type) {
+	case int:
+		return value
+	case int64:
+		return int(value)
+	case float64:
+		return int(value)
+	}
+	return 0
+}
+
+// 取出 response.Error 写入的错误信息
+func errorMessage(code int, body interface{}) string {
+	var value struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	marshal, _ := json.Marshal(body)
+	_ = json.Unmarshal(marshal, &value)
+	if value.Error.Message == "" {
+		return http.StatusText(code)
+	}
+	return value.Error.Message
+}
@@ -1,20 +1,14 @@
 package gin
 
 import (
-	"chatgpt-adapter/core/common/toolcall"
-	"chatgpt-adapter/core/common/vars"
+	"chatgpt-adapter/core/dispatch"
 	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
-	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/iocgo/sdk"
-	"time"
 )
 
-const ginTokens = "__tokens__"
-
 // @Router()
 type Handler struct{ extensions []inter.Adapter }
 
@@ -39,78 +33,11 @@ func (h *Handler) index(gtx *gin.Context) {
 func (h *Handler) completions(gtx *gin.Context) {
 	var completion model.Completion
 	if err := gtx.BindJSON(&completion); err != nil {
-		logger.Error(err)
-		response.Error(gtx, -1, err)
-		return
-	}
-
-	gtx.Set(vars.GinCompletion, completion)
-	logger.Infof("curr model: %s", completion.Model)
-	if !response.MessageValidator(gtx) {
+		logger.Ctx(gtx.Request.Context()).Error(err)
+		failed(gtx, -1, err)
 		return
 	}
-
-	for _, extension := range h.extensions {
-		ok, err := extension.Match(gtx, completion.Model)
-		if err != nil {
-			response.Error(gtx, -1, err)
-			return
-		}
-		if !ok {
-			continue
-		}
-
-		gtx.Set(vars.GinMatchers, response.NewMatchers(gtx, func(t byte, str string) {
-			if completion.Stream && t == 0 {
-				response.SSEResponse(gtx, "matcher", str, time.Now().Unix())
-			}
-			if completion.Stream && t == 1 {
-				response.ReasonSSEResponse(gtx, "matcher", "", str, time.Now().Unix())
-			}
-		}))
-
-		messages, err := extension.HandleMessages(gtx, completion)
-		if err != nil {
-			logger.Error("Error handling messages: ", err)
-			response.Error(gtx, 500, err)
-			return
-		}
-
-		if gtx.GetInt(ginTokens) == 0 {
-			calcTokens(gtx, messages)
-		}
-
-		completion.Messages = messages
-		gtx.Set(vars.GinCompletion, completion)
-
-		if toolcall.NeedExec(gtx) {
-			if ok, err = extension.ToolChoice(gtx); err != nil {
-				response.Error(gtx, -1, err)
-				return
-			}
-			if ok {
-				return
-			}
-		}
-
-		if err = extension.Completion(gtx); err != nil {
-			response.Error(gtx, -1, err)
-		}
-		return
-	}
-	response.Error(gtx, -1, fmt.Sprintf("model '%s' is not not yet supported", completion.Model))
-}
-
-func calcTokens(gtx *gin.Context, messages []model.Keyv[interface{}]) {
-	tokens := 0
-	for _, message := range messages {
-		if !message.IsString("content") {
-			continue
-		}
-		value := message.GetString("content")
-		tokens += response.CalcTokens(value)
-	}
-	gtx.Set(ginTokens, tokens)
+	openai(gtx, func(ctx *inter.Context) { dispatch.Completions(ctx, h.extensions, completion) })
 }
 
 // @POST(path = "
@@ -122,27 +49,11 @@ func calcTokens(gtx *gin.Context, messages []model.Keyv[interface{}]) {
 func (h *Handler) embeddings(gtx *gin.Context) {
 	var embed model.Embed
 	if err := gtx.BindJSON(&embed); err != nil {
-		logger.Error(err)
-		response.Error(gtx, -1, err)
+		logger.Ctx(gtx.Request.Context()).Error(err)
+		failed(gtx, -1, err)
 		return
 	}
-
-	gtx.Set(vars.GinEmbedding, embed)
-	logger.Infof("curr model: %s", embed.Model)
-	for _, extension := range h.extensions {
-		ok, err := extension.Match(gtx, embed.Model)
-		if err != nil {
-			response.Error(gtx, -1, err)
-			return
-		}
-		if ok {
-			if err = extension.Embedding(gtx); err != nil {
-				response.Error(gtx, -1, err)
-			}
-			return
-		}
-	}
-	response.Error(gtx, -1, fmt.Sprintf("model '%s' is not not yet supported", embed.Model))
+	openai(gtx, func(ctx *inter.Context) { dispatch.Embeddings(ctx, h.extensions, embed) })
 }
 
 // @POST(path = "
@@ -155,25 +66,10 @@ func (h *Handler) embeddings(gtx *gin.Context) {
 func (h *Handler) generations(gtx *gin.Context) {
 	var generation model.Generation
 	if err := gtx.BindJSON(&generation); err != nil {
-		response.Error(gtx, 500, err)
+		failed(gtx, 500, err)
 		return
 	}
-
-	gtx.Set(vars.GinGeneration, generation)
-	for _, extension := range h.extensions {
-		ok, err := extension.Match(gtx, generation.Model)
-		if err != nil {
-			response.Error(gtx, 500, err)
-			return
-		}
-		if ok {
-			if err = extension.Generation(gtx); err != nil {
-				response.Error(gtx, -1, err)
-			}
-			return
-		}
-	}
-	response.Error(gtx, -1, fmt.Sprintf("model '%s' is not not yet supported", generation.Model))
+	openai(gtx, func(ctx *inter.Context) { dispatch.Generations(ctx, h.extensions, generation) })
 }
 
 // @GET(path = "
@@ -183,12 +79,8 @@ func (h *Handler) generations(gtx *gin.Context) {
 //
 // ")
 func (h *Handler) models(gtx *gin.Context) {
-	models := make([]model.Model, 0)
-	for _, extension := range h.extensions {
-		models = append(models, extension.Models()...)
-	}
 	gtx.JSON(200, gin.H{
 		"object": "list",
-		"data":   models,
+		"data":   dispatch.Models(h.extensions),
 	})
 }
@@ -5,6 +5,7 @@ import (
 	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
 	"github.com/sirupsen/logrus"
 
+	"context"
 	"io"
 	"os"
 	"path"
@@ -15,7 +16,12 @@ import (
 	"time"
 )
 
-func InitLogger(basePath string, level logrus.Level) {
+var (
+	jsonFormat bool
+)
+
+// format: text | json
+func InitLogger(basePath string, level logrus.Level, format string) {
 	logrus.SetLevel(level)
 	if len(basePath) == 0 {
 		basePath = "log"
@@ -34,16 +40,36 @@ func InitLogger(basePath string, level logrus.Level) {
 
 	writers := []io.Writer{writer, os.Stdout}
 	logrus.SetOutput(io.MultiWriter(writers...))
-	logrus.SetFormatter(&nested.Formatter{
-		HideKeys:              true,
-		TimestampFormat:       "2006-01-02 15:04:05",
-		CallerFirst:           true,
-		NoColors:              true,
-		CustomCallerFormatter: CustomCallerFormatter,
-	})
+	jsonFormat = format == "json"
+	if jsonFormat {
+		logrus.SetFormatter(&logrus.JSONFormatter{
+			TimestampFormat: time.RFC3339Nano,
+			FieldMap: logrus.FieldMap{
+				logrus.FieldKeyTime: "time",
+				logrus.FieldKeyMsg:  "msg",
+			},
+			CallerPrettyfier: func(frame *runtime.Frame) (function string, file string) {
+				return "", path.Base(frame.File) + ":" + strconv.Itoa(frame.Line)
+			},
+		})
+	} else {
+		logrus.SetFormatter(&nested.Formatter{
+			HideKeys:              true,
+			TimestampFormat:       "2006-01-02 15:04:05",
+			CallerFirst:           true,
+			NoColors:              true,
+			CustomCallerFormatter: CustomCallerFormatter,
+		})
+	}
+	logrus.AddHook(requestHook{})
+	logrus.AddHook(redactHook{})
 	logrus.SetReportCaller(true)
 }
 
+func IsJSON() bool {
+	return jsonFormat
+}
+
 func CustomCallerFormatter(frame *runtime.Frame) string {
 	trimPackage := func(pkg string) string {
 		if pkg == "" {
@@ -68,8 +94,8 @@ func CustomCallerFormatter(frame *runtime.Frame) string {
 	}
 
 	// 尝试获取上层栈
-	pcs := make([]uintptr, 10)
-	depth := runtime.Callers(10, pcs)
+	pcs := make([]uintptr, 32)
+	depth := runtime.Callers(2, pcs)
 	frames := runtime.CallersFrames(pcs[:depth])
 	for f, next := frames.Next(); next; f, next = frames.Next() {
 		if f.PC == frame.PC {
@@ -142,3 +168,52 @@ func Fatal(args ...interface{}) {
 func Fatalf(format string, args ...interface{}) {
 	logrus.Fatalf(format, args...)
 }
+
+// 携带 context 的日志，context 中的请求id等字段会被记录
+//
+//	logger.Ctx(ctx).Infof("curr model: %s", model)
+func Ctx(ctx context.Context) Entry {
+	return Entry{logrus.WithContext(ctx)}
+}
+
+type Entry struct{ entry *logrus.Entry }
+
+func (e Entry) Trace(args ...interface{}) {
+	e.entry.Trace(args...)
+}
+
+func (e Entry) Tracef(format string, args ...interface{}) {
+	e.entry.Tracef(format, args...)
+}
+
+func (e Entry) Debug(args ...interface{}) {
+	e.entry.Debug(args...)
+}
+
+func (e Entry) Debugf(format string, args ...interface{}) {
+	e.entry.Debugf(format, args...)
+}
+
+func (e Entry) Info(args ...interface{}) {
+	e.entry.Info(args...)
+}
+
+func (e Entry) Infof(format string, args ...interface{}) {
+	e.entry.Infof(format, args...)
+}
+
+func (e Entry) Warn(args ...interface{}) {
+	e.entry.Warn(args...)
+}
+
+func (e Entry) Warnf(format string, args ...interface{}) {
+	e.entry.Warnf(format, args...)
+}
+
+func (e Entry) Error(args ...interface{}) {
+	e.entry.Error(args...)
+}
+
+func (e Entry) Errorf(format string, args ...interface{}) {
+	e.entry.Errorf(format, args...)
+}
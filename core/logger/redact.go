@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	redactRules = []struct {
+		reg  *regexp.Regexp
+		repl string
+	}{
+		{regexp.MustCompile(`(?i)(bearer\s+)[^\s"',;]+`), "${1}***"},
+		{regexp.MustCompile(`(?i)((?:proxy-)?authorization|x-(?:goog-)?api-key|set-cookie|cookies?)(\s*[:=]\s*)[^\r\n]+`), "${1}${2}***"},
+		{regexp.MustCompile(`(?i)("(?:token|cookies?|password|api[_-]?key|secret|access_token|refresh_token|authorization)"\s*:\s*")[^"]*(")`), "${1}***${2}"},
+		{regexp.MustCompile(`(?i)([?&](?:key|api[_-]?key|access_token|token)=)[^&\s"']+`), "${1}***"},
+		{regexp.MustCompile(`\bsk-[\w\-]{8,}`), "sk-***"},
+		{regexp.MustCompile(`\beyJ[\w\-]+\.[\w\-]+\.[\w\-]+`), "***"},
+	}
+)
+
+// 日志脱敏，屏蔽 token、cookie 等敏感信息
+type redactHook struct{}
+
+func (redactHook) Levels() []logrus.Level { return logrus.AllLevels }
+func (redactHook) Fire(entry *logrus.Entry) error {
+	entry.Message = Redact(entry.Message)
+	for k, v := range entry.Data {
+		if str, ok := v.(string); ok {
+			entry.Data[k] = Redact(str)
+		}
+	}
+	return nil
+}
+
+func Redact(str string) string {
+	for _, rule := range redactRules {
+		str = rule.reg.ReplaceAllString(str, rule.repl)
+	}
+	return str
+}
+
+// 生成密钥的展示标签，仅保留首尾少量字符
+func Label(secret string) string {
+	r := []rune(secret)
+	if len(r) == 0 {
+		return "-"
+	}
+	if len(r) <= 8 {
+		return "***"
+	}
+	return string(r[:3]) + "***" + string(r[len(r)-4:])
+}
@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"context"
+	"runtime"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+type requestKey struct{}
+
+// 请求id随 context 传递，通过 Ctx(ctx) 输出的日志将携带 request_id 字段
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestKey{}, id)
+}
+
+// context 携带的请求id
+func RequestId(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestKey{}).(string)
+	return id
+}
+
+// 访问日志，json 模式下每个请求输出一行
+func Access(fields map[string]interface{}) {
+	if !jsonFormat {
+		return
+	}
+	logrus.WithFields(fields).
+		WithField("type", "access").
+		Info("access")
+}
+
+type requestHook struct{}
+
+func (requestHook) Levels() []logrus.Level { return logrus.AllLevels }
+func (requestHook) Fire(entry *logrus.Entry) error {
+	if _, ok := entry.Data["request_id"]; !ok {
+		if id := RequestId(entry.Context); id != "" {
+			entry.Data["request_id"] = id
+		}
+	}
+	if jsonFormat && entry.HasCaller() {
+		if frame, ok := caller(); ok {
+			entry.Caller = &frame
+		}
+	}
+	return nil
+}
+
+// 跳过 logrus 与 logger 包装层，获取真实的调用位置
+func caller() (frame runtime.Frame, ok bool) {
+	pcs := make([]uintptr, 16)
+	depth := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:depth])
+	for f, next := frames.Next(); next; f, next = frames.Next() {
+		if strings.Contains(f.Function, "sirupsen/logrus") ||
+			strings.Contains(f.Function, "core/logger.") {
+			continue
+		}
+		return f, true
+	}
+	return
+}
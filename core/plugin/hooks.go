@@ -0,0 +1,195 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/gin/inter"
+	"chatgpt-adapter/core/gin/model"
+	"chatgpt-adapter/core/logger"
+)
+
+const (
+	ginContent = "__plugin-content__"
+
+	matDefault = 0 // 与 response.MatDefault 一致，继续执行下一个匹配器
+)
+
+// on_request:  {"model": "...", "completion": {...}}
+//
+//	=> {"completion": {...}} 替换请求，可修改 model 改变路由
+//	=> {"error": "..."}      拒绝请求
+func Request(gtx *inter.Context, completion model.Completion) (model.Completion, error) {
+	for _, e := range of(completion.Model, hookRequest) {
+		input, err := json.Marshal(map[string]interface{}{
+			"model":      completion.Model,
+			"completion": completion,
+		})
+		if err != nil {
+			return completion, err
+		}
+
+		output, err := e.call(hookRequest, input)
+		if err != nil {
+			logger.Ctx(gtx).Errorf("plugin `%s` %s failed: %v", e.Name, hookRequest, err)
+			continue
+		}
+		if len(output) == 0 {
+			continue
+		}
+
+		var result struct {
+			Completion *model.Completion `json:"completion"`
+			Error      string            `json:"error"`
+		}
+		if err = json.Unmarshal(output, &result); err != nil {
+			logger.Ctx(gtx).Errorf("plugin `%s` %s returned invalid json: %v", e.Name, hookRequest, err)
+			continue
+		}
+		if result.Error != "" {
+			return completion, errors.New(result.Error)
+		}
+		if result.Completion != nil {
+			completion = *result.Completion
+		}
+	}
+	return completion, nil
+}
+
+// on_chunk: {"content": "...", "done": false, "state": ...}
+//
+//	=> {"content": "...", "state": ...} 替换当前片段，state 原样带入下次调用
+func Matchers(gtx *inter.Context) (slice []inter.Matcher) {
+	completion := common.GetGinCompletion(gtx)
+	for _, e := range of(completion.Model, hookChunk) {
+		slice = append(slice, &chunkMatcher{entry: e})
+	}
+	return
+}
+
+type chunkMatcher struct {
+	*entry
+	state json.RawMessage
+}
+
+func (mat *chunkMatcher) Match(content string, over bool) (state int, result string) {
+	state, result = matDefault, content
+	if content == "" && !over {
+		return
+	}
+
+	input, err := json.Marshal(map[string]interface{}{
+		"content": content,
+		"done":    over,
+		"state":   mat.state,
+	})
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	output, err := mat.call(hookChunk, input)
+	if err != nil {
+		logger.Errorf("plugin `%s` %s failed: %v", mat.Name, hookChunk, err)
+		return
+	}
+	if len(output) == 0 {
+		return
+	}
+
+	var value struct {
+		Content *string         `json:"content"`
+		State   json.RawMessage `json:"state"`
+	}
+	if err = json.Unmarshal(output, &value); err != nil {
+		logger.Errorf("plugin `%s` %s returned invalid json: %v", mat.Name, hookChunk, err)
+		return
+	}
+
+	mat.state = value.State
+	if value.Content != nil {
+		result = *value.Content
+	}
+	return
+}
+
+// 流式响应时收集已发送的内容，供 on_response 使用
+func Collect(gtx *inter.Context, content string) {
+	if content == "" || !has(gtx, hookResponse) {
+		return
+	}
+
+	var sb *strings.Builder
+	if value, ok := gtx.Get(ginContent); ok {
+		sb = value.(*strings.Builder)
+	} else {
+		sb = new(strings.Builder)
+		gtx.Set(ginContent, sb)
+	}
+	sb.WriteString(content)
+}
+
+// on_response: {"model": "...", "content": "...", "stream": false}
+//
+//	=> {"content": "...", "append": "..."} 非流式替换全部内容；流式已发送的内容无法修改，仅追加 append
+func Response(gtx *inter.Context, content string) string {
+	content, _ = response(gtx, content, false)
+	return content
+}
+
+// 流式响应结束前需要追加的内容
+func Append(gtx *inter.Context) string {
+	content := ""
+	if value, ok := gtx.Get(ginContent); ok {
+		content = value.(*strings.Builder).String()
+	}
+	_, appended := response(gtx, content, true)
+	return appended
+}
+
+func response(gtx *inter.Context, content string, stream bool) (string, string) {
+	completion := common.GetGinCompletion(gtx)
+	appended := ""
+	for _, e := range of(completion.Model, hookResponse) {
+		input, err := json.Marshal(map[string]interface{}{
+			"model":   completion.Model,
+			"content": content,
+			"stream":  stream,
+		})
+		if err != nil {
+			logger.Ctx(gtx).Error(err)
+			break
+		}
+
+		output, err := e.call(hookResponse, input)
+		if err != nil {
+			logger.Ctx(gtx).Errorf("plugin `%s` %s failed: %v", e.Name, hookResponse, err)
+			continue
+		}
+		if len(output) == 0 {
+			continue
+		}
+
+		var value struct {
+			Content *string `json:"content"`
+			Append  string  `json:"append"`
+		}
+		if err = json.Unmarshal(output, &value); err != nil {
+			logger.Ctx(gtx).Errorf("plugin `%s` %s returned invalid json: %v", e.Name, hookResponse, err)
+			continue
+		}
+
+		if value.Content != nil && !stream {
+			content = *value.Content
+		}
+		content += value.Append
+		appended += value.Append
+	}
+	return content, appended
+}
+
+func has(gtx *inter.Context, hook string) bool {
+	return len(of(common.GetGinCompletion(gtx).Model, hook)) > 0
+}
@@ -0,0 +1,250 @@
+package plugin
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"chatgpt-adapter/core/common/inited"
+	"chatgpt-adapter/core/common/wasm"
+	"chatgpt-adapter/core/logger"
+	"github.com/iocgo/sdk/env"
+)
+
+// WASM 插件，按配置顺序依次执行
+//
+//	plugins:
+//	  - name: redact
+//	    path: plugins/redact.wasm
+//	    models: [ "gpt-*", "coze/*" ] # glob，为空则全部模型
+//	    memory: 32                   # 线性内存上限 MB，默认 32
+//	    timeout: 500                 # 单次调用超时 ms，默认 500
+//	    fuel: 100000000              # 单次调用分支与调用次数上限，默认 1e8
+const (
+	hookRequest  = "on_request"
+	hookChunk    = "on_chunk"
+	hookResponse = "on_response"
+)
+
+type conf struct {
+	Name    string   `mapstructure:"name"`
+	Path    string   `mapstructure:"path"`
+	Models  []string `mapstructure:"models"`
+	Memory  uint64   `mapstructure:"memory"`
+	Timeout int      `mapstructure:"timeout"`
+	Fuel    uint64   `mapstructure:"fuel"`
+}
+
+type entry struct {
+	conf
+	path   string // 绝对路径
+	module *wasm.Module
+	limits wasm.Limits
+	pool   chan *wasm.Guest
+}
+
+var (
+	registry atomic.Pointer[[]*entry]
+)
+
+func init() {
+	inited.AddInitialized(func(env *env.Environment) {
+		entries, err := load(env)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		if len(entries) > 0 {
+			registry.Store(&entries)
+			logger.Infof("loaded plugins: %d", len(entries))
+		}
+		watch(env)
+	})
+
+	inited.AddReloaded(func(env *env.Environment) (func(), error) {
+		entries, err := load(env)
+		if err != nil {
+			return nil, err
+		}
+		return func() {
+			swap(entries)
+			logger.Infof("reload plugins: %d", len(entries))
+		}, nil
+	})
+}
+
+func load(env *env.Environment) (entries []*entry, err error) {
+	var confs []conf
+	if err = env.UnmarshalKey("plugins", &confs); err != nil {
+		return nil, fmt.Errorf("plugins: %v", err)
+	}
+
+	names := make(map[string]bool)
+	for i, c := range confs {
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("plugins[%d]", i)
+		}
+		if names[c.Name] {
+			return nil, fmt.Errorf("plugins[%d]: duplicate name `%s`", i, c.Name)
+		}
+		names[c.Name] = true
+
+		for _, pattern := range c.Models {
+			if _, err = path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("plugins[%d].models: %v", i, err)
+			}
+		}
+
+		e, err := compile(c)
+		if err != nil {
+			return nil, fmt.Errorf("plugins[%d]: %v", i, err)
+		}
+		entries = append(entries, e)
+	}
+	return
+}
+
+func compile(c conf) (*entry, error) {
+	if c.Path == "" {
+		return nil, fmt.Errorf("`path` is required")
+	}
+
+	abs, err := filepath.Abs(c.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	module, err := wasm.Compile(abs)
+	if err != nil {
+		return nil, fmt.Errorf("compile `%s` failed: %v", c.Path, err)
+	}
+	if !module.Has(hookRequest) && !module.Has(hookChunk) && !module.Has(hookResponse) {
+		return nil, fmt.Errorf("`%s` exports none of %s, %s, %s", c.Path, hookRequest, hookChunk, hookResponse)
+	}
+
+	memory, timeout, fuel := c.Memory, c.Timeout, c.Fuel
+	if memory == 0 {
+		memory = 32
+	}
+	if timeout == 0 {
+		timeout = 500
+	}
+	if fuel == 0 {
+		fuel = 1e8
+	}
+
+	limits := wasm.Limits{
+		Memory:  memory << 20,
+		Timeout: time.Duration(timeout) * time.Millisecond,
+		Fuel:    fuel,
+	}
+	if err = module.Check(limits); err != nil {
+		return nil, fmt.Errorf("load `%s` failed: %v", c.Path, err)
+	}
+
+	return &entry{
+		conf:   c,
+		path:   abs,
+		module: module,
+		limits: limits,
+		pool:   make(chan *wasm.Guest, runtime.GOMAXPROCS(0)),
+	}, nil
+}
+
+// 替换注册表并释放旧实例
+func swap(entries []*entry) {
+	var older *[]*entry
+	if len(entries) == 0 {
+		older = registry.Swap(nil)
+	} else {
+		older = registry.Swap(&entries)
+	}
+	rewatch()
+	if older == nil {
+		return
+	}
+	for _, e := range *older {
+		e.drain()
+	}
+}
+
+func (e *entry) matches(model string) bool {
+	if len(e.Models) == 0 {
+		return true
+	}
+	for _, pattern := range e.Models {
+		if ok, _ := path.Match(pattern, model); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// 单次调用：从池中取实例，超时或超限的实例直接丢弃
+func (e *entry) call(hook string, input []byte) ([]byte, error) {
+	var (
+		guest *wasm.Guest
+		err   error
+	)
+
+	select {
+	case guest = <-e.pool:
+	default:
+		guest, err = e.module.Instantiate(e.limits, e.log)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	output, err := guest.Call(hook, input)
+	if guest.Broken() {
+		guest.Close()
+		return output, err
+	}
+
+	select {
+	case e.pool <- guest:
+	default:
+		guest.Close()
+	}
+	return output, err
+}
+
+func (e *entry) drain() {
+	for {
+		select {
+		case guest := <-e.pool:
+			guest.Close()
+		default:
+			return
+		}
+	}
+}
+
+func (e *entry) log(level int32, message string) {
+	switch level {
+	case 0:
+		logger.Debugf("[plugin:%s] %s", e.Name, message)
+	case 2:
+		logger.Warnf("[plugin:%s] %s", e.Name, message)
+	case 3:
+		logger.Errorf("[plugin:%s] %s", e.Name, message)
+	default:
+		logger.Infof("[plugin:%s] %s", e.Name, message)
+	}
+}
+
+func of(model, hook string) (slice []*entry) {
+	entries := registry.Load()
+	if entries == nil {
+		return
+	}
+	for _, e := range *entries {
+		if e.module.Has(hook) && e.matches(model) {
+			slice = append(slice, e)
+		}
+	}
+	return
+}
@@ -0,0 +1,131 @@
+package plugin
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"chatgpt-adapter/core/logger"
+	"github.com/fsnotify/fsnotify"
+	"github.com/iocgo/sdk/env"
+)
+
+var (
+	watcher *fsnotify.Watcher
+	wmu     sync.Mutex
+	timers  = make(map[string]*time.Timer)
+)
+
+// 监听插件文件变更，单独重新编译变更的插件；与配置热重载共用 server.hot-reload 开关
+func watch(env *env.Environment) {
+	if env.IsSet("server.hot-reload") && !env.GetBool("server.hot-reload") {
+		return
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Error(err)
+		return
+	}
+
+	watcher = w
+	rewatch()
+	go func() {
+		defer w.Close()
+		for {
+			select {
+			case event, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) && !event.Has(fsnotify.Rename) {
+					continue
+				}
+				debounce(filepath.Clean(event.Name))
+			case err, ok := <-w.Errors:
+				if !ok {
+					return
+				}
+				logger.Error(err)
+			}
+		}
+	}()
+}
+
+// 按当前注册表监听插件所在目录，编辑器保存时通常是重命名替换
+func rewatch() {
+	if watcher == nil {
+		return
+	}
+
+	dirs := make(map[string]bool)
+	if entries := registry.Load(); entries != nil {
+		for _, e := range *entries {
+			dirs[filepath.Dir(e.path)] = true
+		}
+	}
+
+	for _, dir := range watcher.WatchList() {
+		if !dirs[dir] {
+			_ = watcher.Remove(dir)
+		}
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			logger.Error(err)
+		}
+	}
+}
+
+func debounce(name string) {
+	wmu.Lock()
+	defer wmu.Unlock()
+	if timer, ok := timers[name]; ok {
+		timer.Stop()
+	}
+	timers[name] = time.AfterFunc(500*time.Millisecond, func() {
+		wmu.Lock()
+		delete(timers, name)
+		wmu.Unlock()
+		recompile(name)
+	})
+}
+
+// 编译失败时保留旧版本
+func recompile(name string) {
+	entries := registry.Load()
+	if entries == nil {
+		return
+	}
+
+	changed := false
+	next := make([]*entry, len(*entries))
+	for i, e := range *entries {
+		next[i] = e
+		if e.path != name {
+			continue
+		}
+
+		ne, err := compile(e.conf)
+		if err != nil {
+			logger.Errorf("reload plugin `%s` failed, keep the current version: %v", e.Name, err)
+			continue
+		}
+		next[i], changed = ne, true
+		logger.Infof("reload plugin `%s`: %s", e.Name, e.Path)
+	}
+
+	if !changed {
+		return
+	}
+
+	// 期间配置已重载则放弃
+	if !registry.CompareAndSwap(entries, &next) {
+		return
+	}
+	for i, e := range *entries {
+		if next[i] != e {
+			e.drain()
+		}
+	}
+}
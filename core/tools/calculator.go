@@ -0,0 +1,295 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"chatgpt-adapter/core/gin/model"
+	"github.com/iocgo/sdk/env"
+)
+
+// 四则运算表达式求值，支持 + - * / % ^、括号、常量 pi e 及常用函数
+type calculator struct{}
+
+func init() {
+	Register("calculator", func(*env.Environment) (Provider, error) { return calculator{}, nil })
+}
+
+var (
+	constants = map[string]float64{"pi": math.Pi, "e": math.E}
+	functions = map[string]func(args ...float64) (float64, error){
+		"sqrt":  unary(math.Sqrt),
+		"abs":   unary(math.Abs),
+		"floor": unary(math.Floor),
+		"ceil":  unary(math.Ceil),
+		"round": unary(math.Round),
+		"ln":    unary(math.Log),
+		"log10": unary(math.Log10),
+		"exp":   unary(math.Exp),
+		"sin":   unary(math.Sin),
+		"cos":   unary(math.Cos),
+		"tan":   unary(math.Tan),
+		"pow": func(args ...float64) (float64, error) {
+			if len(args) != 2 {
+				return 0, errors.New("expects 2 arguments")
+			}
+			return math.Pow(args[0], args[1]), nil
+		},
+		"min": func(args ...float64) (float64, error) {
+			if len(args) == 0 {
+				return 0, errors.New("expects at least 1 argument")
+			}
+			return reduce(args, math.Min), nil
+		},
+		"max": func(args ...float64) (float64, error) {
+			if len(args) == 0 {
+				return 0, errors.New("expects at least 1 argument")
+			}
+			return reduce(args, math.Max), nil
+		},
+	}
+)
+
+func (calculator) Definition() model.Keyv[interface{}] {
+	return definition(`{
+		"name": "calculator",
+		"description": "Evaluate an arithmetic expression. Supports + - * / % ^, parentheses, pi, e and sqrt abs floor ceil round ln log10 exp sin cos tan pow min max",
+		"parameters": {
+			"type": "object",
+			"properties": {
+				"expression": { "type": "string", "description": "The expression, e.g. (1 + 2) * sqrt(16)" }
+			},
+			"required": [ "expression" ]
+		}
+	}`)
+}
+
+func (calculator) Call(_ context.Context, args map[string]interface{}) (string, error) {
+	value, err := Evaluate(stringArg(args, "expression"))
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64), nil
+}
+
+// 表达式求值
+func Evaluate(expression string) (float64, error) {
+	p := &parser{runes: []rune(expression)}
+	if len(p.runes) > 1024 {
+		return 0, errors.New("expression is too long")
+	}
+	value, err := p.expr()
+	if err != nil {
+		return 0, err
+	}
+	if p.skip(); p.pos < len(p.runes) {
+		return 0, fmt.Errorf("unexpected `%c` at %d", p.runes[p.pos], p.pos)
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, errors.New("result is not a finite number")
+	}
+	return value, nil
+}
+
+type parser struct {
+	runes []rune
+	pos   int
+}
+
+func (p *parser) skip() {
+	for p.pos < len(p.runes) && unicode.IsSpace(p.runes[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *parser) peek() rune {
+	if p.skip(); p.pos < len(p.runes) {
+		return p.runes[p.pos]
+	}
+	return 0
+}
+
+// expr = term {("+"|"-") term}
+func (p *parser) expr() (float64, error) {
+	value, err := p.term()
+	for err == nil {
+		switch p.peek() {
+		case '+', '-':
+			op := p.runes[p.pos]
+			p.pos++
+			var right float64
+			if right, err = p.term(); err == nil {
+				value = elseOf(op == '+', value+right, value-right)
+			}
+		default:
+			return value, nil
+		}
+	}
+	return 0, err
+}
+
+// term = power {("*"|"/"|"%") power}
+func (p *parser) term() (float64, error) {
+	value, err := p.power()
+	for err == nil {
+		op := p.peek()
+		if op != '*' && op != '/' && op != '%' {
+			return value, nil
+		}
+		p.pos++
+		var right float64
+		if right, err = p.power(); err != nil {
+			break
+		}
+		if op != '*' && right == 0 {
+			return 0, errors.New("division by zero")
+		}
+		switch op {
+		case '*':
+			value *= right
+		case '/':
+			value /= right
+		default:
+			value = math.Mod(value, right)
+		}
+	}
+	return 0, err
+}
+
+// power = unary ["^" power]，右结合
+func (p *parser) power() (float64, error) {
+	value, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	if p.peek() == '^' {
+		p.pos++
+		exponent, e := p.power()
+		if e != nil {
+			return 0, e
+		}
+		value = math.Pow(value, exponent)
+	}
+	return value, nil
+}
+
+// unary = ("+"|"-") unary | primary
+func (p *parser) unary() (float64, error) {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		value, err := p.unary()
+		return -value, err
+	case '+':
+		p.pos++
+		return p.unary()
+	}
+	return p.primary()
+}
+
+// primary = number | "(" expr ")" | name | name "(" args ")"
+func (p *parser) primary() (float64, error) {
+	r := p.peek()
+	switch {
+	case r == '(':
+		p.pos++
+		value, err := p.expr()
+		if err != nil {
+			return 0, err
+		}
+		if p.peek() != ')' {
+			return 0, fmt.Errorf("missing `)` at %d", p.pos)
+		}
+		p.pos++
+		return value, nil
+
+	case unicode.IsDigit(r) || r == '.':
+		start := p.pos
+		for p.pos < len(p.runes) && (unicode.IsDigit(p.runes[p.pos]) || p.runes[p.pos] == '.' || p.runes[p.pos] == '_') {
+			p.pos++
+		}
+		// 科学计数法
+		if p.pos < len(p.runes) && (p.runes[p.pos] == 'e' || p.runes[p.pos] == 'E') {
+			next := p.pos + 1
+			if next < len(p.runes) && (p.runes[next] == '+' || p.runes[next] == '-') {
+				next++
+			}
+			if next < len(p.runes) && unicode.IsDigit(p.runes[next]) {
+				for p.pos = next; p.pos < len(p.runes) && unicode.IsDigit(p.runes[p.pos]); p.pos++ {
+				}
+			}
+		}
+		return strconv.ParseFloat(string(p.runes[start:p.pos]), 64)
+
+	case unicode.IsLetter(r):
+		start := p.pos
+		for p.pos < len(p.runes) && (unicode.IsLetter(p.runes[p.pos]) || unicode.IsDigit(p.runes[p.pos])) {
+			p.pos++
+		}
+		name := strings.ToLower(string(p.runes[start:p.pos]))
+		if p.peek() != '(' {
+			if value, ok := constants[name]; ok {
+				return value, nil
+			}
+			return 0, fmt.Errorf("unknown constant `%s`", name)
+		}
+
+		fn, ok := functions[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown function `%s`", name)
+		}
+		p.pos++
+		var args []float64
+		for p.peek() != ')' {
+			value, err := p.expr()
+			if err != nil {
+				return 0, err
+			}
+			args = append(args, value)
+			if p.peek() == ',' {
+				p.pos++
+			} else if p.peek() != ')' {
+				return 0, fmt.Errorf("missing `)` at %d", p.pos)
+			}
+		}
+		p.pos++
+		value, err := fn(args...)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %v", name, err)
+		}
+		return value, nil
+
+	case r == 0:
+		return 0, errors.New("unexpected end of expression")
+	}
+	return 0, fmt.Errorf("unexpected `%c` at %d", r, p.pos)
+}
+
+func unary(fn func(float64) float64) func(args ...float64) (float64, error) {
+	return func(args ...float64) (float64, error) {
+		if len(args) != 1 {
+			return 0, errors.New("expects 1 argument")
+		}
+		return fn(args[0]), nil
+	}
+}
+
+func reduce(values []float64, fn func(a, b float64) float64) float64 {
+	result := values[0]
+	for _, value := range values[1:] {
+		result = fn(result, value)
+	}
+	return result
+}
+
+func elseOf[T any](condition bool, t1, t2 T) T {
+	if condition {
+		return t1
+	}
+	return t2
+}
@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"chatgpt-adapter/core/gin/model"
+	"github.com/iocgo/sdk/env"
+)
+
+// 当前时间
+type clock struct{}
+
+func init() {
+	Register("clock", func(*env.Environment) (Provider, error) { return clock{}, nil })
+}
+
+func (clock) Definition() model.Keyv[interface{}] {
+	return definition(`{
+		"name": "clock",
+		"description": "Get the current date and time",
+		"parameters": {
+			"type": "object",
+			"properties": {
+				"timezone": { "type": "string", "description": "IANA time zone, e.g. Asia/Shanghai. Defaults to the server time zone" }
+			}
+		}
+	}`)
+}
+
+func (clock) Call(_ context.Context, args map[string]interface{}) (string, error) {
+	now := time.Now()
+	if name := stringArg(args, "timezone"); name != "" {
+		location, err := time.LoadLocation(name)
+		if err != nil {
+			return "", err
+		}
+		now = now.In(location)
+	}
+
+	bytes, err := json.Marshal(map[string]interface{}{
+		"time":     now.Format(time.RFC3339),
+		"weekday":  now.Weekday().String(),
+		"timezone": now.Location().String(),
+		"unix":     now.Unix(),
+	})
+	return string(bytes), err
+}
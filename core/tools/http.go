@@ -0,0 +1,142 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"chatgpt-adapter/core/gin/model"
+	"github.com/iocgo/sdk/env"
+)
+
+// 请求白名单内的主机
+//
+//	agent:
+//	  http:
+//	    hosts: [ "wiki.internal", "*.svc.cluster.local" ] # 主机名，glob
+//	    timeout: 10                                       # s，默认 10
+//	    max-bytes: 65536                                  # 返回内容上限，默认 64KB
+type httpTool struct {
+	hosts    []string
+	maxBytes int64
+	client   *http.Client
+}
+
+func init() {
+	Register("http", newHttpTool)
+}
+
+func newHttpTool(env *env.Environment) (Provider, error) {
+	t := &httpTool{
+		hosts:    env.GetStringSlice("agent.http.hosts"),
+		maxBytes: 64 * 1024,
+	}
+	if len(t.hosts) == 0 {
+		return nil, errors.New("agent.http.hosts is empty")
+	}
+	for _, pattern := range t.hosts {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("bad host pattern '%s'", pattern)
+		}
+	}
+	if env.IsSet("agent.http.max-bytes") {
+		t.maxBytes = env.GetInt64("agent.http.max-bytes")
+	}
+
+	timeout := 10 * time.Second
+	if env.IsSet("agent.http.timeout") {
+		timeout = time.Duration(env.GetInt("agent.http.timeout")) * time.Second
+	}
+	t.client = &http.Client{
+		Timeout: timeout,
+		// 重定向同样只允许白名单内的主机
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			return t.allowed(req.URL)
+		},
+	}
+	return t, nil
+}
+
+func (t *httpTool) Definition() model.Keyv[interface{}] {
+	return definition(fmt.Sprintf(`{
+		"name": "http",
+		"description": "Send an HTTP request to an internal service. Allowed hosts: %s",
+		"parameters": {
+			"type": "object",
+			"properties": {
+				"url": { "type": "string", "description": "The full url, http or https" },
+				"method": { "type": "string", "description": "HTTP method", "enum": [ "GET", "POST" ] },
+				"body": { "type": "string", "description": "Request body of POST" }
+			},
+			"required": [ "url" ]
+		}
+	}`, strings.Join(t.hosts, ", ")))
+}
+
+func (t *httpTool) Call(ctx context.Context, args map[string]interface{}) (string, error) {
+	u, err := url.Parse(stringArg(args, "url"))
+	if err != nil {
+		return "", err
+	}
+	if err = t.allowed(u); err != nil {
+		return "", err
+	}
+
+	method := strings.ToUpper(stringArg(args, "method"))
+	if method == "" {
+		method = http.MethodGet
+	}
+	if method != http.MethodGet && method != http.MethodPost {
+		return "", fmt.Errorf("method `%s` is not allowed", method)
+	}
+
+	var body io.Reader
+	if str := stringArg(args, "body"); str != "" && method == http.MethodPost {
+		body = strings.NewReader(str)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return "", err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := t.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(response.Body, t.maxBytes+1))
+	if err != nil {
+		return "", err
+	}
+	content := string(data)
+	if int64(len(data)) > t.maxBytes {
+		content = string(data[:t.maxBytes]) + "\n...(truncated)"
+	}
+	return fmt.Sprintf("status: %d\n\n%s", response.StatusCode, content), nil
+}
+
+func (t *httpTool) allowed(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("scheme `%s` is not allowed", u.Scheme)
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, pattern := range t.hosts {
+		if ok, _ := path.Match(strings.ToLower(pattern), host); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("host `%s` is not allowed", host)
+}
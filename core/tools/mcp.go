@@ -0,0 +1,323 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"chatgpt-adapter/core/gin/model"
+	"chatgpt-adapter/core/logger"
+	"github.com/iocgo/sdk/env"
+)
+
+// MCP 服务器提供的工具同样作为网关工具，stdio 以子进程运行，url 为 Streamable HTTP
+//
+//	agent:
+//	  mcp:
+//	    - name: files
+//	      command: npx
+//	      args: [ "-y", "@modelcontextprotocol/server-filesystem", "/data" ]
+//	      env: [ "KEY=xxx" ]
+//	    - name: search
+//	      url: http://127.0.0.1:8931/mcp
+//	      headers: { Authorization: "Bearer xxx" }
+//	      models: [ "custom/*" ]   # 提供给哪些模型，默认同 agent.models
+//	      tools: [ "search" ]      # 只提供部分工具，默认全部
+//	      prefix: "search_"        # 工具名前缀，避免重名
+//	      timeout: 60              # 单次调用超时 s，默认 60
+type mcpConf struct {
+	Name    string            `mapstructure:"name"`
+	Command string            `mapstructure:"command"`
+	Args    []string          `mapstructure:"args"`
+	Env     []string          `mapstructure:"env"`
+	Dir     string            `mapstructure:"dir"`
+	Url     string            `mapstructure:"url"`
+	Headers map[string]string `mapstructure:"headers"`
+	Models  []string          `mapstructure:"models"`
+	Tools   []string          `mapstructure:"tools"`
+	Prefix  string            `mapstructure:"prefix"`
+	Timeout int               `mapstructure:"timeout"`
+}
+
+const mcpProtocol = "2025-03-26"
+
+var (
+	// 连接已断开，请求可能已送达
+	errMCPClosed = errors.New("mcp: connection closed")
+	// 请求未送达（进程已退出、会话已失效），可以重新连接后重发
+	errMCPLost = errors.New("mcp: connection lost, request not sent")
+)
+
+type mcpMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Id      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  interface{}     `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *mcpError       `json:"error,omitempty"`
+}
+
+type mcpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *mcpError) Error() string {
+	return fmt.Sprintf("mcp error %d: %s", e.Code, e.Message)
+}
+
+// 传输层：stdio 子进程或 Streamable HTTP
+type mcpTransport interface {
+	call(ctx context.Context, method string, params interface{}) (json.RawMessage, error)
+	notify(ctx context.Context, method string, params interface{}) error
+	alive() bool
+	close() error
+}
+
+// 一个 MCP 服务器，断开后在下一次调用时重新连接
+type mcpServer struct {
+	mcpConf
+
+	mu        sync.Mutex
+	transport mcpTransport
+}
+
+// MCP 工具
+type mcpTool struct {
+	server *mcpServer
+	name   string // 服务器上的工具名
+	def    model.Keyv[interface{}]
+}
+
+// 只解析配置，服务器在 openMCP 中连接
+func loadMCP(env *env.Environment, config *Config) error {
+	var confs []mcpConf
+	if err := env.UnmarshalKey("agent.mcp", &confs); err != nil {
+		return fmt.Errorf("agent.mcp: %v", err)
+	}
+
+	for i, c := range confs {
+		if (c.Command == "") == (c.Url == "") {
+			return fmt.Errorf("agent.mcp[%d]: one of command and url is required", i)
+		}
+		for _, pattern := range c.Models {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("agent.mcp[%d]: bad pattern '%s'", i, pattern)
+			}
+		}
+		if c.Name == "" {
+			c.Name = elseOf(c.Command != "", c.Command, c.Url)
+		}
+		if c.Timeout == 0 {
+			c.Timeout = 60
+		}
+		config.mcp = append(config.mcp, c)
+	}
+	return nil
+}
+
+// 连接服务器并获取工具，配置生效时调用
+func (c *Config) openMCP() {
+	for _, conf := range c.mcp {
+		// 连接失败不影响其它工具，重新加载配置时再次尝试
+		server := &mcpServer{mcpConf: conf}
+		tools, err := server.tools()
+		if err != nil {
+			logger.Errorf("mcp server `%s` unavailable: %v", conf.Name, err)
+			server.close()
+			continue
+		}
+		c.servers = append(c.servers, server)
+
+		models := elseOf(len(conf.Models) > 0, conf.Models, c.Models)
+		for _, t := range tools {
+			name := t.def.GetString("name")
+			if _, exists := c.providers[name]; exists {
+				logger.Warnf("mcp server `%s` tool `%s` conflicts with an existing tool, skipped", conf.Name, name)
+				continue
+			}
+			c.providers[name] = entry{t, models}
+		}
+		logger.Infof("mcp server `%s` connected, tools: %d", conf.Name, len(tools))
+	}
+}
+
+// 发现工具
+func (s *mcpServer) tools() (tools []*mcpTool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cursor := ""
+	for {
+		params := map[string]interface{}{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		result, e := s.call(ctx, "tools/list", params)
+		if e != nil {
+			return nil, e
+		}
+
+		var value struct {
+			Tools []struct {
+				Name        string                 `json:"name"`
+				Description string                 `json:"description"`
+				InputSchema map[string]interface{} `json:"inputSchema"`
+			} `json:"tools"`
+			NextCursor string `json:"nextCursor"`
+		}
+		if err = json.Unmarshal(result, &value); err != nil {
+			return
+		}
+
+		for _, t := range value.Tools {
+			if len(s.Tools) > 0 && !slices.Contains(s.Tools, t.Name) {
+				continue
+			}
+			schema := t.InputSchema
+			if schema == nil {
+				schema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+			}
+			tools = append(tools, &mcpTool{server: s, name: t.Name, def: model.Keyv[interface{}]{
+				"name":        s.Prefix + t.Name,
+				"description": t.Description,
+				"parameters":  schema,
+			}})
+		}
+
+		if value.NextCursor == "" {
+			return
+		}
+		cursor = value.NextCursor
+	}
+}
+
+// 已连接的传输层，未连接或已断开时重新连接并初始化
+func (s *mcpServer) conn(ctx context.Context) (mcpTransport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.transport != nil && s.transport.alive() {
+		return s.transport, nil
+	}
+	if s.transport != nil {
+		_ = s.transport.close()
+		s.transport = nil
+	}
+
+	var (
+		t   mcpTransport
+		err error
+	)
+	if s.Command != "" {
+		t, err = newStdioTransport(s.mcpConf)
+	} else {
+		t = newHttpTransport(s.mcpConf)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = t.call(ctx, "initialize", map[string]interface{}{
+		"protocolVersion": mcpProtocol,
+		"capabilities":    map[string]interface{}{},
+		"clientInfo":      map[string]string{"name": "chatgpt-adapter", "version": "1.0.0"},
+	})
+	if err == nil {
+		err = t.notify(ctx, "notifications/initialized", nil)
+	}
+	if err != nil {
+		_ = t.close()
+		return nil, fmt.Errorf("initialize: %v", err)
+	}
+
+	s.transport = t
+	return t, nil
+}
+
+// 请求未送达时重新连接后重试一次；已送达的请求（如 tools/call）可能已执行，不重发
+func (s *mcpServer) call(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	for attempt := 0; ; attempt++ {
+		t, err := s.conn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		result, err := t.call(ctx, method, params)
+		if errors.Is(err, errMCPLost) && attempt == 0 {
+			logger.Ctx(ctx).Warnf("mcp server `%s` disconnected, reconnecting", s.Name)
+			continue
+		}
+		return result, err
+	}
+}
+
+func (s *mcpServer) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.transport != nil {
+		if err := s.transport.close(); err != nil {
+			logger.Error(err)
+		}
+		s.transport = nil
+	}
+}
+
+func (t *mcpTool) Definition() model.Keyv[interface{}] {
+	// 调用方可能修改定义（如分配 toolId），每次返回副本
+	var def model.Keyv[interface{}]
+	marshal, _ := json.Marshal(t.def)
+	_ = json.Unmarshal(marshal, &def)
+	return def
+}
+
+func (t *mcpTool) Call(ctx context.Context, args map[string]interface{}) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(t.server.Timeout)*time.Second)
+	defer cancel()
+
+	result, err := t.server.call(ctx, "tools/call", map[string]interface{}{"name": t.name, "arguments": args})
+	if err != nil {
+		return "", err
+	}
+
+	var value struct {
+		Content           []map[string]interface{} `json:"content"`
+		StructuredContent interface{}              `json:"structuredContent"`
+		IsError           bool                     `json:"isError"`
+	}
+	if err = json.Unmarshal(result, &value); err != nil {
+		return "", err
+	}
+
+	var contents []string
+	for _, c := range value.Content {
+		switch c["type"] {
+		case "text":
+			contents = append(contents, fmt.Sprint(c["text"]))
+		case "resource":
+			resource, _ := c["resource"].(map[string]interface{})
+			if text, ok := resource["text"].(string); ok {
+				contents = append(contents, text)
+				continue
+			}
+			contents = append(contents, fmt.Sprintf("[resource %v]", resource["uri"]))
+		default:
+			// 图片、音频等无法作为文本提供给模型
+			contents = append(contents, fmt.Sprintf("[%v %v]", c["type"], c["mimeType"]))
+		}
+	}
+	if len(contents) == 0 && value.StructuredContent != nil {
+		marshal, _ := json.Marshal(value.StructuredContent)
+		contents = append(contents, string(marshal))
+	}
+
+	content := strings.Join(contents, "\n")
+	if value.IsError {
+		return "", errors.New(content)
+	}
+	return content, nil
+}
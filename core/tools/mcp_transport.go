@@ -0,0 +1,317 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"chatgpt-adapter/core/logger"
+)
+
+// stdio：行分隔的 JSON-RPC，stderr 记入日志
+type stdioTransport struct {
+	name    string
+	mu      sync.Mutex // 保护 stdin
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	seq     atomic.Int64
+	pending sync.Map // int64 => chan *mcpMessage
+	exited  chan struct{}
+}
+
+func newStdioTransport(c mcpConf) (*stdioTransport, error) {
+	cmd := exec.Command(c.Command, c.Args...)
+	cmd.Dir = c.Dir
+	cmd.Env = append(os.Environ(), c.Env...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	t := &stdioTransport{name: c.Name, cmd: cmd, stdin: stdin, exited: make(chan struct{})}
+	go func() {
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			logger.Infof("[mcp:%s] %s", t.name, scanner.Text())
+		}
+	}()
+	go func() {
+		t.read(stdout)
+		_ = cmd.Wait()
+		close(t.exited)
+	}()
+	return t, nil
+}
+
+func (t *stdioTransport) read(stdout io.Reader) {
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			t.dispatch(line)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (t *stdioTransport) dispatch(line []byte) {
+	var msg mcpMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		logger.Warnf("[mcp:%s] invalid message: %s", t.name, line)
+		return
+	}
+
+	switch {
+	case msg.Method == "" && msg.Id != nil:
+		if ch, ok := t.pending.Load(*msg.Id); ok {
+			ch.(chan *mcpMessage) <- &msg
+		}
+	case msg.Method != "" && msg.Id != nil:
+		// 服务器发起的请求，只响应 ping
+		reply := mcpMessage{Id: msg.Id, Result: json.RawMessage("{}")}
+		if msg.Method != "ping" {
+			reply = mcpMessage{Id: msg.Id, Error: &mcpError{Code: -32601, Message: "method not found"}}
+		}
+		if err := t.write(reply); err != nil {
+			logger.Error(err)
+		}
+	}
+}
+
+func (t *stdioTransport) write(msg mcpMessage) error {
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.alive() {
+		return errMCPLost
+	}
+	_, err = t.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (t *stdioTransport) call(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	id := t.seq.Add(1)
+	ch := make(chan *mcpMessage, 1)
+	t.pending.Store(id, ch)
+	defer t.pending.Delete(id)
+
+	if err := t.write(mcpMessage{Id: &id, Method: method, Params: params}); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-ctx.Done():
+		_ = t.notify(context.Background(), "notifications/cancelled", map[string]interface{}{"requestId": id})
+		return nil, ctx.Err()
+	case <-t.exited:
+		return nil, errMCPClosed
+	case msg := <-ch:
+		if msg.Error != nil {
+			return nil, msg.Error
+		}
+		return msg.Result, nil
+	}
+}
+
+func (t *stdioTransport) notify(_ context.Context, method string, params interface{}) error {
+	return t.write(mcpMessage{Method: method, Params: params})
+}
+
+func (t *stdioTransport) alive() bool {
+	select {
+	case <-t.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// 关闭 stdin 通知服务器退出，随后结束进程
+func (t *stdioTransport) close() error {
+	t.mu.Lock()
+	_ = t.stdin.Close()
+	t.mu.Unlock()
+	if t.cmd.Process != nil {
+		_ = t.cmd.Process.Kill()
+	}
+	<-t.exited
+	return nil
+}
+
+// Streamable HTTP：每条消息一次 POST，响应为 json 或 SSE 流
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	seq     atomic.Int64
+	session atomic.Value // Mcp-Session-Id
+	closed  atomic.Bool
+}
+
+func newHttpTransport(c mcpConf) *httpTransport {
+	return &httpTransport{url: c.Url, headers: c.Headers, client: &http.Client{}}
+}
+
+func (t *httpTransport) post(ctx context.Context, msg mcpMessage) (*http.Response, error) {
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	t.header(request)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json, text/event-stream")
+
+	response, err := t.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if session := response.Header.Get("Mcp-Session-Id"); session != "" {
+		t.session.Store(session)
+	}
+
+	switch {
+	case response.StatusCode == http.StatusNotFound && t.sessionId() != "":
+		// 会话已失效，服务器未处理该请求，重新初始化
+		response.Body.Close()
+		t.closed.Store(true)
+		return nil, errMCPLost
+	case response.StatusCode >= http.StatusBadRequest:
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		response.Body.Close()
+		return nil, fmt.Errorf("mcp http %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return response, nil
+}
+
+func (t *httpTransport) header(request *http.Request) {
+	for k, v := range t.headers {
+		request.Header.Set(k, v)
+	}
+	if session := t.sessionId(); session != "" {
+		request.Header.Set("Mcp-Session-Id", session)
+		request.Header.Set("Mcp-Protocol-Version", mcpProtocol)
+	}
+}
+
+func (t *httpTransport) sessionId() string {
+	session, _ := t.session.Load().(string)
+	return session
+}
+
+func (t *httpTransport) call(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	id := t.seq.Add(1)
+	response, err := t.post(ctx, mcpMessage{Id: &id, Method: method, Params: params})
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	var msg *mcpMessage
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if mediaType == "text/event-stream" {
+		msg, err = readEvents(response.Body, id)
+	} else {
+		msg = new(mcpMessage)
+		err = json.NewDecoder(response.Body).Decode(msg)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if msg.Error != nil {
+		return nil, msg.Error
+	}
+	return msg.Result, nil
+}
+
+// 读取 SSE 直到出现对应 id 的响应，其间的通知忽略；响应前断开时请求可能已执行，不视为未送达
+func readEvents(body io.Reader, id int64) (*mcpMessage, error) {
+	var (
+		reader = bufio.NewReader(body)
+		data   strings.Builder
+	)
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		case line == "" && data.Len() > 0:
+			var msg mcpMessage
+			if e := json.Unmarshal([]byte(data.String()), &msg); e == nil && msg.Method == "" && msg.Id != nil && *msg.Id == id {
+				return &msg, nil
+			}
+			data.Reset()
+		}
+		if err != nil {
+			if err == io.EOF {
+				err = fmt.Errorf("mcp: stream ended before the response: %w", errMCPClosed)
+			}
+			return nil, err
+		}
+	}
+}
+
+func (t *httpTransport) notify(ctx context.Context, method string, params interface{}) error {
+	response, err := t.post(ctx, mcpMessage{Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+func (t *httpTransport) alive() bool {
+	return !t.closed.Load()
+}
+
+// 结束会话
+func (t *httpTransport) close() error {
+	t.closed.Store(true)
+	if t.sessionId() == "" {
+		return nil
+	}
+	request, err := http.NewRequest(http.MethodDelete, t.url, nil)
+	if err != nil {
+		return err
+	}
+	t.header(request)
+	response, err := t.client.Do(request)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
@@ -0,0 +1,297 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"chatgpt-adapter/core/common/inited"
+	"chatgpt-adapter/core/gin/model"
+	"chatgpt-adapter/core/logger"
+	"github.com/iocgo/sdk/env"
+)
+
+// 由网关执行的工具
+//
+//	agent:
+//	  models: [ "custom/*" ]      # 启用的模型，glob
+//	  tools: [ clock, calculator, http, sql ]
+//	  max-iterations: 5           # 最多执行工具的轮数，默认 5
+//	  timeout: 120                # 整个循环的超时 s，默认 120
+//	  steps: reasoning            # 中间步骤的输出：none（默认）、reasoning、event
+type Provider interface {
+	// 工具定义，即 tools[].function：name、description、parameters
+	Definition() model.Keyv[interface{}]
+	// 执行工具，返回作为 tool 消息内容的结果
+	Call(ctx context.Context, args map[string]interface{}) (string, error)
+}
+
+// 按配置创建工具，只解析与校验配置
+//
+// 连接池、子进程等资源在工具的 Open() error 中创建，配置生效时才调用；
+// 实现 Close() error 的工具在配置被替换且不再使用后释放
+type Factory func(env *env.Environment) (Provider, error)
+
+type Config struct {
+	Models        []string
+	MaxIterations int
+	Timeout       time.Duration
+	Steps         string
+
+	providers map[string]entry
+	mcp       []mcpConf
+	servers   []*mcpServer
+
+	mu      sync.Mutex
+	refs    int  // 正在使用的请求数
+	retired bool // 已被新配置替换
+}
+
+// 工具及提供给哪些模型
+type entry struct {
+	Provider
+	models []string
+}
+
+var (
+	factories = make(map[string]Factory)
+	configs   atomic.Pointer[Config]
+)
+
+func init() {
+	inited.AddInitialized(func(env *env.Environment) {
+		config, err := load(env)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		if err = config.open(); err != nil {
+			logger.Fatal(err)
+		}
+		configs.Store(config)
+	})
+
+	// 校验阶段只解析配置，生效时才创建连接；旧配置等正在执行的请求结束后再释放
+	inited.AddReloaded(func(env *env.Environment) (func(), error) {
+		config, err := load(env)
+		if err != nil {
+			return nil, err
+		}
+		return func() {
+			if err := config.open(); err != nil {
+				logger.Error(err)
+			}
+			if old := configs.Swap(config); old != nil {
+				old.retire()
+			}
+			logger.Infof("reload agent tools: %d", len(config.providers))
+		}, nil
+	})
+}
+
+// 注册工具，name 即配置 agent.tools 中的名字
+func Register(name string, factory Factory) {
+	if _, ok := factories[name]; ok {
+		panic(fmt.Sprintf("tool provider `%s` already registered", name))
+	}
+	factories[name] = factory
+}
+
+// 已注册的工具名
+func Names() (names []string) {
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+func load(env *env.Environment) (*Config, error) {
+	config := &Config{
+		Models:        env.GetStringSlice("agent.models"),
+		MaxIterations: 5,
+		Timeout:       120 * time.Second,
+		Steps:         env.GetString("agent.steps"),
+		providers:     make(map[string]entry),
+	}
+	if env.IsSet("agent.max-iterations") {
+		config.MaxIterations = env.GetInt("agent.max-iterations")
+	}
+	if env.IsSet("agent.timeout") {
+		config.Timeout = time.Duration(env.GetInt("agent.timeout")) * time.Second
+	}
+
+	switch config.Steps {
+	case "":
+		config.Steps = "none"
+	case "none", "reasoning", "event":
+	default:
+		return nil, fmt.Errorf("agent.steps: unknown value '%s'", config.Steps)
+	}
+
+	for _, pattern := range config.Models {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("agent.models: bad pattern '%s'", pattern)
+		}
+	}
+
+	for _, name := range env.GetStringSlice("agent.tools") {
+		factory, ok := factories[name]
+		if !ok {
+			return nil, fmt.Errorf("agent.tools: unknown tool '%s', available: %v", name, Names())
+		}
+		provider, err := factory(env)
+		if err != nil {
+			return nil, fmt.Errorf("agent.tools: %s: %v", name, err)
+		}
+		config.providers[provider.Definition().GetString("name")] = entry{provider, config.Models}
+	}
+
+	if err := loadMCP(env, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// 创建工具的连接并连接 MCP 服务器，失败的工具不提供给模型
+func (c *Config) open() (err error) {
+	for name, e := range c.providers {
+		opener, ok := e.Provider.(interface{ Open() error })
+		if !ok {
+			continue
+		}
+		if e := opener.Open(); e != nil {
+			err = errors.Join(err, fmt.Errorf("agent.tools: %s: %v", name, e))
+			delete(c.providers, name)
+		}
+	}
+	c.openMCP()
+	return
+}
+
+// 不再分配给新请求，最后一个请求结束后释放
+func (c *Config) retire() {
+	c.mu.Lock()
+	c.retired = true
+	idle := c.refs == 0
+	c.mu.Unlock()
+	if idle {
+		c.close()
+	}
+}
+
+// 请求结束，与 Of 成对调用
+func (c *Config) Release() {
+	c.mu.Lock()
+	c.refs--
+	idle := c.retired && c.refs == 0
+	c.mu.Unlock()
+	if idle {
+		c.close()
+	}
+}
+
+// 当前配置并增加引用，已被替换的配置不再分配
+func acquire() *Config {
+	for {
+		config := configs.Load()
+		if config == nil {
+			return nil
+		}
+		config.mu.Lock()
+		if !config.retired {
+			config.refs++
+			config.mu.Unlock()
+			return config
+		}
+		config.mu.Unlock()
+	}
+}
+
+// 释放工具持有的连接
+func (c *Config) close() {
+	for _, e := range c.providers {
+		if closer, ok := e.Provider.(interface{ Close() error }); ok {
+			if err := closer.Close(); err != nil {
+				logger.Error(err)
+			}
+		}
+	}
+	for _, server := range c.servers {
+		server.close()
+	}
+}
+
+// 模型启用的网关工具配置，未启用返回 nil；使用完毕后须调用 Release
+func Of(mod string) *Config {
+	config := acquire()
+	if config == nil {
+		return nil
+	}
+	for _, e := range config.providers {
+		if matchModel(e.models, mod) {
+			return config
+		}
+	}
+	config.Release()
+	return nil
+}
+
+// 按工具名查找
+func (c *Config) Lookup(name string) (Provider, bool) {
+	e, ok := c.providers[name]
+	return e.Provider, ok
+}
+
+// 追加到请求中的工具定义，与客户端工具重名时以客户端为准
+func (c *Config) Tools(mod string, exists []model.Keyv[interface{}]) (tools []model.Keyv[interface{}]) {
+	names := make(map[string]bool)
+	for _, t := range exists {
+		names[t.GetKeyv("function").GetString("name")] = true
+	}
+
+	var keys []string
+	for name, e := range c.providers {
+		if !names[name] && matchModel(e.models, mod) {
+			keys = append(keys, name)
+		}
+	}
+	sort.Strings(keys)
+	for _, name := range keys {
+		tools = append(tools, model.Keyv[interface{}]{
+			"type":     "function",
+			"function": map[string]interface{}(c.providers[name].Definition()),
+		})
+	}
+	return
+}
+
+func matchModel(patterns []string, mod string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, mod); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// 参数读取
+func stringArg(args map[string]interface{}, key string) string {
+	if value, ok := args[key].(string); ok {
+		return value
+	}
+	return ""
+}
+
+// 以 json 声明工具定义，保证各层均为 map[string]interface{}、[]interface{}，与请求中的 tools 一致
+func definition(js string) (value model.Keyv[interface{}]) {
+	if err := json.Unmarshal([]byte(js), &value); err != nil {
+		panic(err)
+	}
+	return
+}
@@ -0,0 +1,158 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"chatgpt-adapter/core/gin/model"
+	"github.com/iocgo/sdk/env"
+)
+
+// 在只读事务中执行查询，内置 pgx（PostgreSQL），以 -tags mysql 编译时可用 mysql
+//
+//	agent:
+//	  sql:
+//	    driver: pgx
+//	    dsn: "postgres://readonly@db/app?sslmode=disable"
+//	    max-rows: 100          # 默认 100
+//	    description: "..."     # 库表说明，帮助模型编写查询
+type sqlTool struct {
+	driver      string
+	dsn         string
+	db          *sql.DB
+	maxRows     int
+	description string
+}
+
+func init() {
+	Register("sql", newSqlTool)
+}
+
+func newSqlTool(env *env.Environment) (Provider, error) {
+	driver, dsn := env.GetString("agent.sql.driver"), env.GetString("agent.sql.dsn")
+	if driver == "" || dsn == "" {
+		return nil, errors.New("agent.sql.driver and agent.sql.dsn are required")
+	}
+	if !slices.Contains(sql.Drivers(), driver) {
+		return nil, fmt.Errorf("unknown driver '%s', available: %v", driver, sql.Drivers())
+	}
+
+	t := &sqlTool{driver: driver, dsn: dsn, maxRows: 100, description: env.GetString("agent.sql.description")}
+	if env.IsSet("agent.sql.max-rows") {
+		t.maxRows = env.GetInt("agent.sql.max-rows")
+	}
+	return t, nil
+}
+
+// 创建连接池，配置生效时调用
+func (t *sqlTool) Open() (err error) {
+	t.db, err = sql.Open(t.driver, t.dsn)
+	return
+}
+
+func (t *sqlTool) Definition() model.Keyv[interface{}] {
+	description := "Run a read-only SQL query (SELECT / WITH / SHOW / EXPLAIN) and get the rows as json"
+	if t.description != "" {
+		description += ". " + t.description
+	}
+	marshal, _ := json.Marshal(description)
+	return definition(fmt.Sprintf(`{
+		"name": "sql",
+		"description": %s,
+		"parameters": {
+			"type": "object",
+			"properties": {
+				"query": { "type": "string", "description": "A single SQL statement" }
+			},
+			"required": [ "query" ]
+		}
+	}`, marshal))
+}
+
+func (t *sqlTool) Call(ctx context.Context, args map[string]interface{}) (string, error) {
+	query := strings.TrimSuffix(strings.TrimSpace(stringArg(args, "query")), ";")
+	if err := readonly(query); err != nil {
+		return "", err
+	}
+
+	// 只读事务，执行后回滚
+	tx, err := t.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		result    = make([]map[string]interface{}, 0)
+		truncated = false
+	)
+	for rows.Next() {
+		if len(result) >= t.maxRows {
+			truncated = true
+			break
+		}
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err = rows.Scan(pointers...); err != nil {
+			return "", err
+		}
+
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			if bytes, ok := values[i].([]byte); ok {
+				values[i] = string(bytes)
+			}
+			row[column] = values[i]
+		}
+		result = append(result, row)
+	}
+	if err = rows.Err(); err != nil {
+		return "", err
+	}
+
+	marshal, err := json.Marshal(map[string]interface{}{"rows": result, "truncated": truncated})
+	return string(marshal), err
+}
+
+func (t *sqlTool) Close() error {
+	if t.db == nil {
+		return nil
+	}
+	return t.db.Close()
+}
+
+// 只允许单条查询语句，只读事务之外的一道防线
+func readonly(query string) error {
+	if query == "" {
+		return errors.New("query is empty")
+	}
+	if strings.Contains(query, ";") {
+		return errors.New("only a single statement is allowed")
+	}
+
+	fields := strings.Fields(query)
+	switch strings.ToUpper(fields[0]) {
+	case "SELECT", "WITH", "SHOW", "EXPLAIN", "DESCRIBE", "DESC":
+		return nil
+	}
+	return fmt.Errorf("statement `%s` is not allowed", fields[0])
+}
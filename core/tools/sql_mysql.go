@@ -0,0 +1,6 @@
+//go:build mysql
+
+package tools
+
+// MySQL 驱动，以 -tags mysql 编译后可用，driver: mysql
+import _ "github.com/go-sql-driver/mysql"
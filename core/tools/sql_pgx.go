@@ -0,0 +1,4 @@
+package tools
+
+// PostgreSQL 驱动，driver: pgx
+import _ "github.com/jackc/pgx/v5/stdlib"
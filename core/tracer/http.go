@@ -0,0 +1,95 @@
+package tracer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+
+	"github.com/bincooo/emit.io"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 发出 emit 请求并记录为 upstream span，同时向上游注入 traceparent。
+// ja3 请求走 tls-client，不经过 net/http 的 httptrace，需以此包裹才会记录；
+// span 到收到响应头（或 conditions 校验失败）结束
+//
+//	response, err := tracer.Do(ctx, emit.ClientBuilder(common.HTTPClient).Ja3().POST(u), emit.Status(http.StatusOK))
+func Do(ctx context.Context, builder *emit.Builder, conditions ...func(*http.Response) error) (*http.Response, error) {
+	if !Enabled() {
+		return builder.DoC(conditions...)
+	}
+
+	ctx, span := Start(ctx, "upstream.request")
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	for key, value := range carrier {
+		builder.Header(key, value)
+	}
+
+	response, err := builder.DoC(conditions...)
+	if response != nil {
+		span.SetAttributes(attribute.Int("http.response.status_code", response.StatusCode))
+	}
+	End(span, err)
+	return response, err
+}
+
+// 以 net/http 的 httptrace 钩子记录上游请求，请求需携带该 context 发出 (emit.Builder.Context)。
+// span 从获取连接开始，到收到响应首字节结束；ja3 请求不经过 net/http，由 Do 记录
+//
+//	ctx, done := withHTTPTrace(ctx)
+//	defer done() // 结束未完成的 span，如请求超时
+func withHTTPTrace(ctx context.Context) (context.Context, func()) {
+	var (
+		mu    sync.Mutex
+		spans []trace.Span
+	)
+
+	pop := func(err error) {
+		mu.Lock()
+		if len(spans) == 0 {
+			mu.Unlock()
+			return
+		}
+		span := spans[0]
+		spans = spans[1:]
+		mu.Unlock()
+		End(span, err)
+	}
+
+	clientTrace := &httptrace.ClientTrace{
+		GetConn: func(hostPort string) {
+			_, span := Start(ctx, "upstream", attribute.String("server.address", hostPort))
+			mu.Lock()
+			spans = append(spans, span)
+			mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			mu.Lock()
+			if len(spans) > 0 {
+				spans[len(spans)-1].SetAttributes(attribute.Bool("http.conn.reused", info.Reused))
+			}
+			mu.Unlock()
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err != nil {
+				pop(info.Err)
+			}
+		},
+		GotFirstResponseByte: func() { pop(nil) },
+	}
+
+	return httptrace.WithClientTrace(ctx, clientTrace), func() {
+		mu.Lock()
+		remaining := spans
+		spans = nil
+		mu.Unlock()
+		for _, span := range remaining {
+			span.End()
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package tracer
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"chatgpt-adapter/core/common/inited"
+	"chatgpt-adapter/core/gin/inter"
+	"chatgpt-adapter/core/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/iocgo/sdk/env"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const name = "chatgpt-adapter"
+
+var (
+	provider *sdktrace.TracerProvider
+)
+
+// tracing:
+//
+//	enabled: true
+//	endpoint: http://127.0.0.1:4318
+//	service-name: chatgpt-adapter
+//	sample-ratio: 1
+//	headers:
+//	  authorization: xxx
+func init() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	inited.AddInitialized(func(env *env.Environment) {
+		if !env.GetBool("tracing.enabled") {
+			return
+		}
+
+		endpoint := env.GetString("tracing.endpoint")
+		if endpoint == "" {
+			endpoint = "http://127.0.0.1:4318"
+		}
+
+		service := env.GetString("tracing.service-name")
+		if service == "" {
+			service = name
+		}
+
+		ratio := 1.0
+		if env.IsSet("tracing.sample-ratio") {
+			ratio = env.GetFloat64("tracing.sample-ratio")
+		}
+
+		res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+			attribute.String("service.name", service),
+		))
+		if err != nil {
+			logger.Error(err)
+			res = resource.Default()
+		}
+
+		// OTLP/HTTP: POST {endpoint}/v1/traces
+		url := strings.TrimSuffix(endpoint, "/")
+		if !strings.HasSuffix(url, "/v1/traces") {
+			url += "/v1/traces"
+		}
+		exporter, err := otlptracehttp.New(context.Background(),
+			otlptracehttp.WithEndpointURL(url),
+			otlptracehttp.WithHeaders(env.GetStringMapString("tracing.headers")))
+		if err != nil {
+			logger.Errorf("tracing disabled: %v", err)
+			return
+		}
+
+		provider = sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(exporter),
+			sdktrace.WithResource(res),
+			sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		)
+		otel.SetTracerProvider(provider)
+		logger.Infof("tracing enabled, exporting to %s", endpoint)
+
+		inited.AddExited(shutdown)
+	})
+}
+
+func shutdown(*env.Environment) {
+	timeout, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := provider.Shutdown(timeout); err != nil {
+		logger.Error(err)
+	}
+}
+
+func Enabled() bool {
+	return provider != nil
+}
+
+func Start(ctx context.Context, spanName string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return otel.Tracer(name).Start(ctx, spanName, trace.WithAttributes(attrs...))
+}
+
+// 以适配器上下文开启 span，返回的上下文携带该 span，传给子调用；
+// 子调用以该 context 发出的上游请求会记录为子 span
+//
+//	sub, end := tracer.Span(ctx, "adapter.completion")
+//	err := extension.Completion(sub)
+//	end(err)
+func Span(ctx *inter.Context, spanName string, attrs ...attribute.KeyValue) (*inter.Context, func(err error)) {
+	spanCtx, span := Start(ctx.Context, spanName, attrs...)
+	if !Enabled() {
+		return ctx.WithContext(spanCtx), func(err error) { End(span, err) }
+	}
+
+	spanCtx, done := withHTTPTrace(spanCtx)
+	return ctx.WithContext(spanCtx), func(err error) {
+		done()
+		End(span, err)
+	}
+}
+
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// 服务端入口 span，解析 W3C traceparent
+func Middleware(gtx *gin.Context) {
+	if !Enabled() || gtx.Request.Method == "OPTIONS" {
+		gtx.Next()
+		return
+	}
+
+	propagator := otel.GetTextMapPropagator()
+	ctx := propagator.Extract(gtx.Request.Context(), propagation.HeaderCarrier(gtx.Request.Header))
+	route := gtx.FullPath()
+	if route == "" {
+		route = gtx.Request.URL.Path
+	}
+
+	ctx, span := otel.Tracer(name).Start(ctx, gtx.Request.Method+" "+route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", gtx.Request.Method),
+			attribute.String("http.route", route),
+			attribute.String("url.path", gtx.Request.URL.Path),
+		))
+	gtx.Request = gtx.Request.WithContext(ctx)
+
+	propagator.Inject(ctx, propagation.HeaderCarrier(gtx.Writer.Header()))
+	gtx.Next()
+
+	status := gtx.Writer.Status()
+	span.SetAttributes(attribute.Int("http.response.status_code", status))
+	if status >= 500 {
+		span.SetStatus(codes.Error, "")
+	}
+	span.End()
+}
@@ -0,0 +1,280 @@
+package usage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"chatgpt-adapter/core/common/inited"
+	"chatgpt-adapter/core/logger"
+	"github.com/iocgo/sdk/env"
+	bolt "go.etcd.io/bbolt"
+)
+
+// 用量账本：每个请求一条记录，写入内嵌的 bbolt 数据库
+//
+//	usage:
+//	  enabled: true
+//	  path: data/usage.db # 默认 usage.db
+//	  retention: 90       # 保留天数，0 为不清理
+type Record struct {
+	Time             time.Time `json:"time"`
+	RequestId        string    `json:"request_id"`
+	Key              string    `json:"key"` // 客户端凭证的标签，不保存原文
+	Model            string    `json:"model"`
+	Adapter          string    `json:"adapter"`
+	Account          string    `json:"account"` // 账号池中的账号
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	ReasoningTokens  int       `json:"reasoning_tokens"`
+	TotalTokens      int       `json:"total_tokens"`
+	Latency          int64     `json:"latency_ms"`
+	Status           int       `json:"status"`
+	Source           string    `json:"source"` // upstream 请求上游、cache 命中响应缓存、coalesced 合并到进行中的请求
+}
+
+// 记录的来源
+const (
+	SourceUpstream  = "upstream"
+	SourceCache     = "cache"
+	SourceCoalesced = "coalesced"
+)
+
+type Ledger struct {
+	db        *bolt.DB
+	path      string
+	retention atomic.Int64 // 天
+	mu        sync.RWMutex // 保护 queue 的关闭
+	stopped   bool
+	queue     chan Record
+	closed    chan struct{}
+}
+
+var (
+	bucket  = []byte("records")
+	ledgers atomic.Pointer[Ledger]
+)
+
+// 账本配置
+type settings struct {
+	enabled   bool
+	path      string
+	retention int64 // 天
+}
+
+func init() {
+	inited.AddInitialized(func(env *env.Environment) {
+		ledger, err := open(load(env), nil)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		ledgers.Store(ledger)
+	})
+
+	// 校验阶段只读取配置，生效时才打开文件；打开失败时沿用当前的账本
+	inited.AddReloaded(func(env *env.Environment) (func(), error) {
+		conf := load(env)
+		return func() {
+			ledger, err := open(conf, ledgers.Load())
+			if err != nil {
+				logger.Error(err)
+				return
+			}
+			if old := ledgers.Swap(ledger); old != nil && old != ledger {
+				old.close()
+			}
+		}, nil
+	})
+}
+
+func load(env *env.Environment) settings {
+	conf := settings{enabled: env.GetBool("usage.enabled"), path: env.GetString("usage.path"), retention: 90}
+	if conf.path == "" {
+		conf.path = "usage.db"
+	}
+	if env.IsSet("usage.retention") {
+		conf.retention = env.GetInt64("usage.retention")
+	}
+	return conf
+}
+
+// 路径未变化时沿用当前的账本，bbolt 不允许重复打开同一文件
+func open(conf settings, current *Ledger) (*Ledger, error) {
+	if !conf.enabled {
+		return nil, nil
+	}
+
+	path, retention := conf.path, conf.retention
+	if current != nil && current.path == path {
+		current.retention.Store(retention)
+		return current, nil
+	}
+
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("usage.path: %v", err)
+		}
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 3 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("usage.path: open '%s' failed: %v", path, err)
+	}
+	if err = db.Update(func(tx *bolt.Tx) error {
+		_, e := tx.CreateBucketIfNotExists(bucket)
+		return e
+	}); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	ledger := &Ledger{db: db, path: path, queue: make(chan Record, 4096), closed: make(chan struct{})}
+	ledger.retention.Store(retention)
+	go ledger.run()
+	logger.Infof("usage ledger opened: %s", path)
+	return ledger, nil
+}
+
+// 凭证的脱敏标签可能重复，附上摘要以区分不同的凭证
+func KeyLabel(key string) string {
+	if key == "" {
+		return "-"
+	}
+	sum := sha256.Sum256([]byte(key))
+	return logger.Label(key) + "#" + hex.EncodeToString(sum[:4])
+}
+
+// 未开启时返回 nil
+func Current() *Ledger {
+	return ledgers.Load()
+}
+
+// 记录一次请求，异步写入；队列已满时丢弃，不阻塞请求
+func Add(record Record) {
+	ledger := ledgers.Load()
+	if ledger == nil {
+		return
+	}
+	ledger.mu.RLock()
+	defer ledger.mu.RUnlock()
+	if ledger.stopped {
+		return
+	}
+	select {
+	case ledger.queue <- record:
+	default:
+		logger.Warnf("usage ledger queue is full, record dropped: %s", record.RequestId)
+	}
+}
+
+// 批量写入，并每小时清理过期的记录
+func (l *Ledger) run() {
+	defer close(l.closed)
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	l.prune()
+
+	for {
+		select {
+		case record, ok := <-l.queue:
+			if !ok {
+				return
+			}
+			records := []Record{record}
+		drain:
+			for len(records) < 256 {
+				select {
+				case record, ok = <-l.queue:
+					if !ok {
+						break drain
+					}
+					records = append(records, record)
+				default:
+					break drain
+				}
+			}
+			if err := l.write(records); err != nil {
+				logger.Errorf("usage ledger write failed: %v", err)
+			}
+		case <-ticker.C:
+			l.prune()
+		}
+	}
+}
+
+func (l *Ledger) write(records []Record) error {
+	return l.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		for _, record := range records {
+			value, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			seq, _ := b.NextSequence()
+			if err = b.Put(recordKey(record.Time, seq), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 键以时间开头，按时间范围查询时只需顺序扫描
+func recordKey(t time.Time, seq uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
+
+func (l *Ledger) prune() {
+	days := l.retention.Load()
+	if days <= 0 {
+		return
+	}
+
+	end := recordKey(time.Now().AddDate(0, 0, -int(days)), 0)
+	removed := 0
+	err := l.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucket).Cursor()
+		// 删除后游标位置不可靠，每次从头开始
+		for k, _ := c.First(); k != nil && bytes.Compare(k, end) < 0; k, _ = c.First() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Errorf("usage ledger prune failed: %v", err)
+		return
+	}
+	if removed > 0 {
+		logger.Infof("usage ledger pruned %d record(s) older than %d day(s)", removed, days)
+	}
+}
+
+// 写完队列中的记录后关闭
+func (l *Ledger) close() {
+	l.mu.Lock()
+	if l.stopped {
+		l.mu.Unlock()
+		return
+	}
+	l.stopped = true
+	close(l.queue)
+	l.mu.Unlock()
+
+	<-l.closed
+	if err := l.db.Close(); err != nil {
+		logger.Error(err)
+	}
+}
@@ -0,0 +1,189 @@
+package usage
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// 可分组的字段
+var Groups = []string{"key", "model", "adapter", "account", "status", "source", "day", "hour"}
+
+type Query struct {
+	From, To time.Time
+	GroupBy  []string
+	Filter   map[string]string // 分组字段 => 值，精确匹配
+	Limit    int               // 不分组时返回的记录数上限，0 不限制
+}
+
+// 分组汇总
+type Summary struct {
+	Group            map[string]string `json:"group,omitempty"`
+	Requests         int               `json:"requests"`
+	Errors           int               `json:"errors"` // status >= 400
+	PromptTokens     int               `json:"prompt_tokens"`
+	CompletionTokens int               `json:"completion_tokens"`
+	ReasoningTokens  int               `json:"reasoning_tokens"`
+	TotalTokens      int               `json:"total_tokens"`
+	LatencyAvg       int64             `json:"latency_avg_ms"`
+
+	latency int64
+}
+
+func (q Query) Check() error {
+	if !q.To.After(q.From) {
+		return fmt.Errorf("`to` must be after `from`")
+	}
+	for _, group := range q.GroupBy {
+		if !slices.Contains(Groups, group) {
+			return fmt.Errorf("unknown group '%s', expected one of %s", group, strings.Join(Groups, ", "))
+		}
+	}
+	for group := range q.Filter {
+		if !slices.Contains(Groups, group) {
+			return fmt.Errorf("unknown filter '%s', expected one of %s", group, strings.Join(Groups, ", "))
+		}
+	}
+	return nil
+}
+
+func (r Record) field(name string) string {
+	switch name {
+	case "key":
+		return r.Key
+	case "model":
+		return r.Model
+	case "adapter":
+		return r.Adapter
+	case "account":
+		return r.Account
+	case "status":
+		return strconv.Itoa(r.Status)
+	case "source":
+		return r.Source
+	case "day":
+		return r.Time.Local().Format("2006-01-02")
+	case "hour":
+		return r.Time.Local().Format("2006-01-02 15:00")
+	}
+	return ""
+}
+
+// 按时间范围顺序读取记录
+func (l *Ledger) scan(q Query, f func(record Record) bool) error {
+	start, end := recordKey(q.From, 0), recordKey(q.To, 0)
+	return l.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucket).Cursor()
+		for k, v := c.Seek(start); k != nil && bytes.Compare(k, end) < 0; k, v = c.Next() {
+			var record Record
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			matched := true
+			for name, value := range q.Filter {
+				if record.field(name) != value {
+					matched = false
+					break
+				}
+			}
+			if matched && !f(record) {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// 不分组时的明细
+func (l *Ledger) Records(q Query) (records []Record, err error) {
+	records = make([]Record, 0)
+	err = l.scan(q, func(record Record) bool {
+		records = append(records, record)
+		return q.Limit <= 0 || len(records) < q.Limit
+	})
+	return
+}
+
+// 分组汇总，按 total_tokens 从高到低排列
+func (l *Ledger) Summarize(q Query) ([]Summary, error) {
+	var (
+		summaries = make([]*Summary, 0)
+		indexes   = make(map[string]*Summary)
+	)
+	err := l.scan(q, func(record Record) bool {
+		values := make([]string, len(q.GroupBy))
+		for i, group := range q.GroupBy {
+			values[i] = record.field(group)
+		}
+		id := strings.Join(values, "\x00")
+
+		s, ok := indexes[id]
+		if !ok {
+			s = &Summary{Group: make(map[string]string, len(values))}
+			for i, group := range q.GroupBy {
+				s.Group[group] = values[i]
+			}
+			indexes[id] = s
+			summaries = append(summaries, s)
+		}
+		s.Requests++
+		if record.Status >= 400 {
+			s.Errors++
+		}
+		s.PromptTokens += record.PromptTokens
+		s.CompletionTokens += record.CompletionTokens
+		s.ReasoningTokens += record.ReasoningTokens
+		s.TotalTokens += record.TotalTokens
+		s.latency += record.Latency
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Summary, 0, len(summaries))
+	for _, s := range summaries {
+		s.LatencyAvg = s.latency / int64(s.Requests)
+		result = append(result, *s)
+	}
+	slices.SortStableFunc(result, func(a, b Summary) int { return b.TotalTokens - a.TotalTokens })
+	return result, nil
+}
+
+func WriteRecordsCSV(w io.Writer, records []Record) error {
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{"time", "request_id", "key", "model", "adapter", "account",
+		"prompt_tokens", "completion_tokens", "reasoning_tokens", "total_tokens", "latency_ms", "status", "source"})
+	for _, r := range records {
+		_ = writer.Write([]string{r.Time.Format(time.RFC3339), r.RequestId, r.Key, r.Model, r.Adapter, r.Account,
+			strconv.Itoa(r.PromptTokens), strconv.Itoa(r.CompletionTokens), strconv.Itoa(r.ReasoningTokens), strconv.Itoa(r.TotalTokens),
+			strconv.FormatInt(r.Latency, 10), strconv.Itoa(r.Status), r.Source})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func WriteSummariesCSV(w io.Writer, groupBy []string, summaries []Summary) error {
+	writer := csv.NewWriter(w)
+	_ = writer.Write(append(slices.Clone(groupBy), "requests", "errors",
+		"prompt_tokens", "completion_tokens", "reasoning_tokens", "total_tokens", "latency_avg_ms"))
+	for _, s := range summaries {
+		row := make([]string, 0, len(groupBy)+7)
+		for _, group := range groupBy {
+			row = append(row, s.Group[group])
+		}
+		_ = writer.Write(append(row, strconv.Itoa(s.Requests), strconv.Itoa(s.Errors),
+			strconv.Itoa(s.PromptTokens), strconv.Itoa(s.CompletionTokens), strconv.Itoa(s.ReasoningTokens), strconv.Itoa(s.TotalTokens),
+			strconv.FormatInt(s.LatencyAvg, 10)))
+	}
+	writer.Flush()
+	return writer.Error()
+}
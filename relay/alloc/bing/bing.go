@@ -1,6 +1,9 @@
 package bing
 
 import (
+	"chatgpt-adapter/core/gin/inter"
+	"fmt"
+	"sync/atomic"
 	"time"
 
 	"chatgpt-adapter/core/common"
@@ -8,42 +11,81 @@ import (
 	"chatgpt-adapter/core/common/vars"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
-	"github.com/gin-gonic/gin"
 	"github.com/iocgo/sdk/env"
 	"github.com/iocgo/sdk/proxy"
-	"github.com/iocgo/sdk/stream"
 )
 
 var (
-	cookiesContainer *common.PollContainer[map[string]string]
+	// 重载时可能由空配置新建，请求期间通过 Load 读取
+	cookiesContainer atomic.Pointer[common.PollContainer[map[string]string]]
 )
 
 func init() {
 	inited.AddInitialized(func(env *env.Environment) {
-		cookies, ok := env.Get("bing.cookies").([]interface{})
-		if !ok {
+		slice, err := parseCookies(env)
+		if err != nil {
+			logger.Error(err)
+		}
+		if len(slice) == 0 {
 			return
 		}
-		slice := stream.Map(stream.OfSlice(cookies), func(t interface{}) (obj map[string]string) {
-			m, o := t.(map[string]interface{})
-			if !o {
+
+		container := common.NewPollContainer[map[string]string]("bing", slice, 6*time.Hour)
+		container.Condition = condition
+		cookiesContainer.Store(container)
+	})
+
+	inited.AddReloaded(func(env *env.Environment) (func(), error) {
+		slice, err := parseCookies(env)
+		if err != nil {
+			return nil, err
+		}
+		return func() {
+			container := cookiesContainer.Load()
+			if container == nil {
+				if len(slice) == 0 {
+					return
+				}
+				container = common.NewPollContainer[map[string]string]("bing", slice, 6*time.Hour)
+				container.Condition = condition
+				cookiesContainer.Store(container)
 				return
 			}
-			return map[string]string{
-				"scopeId": m["scopeid"].(string),
-				"idToken": m["idtoken"].(string),
-				"cookie":  m["cookie"].(string),
+			if err = container.Reset(slice); err != nil {
+				logger.Error(err)
 			}
-		}).ToSlice()
-
-		cookiesContainer = common.NewPollContainer[map[string]string]("bing", slice, 6*time.Hour)
-		cookiesContainer.Condition = condition
+		}, nil
 	})
 }
 
+func parseCookies(env *env.Environment) (slice []map[string]string, err error) {
+	cookies, ok := env.Get("bing.cookies").([]interface{})
+	if !ok {
+		return
+	}
+	for i, t := range cookies {
+		m, o := t.(map[string]interface{})
+		if !o {
+			continue
+		}
+		scopeId, _ := m["scopeid"].(string)
+		idToken, _ := m["idtoken"].(string)
+		cookie, _ := m["cookie"].(string)
+		if scopeId == "" || idToken == "" || cookie == "" {
+			return nil, fmt.Errorf("bing.cookies[%d]: scopeid, idtoken and cookie are required", i)
+		}
+		slice = append(slice, map[string]string{
+			"scopeId": scopeId,
+			"idToken": idToken,
+			"cookie":  cookie,
+		})
+	}
+	return
+}
+
 func InvocationHandler(ctx *proxy.Context) {
 	var (
-		gtx  = ctx.In[0].(*gin.Context)
+		gtx  = ctx.In[0].(*inter.Context)
 		echo = gtx.GetBool(vars.GinEcho)
 	)
 
@@ -52,21 +94,23 @@ func InvocationHandler(ctx *proxy.Context) {
 		return
 	}
 
-	logger.Infof("execute static proxy [relay/llm/bing.api]: func %s(...)", ctx.Method)
+	logger.Ctx(gtx).Infof("execute static proxy [relay/llm/bing.api]: func %s(...)", ctx.Method)
 
-	if cookiesContainer.Len() == 0 {
+	container := cookiesContainer.Load()
+	if container == nil || container.Len() == 0 {
 		response.Error(gtx, -1, "empty cookies")
 		return
 	}
 
-	cookie, err := cookiesContainer.Poll()
+	cookie, err := container.Poll(gtx)
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(gtx).Error(err)
 		response.Error(gtx, -1, err)
 		return
 	}
-	defer resetMarked(cookie)
-	gtx.Set("token", cookie)
+	defer resetMarked(container, cookie)
+	gtx.Set(vars.GinPoolEntry, container.Id(cookie))
+	gtx.Set(vars.GinToken, cookie)
 
 	//
 	ctx.Do()
@@ -80,13 +124,13 @@ func InvocationHandler(ctx *proxy.Context) {
 	}
 
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(gtx).Error(err)
 		return
 	}
 }
 
 func condition(cookie map[string]string, argv ...interface{}) bool {
-	marker, err := cookiesContainer.Marked(cookie)
+	marker, err := cookiesContainer.Load().Marked(cookie)
 	if err != nil {
 		logger.Error(err)
 		return false
@@ -94,8 +138,8 @@ func condition(cookie map[string]string, argv ...interface{}) bool {
 	return marker == 0
 }
 
-func resetMarked(cookie map[string]string) {
-	marker, err := cookiesContainer.Marked(cookie)
+func resetMarked(container *common.PollContainer[map[string]string], cookie map[string]string) {
+	marker, err := container.Marked(cookie)
 	if err != nil {
 		logger.Error(err)
 		return
@@ -105,7 +149,7 @@ func resetMarked(cookie map[string]string) {
 		return
 	}
 
-	err = cookiesContainer.MarkTo(cookie, 0)
+	err = container.MarkTo(cookie, 0)
 	if err != nil {
 		logger.Error(err)
 	}
@@ -1,10 +1,13 @@
 package coze
 
 import (
+	"chatgpt-adapter/core/gin/inter"
 	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"chatgpt-adapter/core/common"
@@ -15,7 +18,6 @@ import (
 	"chatgpt-adapter/core/logger"
 	"github.com/bincooo/coze-api"
 	"github.com/bincooo/emit.io"
-	"github.com/gin-gonic/gin"
 	"github.com/iocgo/sdk/env"
 	"github.com/iocgo/sdk/proxy"
 )
@@ -29,7 +31,12 @@ type account struct {
 }
 
 var (
-	cookiesContainer *common.PollContainer[*account]
+	// 重载时可能由空配置新建，请求期间通过 Load 读取
+	cookiesContainer atomic.Pointer[common.PollContainer[*account]]
+
+	// email => account，重载与登录任务并发访问，由 accountsMu 保护
+	accountsMu sync.Mutex
+	accounts   = make(map[string]*account)
 )
 
 func init() {
@@ -47,17 +54,98 @@ func init() {
 			panic("don't used browser-less, please setting `browser-less.enabled` or `browser-less.reversal`")
 		}
 
-		cookiesContainer = common.NewPollContainer("coze", make([]*account, 0), 60*time.Second) // 报错进入60秒冷却
-		cookiesContainer.Condition = condition(env.GetString("server.proxied"))
+		container := common.NewPollContainer("coze", make([]*account, 0), 60*time.Second) // 报错进入60秒冷却
+		container.Condition = condition(env.GetString("server.proxied"))
+		cookiesContainer.Store(container)
+		accountsMu.Lock()
+		for _, value := range values {
+			accounts[value.E] = value
+		}
+		accountsMu.Unlock()
 		run(env, values...)
 	})
+
+	// 仅同步增删的账号，已登录的账号保持不变
+	inited.AddReloaded(func(env *env.Environment) (func(), error) {
+		var values []*account
+		if err := env.UnmarshalKey("coze.websdk.accounts", &values); err != nil {
+			return nil, fmt.Errorf("coze.websdk.accounts: %v", err)
+		}
+		if len(values) > 0 && !env.GetBool("browser-less.enabled") && env.GetString("browser-less.reversal") == "" {
+			return nil, errors.New("don't used browser-less, please setting `browser-less.enabled` or `browser-less.reversal`")
+		}
+		return func() { reloadAccounts(env, values) }, nil
+	})
+}
+
+func reloadAccounts(env *env.Environment, values []*account) {
+	added := make([]*account, 0)
+	keys := make(map[string]bool)
+	removed := make([]*account, 0)
+
+	accountsMu.Lock()
+	for _, value := range values {
+		if older, ok := accounts[value.E]; ok && older.P == value.P && older.V == value.V {
+			keys[value.E] = true
+			continue
+		}
+		added = append(added, value)
+	}
+
+	// 移除已删除或变更的账号，变更的账号以新配置重新登录
+	for email, value := range accounts {
+		if keys[email] {
+			continue
+		}
+		delete(accounts, email)
+		removed = append(removed, value)
+	}
+
+	for _, value := range added {
+		accounts[value.E] = value
+	}
+	accountsMu.Unlock()
+
+	// 不持有 accountsMu：Poll 持有容器锁时会经 condition 获取 accountsMu
+	for _, value := range removed {
+		if container := cookiesContainer.Load(); container != nil {
+			if err := container.Remove(value); err != nil {
+				logger.Error(err)
+			}
+		}
+		removeTaskOf(value)
+	}
+
+	if len(added) == 0 {
+		return
+	}
+
+	logger.Infof("reload coze accounts: +%d", len(added))
+	if cookiesContainer.Load() == nil {
+		container := common.NewPollContainer("coze", make([]*account, 0), 60*time.Second)
+		container.Condition = condition(env.GetString("server.proxied"))
+		cookiesContainer.Store(container)
+		run(env, added...)
+		return
+	}
+
+	objs := make([]*obj, 0, len(added))
+	for _, value := range added {
+		objs = append(objs, &obj{value, w_retry})
+	}
+	go runTasks(env, objs...)
+}
+
+// 账号仍在配置中，调用方需持有 accountsMu；已被重载移除的账号不再加入轮询或任务
+func configured(value *account) bool {
+	return accounts[value.E] == value
 }
 
 func InvocationHandler(ctx *proxy.Context) {
 	var (
-		context    = ctx.In[0].(*gin.Context)
+		context    = ctx.In[0].(*inter.Context)
 		completion = common.GetGinCompletion(context)
-		proxied    = env.Env.GetString("server.proxied")
+		proxied    = inited.Env().GetString("server.proxied")
 		echo       = context.GetBool(vars.GinEcho)
 	)
 
@@ -66,7 +154,7 @@ func InvocationHandler(ctx *proxy.Context) {
 		return
 	}
 
-	logger.Infof("execute static proxy [relay/llm/coze.api]: func %s(...)", ctx.Method)
+	logger.Ctx(context).Infof("execute static proxy [relay/llm/coze.api]: func %s(...)", ctx.Method)
 
 	var (
 		err  error
@@ -76,31 +164,32 @@ func InvocationHandler(ctx *proxy.Context) {
 	)
 
 	if isSdk(context, completion.Model) {
-		meta, err = cookiesContainer.Poll()
+		meta, err = cookiesContainer.Load().Poll(context)
 		if err != nil {
-			logger.Error(err)
+			logger.Ctx(context).Error(err)
 			response.Error(context, -1, err)
 			return
 		}
 
 		defer resetMarked(meta)
+		context.Set(vars.GinPoolEntry, cookiesContainer.Load().Id(meta))
 		cookies = meta.Cookies
-		logger.Infof("roll now Cookies: %s", cookies)
+		logger.Ctx(context).Infof("roll now account: %s", cookiesContainer.Load().Id(meta))
 
 		completion.Model, err = sdkModel(context, proxied, cookies)
 		if err != nil {
-			logger.Error(err)
+			logger.Ctx(context).Error(err)
 			response.Error(context, -1, err)
 			return
 		}
-		context.Set(vars.GinCompletion, completion)
+		common.SetGinCompletion(context, completion)
 	}
 
 	values := strings.Split(completion.Model[5:], "-")
 	if isOwner(completion.Model) && len(values) > 2 {
 		var scene int
 		if scene, err = strconv.Atoi(values[2]); err != nil {
-			logger.Error(err)
+			logger.Ctx(context).Error(err)
 			response.Error(context, -1, err)
 			return
 		}
@@ -116,7 +205,7 @@ func InvocationHandler(ctx *proxy.Context) {
 		}
 	}
 
-	context.Set("token", cookies)
+	common.SetGinToken(context, cookies)
 
 	ctx.Do()
 
@@ -129,14 +218,14 @@ func InvocationHandler(ctx *proxy.Context) {
 
 	if err != nil {
 		if meta != nil {
-			_ = cookiesContainer.MarkTo(meta, 2)
-			logger.Infof("coze websdk[%s] 进入冷却状态", meta.E)
+			_ = cookiesContainer.Load().MarkTo(meta, 2)
+			logger.Ctx(context).Infof("coze websdk[%s] 进入冷却状态", meta.E)
 		}
 		return
 	}
 }
 
-func isSdk(ctx *gin.Context, model string) bool {
+func isSdk(ctx *inter.Context, model string) bool {
 	if common.IsGinCozeWebsdk(ctx) {
 		return true
 	}
@@ -147,7 +236,7 @@ func isSdk(ctx *gin.Context, model string) bool {
 	return false
 }
 
-func sdkModel(ctx *gin.Context, proxies string, cookie string) (model string, err error) {
+func sdkModel(ctx *inter.Context, proxies string, cookie string) (model string, err error) {
 	options := coze.NewDefaultOptions("xxx", "xxx", 1000, false, proxies)
 	co, msToken := extCookie(cookie)
 	chat := coze.New(co, msToken, options)
@@ -180,24 +269,24 @@ func sdkModel(ctx *gin.Context, proxies string, cookie string) (model string, er
 }
 
 // return true 终止
-func draftBot(ctx *gin.Context, systemMessage string, chat coze.Chat, completion model.Completion) (emitErr *emit.Error) {
-	value, err := chat.BotInfo(ctx.Request.Context())
+func draftBot(ctx *inter.Context, systemMessage string, chat coze.Chat, completion model.Completion) (emitErr *emit.Error) {
+	value, err := chat.BotInfo(ctx)
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return &emit.Error{Code: -1, Err: err}
 	}
 
 	botId := customBotId(completion.Model)
-	if err = chat.DraftBot(ctx.Request.Context(), coze.DraftInfo{
+	if err = chat.DraftBot(ctx, coze.DraftInfo{
 		Model:            value["model"].(string),
 		TopP:             completion.TopP,
-		Temperature:      completion.Temperature,
+		Temperature:      completion.TemperatureOr(0),
 		MaxTokens:        completion.MaxTokens,
 		FrequencyPenalty: 0,
 		PresencePenalty:  0,
 		ResponseFormat:   0,
 	}, systemMessage); err != nil {
-		logger.Error(fmt.Errorf("全局配置修改失败[%s]：%v", botId, err))
+		logger.Ctx(ctx).Error(fmt.Errorf("全局配置修改失败[%s]：%v", botId, err))
 		return &emit.Error{Code: -1, Err: err}
 	}
 	return
@@ -2,6 +2,7 @@ package coze
 
 import (
 	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/common/inited"
 	"chatgpt-adapter/core/logger"
 	"context"
 	"github.com/bincooo/coze-api"
@@ -29,12 +30,30 @@ var (
 )
 
 func appendTask(value *account) {
+	retryTask(value, w_retry)
+}
+
+// 放入任务容器，账号已被重载移除时丢弃
+func retryTask(value *account, count int) {
 	if value == nil {
 		return
 	}
+	accountsMu.Lock()
+	defer accountsMu.Unlock()
+	if !configured(value) {
+		return
+	}
+	w_mu.Lock()
+	defer w_mu.Unlock()
+	taskContainer = append(taskContainer, &obj{value, count})
+}
+
+func copyTasks() []*obj {
 	w_mu.Lock()
 	defer w_mu.Unlock()
-	taskContainer = append(taskContainer, &obj{value, w_retry})
+	container := make([]*obj, len(taskContainer))
+	copy(container, taskContainer)
+	return container
 }
 
 func removeTask(value *obj) {
@@ -55,6 +74,17 @@ func removeTask(value *obj) {
 	}
 }
 
+func removeTaskOf(value *account) {
+	w_mu.Lock()
+	defer w_mu.Unlock()
+	for idx := 0; idx < len(taskContainer); idx++ {
+		if taskContainer[idx].value == value {
+			taskContainer = append(taskContainer[:idx], taskContainer[idx+1:]...)
+			idx--
+		}
+	}
+}
+
 func condition(proxied string) func(value *account, argv ...interface{}) bool {
 	return func(value *account, argv ...interface{}) bool {
 		cookies := value.Cookies
@@ -62,7 +92,7 @@ func condition(proxied string) func(value *account, argv ...interface{}) bool {
 			return false
 		}
 
-		marker, err := cookiesContainer.Marked(value)
+		marker, err := cookiesContainer.Load().Marked(value)
 		if err != nil {
 			logger.Error(err)
 			return false
@@ -88,7 +118,7 @@ func condition(proxied string) func(value *account, argv ...interface{}) bool {
 
 		logger.Infof("coze websdk credits[%s]: %v", value.E, credits)
 		if credits == 0 { // 额度用尽，放入重置任务容器中
-			if err = cookiesContainer.Remove(value); err != nil {
+			if err = cookiesContainer.Load().Remove(value); err != nil {
 				logger.Error(err)
 				return false
 			}
@@ -99,7 +129,7 @@ func condition(proxied string) func(value *account, argv ...interface{}) bool {
 }
 
 func resetMarked(key interface{}) {
-	marker, err := cookiesContainer.Marked(key)
+	marker, err := cookiesContainer.Load().Marked(key)
 	if err != nil {
 		logger.Error(err)
 		return
@@ -109,7 +139,7 @@ func resetMarked(key interface{}) {
 		return
 	}
 
-	err = cookiesContainer.MarkTo(key, 0)
+	err = cookiesContainer.Load().MarkTo(key, 0)
 	if err != nil {
 		logger.Error(err)
 	}
@@ -121,30 +151,29 @@ func run(env *env.Environment, opts ...*account) {
 		objs = append(objs, &obj{opt, w_retry})
 	}
 	go runTasks(env, objs...)
-	go loop(env)
+	go loop()
 }
 
 // 重置任务函数
-func loop(env *env.Environment) {
+func loop() {
 	s5 := 5 * time.Second
-	baseUrl := env.GetString("browser-less.reversal")
+	baseUrl := inited.Env().GetString("browser-less.reversal")
 	if baseUrl == "" {
-		baseUrl = "http://127.0.0.1:" + env.GetString("browser-less.port")
+		baseUrl = "http://127.0.0.1:" + inited.Env().GetString("browser-less.port")
 	}
 
 	for {
 		// 等待初始化完成
-		if w_init || len(taskContainer) == 0 {
+		container := copyTasks()
+		if w_init || len(container) == 0 {
 			time.Sleep(s5)
 			continue
 		}
 
-		container := make([]*obj, len(taskContainer))
-		copy(container, taskContainer)
 		for _, item := range container {
 			cookies := item.value.Cookies
 			if cookies != "" {
-				options := coze.NewDefaultOptions("xxx", "xxx", 1000, false, env.GetString("server.proxied"))
+				options := coze.NewDefaultOptions("xxx", "xxx", 1000, false, inited.Env().GetString("server.proxied"))
 				co, msToken := extCookie(cookies)
 				chat := coze.New(co, msToken, options)
 				chat.Session(common.HTTPClient)
@@ -156,7 +185,7 @@ func loop(env *env.Environment) {
 				if err == nil && credits > 0 {
 					logger.Infof("有剩余额度：%d, 不用重置", credits)
 					removeTask(item)
-					if runTasks(env, item) {
+					if runTasks(inited.Env(), item) {
 						//
 					}
 					continue
@@ -184,7 +213,7 @@ func loop(env *env.Environment) {
 			}
 
 			removeTask(item)
-			if runTasks(env, item) {
+			if runTasks(inited.Env(), item) {
 				//
 			}
 		}
@@ -230,7 +259,7 @@ func runTasks(env *env.Environment, opts ...*obj) (exec bool) {
 		if err != nil {
 			cancel()
 			logger.Errorf("coze websdk 同步失败[%s]：%v", item.value.E, err)
-			taskContainer = append(taskContainer, &obj{item.value, item.count - 1})
+			retryTask(item.value, item.count-1)
 			if response != nil && strings.Contains(response.Header.Get("content-type"), "application/json") {
 				logger.Error(emit.TextResponse(response))
 			}
@@ -241,18 +270,25 @@ func runTasks(env *env.Environment, opts ...*obj) (exec bool) {
 		cancel()
 		if err != nil {
 			logger.Errorf("coze websdk 同步失败[%s]：%v", item.value.E, err)
-			taskContainer = append(taskContainer, &obj{item.value, item.count - 1})
+			retryTask(item.value, item.count-1)
 			continue
 		}
 
 		if v, ok := o["ok"].(bool); !ok || !v {
 			logger.Errorf("coze websdk 同步失败[%s]", item.value.E)
-			taskContainer = append(taskContainer, &obj{item.value, item.count - 1})
+			retryTask(item.value, item.count-1)
 			continue
 		}
 
+		accountsMu.Lock()
+		if !configured(item.value) {
+			accountsMu.Unlock()
+			logger.Infof("coze websdk 账号已移除[%s]", item.value.E)
+			continue
+		}
 		item.value.Cookies = o["data"].(string)
-		cookiesContainer.Add(item.value)
+		cookiesContainer.Load().Add(item.value)
+		accountsMu.Unlock()
 		logger.Infof("coze websdk 同步成功[%s]", item.value.E)
 
 		proxied := env.GetString("server.proxied")
@@ -1,6 +1,7 @@
 package bing
 
 import (
+	"chatgpt-adapter/core/gin/inter"
 	"errors"
 	"net/http"
 	"strings"
@@ -13,7 +14,6 @@ import (
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
 	"github.com/bincooo/emit.io"
-	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/iocgo/sdk/env"
 	"github.com/iocgo/sdk/proxy"
@@ -34,11 +34,20 @@ func init() {
 		cookiesContainer = common.NewPollContainer[string]("grok", cookies, time.Hour)
 		cookiesContainer.Condition = condition
 	})
+
+	inited.AddReloaded(func(env *env.Environment) (func(), error) {
+		cookies := env.GetStringSlice("grok.cookies")
+		return func() {
+			if err := cookiesContainer.Reset(cookies); err != nil {
+				logger.Error(err)
+			}
+		}, nil
+	})
 }
 
 func InvocationHandler(ctx *proxy.Context) {
 	var (
-		gtx  = ctx.In[0].(*gin.Context)
+		gtx  = ctx.In[0].(*inter.Context)
 		echo = gtx.GetBool(vars.GinEcho)
 	)
 
@@ -47,7 +56,7 @@ func InvocationHandler(ctx *proxy.Context) {
 		return
 	}
 
-	logger.Infof("execute static proxy [relay/llm/grok.api]: func %s(...)", ctx.Method)
+	logger.Ctx(gtx).Infof("execute static proxy [relay/llm/grok.api]: func %s(...)", ctx.Method)
 
 	if cookiesContainer.Len() == 0 {
 		response.Error(gtx, -1, "empty cookies")
@@ -56,12 +65,13 @@ func InvocationHandler(ctx *proxy.Context) {
 
 	cookie, err := cookiesContainer.Poll(gtx)
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(gtx).Error(err)
 		response.Error(gtx, -1, err)
 		return
 	}
 	defer resetMarked(cookie)
-	gtx.Set("token", cookie)
+	gtx.Set(vars.GinPoolEntry, cookiesContainer.Id(cookie))
+	common.SetGinToken(gtx, cookie)
 
 	//
 	ctx.Do()
@@ -75,7 +85,7 @@ func InvocationHandler(ctx *proxy.Context) {
 	}
 
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(gtx).Error(err)
 		return
 	}
 }
@@ -92,10 +102,10 @@ func condition(cookie string, argv ...interface{}) (ok bool) {
 		return
 	}
 
-	ctx := argv[0].(*gin.Context)
+	ctx := argv[0].(*inter.Context)
 	completion := common.GetGinCompletion(ctx)
 	r, err := emit.ClientBuilder(common.HTTPClient).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		POST("https://grok.com/rest/rate-limits").
 		//Header("accept-language", "en-US,en;q=0.9").
 		Header("origin", "https://grok.com").
@@ -114,19 +124,19 @@ func condition(cookie string, argv ...interface{}) (ok bool) {
 	if err != nil {
 		var busErr emit.Error
 		if errors.As(err, &busErr) && busErr.Code == 403 {
-			_ = hookCloudflare(env.Env)
+			_ = hookCloudflare(inited.Env())
 			ctx.Set("clearance", clearance)
 			ctx.Set("userAgent", userAgent)
 			ctx.Set("lang", lang)
 		}
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return false
 	}
 
 	defer r.Body.Close()
 	obj, err := emit.ToMap(r)
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return false
 	}
 
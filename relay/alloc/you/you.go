@@ -1,6 +1,7 @@
 package you
 
 import (
+	"chatgpt-adapter/core/gin/inter"
 	"context"
 	"errors"
 	"net/http"
@@ -15,7 +16,6 @@ import (
 	"chatgpt-adapter/core/logger"
 	"github.com/bincooo/emit.io"
 	"github.com/bincooo/you.com"
-	"github.com/gin-gonic/gin"
 	"github.com/iocgo/sdk/env"
 	"github.com/iocgo/sdk/proxy"
 )
@@ -36,21 +36,30 @@ func init() {
 	inited.AddInitialized(func(env *env.Environment) {
 		cookies := env.GetStringSlice("you.cookies")
 		cookiesContainer = common.NewPollContainer[string]("you", cookies, 6*time.Hour)
-		cookiesContainer.Condition = condition(env)
+		cookiesContainer.Condition = condition()
 		if len(cookies) > 0 && env.GetBool("you.task") {
-			go timer(env)
+			go timer()
 		}
 	})
+
+	inited.AddReloaded(func(env *env.Environment) (func(), error) {
+		cookies := env.GetStringSlice("you.cookies")
+		return func() {
+			if err := cookiesContainer.Reset(cookies); err != nil {
+				logger.Error(err)
+			}
+		}, nil
+	})
 }
 
-func timer(env *env.Environment) {
+func timer() {
 	m30 := 30 * time.Minute
 
 	for {
 		time.Sleep(m30)
 		if clearance != "" {
 			timeout, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-			chat := you.New(iCookie, you.GPT_4, env.GetString("server.proxied"))
+			chat := you.New(iCookie, you.GPT_4, inited.Env().GetString("server.proxied"))
 			chat.CloudFlare(clearance, userAgent, lang)
 			chat.Client(common.HTTPClient)
 			_, err := chat.State(timeout)
@@ -75,7 +84,7 @@ func timer(env *env.Environment) {
 		}
 
 		// 尝试过盾
-		if err := hookCloudflare(env); err != nil {
+		if err := hookCloudflare(inited.Env()); err != nil {
 			logger.Errorf("you.com 尝试过盾失败：%v", err)
 			continue
 		}
@@ -86,7 +95,7 @@ func timer(env *env.Environment) {
 
 func InvocationHandler(ctx *proxy.Context) {
 	var (
-		gtx  = ctx.In[0].(*gin.Context)
+		gtx  = ctx.In[0].(*inter.Context)
 		echo = gtx.GetBool(vars.GinEcho)
 	)
 
@@ -95,21 +104,22 @@ func InvocationHandler(ctx *proxy.Context) {
 		return
 	}
 
-	logger.Infof("execute static proxy [relay/llm/you.api]: func %s(...)", ctx.Method)
+	logger.Ctx(gtx).Infof("execute static proxy [relay/llm/you.api]: func %s(...)", ctx.Method)
 
 	if cookiesContainer.Len() == 0 {
 		response.Error(gtx, -1, "empty cookies")
 		return
 	}
 
-	cookies, err := cookiesContainer.Poll()
+	cookies, err := cookiesContainer.Poll(gtx)
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(gtx).Error(err)
 		response.Error(gtx, -1, err)
 		return
 	}
 	defer resetMarked(cookies)
-	gtx.Set("token", cookies)
+	gtx.Set(vars.GinPoolEntry, cookiesContainer.Id(cookies))
+	common.SetGinToken(gtx, cookies)
 	gtx.Set("clearance", clearance)
 	gtx.Set("userAgent", userAgent)
 	gtx.Set("lang", lang)
@@ -126,7 +136,7 @@ func InvocationHandler(ctx *proxy.Context) {
 	}
 
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(gtx).Error(err)
 		var se emit.Error
 		if errors.As(err, &se) && se.Code > 400 {
 			_ = cookiesContainer.MarkTo(cookies, 2)
@@ -143,7 +153,7 @@ func InvocationHandler(ctx *proxy.Context) {
 	}
 }
 
-func condition(env *env.Environment) func(string, ...interface{}) bool {
+func condition() func(string, ...interface{}) bool {
 	return func(cookies string, argv ...interface{}) bool {
 
 		marker, err := cookiesContainer.Marked(cookies)
@@ -157,7 +167,7 @@ func condition(env *env.Environment) func(string, ...interface{}) bool {
 		}
 
 		// return true
-		chat := you.New(cookies, you.CLAUDE_2, env.GetString("server.proxied"))
+		chat := you.New(cookies, you.CLAUDE_2, inited.Env().GetString("server.proxied"))
 		chat.Client(common.HTTPClient)
 		chat.CloudFlare(clearance, userAgent, lang)
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -169,7 +179,7 @@ func condition(env *env.Environment) func(string, ...interface{}) bool {
 			if errors.As(err, &se) {
 				if se.Code == 403 {
 					cleanCloudflare()
-					_ = hookCloudflare(env)
+					_ = hookCloudflare(inited.Env())
 				}
 				if se.Code == 401 { // cookie 失效？？？
 					_ = cookiesContainer.MarkTo(cookies, 2)
@@ -1,6 +1,8 @@
 package hf
 
 import (
+	"chatgpt-adapter/core/common/inited"
+	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/tokenizer"
 	"context"
 	"encoding/json"
@@ -19,7 +21,6 @@ import (
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/logger"
 	"github.com/bincooo/emit.io"
-	"github.com/gin-gonic/gin"
 	"github.com/iocgo/sdk/env"
 )
 
@@ -30,16 +31,22 @@ var (
 
 type api struct {
 	inter.BaseAdapter
+}
 
-	env *env.Environment
+// 仅支持图片生成
+func (*api) Capabilities(string) *model.Capabilities {
+	return &model.Capabilities{
+		Input:  []string{model.ModalityText},
+		Output: []string{model.ModalityImage},
+	}
 }
 
-func (api *api) Match(ctx *gin.Context, model string) (ok bool, err error) {
+func (api *api) Match(ctx *inter.Context, model string) (ok bool, err error) {
 	if model != "dall-e-3" {
 		return
 	}
 
-	token := ctx.GetString("token")
+	token := common.GetGinToken(ctx)
 
 	// prodia 服务关闭了
 	//if token == "sk-prodia-sd" {
@@ -87,7 +94,7 @@ func (api *api) Match(ctx *gin.Context, model string) (ok bool, err error) {
 	return
 }
 
-func (api *api) Generation(ctx *gin.Context) (err error) {
+func (api *api) Generation(ctx *inter.Context) (err error) {
 	var (
 		value        = ""
 		modelSlice   []string
@@ -96,9 +103,9 @@ func (api *api) Generation(ctx *gin.Context) (err error) {
 		generation   = common.GetGinGeneration(ctx)
 	)
 
-	message, err := completeTagsGenerator(ctx, api.env, generation.Message)
+	message, err := completeTagsGenerator(ctx, inited.Env(), generation.Message)
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return
 	}
 
@@ -109,57 +116,57 @@ func (api *api) Generation(ctx *gin.Context) (err error) {
 	mod := matchModel(generation.Style, space)
 	samples := matchSamples(generation.Quality, space)
 
-	logger.Infof("curr space info[%s]: %s, %s", space, mod, samples)
+	logger.Ctx(ctx).Infof("curr space info[%s]: %s, %s", space, mod, samples)
 	switch space {
 	case "prodia-xl":
 		modelSlice = XL_MODELS
 		samplesSlice = XL_SAMPLES
-		value, err = Ox1(ctx, api.env, mod, samples, message)
+		value, err = Ox1(ctx, inited.Env(), mod, samples, message)
 	case "dalle-4k":
 		modelSlice = DALLE4K_MODELS
-		value, err = Ox2(ctx, api.env, mod, message)
+		value, err = Ox2(ctx, inited.Env(), mod, message)
 	case "dalle-3xl":
-		value, err = Ox3(ctx, api.env, message)
+		value, err = Ox3(ctx, inited.Env(), message)
 	case "animagine-xl-3.1":
 		modelSlice = ANIMAGINE_XL31_MODELS
 		samplesSlice = ANIMAGINE_XL31_SAMPLES
-		value, err = Ox4(ctx, api.env, mod, samples, message)
+		value, err = Ox4(ctx, inited.Env(), mod, samples, message)
 	case "animagine-xl-4.0":
 		modelSlice = ANIMAGINE_XL40_MODELS
 		samplesSlice = ANIMAGINE_XL40_SAMPLES
-		value, err = Ox5(ctx, api.env, mod, samples, message)
+		value, err = Ox5(ctx, inited.Env(), mod, samples, message)
 	case "google":
 		modelSlice = GOOGLE_MODELS
-		value, err = google(ctx, api.env, mod, message)
+		value, err = google(ctx, inited.Env(), mod, message)
 	default:
 		modelSlice = SD_MODELS
 		samplesSlice = SD_SAMPLES
-		value, err = Ox0(ctx, api.env, mod, samples, message)
+		value, err = Ox0(ctx, inited.Env(), mod, samples, message)
 	}
 
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return
 	}
 
 	if ctx.GetBool(ginRmbg) {
-		v, e := rmbg(ctx, api.env, value)
+		v, e := rmbg(ctx, inited.Env(), value)
 		if e != nil {
-			logger.Error(e)
+			logger.Ctx(ctx).Error(e)
 		} else {
 			value = v
 		}
 	}
 
 	if !strings.HasPrefix(value, "http") {
-		domain := api.env.GetString("domain")
+		domain := inited.Env().GetString("domain")
 		if domain == "" {
 			domain = fmt.Sprintf("http://127.0.0.1:%d", ctx.GetInt("port"))
 		}
 		value = fmt.Sprintf("%s/file/%s", domain, value[4:])
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
+	response.Forward(ctx, http.StatusOK, map[string]interface{}{
 		"created": time.Now().Unix(),
 		"styles":  modelSlice,
 		"samples": samplesSlice,
@@ -238,7 +245,7 @@ func matchModel(style, spase string) string {
 	}
 }
 
-func completeTagsGenerator(ctx *gin.Context, env *env.Environment, content string) (string, error) {
+func completeTagsGenerator(ctx *inter.Context, env *env.Environment, content string) (string, error) {
 	var (
 		proxied = env.GetString("server.proxied")
 		mod     = env.GetString("llm.model")
@@ -299,7 +306,7 @@ func completeTagsGenerator(ctx *gin.Context, env *env.Environment, content strin
 		"max_tokens":  4096,
 	}
 
-	res, err := fetch(ctx.Request.Context(), proxied, baseUrl, cookie, obj)
+	res, err := fetch(ctx, proxied, baseUrl, cookie, obj)
 	if err != nil {
 		return "", err
 	}
@@ -312,7 +319,7 @@ func completeTagsGenerator(ctx *gin.Context, env *env.Environment, content strin
 
 	var r model.Response
 	if err = json.Unmarshal(data, &r); err != nil {
-		logger.Error("data: %s", data)
+		logger.Ctx(ctx).Error("data: %s", data)
 		return "", err
 	}
 
@@ -331,14 +338,14 @@ func completeTagsGenerator(ctx *gin.Context, env *env.Environment, content strin
 	if left > -1 && left < right {
 		message = strings.ReplaceAll(message[left+3:right], "\"", "")
 		contents = append(contents, message)
-		logger.Infof("system assistant generate message[%s]: %s", mod, strings.Join(contents, ", "))
+		logger.Ctx(ctx).Infof("system assistant generate message[%s]: %s", mod, strings.Join(contents, ", "))
 		return strings.Join(contents, ", "), nil
 	}
 
 	if strings.HasSuffix(message, `"""`) { // 哎。bing 偶尔会漏掉前面的"""
 		message = strings.ReplaceAll(message[:len(message)-3], "\"", "")
 		contents = append(contents, message)
-		logger.Infof("system assistant generate message[%s]: %s", mod, strings.Join(contents, ", "))
+		logger.Ctx(ctx).Infof("system assistant generate message[%s]: %s", mod, strings.Join(contents, ", "))
 		return strings.Join(contents, ", "), nil
 	}
 
@@ -348,12 +355,12 @@ func completeTagsGenerator(ctx *gin.Context, env *env.Environment, content strin
 	if left > -1 && left < right {
 		message = strings.ReplaceAll(message[left+3:right], "\"", "")
 		contents = append(contents, message)
-		logger.Infof("system assistant generate message[%s]: %s", mod, strings.Join(contents, ", "))
+		logger.Ctx(ctx).Infof("system assistant generate message[%s]: %s", mod, strings.Join(contents, ", "))
 		return strings.Join(contents, ", "), nil
 	}
 
-	logger.Info("response content: ", message)
-	logger.Errorf("system assistant generate message[%s] error: system assistant generate message failed", mod)
+	logger.Ctx(ctx).Info("response content: ", message)
+	logger.Ctx(ctx).Errorf("system assistant generate message[%s] error: system assistant generate message failed", mod)
 	return "", errors.New("system assistant generate message failed")
 }
 
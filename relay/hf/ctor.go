@@ -8,4 +8,4 @@ import (
 )
 
 // @Inject(name = "hf-adapter")
-func New(env *env.Environment) inter.Adapter { return &api{env: env} }
+func New(env *env.Environment) inter.Adapter { return &api{} }
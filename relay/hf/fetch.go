@@ -2,6 +2,7 @@ package hf
 
 import (
 	"bytes"
+	"chatgpt-adapter/core/gin/inter"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -18,7 +19,6 @@ import (
 	"chatgpt-adapter/core/logger"
 	"github.com/bincooo/emit.io"
 	"github.com/gabriel-vasile/mimetype"
-	"github.com/gin-gonic/gin"
 	"github.com/iocgo/sdk/env"
 )
 
@@ -27,7 +27,7 @@ const (
 	userAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/125.0.0.0 Safari/537.36 Edg/125.0.0.0"
 )
 
-func Ox0(ctx *gin.Context, env *env.Environment, model, samples, message string) (value string, err error) {
+func Ox0(ctx *inter.Context, env *env.Environment, model, samples, message string) (value string, err error) {
 	var (
 		hash    = emit.GioHash()
 		proxies = env.GetString("server.proxied")
@@ -48,7 +48,7 @@ func Ox0(ctx *gin.Context, env *env.Environment, model, samples, message string)
 	}
 	response, err := emit.ClientBuilder(common.HTTPClient).
 		Proxies(proxies).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		POST(baseUrl+"/queue/join").
 		JSONHeader().
 		Header("User-Agent", userAgent).
@@ -63,12 +63,12 @@ func Ox0(ctx *gin.Context, env *env.Environment, model, samples, message string)
 		return
 	}
 
-	logger.Info(emit.TextResponse(response))
+	logger.Ctx(ctx).Info(emit.TextResponse(response))
 	_ = response.Body.Close()
 
 	response, err = emit.ClientBuilder(common.HTTPClient).
 		Proxies(proxies).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		GET(baseUrl+"/queue/data").
 		Query("session_hash", hash).
 		Header("User-Agent", userAgent).
@@ -78,13 +78,13 @@ func Ox0(ctx *gin.Context, env *env.Environment, model, samples, message string)
 	}
 
 	defer response.Body.Close()
-	c, err := emit.NewGio(ctx.Request.Context(), response)
+	c, err := emit.NewGio(ctx, response)
 	if err != nil {
 		return
 	}
 
 	c.Event("*", func(j emit.JoinEvent) (_ interface{}) {
-		logger.Debugf("event: %s", j.InitialBytes)
+		logger.Ctx(ctx).Debugf("event: %s", j.InitialBytes)
 		return
 	})
 
@@ -106,7 +106,7 @@ func Ox0(ctx *gin.Context, env *env.Environment, model, samples, message string)
 	return
 }
 
-func Ox1(ctx *gin.Context, env *env.Environment, model, samples, message string) (value string, err error) {
+func Ox1(ctx *inter.Context, env *env.Environment, model, samples, message string) (value string, err error) {
 	var (
 		hash    = emit.GioHash()
 		proxied = env.GetString("server.proxied")
@@ -122,13 +122,13 @@ func Ox1(ctx *gin.Context, env *env.Environment, model, samples, message string)
 	}
 
 	defer response.Body.Close()
-	c, err := emit.NewGio(ctx.Request.Context(), conn)
+	c, err := emit.NewGio(ctx, conn)
 	if err != nil {
 		return
 	}
 
 	c.Event("*", func(j emit.JoinEvent) (_ interface{}) {
-		logger.Debugf("event: %s", j.InitialBytes)
+		logger.Ctx(ctx).Debugf("event: %s", j.InitialBytes)
 		return
 	})
 
@@ -179,7 +179,7 @@ func Ox1(ctx *gin.Context, env *env.Environment, model, samples, message string)
 	return
 }
 
-func Ox2(ctx *gin.Context, env *env.Environment, model, message string) (value string, err error) {
+func Ox2(ctx *inter.Context, env *env.Environment, model, message string) (value string, err error) {
 	var (
 		hash    = emit.GioHash()
 		proxied = env.GetString("server.proxied")
@@ -210,7 +210,7 @@ func Ox2(ctx *gin.Context, env *env.Environment, model, message string) (value s
 	}
 	response, err := emit.ClientBuilder(common.HTTPClient).
 		Proxies(proxied).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		POST(baseUrl+"/queue/join").
 		JSONHeader().
 		Body(map[string]interface{}{
@@ -224,11 +224,11 @@ func Ox2(ctx *gin.Context, env *env.Environment, model, message string) (value s
 		return
 	}
 
-	logger.Info(emit.TextResponse(response))
+	logger.Ctx(ctx).Info(emit.TextResponse(response))
 	_ = response.Body.Close()
 	response, err = emit.ClientBuilder(common.HTTPClient).
 		Proxies(proxied).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		GET(baseUrl+"/queue/data").
 		Query("session_hash", hash).
 		DoC(emit.Status(http.StatusOK), emit.IsSTREAM)
@@ -237,13 +237,13 @@ func Ox2(ctx *gin.Context, env *env.Environment, model, message string) (value s
 	}
 
 	defer response.Body.Close()
-	c, err := emit.NewGio(ctx.Request.Context(), response)
+	c, err := emit.NewGio(ctx, response)
 	if err != nil {
 		return
 	}
 
 	c.Event("*", func(j emit.JoinEvent) (_ interface{}) {
-		logger.Debugf("event: %s", j.InitialBytes)
+		logger.Ctx(ctx).Debugf("event: %s", j.InitialBytes)
 		return
 	})
 
@@ -282,7 +282,7 @@ func Ox2(ctx *gin.Context, env *env.Environment, model, message string) (value s
 	return
 }
 
-func Ox3(ctx *gin.Context, env *env.Environment, message string) (value string, err error) {
+func Ox3(ctx *inter.Context, env *env.Environment, message string) (value string, err error) {
 	var (
 		hash    = emit.GioHash()
 		proxied = env.GetString("server.proxied")
@@ -311,7 +311,7 @@ func Ox3(ctx *gin.Context, env *env.Environment, message string) (value string,
 	}
 	response, err := emit.ClientBuilder(common.HTTPClient).
 		Proxies(proxied).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		POST(baseUrl+"/queue/join").
 		Header("Origin", baseUrl).
 		Header("Referer", baseUrl+"/?__theme=light").
@@ -328,12 +328,12 @@ func Ox3(ctx *gin.Context, env *env.Environment, message string) (value string,
 		return "", err
 	}
 
-	logger.Info(emit.TextResponse(response))
+	logger.Ctx(ctx).Info(emit.TextResponse(response))
 	_ = response.Body.Close()
 
 	response, err = emit.ClientBuilder(common.HTTPClient).
 		Proxies(proxied).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		GET(baseUrl+"/queue/data").
 		Query("session_hash", hash).
 		Header("Origin", baseUrl).
@@ -347,13 +347,13 @@ func Ox3(ctx *gin.Context, env *env.Environment, message string) (value string,
 	}
 
 	defer response.Body.Close()
-	c, err := emit.NewGio(ctx.Request.Context(), response)
+	c, err := emit.NewGio(ctx, response)
 	if err != nil {
 		return "", err
 	}
 
 	c.Event("*", func(j emit.JoinEvent) (_ interface{}) {
-		logger.Debugf("event: %s", j.InitialBytes)
+		logger.Ctx(ctx).Debugf("event: %s", j.InitialBytes)
 		return
 	})
 
@@ -410,7 +410,7 @@ func Ox3(ctx *gin.Context, env *env.Environment, message string) (value string,
 }
 
 // 潦草漫画的风格v3
-func Ox4(ctx *gin.Context, env *env.Environment, model, samples, message string) (value string, err error) {
+func Ox4(ctx *inter.Context, env *env.Environment, model, samples, message string) (value string, err error) {
 	var (
 		hash    = emit.GioHash()
 		proxied = env.GetString("server.proxied")
@@ -447,7 +447,7 @@ func Ox4(ctx *gin.Context, env *env.Environment, model, samples, message string)
 	}
 	response, err := emit.ClientBuilder(common.HTTPClient).
 		Proxies(proxied).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		POST(baseUrl+"/queue/join").
 		Header("Origin", baseUrl).
 		Header("Referer", baseUrl+"/?__theme=light").
@@ -463,12 +463,12 @@ func Ox4(ctx *gin.Context, env *env.Environment, model, samples, message string)
 	if err != nil {
 		return "", err
 	}
-	logger.Info(emit.TextResponse(response))
+	logger.Ctx(ctx).Info(emit.TextResponse(response))
 	_ = response.Body.Close()
 
 	response, err = emit.ClientBuilder(common.HTTPClient).
 		Proxies(proxied).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		GET(baseUrl+"/queue/data").
 		Query("session_hash", hash).
 		Header("Origin", baseUrl).
@@ -482,13 +482,13 @@ func Ox4(ctx *gin.Context, env *env.Environment, model, samples, message string)
 	}
 
 	defer response.Body.Close()
-	c, err := emit.NewGio(ctx.Request.Context(), response)
+	c, err := emit.NewGio(ctx, response)
 	if err != nil {
 		return "", err
 	}
 
 	c.Event("*", func(j emit.JoinEvent) (_ interface{}) {
-		logger.Debugf("event: %s", j.InitialBytes)
+		logger.Ctx(ctx).Debugf("event: %s", j.InitialBytes)
 		return
 	})
 
@@ -546,7 +546,7 @@ func Ox4(ctx *gin.Context, env *env.Environment, model, samples, message string)
 }
 
 // 潦草漫画的风格v4
-func Ox5(ctx *gin.Context, env *env.Environment, model, samples, message string) (value string, err error) {
+func Ox5(ctx *inter.Context, env *env.Environment, model, samples, message string) (value string, err error) {
 	var (
 		hash    = emit.GioHash()
 		proxied = env.GetString("server.proxied")
@@ -581,7 +581,7 @@ func Ox5(ctx *gin.Context, env *env.Environment, model, samples, message string)
 	}
 	response, err := emit.ClientBuilder(common.HTTPClient).
 		Proxies(proxied).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		POST(baseUrl+"/queue/join").
 		Header("Origin", baseUrl).
 		Header("Referer", baseUrl+"/?__theme=light").
@@ -597,12 +597,12 @@ func Ox5(ctx *gin.Context, env *env.Environment, model, samples, message string)
 	if err != nil {
 		return "", err
 	}
-	logger.Info(emit.TextResponse(response))
+	logger.Ctx(ctx).Info(emit.TextResponse(response))
 	_ = response.Body.Close()
 
 	response, err = emit.ClientBuilder(common.HTTPClient).
 		Proxies(proxied).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		GET(baseUrl+"/queue/data").
 		Query("session_hash", hash).
 		Header("Origin", baseUrl).
@@ -618,7 +618,7 @@ func Ox5(ctx *gin.Context, env *env.Environment, model, samples, message string)
 
 	response, err = emit.ClientBuilder(common.HTTPClient).
 		Proxies(proxied).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		POST(baseUrl+"/queue/join").
 		Header("Origin", baseUrl).
 		Header("Referer", baseUrl+"/?__theme=light").
@@ -634,12 +634,12 @@ func Ox5(ctx *gin.Context, env *env.Environment, model, samples, message string)
 	if err != nil {
 		return "", err
 	}
-	logger.Info(emit.TextResponse(response))
+	logger.Ctx(ctx).Info(emit.TextResponse(response))
 	_ = response.Body.Close()
 
 	response, err = emit.ClientBuilder(common.HTTPClient).
 		Proxies(proxied).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		GET(baseUrl+"/queue/data").
 		Query("session_hash", hash).
 		Header("Origin", baseUrl).
@@ -653,13 +653,13 @@ func Ox5(ctx *gin.Context, env *env.Environment, model, samples, message string)
 	}
 
 	defer response.Body.Close()
-	c, err := emit.NewGio(ctx.Request.Context(), response)
+	c, err := emit.NewGio(ctx, response)
 	if err != nil {
 		return "", err
 	}
 
 	c.Event("*", func(j emit.JoinEvent) (_ interface{}) {
-		logger.Debugf("event: %s", j.InitialBytes)
+		logger.Ctx(ctx).Debugf("event: %s", j.InitialBytes)
 		return
 	})
 
@@ -716,7 +716,7 @@ func Ox5(ctx *gin.Context, env *env.Environment, model, samples, message string)
 	return
 }
 
-func rmbg(ctx *gin.Context, env *env.Environment, path string) (value string, err error) {
+func rmbg(ctx *inter.Context, env *env.Environment, path string) (value string, err error) {
 	var (
 		hash    = emit.GioHash()
 		proxied = env.GetString("server.proxied")
@@ -757,7 +757,7 @@ label:
 
 	response, err := emit.ClientBuilder(common.HTTPClient).
 		Proxies(proxied).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		POST(baseUrl+"/upload").
 		Query("upload_id", hash).
 		Header("Origin", baseUrl).
@@ -796,7 +796,7 @@ label:
 	}
 	response, err = emit.ClientBuilder(common.HTTPClient).
 		Proxies(proxied).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		POST(baseUrl+"/queue/join").
 		Header("Origin", baseUrl).
 		Header("Referer", baseUrl+"/?__theme=light").
@@ -812,12 +812,12 @@ label:
 	if err != nil {
 		return "", err
 	}
-	logger.Info(emit.TextResponse(response))
+	logger.Ctx(ctx).Info(emit.TextResponse(response))
 	_ = response.Body.Close()
 
 	response, err = emit.ClientBuilder(common.HTTPClient).
 		Proxies(proxied).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		GET(baseUrl+"/queue/data").
 		Query("session_hash", hash).
 		Header("Origin", baseUrl).
@@ -831,13 +831,13 @@ label:
 	}
 
 	defer response.Body.Close()
-	c, err := emit.NewGio(ctx.Request.Context(), response)
+	c, err := emit.NewGio(ctx, response)
 	if err != nil {
 		return "", err
 	}
 
 	c.Event("*", func(j emit.JoinEvent) (_ interface{}) {
-		logger.Debugf("event: %s", j.InitialBytes)
+		logger.Ctx(ctx).Debugf("event: %s", j.InitialBytes)
 		return
 	})
 
@@ -870,7 +870,7 @@ label:
 	return
 }
 
-func google(ctx *gin.Context, env *env.Environment, model, message string) (value string, err error) {
+func google(ctx *inter.Context, env *env.Environment, model, message string) (value string, err error) {
 	var (
 		hash    = emit.GioHash()
 		proxied = env.GetString("server.proxied")
@@ -879,7 +879,7 @@ func google(ctx *gin.Context, env *env.Environment, model, message string) (valu
 
 	conn, response, err := emit.SocketBuilder(common.HTTPClient).
 		Proxies(proxied).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		URL(baseUrl + "/queue/join").
 		DoS(http.StatusSwitchingProtocols)
 	if err != nil {
@@ -887,13 +887,13 @@ func google(ctx *gin.Context, env *env.Environment, model, message string) (valu
 	}
 	defer response.Body.Close()
 
-	c, err := emit.NewGio(ctx.Request.Context(), conn)
+	c, err := emit.NewGio(ctx, conn)
 	if err != nil {
 		return
 	}
 
 	c.Event("*", func(j emit.JoinEvent) (_ interface{}) {
-		logger.Debugf("event: %s", j.InitialBytes)
+		logger.Ctx(ctx).Debugf("event: %s", j.InitialBytes)
 		return
 	})
 
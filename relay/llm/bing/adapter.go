@@ -1,6 +1,7 @@
 package bing
 
 import (
+	"chatgpt-adapter/core/common/inited"
 	"context"
 	"encoding/base64"
 	"errors"
@@ -12,13 +13,12 @@ import (
 
 	"chatgpt-adapter/core/cache"
 	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/common/vars"
 	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"github.com/bincooo/edge-api"
 	"github.com/bincooo/emit.io"
-	"github.com/gin-gonic/gin"
-	"github.com/iocgo/sdk/env"
 	"github.com/iocgo/sdk/stream"
 )
 
@@ -29,15 +29,13 @@ var (
 
 type api struct {
 	inter.BaseAdapter
-
-	env *env.Environment
 }
 
-func (api *api) Match(ctx *gin.Context, model string) (ok bool, err error) {
-	var token = ctx.GetString("token")
+func (api *api) Match(ctx *inter.Context, model string) (ok bool, err error) {
+	var token = common.GetGinToken(ctx)
 	ok = Model == model || model == Model+"-reason"
 	if ok {
-		password := api.env.GetString("server.password")
+		password := inited.Env().GetString("server.password")
 		if password != "" && password != token {
 			err = response.UnauthorizedError
 			return
@@ -62,7 +60,16 @@ func (*api) Models() (slice []model.Model) {
 	return
 }
 
-func (api *api) ToolChoice(ctx *gin.Context) (ok bool, err error) {
+func (*api) Capabilities(mod string) *model.Capabilities {
+	return &model.Capabilities{
+		Input:     []string{model.ModalityText, model.ModalityImage},
+		Output:    []string{model.ModalityText},
+		Tools:     model.ToolsEmulated,
+		Reasoning: mod == Model+"-reason",
+	}
+}
+
+func (api *api) ToolChoice(ctx *inter.Context) (ok bool, err error) {
 	var (
 		completion = common.GetGinCompletion(ctx)
 	)
@@ -73,24 +80,24 @@ func (api *api) ToolChoice(ctx *gin.Context) (ok bool, err error) {
 	return
 }
 
-func (api *api) Completion(ctx *gin.Context) (err error) {
+func (api *api) Completion(ctx *inter.Context) (err error) {
 	var (
-		cookie, _  = common.GetGinValue[map[string]string](ctx, "token")
+		cookie, _  = common.GetGinValue[map[string]string](ctx, vars.GinToken)
 		completion = common.GetGinCompletion(ctx)
-		proxied    = api.env.GetBool("bing.proxied")
+		proxied    = inited.Env().GetBool("bing.proxied")
 	)
 
 	content, query, attr := convertRequest(ctx, completion)
 	newTok := false
 refresh:
-	timeout, cancel := context.WithTimeout(ctx.Request.Context(), 10*time.Second)
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 	accessToken, err := genToken(timeout, cookie, proxied, newTok)
 	if err != nil {
 		return
 	}
 
-	timeout, cancel = context.WithTimeout(ctx.Request.Context(), 10*time.Second)
+	timeout, cancel = context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 	conversationId, err := edge.CreateConversation(elseOf(proxied, common.HTTPClient, common.NopHTTPClient), timeout, accessToken)
 	if err != nil {
@@ -115,7 +122,7 @@ refresh:
 
 	challenge := ""
 label:
-	message, err := edge.Chat(elseOf(proxied, common.HTTPClient, common.NopHTTPClient), ctx.Request.Context(),
+	message, err := edge.Chat(elseOf(proxied, common.HTTPClient, common.NopHTTPClient), ctx,
 		accessToken,
 		conversationId,
 		challenge,
@@ -139,7 +146,7 @@ label:
 	return
 }
 
-func extAttr(ctx *gin.Context, proxied bool, attr, accessToken string) (ret string, err error) {
+func extAttr(ctx *inter.Context, proxied bool, attr, accessToken string) (ret string, err error) {
 	var buffer []byte
 	if strings.HasPrefix(attr, "http") {
 		buffer, err = common.DownloadBuffer(common.HTTPClient, "", attr, nil)
@@ -156,11 +163,11 @@ func extAttr(ctx *gin.Context, proxied bool, attr, accessToken string) (ret stri
 		return
 	}
 
-	ret, err = edge.Attachments(elseOf(proxied, common.HTTPClient, common.NopHTTPClient), ctx.Request.Context(), buffer, accessToken)
+	ret, err = edge.Attachments(elseOf(proxied, common.HTTPClient, common.NopHTTPClient), ctx, buffer, accessToken)
 	return
 }
 
-func convertRequest(ctx *gin.Context, completion model.Completion) (content, query, attr string) {
+func convertRequest(ctx *inter.Context, completion model.Completion) (content, query, attr string) {
 	countMax := 10240
 	count := 0
 	pos := 0
@@ -1,25 +1,20 @@
 package bing
 
 import (
+	"chatgpt-adapter/core/common/inited"
+	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/bincooo/emit.io"
-	"github.com/iocgo/sdk/env"
 	"net/http"
-	"sync"
 	"time"
 
 	"chatgpt-adapter/core/common"
 	"chatgpt-adapter/core/common/vars"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
-	"github.com/gin-gonic/gin"
-)
-
-const (
-	ginTokens = "__tokens__"
 )
 
 func waitMessage(message chan []byte, cancel func(str string) bool) (content string, err error) {
@@ -58,15 +53,10 @@ func waitMessage(message chan []byte, cancel func(str string) bool) (content str
 	return
 }
 
-func waitResponse(ctx *gin.Context, message chan []byte, sse bool) (content string) {
+func waitResponse(ctx *inter.Context, message chan []byte, sse bool) (content string) {
 	created := time.Now().Unix()
-	logger.Infof("waitResponse ...")
-	tokens := ctx.GetInt(ginTokens)
-	onceExec := sync.OnceFunc(func() {
-		if !sse {
-			ctx.Writer.WriteHeader(http.StatusOK)
-		}
-	})
+	logger.Ctx(ctx).Infof("waitResponse ...")
+	tokens := common.GetGinTokens(ctx)
 
 	var (
 		matchers = common.GetGinMatchers(ctx)
@@ -94,7 +84,7 @@ func waitResponse(ctx *gin.Context, message chan []byte, sse bool) (content stri
 		var msg model.Keyv[interface{}]
 		err := json.Unmarshal(chunk, &msg)
 		if err != nil {
-			logger.Error(err)
+			logger.Ctx(ctx).Error(err)
 			continue
 		}
 
@@ -112,9 +102,8 @@ func waitResponse(ctx *gin.Context, message chan []byte, sse bool) (content stri
 			continue
 		}
 
-		logger.Debug("----- raw -----")
-		logger.Debug(raw)
-		onceExec()
+		logger.Ctx(ctx).Debug("----- raw -----")
+		logger.Ctx(ctx).Debug(raw)
 
 		raw = response.ExecMatchers(matchers, raw, false)
 		if len(raw) == 0 {
@@ -143,11 +132,11 @@ func waitResponse(ctx *gin.Context, message chan []byte, sse bool) (content stri
 	return
 }
 
-func asError(ctx *gin.Context, msg interface{}) {
+func asError(ctx *inter.Context, msg interface{}) {
 	if msg == nil || msg == "" {
 		return
 	}
-	logger.Error(msg)
+	logger.Ctx(ctx).Error(msg)
 	if response.NotSSEHeader(ctx) {
 		response.Error(ctx, -1, msg)
 	}
@@ -155,14 +144,14 @@ func asError(ctx *gin.Context, msg interface{}) {
 }
 
 func hookCloudflare() (challenge string, err error) {
-	baseUrl := env.Env.GetString("browser-less.reversal")
-	if !env.Env.GetBool("browser-less.enabled") && baseUrl == "" {
+	baseUrl := inited.Env().GetString("browser-less.reversal")
+	if !inited.Env().GetBool("browser-less.enabled") && baseUrl == "" {
 		return "", errors.New("trying cloudflare failed, please setting `browser-less.enabled` or `browser-less.reversal`")
 	}
 
 	logger.Info("trying cloudflare ...")
 	if baseUrl == "" {
-		baseUrl = "http://127.0.0.1:" + env.Env.GetString("browser-less.port")
+		baseUrl = "http://127.0.0.1:" + inited.Env().GetString("browser-less.port")
 	}
 
 	r, err := emit.ClientBuilder(common.HTTPClient).
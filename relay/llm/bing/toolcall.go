@@ -2,8 +2,10 @@ package bing
 
 import (
 	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/common/inited"
 	"chatgpt-adapter/core/common/toolcall"
 	"chatgpt-adapter/core/common/vars"
+	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
@@ -11,34 +13,32 @@ import (
 	"errors"
 	"github.com/bincooo/edge-api"
 	"github.com/bincooo/emit.io"
-	"github.com/gin-gonic/gin"
-	"github.com/iocgo/sdk/env"
 	"time"
 )
 
-func toolChoice(ctx *gin.Context, completion model.Completion) bool {
-	logger.Info("completeTools ...")
+func toolChoice(ctx *inter.Context, completion model.Completion) bool {
+	logger.Ctx(ctx).Info("completeTools ...")
 	echo := ctx.GetBool(vars.GinEcho)
-	cookie, _ := common.GetGinValue[map[string]string](ctx, "token")
-	proxied := env.Env.GetBool("bing.proxied")
+	cookie, _ := common.GetGinValue[map[string]string](ctx, vars.GinToken)
+	proxied := inited.Env().GetBool("bing.proxied")
 
 	exec, err := toolcall.ToolChoice(ctx, completion, func(message string) (string, error) {
 		message += "\n\nAi:"
 		if echo {
-			logger.Infof("toolCall message: \n%s", message)
+			logger.Ctx(ctx).Infof("toolCall message: \n%s", message)
 			return "", nil
 		}
 
 		newTok := false
 	refresh:
-		timeout, cancel := context.WithTimeout(ctx.Request.Context(), 10*time.Second)
+		timeout, cancel := context.WithTimeout(ctx, 10*time.Second)
 		defer cancel()
 		accessToken, err := genToken(timeout, cookie, proxied, newTok)
 		if err != nil {
 			return "", err
 		}
 
-		timeout, cancel = context.WithTimeout(ctx.Request.Context(), 10*time.Second)
+		timeout, cancel = context.WithTimeout(ctx, 10*time.Second)
 		defer cancel()
 		conversationId, err := edge.CreateConversation(elseOf(proxied, common.HTTPClient, common.NopHTTPClient), timeout, accessToken)
 		if err != nil {
@@ -57,7 +57,7 @@ func toolChoice(ctx *gin.Context, completion model.Completion) bool {
 		challenge := ""
 	label:
 		buffer, err := edge.Chat(elseOf(proxied, common.HTTPClient, common.NopHTTPClient),
-			ctx.Request.Context(),
+			ctx,
 			accessToken,
 			conversationId,
 			challenge, "", message, "",
@@ -73,11 +73,11 @@ func toolChoice(ctx *gin.Context, completion model.Completion) bool {
 			return "", err
 		}
 
-		return waitMessage(buffer, toolcall.Cancel)
+		return waitMessage(buffer, toolcall.CancelOf(ctx))
 	})
 
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		response.Error(ctx, -1, err)
 		return true
 	}
@@ -9,5 +9,5 @@ import (
 
 // @Inject(name = "blackbox-adapter")
 func New(env *env.Environment) inter.Adapter {
-	return &api{env: env}
+	return &api{}
 }
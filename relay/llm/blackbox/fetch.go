@@ -2,11 +2,11 @@ package blackbox
 
 import (
 	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"context"
 	"github.com/bincooo/emit.io"
-	"github.com/gin-gonic/gin"
 	"github.com/iocgo/sdk/env"
 	"net/http"
 )
@@ -59,7 +59,7 @@ func fetch(ctx context.Context, proxied, cookie string, request blackboxRequest)
 	return
 }
 
-func convertRequest(ctx *gin.Context, env *env.Environment, completion model.Completion) (request blackboxRequest) {
+func convertRequest(ctx *inter.Context, env *env.Environment, completion model.Completion) (request blackboxRequest) {
 	request.Messages = completion.Messages
 	specialized := ctx.GetBool("specialized")
 	if specialized && response.IsClaude(ctx, completion.Model) {
@@ -77,7 +77,7 @@ func convertRequest(ctx *gin.Context, env *env.Environment, completion model.Com
 	request.CodeModelMode = true
 	request.MaxTokens = completion.MaxTokens
 	request.PlaygroundTopP = completion.TopP
-	request.PlaygroundTemperature = completion.Temperature
+	request.PlaygroundTemperature = completion.TemperatureOr(0)
 	request.UserSelectedModel = completion.Model[9:]
 	request.Validated = env.GetString("blackbox.token")
 	request.AgentMode = struct{}{}
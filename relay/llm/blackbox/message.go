@@ -2,20 +2,15 @@ package blackbox
 
 import (
 	"bufio"
+	"chatgpt-adapter/core/gin/inter"
 	"io"
 	"net/http"
-	"sync"
 	"time"
 
 	"chatgpt-adapter/core/common"
 	"chatgpt-adapter/core/common/vars"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
-	"github.com/gin-gonic/gin"
-)
-
-const (
-	ginTokens = "__tokens__"
 )
 
 func waitMessage(r *http.Response, cancel func(str string) bool) (content string, err error) {
@@ -43,15 +38,10 @@ func waitMessage(r *http.Response, cancel func(str string) bool) (content string
 	return
 }
 
-func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string) {
+func waitResponse(ctx *inter.Context, r *http.Response, sse bool) (content string) {
 	created := time.Now().Unix()
-	logger.Infof("waitResponse ...")
-	tokens := ctx.GetInt(ginTokens)
-	onceExec := sync.OnceFunc(func() {
-		if !sse {
-			ctx.Writer.WriteHeader(http.StatusOK)
-		}
-	})
+	logger.Ctx(ctx).Infof("waitResponse ...")
+	tokens := common.GetGinTokens(ctx)
 
 	var (
 		matchers = common.GetGinMatchers(ctx)
@@ -75,9 +65,8 @@ func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string)
 		}
 
 		raw := string(char)
-		logger.Debug("----- raw -----")
-		logger.Debug(raw)
-		onceExec()
+		logger.Ctx(ctx).Debug("----- raw -----")
+		logger.Ctx(ctx).Debug(raw)
 
 		raw = response.ExecMatchers(matchers, raw, false)
 		if len(raw) == 0 {
@@ -106,12 +95,12 @@ func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string)
 	return
 }
 
-func asError(ctx *gin.Context, err error) (ok bool) {
+func asError(ctx *inter.Context, err error) (ok bool) {
 	if err == nil {
 		return
 	}
 
-	logger.Error(err)
+	logger.Ctx(ctx).Error(err)
 	if response.NotSSEHeader(ctx) {
 		response.Error(ctx, -1, err)
 	}
@@ -1,6 +1,7 @@
 package coze
 
 import (
+	"chatgpt-adapter/core/common/inited"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -13,8 +14,6 @@ import (
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
 	"github.com/bincooo/coze-api"
-	"github.com/gin-gonic/gin"
-	"github.com/iocgo/sdk/env"
 	"github.com/iocgo/sdk/stream"
 )
 
@@ -24,19 +23,17 @@ var (
 
 type api struct {
 	inter.BaseAdapter
-
-	env *env.Environment
 }
 
-func (api *api) Match(ctx *gin.Context, model string) (ok bool, err error) {
+func (api *api) Match(ctx *inter.Context, model string) (ok bool, err error) {
 	if Model == model {
 		ok = true
 		return
 	}
 
-	var token = ctx.GetString("token")
+	var token = common.GetGinToken(ctx)
 	if model == "coze/websdk" {
-		password := api.env.GetString("server.password")
+		password := inited.Env().GetString("server.password")
 		if password != "" && password != token {
 			err = response.UnauthorizedError
 			return
@@ -50,7 +47,7 @@ func (api *api) Match(ctx *gin.Context, model string) (ok bool, err error) {
 		values := strings.Split(model[5:], "-")
 		if len(values) > 2 {
 			_, err = strconv.Atoi(values[2])
-			logger.Warn(err)
+			logger.Ctx(ctx).Warn(err)
 			ok = err == nil
 			return
 		}
@@ -83,10 +80,20 @@ func (*api) Models() []model.Model {
 	}
 }
 
-func (api *api) ToolChoice(ctx *gin.Context) (ok bool, err error) {
+// 未知的模型不限制上下文与输出
+func (*api) Capabilities(mod string) *model.Capabilities {
+	caps := &model.Capabilities{
+		Output: []string{model.ModalityText},
+		Tools:  model.ToolsEmulated,
+	}
+	caps.ContextWindow, caps.MaxOutput = model.LimitsOf(mod)
+	return caps
+}
+
+func (api *api) ToolChoice(ctx *inter.Context) (ok bool, err error) {
 	var (
-		cookie     = ctx.GetString("token")
-		proxied    = api.env.GetString("server.proxied")
+		cookie     = common.GetGinToken(ctx)
+		proxied    = inited.Env().GetString("server.proxied")
 		completion = common.GetGinCompletion(ctx)
 	)
 
@@ -96,16 +103,16 @@ func (api *api) ToolChoice(ctx *gin.Context) (ok bool, err error) {
 	return
 }
 
-func (api *api) Completion(ctx *gin.Context) (err error) {
+func (api *api) Completion(ctx *inter.Context) (err error) {
 	var (
-		cookie     = ctx.GetString("token")
-		proxied    = api.env.GetString("server.proxied")
+		cookie     = common.GetGinToken(ctx)
+		proxied    = inited.Env().GetString("server.proxied")
 		completion = common.GetGinCompletion(ctx)
 	)
 
 	newMessages, err := mergeMessages(ctx)
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		response.Error(ctx, -1, err)
 		err = nil
 		return
@@ -130,9 +137,9 @@ func (api *api) Completion(ctx *gin.Context) (err error) {
 			stream.OfSlice(newMessages), func(t coze.Message) string { return t.Content }).ToSlice(), "\n\n")
 	}
 
-	chatResponse, err := chat.Reply(ctx.Request.Context(), coze.Text, query)
+	chatResponse, err := chat.Reply(ctx, coze.Text, query)
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return
 	}
 
@@ -143,10 +150,10 @@ func (api *api) Completion(ctx *gin.Context) (err error) {
 	return
 }
 
-func (api *api) Generation(ctx *gin.Context) (err error) {
+func (api *api) Generation(ctx *inter.Context) (err error) {
 	var (
-		cookie     = ctx.GetString("token")
-		proxied    = api.env.GetString("server.proxied")
+		cookie     = common.GetGinToken(ctx)
+		proxied    = inited.Env().GetString("server.proxied")
 		generation = common.GetGinGeneration(ctx)
 	)
 
@@ -159,12 +166,12 @@ func (api *api) Generation(ctx *gin.Context) (err error) {
 	chat := coze.New(co, msToken, options)
 	chat.Session(common.HTTPClient)
 
-	image, err := chat.Images(ctx.Request.Context(), generation.Message)
+	image, err := chat.Images(ctx, generation.Message)
 	if err != nil {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
+	response.Forward(ctx, http.StatusOK, map[string]interface{}{
 		"created": time.Now().Unix(),
 		"styles:": make([]string, 0),
 		"data": []map[string]string{
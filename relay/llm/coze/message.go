@@ -1,10 +1,9 @@
 package coze
 
 import (
+	"chatgpt-adapter/core/gin/inter"
 	"errors"
-	"net/http"
 	"strings"
-	"sync"
 	"time"
 
 	"chatgpt-adapter/core/common"
@@ -12,11 +11,6 @@ import (
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
 	"github.com/bincooo/coze-api"
-	"github.com/gin-gonic/gin"
-)
-
-const (
-	ginTokens = "__tokens__"
 )
 
 func waitMessage(chatResponse chan string, cancel func(str string) bool) (content string, err error) {
@@ -45,15 +39,10 @@ func waitMessage(chatResponse chan string, cancel func(str string) bool) (conten
 	return content, nil
 }
 
-func waitResponse(ctx *gin.Context, chatResponse chan string, sse bool) (content string) {
+func waitResponse(ctx *inter.Context, chatResponse chan string, sse bool) (content string) {
 	created := time.Now().Unix()
-	logger.Infof("waitResponse ...")
-	tokens := ctx.GetInt(ginTokens)
-	onceExec := sync.OnceFunc(func() {
-		if !sse {
-			ctx.Writer.WriteHeader(http.StatusOK)
-		}
-	})
+	logger.Ctx(ctx).Infof("waitResponse ...")
+	tokens := common.GetGinTokens(ctx)
 
 	var (
 		matchers = common.GetGinMatchers(ctx)
@@ -72,7 +61,7 @@ func waitResponse(ctx *gin.Context, chatResponse chan string, sse bool) (content
 
 		if strings.HasPrefix(raw, "error: ") {
 			err := strings.TrimPrefix(raw, "error: ")
-			logger.Error(err)
+			logger.Ctx(ctx).Error(err)
 			if response.NotSSEHeader(ctx) {
 				response.Error(ctx, -1, err)
 			}
@@ -85,9 +74,8 @@ func waitResponse(ctx *gin.Context, chatResponse chan string, sse bool) (content
 			continue
 		}
 
-		logger.Debug("----- raw -----")
-		logger.Debug(raw)
-		onceExec()
+		logger.Ctx(ctx).Debug("----- raw -----")
+		logger.Ctx(ctx).Debug(raw)
 
 		raw = response.ExecMatchers(matchers, raw, false)
 		if len(raw) == 0 {
@@ -117,7 +105,7 @@ func waitResponse(ctx *gin.Context, chatResponse chan string, sse bool) (content
 	return
 }
 
-func mergeMessages(ctx *gin.Context) (newMessages []coze.Message, err error) {
+func mergeMessages(ctx *inter.Context) (newMessages []coze.Message, err error) {
 	var (
 		completion = common.GetGinCompletion(ctx)
 		messages   = completion.Messages
@@ -125,7 +113,7 @@ func mergeMessages(ctx *gin.Context) (newMessages []coze.Message, err error) {
 	)
 
 	tokens := 0
-	defer func() { ctx.Set(ginTokens, tokens) }()
+	defer func() { common.SetGinTokens(ctx, tokens) }()
 
 	messageL := len(messages)
 	if isC && messageL == 1 {
@@ -1,6 +1,7 @@
 package coze
 
 import (
+	"chatgpt-adapter/core/gin/inter"
 	"net/http"
 	"strings"
 
@@ -10,11 +11,10 @@ import (
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
 	"github.com/bincooo/coze-api"
-	"github.com/gin-gonic/gin"
 )
 
-func toolChoice(ctx *gin.Context, cookie, proxies string, completion model.Completion) bool {
-	logger.Info("completeTools ...")
+func toolChoice(ctx *inter.Context, cookie, proxies string, completion model.Completion) bool {
+	logger.Ctx(ctx).Info("completeTools ...")
 	exec, err := toolcall.ToolChoice(ctx, completion, func(message string) (string, error) {
 		message = strings.TrimSpace(message)
 		system := ""
@@ -54,23 +54,23 @@ func toolChoice(ctx *gin.Context, cookie, proxies string, completion model.Compl
 			query = coze.MergeMessages(pMessages)
 		}
 
-		chatResponse, err := chat.Reply(ctx.Request.Context(), coze.Text, query)
+		chatResponse, err := chat.Reply(ctx, coze.Text, query)
 		if err != nil {
 			return "", err
 		}
 
-		return waitMessage(chatResponse, toolcall.Cancel)
+		return waitMessage(chatResponse, toolcall.CancelOf(ctx))
 	})
 
 	if err != nil {
 		errMessage := err.Error()
 		if strings.Contains(errMessage, "Login verification is invalid") {
-			logger.Error(err)
+			logger.Ctx(ctx).Error(err)
 			response.Error(ctx, http.StatusUnauthorized, errMessage)
 			return true
 		}
 
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		response.Error(ctx, -1, errMessage)
 		return true
 	}
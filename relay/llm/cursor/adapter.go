@@ -2,13 +2,13 @@ package cursor
 
 import (
 	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/common/inited"
 	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
-	"github.com/gin-gonic/gin"
-	"github.com/iocgo/sdk/env"
 	"net/url"
+	"strconv"
 	"strings"
 )
 
@@ -18,15 +18,13 @@ var (
 
 type api struct {
 	inter.BaseAdapter
-
-	env *env.Environment
 }
 
-func (api *api) Match(ctx *gin.Context, model string) (ok bool, err error) {
+func (api *api) Match(ctx *inter.Context, model string) (ok bool, err error) {
 	if len(model) <= 7 || Model+"/" != model[:7] {
 		return
 	}
-	slice := api.env.GetStringSlice("cursor.model")
+	slice := inited.Env().GetStringSlice("cursor.model")
 	for _, mod := range append(slice, []string{
 		"claude-3.5-sonnet",
 		"gpt-4.1",
@@ -66,7 +64,7 @@ func (api *api) Match(ctx *gin.Context, model string) (ok bool, err error) {
 }
 
 func (api *api) Models() (slice []model.Model) {
-	for _, mod := range append(api.env.GetStringSlice("cursor.model"), []string{
+	for _, mod := range append(inited.Env().GetStringSlice("cursor.model"), []string{
 		"claude-3.5-sonnet",
 		"gpt-4",
 		"gpt-4o",
@@ -106,21 +104,36 @@ func (api *api) Models() (slice []model.Model) {
 	return
 }
 
-func (api *api) ToolChoice(ctx *gin.Context) (ok bool, err error) {
+// 名称带 `-200k` 等后缀的模型以后缀为上下文窗口
+func (*api) Capabilities(mod string) *model.Capabilities {
+	caps := &model.Capabilities{
+		Output: []string{model.ModalityText},
+		Tools:  model.ToolsEmulated,
+	}
+	caps.ContextWindow, caps.MaxOutput = model.LimitsOf(mod)
+	if i := strings.LastIndex(mod, "-"); i >= 0 && strings.HasSuffix(mod, "k") {
+		if k, err := strconv.Atoi(mod[i+1 : len(mod)-1]); err == nil {
+			caps.ContextWindow = k * 1000
+		}
+	}
+	return caps
+}
+
+func (api *api) ToolChoice(ctx *inter.Context) (ok bool, err error) {
 	var (
-		cookie     = ctx.GetString("token")
+		cookie     = common.GetGinToken(ctx)
 		completion = common.GetGinCompletion(ctx)
 	)
 
-	if toolChoice(ctx, api.env, cookie, completion) {
+	if toolChoice(ctx, inited.Env(), cookie, completion) {
 		ok = true
 	}
 	return
 }
 
-func (api *api) Completion(ctx *gin.Context) (err error) {
+func (api *api) Completion(ctx *inter.Context) (err error) {
 	var (
-		cookie     = ctx.GetString("token")
+		cookie     = common.GetGinToken(ctx)
 		completion = common.GetGinCompletion(ctx)
 	)
 
@@ -138,9 +151,9 @@ func (api *api) Completion(ctx *gin.Context) (err error) {
 		return
 	}
 
-	r, err := fetch(ctx, api.env, cookie, buffer)
+	r, err := fetch(ctx, inited.Env(), cookie, buffer)
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return
 	}
 
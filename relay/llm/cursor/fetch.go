@@ -3,6 +3,7 @@ package cursor
 import (
 	"chatgpt-adapter/core/cache"
 	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/logger"
 	"crypto/sha256"
@@ -11,7 +12,6 @@ import (
 	"encoding/hex"
 	"fmt"
 	"github.com/bincooo/emit.io"
-	"github.com/gin-gonic/gin"
 	"github.com/golang/protobuf/proto"
 	"github.com/google/uuid"
 	"github.com/iocgo/sdk/env"
@@ -30,7 +30,7 @@ var (
 	Version = "0.50.5"
 )
 
-func fetch(ctx *gin.Context, env *env.Environment, cookie string, buffer []byte) (response *http.Response, err error) {
+func fetch(ctx *inter.Context, env *env.Environment, cookie string, buffer []byte) (response *http.Response, err error) {
 	//count, err := checkUsage(ctx, env, 150)
 	//if err != nil {
 	//	return
@@ -55,7 +55,7 @@ func fetch(ctx *gin.Context, env *env.Environment, cookie string, buffer []byte)
 	sessionId := uuid.NewString()
 	configVersion := uuid.NewString()
 	response, err = emit.ClientBuilder(common.HTTPClient).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		Proxies(env.GetString("server.proxied")).
 		POST("https://api2.cursor.sh/aiserver.v1.BidiService/BidiAppend").
 		Header("authorization", "Bearer "+cookie).
@@ -66,7 +66,7 @@ func fetch(ctx *gin.Context, env *env.Environment, cookie string, buffer []byte)
 		Header("traceparent", "00-"+strings.ReplaceAll(uuid.NewString(), "-", "")+"-"+common.Hex(16)+"-00").
 		Header("user-agent", "connect-es/1.6.1").
 		Header("x-amzn-trace-id", "Root="+uuid.NewString()).
-		Header("x-client-key", genClientKey(ctx.GetString("token"))).
+		Header("x-client-key", genClientKey(common.GetGinToken(ctx))).
 		Header("x-cursor-checksum", genChecksum(ctx, env)).
 		Header("x-cursor-client-version", Version).
 		Header("x-cursor-config-version", configVersion).
@@ -96,7 +96,7 @@ func fetch(ctx *gin.Context, env *env.Environment, cookie string, buffer []byte)
 	buffer = append(header, buffer...)
 
 	response, err = emit.ClientBuilder(common.HTTPClient).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		Proxies(env.GetString("server.proxied")).
 		POST("https://api2.cursor.sh/aiserver.v1.ChatService/StreamUnifiedChatWithToolsSSE").
 		Header("authorization", "Bearer "+cookie).
@@ -107,7 +107,7 @@ func fetch(ctx *gin.Context, env *env.Environment, cookie string, buffer []byte)
 		Header("traceparent", "00-"+strings.ReplaceAll(uuid.NewString(), "-", "")+"-"+common.Hex(16)+"-00").
 		Header("user-agent", "connect-es/1.6.1").
 		Header("x-amzn-trace-id", "Root="+uuid.NewString()).
-		Header("x-client-key", genClientKey(ctx.GetString("token"))).
+		Header("x-client-key", genClientKey(common.GetGinToken(ctx))).
 		Header("x-cursor-checksum", genChecksum(ctx, env)).
 		Header("x-cursor-client-version", Version).
 		Header("x-cursor-config-version", configVersion).
@@ -207,9 +207,9 @@ func newMessage() (msg ChatMessage, err error) {
 	return
 }
 
-func checkUsage(ctx *gin.Context, env *env.Environment, max int) (count int, err error) {
+func checkUsage(ctx *inter.Context, env *env.Environment, max int) (count int, err error) {
 	var (
-		cookie = ctx.GetString("token")
+		cookie = common.GetGinToken(ctx)
 	)
 	cookie, err = url.QueryUnescape(cookie)
 	if err != nil {
@@ -221,7 +221,7 @@ func checkUsage(ctx *gin.Context, env *env.Environment, max int) (count int, err
 		user = strings.Split(cookie, "::")[0]
 	}
 	response, err := emit.ClientBuilder(common.HTTPClient).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		Proxies(env.GetString("server.proxied")).
 		GET("https://www.cursor.com/api/usage").
 		Query("user", user).
@@ -241,7 +241,7 @@ func checkUsage(ctx *gin.Context, env *env.Environment, max int) (count int, err
 	if som, ok := obj["startOfMonth"]; ok {
 		t, e := time.Parse("2006-01-02T15:04:05.000Z", som.(string))
 		if e != nil {
-			logger.Error(e)
+			logger.Ctx(ctx).Error(e)
 		} else {
 			if t.Before(time.Now().Add(-(14 * 24 * time.Hour))) { // 超14天
 				return
@@ -271,8 +271,8 @@ func genClientKey(token string) string {
 	return hex.EncodeToString(hex1[:])
 }
 
-func genChecksum(ctx *gin.Context, env *env.Environment) string {
-	token := ctx.GetString("token")
+func genChecksum(ctx *inter.Context, env *env.Environment) string {
+	token := common.GetGinToken(ctx)
 	checksum := ctx.GetHeader("x-cursor-checksum")
 
 	if checksum == "" {
@@ -281,7 +281,7 @@ func genChecksum(ctx *gin.Context, env *env.Environment) string {
 			cacheManager := cache.CursorCacheManager()
 			value, err := cacheManager.GetValue(common.CalcHex(token))
 			if err != nil {
-				logger.Error(err)
+				logger.Ctx(ctx).Error(err)
 				return ""
 			}
 			if value != "" {
@@ -291,7 +291,7 @@ func genChecksum(ctx *gin.Context, env *env.Environment) string {
 			response, err := emit.ClientBuilder(common.HTTPClient).GET(checksum).
 				DoC(emit.Status(http.StatusOK), emit.IsTEXT)
 			if err != nil {
-				logger.Error(err)
+				logger.Ctx(ctx).Error(err)
 				return ""
 			}
 			checksum = emit.TextResponse(response)
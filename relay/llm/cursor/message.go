@@ -3,14 +3,14 @@ package cursor
 import (
 	"bufio"
 	"bytes"
+	"chatgpt-adapter/core/common/inited"
+	"chatgpt-adapter/core/gin/inter"
 	"encoding/json"
 	"fmt"
-	"github.com/iocgo/sdk/env"
 	"io"
 	"net/http"
 	"slices"
 	"strings"
-	"sync"
 	"time"
 
 	"chatgpt-adapter/core/common"
@@ -18,12 +18,9 @@ import (
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
 	"github.com/bincooo/emit.io"
-	"github.com/gin-gonic/gin"
 	"github.com/golang/protobuf/proto"
 )
 
-const ginTokens = "__tokens__"
-
 type chunkError struct {
 	E struct {
 		Code    string `json:"code"`
@@ -101,24 +98,18 @@ func waitMessage(r *http.Response, cancel func(str string) bool) (content string
 	return content, nil
 }
 
-func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string) {
+func waitResponse(ctx *inter.Context, r *http.Response, sse bool) (content string) {
 	defer r.Body.Close()
 	created := time.Now().Unix()
-	logger.Info("waitResponse ...")
+	logger.Ctx(ctx).Info("waitResponse ...")
 	matchers := common.GetGinMatchers(ctx)
 	completion := common.GetGinCompletion(ctx)
-	tokens := ctx.GetInt(ginTokens)
-	thinkReason := env.Env.GetBool("server.think_reason")
+	tokens := common.GetGinTokens(ctx)
+	thinkReason := inited.Env().GetBool("server.think_reason")
 	thinkReason = thinkReason && (slices.Contains([]string{"deepseek-r1", "claude-3.7-sonnet-thinking", "gemini-2.0-flash-thinking-exp"}, completion.Model[7:]))
 	reasoningContent := ""
 	think := 0
 
-	onceExec := sync.OnceFunc(func() {
-		if !sse {
-			ctx.Writer.WriteHeader(http.StatusOK)
-		}
-	})
-
 	scanner := newScanner(r.Body)
 	for {
 		if !scanner.Scan() {
@@ -159,7 +150,7 @@ func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string)
 			}
 
 			if response.NotSSEHeader(ctx) {
-				logger.Error(err)
+				logger.Ctx(ctx).Error(err)
 				response.Error(ctx, -1, err)
 			}
 			return
@@ -187,15 +178,14 @@ func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string)
 			}
 
 			raw = ""
-			logger.Debug("----- think raw -----")
-			logger.Debug(reasonContent)
+			logger.Ctx(ctx).Debug("----- think raw -----")
+			logger.Ctx(ctx).Debug(reasonContent)
 			reasoningContent += reasonContent
 			goto label
 		}
 
-		logger.Debug("----- raw -----")
-		logger.Debug(raw)
-		onceExec()
+		logger.Ctx(ctx).Debug("----- raw -----")
+		logger.Ctx(ctx).Debug(raw)
 
 		raw = response.ExecMatchers(matchers, raw, false)
 		if len(raw) == 0 {
@@ -2,12 +2,11 @@ package deepseek
 
 import (
 	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/common/inited"
 	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
-	"github.com/gin-gonic/gin"
-	"github.com/iocgo/sdk/env"
 )
 
 var (
@@ -16,11 +15,9 @@ var (
 
 type api struct {
 	inter.BaseAdapter
-
-	env *env.Environment
 }
 
-func (api *api) Match(ctx *gin.Context, model string) (ok bool, err error) {
+func (api *api) Match(ctx *inter.Context, model string) (ok bool, err error) {
 	if len(model) <= 9 || Model+"-" != model[:9] {
 		return
 	}
@@ -45,39 +42,50 @@ func (api *api) Models() (slice []model.Model) {
 	return
 }
 
-func (api *api) ToolChoice(ctx *gin.Context) (ok bool, err error) {
+func (*api) Capabilities(mod string) *model.Capabilities {
+	caps := &model.Capabilities{
+		Input:     []string{model.ModalityText},
+		Output:    []string{model.ModalityText},
+		Tools:     model.ToolsEmulated,
+		Reasoning: mod == Model+"-reasoner",
+	}
+	caps.ContextWindow, caps.MaxOutput = model.LimitsOf(mod)
+	return caps
+}
+
+func (api *api) ToolChoice(ctx *inter.Context) (ok bool, err error) {
 	var (
-		cookie     = ctx.GetString("token")
-		proxied    = api.env.GetString("server.proxied")
+		cookie     = common.GetGinToken(ctx)
+		proxied    = inited.Env().GetString("server.proxied")
 		completion = common.GetGinCompletion(ctx)
 	)
 
-	if toolChoice(ctx, api.env, cookie, proxied, completion) {
+	if toolChoice(ctx, inited.Env(), cookie, proxied, completion) {
 		ok = true
 	}
 	return
 }
 
-func (api *api) Completion(ctx *gin.Context) (err error) {
+func (api *api) Completion(ctx *inter.Context) (err error) {
 	var (
-		cookie     = ctx.GetString("token")
-		proxied    = api.env.GetString("server.proxied")
+		cookie     = common.GetGinToken(ctx)
+		proxied    = inited.Env().GetString("server.proxied")
 		completion = common.GetGinCompletion(ctx)
 	)
 
-	request, err := convertRequest(ctx, api.env, completion)
+	request, err := convertRequest(ctx, inited.Env(), completion)
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return
 	}
 
-	r, err := fetch(ctx.Request.Context(), proxied, cookie, request)
+	r, err := fetch(ctx, proxied, cookie, request)
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return
 	}
 
-	defer deleteSession(ctx, api.env, request.ChatSessionId)
+	defer deleteSession(ctx, inited.Env(), request.ChatSessionId)
 	content := waitResponse(ctx, r, completion.Stream)
 	if content == "" && response.NotResponse(ctx) {
 		response.Error(ctx, -1, "EMPTY RESPONSE")
@@ -3,16 +3,17 @@ package deepseek
 import (
 	"bytes"
 	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
+	"chatgpt-adapter/core/tracer"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/bincooo/emit.io"
-	"github.com/gin-gonic/gin"
 	"github.com/iocgo/sdk/env"
 	"net/http"
 	"strings"
@@ -55,7 +56,7 @@ func fetch(ctx context.Context, proxied, cookie string, request deepseekRequest)
 	retry := 3
 label:
 	retry--
-	response, err = emit.ClientBuilder(common.HTTPClient).
+	response, err = tracer.Do(ctx, emit.ClientBuilder(common.HTTPClient).
 		Context(ctx).
 		Proxies(proxied).
 		POST("https://chat.deepseek.com/api/v0/chat/create_pow_challenge").
@@ -72,8 +73,8 @@ label:
 		Header(elseOf(lang != "", "accept-language"), lang).
 		Body(map[string]interface{}{
 			"target_path": "/api/v0/chat/completion",
-		}).
-		DoC(emit.Status(http.StatusOK), emit.IsJSON)
+		}),
+		emit.Status(http.StatusOK), emit.IsJSON)
 	if err != nil {
 		return
 	}
@@ -119,7 +120,7 @@ label:
 		return
 	}
 
-	response, err = emit.ClientBuilder(common.HTTPClient).
+	response, err = tracer.Do(ctx, emit.ClientBuilder(common.HTTPClient).
 		Context(ctx).
 		Proxies(proxied).
 		POST("https://chat.deepseek.com/api/v0/chat/completion").
@@ -136,13 +137,13 @@ label:
 		Header("x-ds-pow-response", base64.RawStdEncoding.EncodeToString(buf)).
 		Header(elseOf(clearance != "", "cookie"), clearance).
 		Header(elseOf(lang != "", "accept-language"), lang).
-		Body(request).
-		DoC(emit.Status(http.StatusOK), emit.IsSTREAM)
+		Body(request),
+		emit.Status(http.StatusOK), emit.IsSTREAM)
 	if err != nil {
 		var busErr emit.Error
 		if errors.As(err, &busErr) && strings.Contains(busErr.Msg, "code\":40300,\"msg\":\"Missing Header") {
 			if retry > 0 {
-				logger.Error(err)
+				logger.Ctx(ctx).Error(err)
 				goto label
 			}
 		}
@@ -150,14 +151,14 @@ label:
 	return
 }
 
-func deleteSession(ctx *gin.Context, env *env.Environment, sessionId string) {
-	_, err := emit.ClientBuilder(common.HTTPClient).
-		Context(ctx.Request.Context()).
+func deleteSession(ctx *inter.Context, env *env.Environment, sessionId string) {
+	_, err := tracer.Do(ctx, emit.ClientBuilder(common.HTTPClient).
+		Context(ctx).
 		Proxies(env.GetString("server.proxied")).
 		POST("https://chat.deepseek.com/api/v0/chat_session/delete").
 		JSONHeader().
 		Ja3().
-		Header("authorization", "Bearer "+ctx.GetString("token")).
+		Header("authorization", "Bearer "+common.GetGinToken(ctx)).
 		Header("referer", "https://chat.deepseek.com/").
 		Header("user-agent", userAgent).
 		Header("x-app-version", "20241129.1").
@@ -168,9 +169,9 @@ func deleteSession(ctx *gin.Context, env *env.Environment, sessionId string) {
 		Header(elseOf(lang != "", "accept-language"), lang).
 		Body(map[string]interface{}{
 			"chat_session_id": sessionId,
-		}).DoC(emit.Status(http.StatusOK), emit.IsJSON)
+		}), emit.Status(http.StatusOK), emit.IsJSON)
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return
 	}
 }
@@ -299,14 +300,14 @@ func calcAnswer(data map[string]interface{}) (num int, err error) {
 //	return
 //}
 
-func convertRequest(ctx *gin.Context, env *env.Environment, completion model.Completion) (request deepseekRequest, err error) {
-	r, err := emit.ClientBuilder(common.HTTPClient).
-		Context(ctx.Request.Context()).
+func convertRequest(ctx *inter.Context, env *env.Environment, completion model.Completion) (request deepseekRequest, err error) {
+	r, err := tracer.Do(ctx, emit.ClientBuilder(common.HTTPClient).
+		Context(ctx).
 		Proxies(env.GetString("server.proxied")).
 		POST("https://chat.deepseek.com/api/v0/chat_session/create").
 		JSONHeader().
 		Ja3().
-		Header("authorization", "Bearer "+ctx.GetString("token")).
+		Header("authorization", "Bearer "+common.GetGinToken(ctx)).
 		Header("referer", "https://chat.deepseek.com/").
 		Header("user-agent", userAgent).
 		Header("x-app-version", "20241129.1").
@@ -317,7 +318,7 @@ func convertRequest(ctx *gin.Context, env *env.Environment, completion model.Com
 		Header(elseOf(lang != "", "accept-language"), lang).
 		Body(map[string]interface{}{
 			"character_id": nil,
-		}).DoC(emit.Status(http.StatusOK), emit.IsJSON)
+		}), emit.Status(http.StatusOK), emit.IsJSON)
 	if err != nil {
 		var busErr emit.Error
 		if errors.As(err, &busErr) && busErr.Code == 403 {
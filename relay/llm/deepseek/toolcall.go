@@ -3,20 +3,20 @@ package deepseek
 import (
 	"chatgpt-adapter/core/common/toolcall"
 	"chatgpt-adapter/core/common/vars"
+	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
-	"github.com/gin-gonic/gin"
 	"github.com/iocgo/sdk/env"
 )
 
-func toolChoice(ctx *gin.Context, env *env.Environment, proxies, cookie string, completion model.Completion) bool {
-	logger.Info("completeTools ...")
+func toolChoice(ctx *inter.Context, env *env.Environment, proxies, cookie string, completion model.Completion) bool {
+	logger.Ctx(ctx).Info("completeTools ...")
 	echo := ctx.GetBool(vars.GinEcho)
 
 	exec, err := toolcall.ToolChoice(ctx, completion, func(message string) (string, error) {
 		if echo {
-			logger.Infof("toolCall message: \n%s", message)
+			logger.Ctx(ctx).Infof("toolCall message: \n%s", message)
 			return "", nil
 		}
 		completion.Messages = []model.Keyv[interface{}]{
@@ -31,17 +31,17 @@ func toolChoice(ctx *gin.Context, env *env.Environment, proxies, cookie string,
 			return "", err
 		}
 
-		r, err := fetch(ctx.Request.Context(), proxies, cookie, request)
+		r, err := fetch(ctx, proxies, cookie, request)
 		if err != nil {
 			return "", err
 		}
 
 		defer deleteSession(ctx, env, request.ChatSessionId)
-		return waitMessage(r, toolcall.Cancel)
+		return waitMessage(r, toolcall.CancelOf(ctx))
 	})
 
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		response.Error(ctx, -1, err)
 		return true
 	}
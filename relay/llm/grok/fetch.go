@@ -2,6 +2,7 @@ package grok
 
 import (
 	"bytes"
+	"chatgpt-adapter/core/gin/inter"
 	"net/http"
 	"strings"
 
@@ -9,7 +10,6 @@ import (
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"github.com/bincooo/emit.io"
-	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/iocgo/sdk/env"
 )
@@ -40,11 +40,11 @@ type grokRequest struct {
 	IsReasoning               bool          `json:"isReasoning"`
 }
 
-func fetch(ctx *gin.Context, proxied, cookie string, request grokRequest) (response *http.Response, err error) {
+func fetch(ctx *inter.Context, proxied, cookie string, request grokRequest) (response *http.Response, err error) {
 	ua := ctx.GetString("userAgent")
 	lang := ctx.GetString("lang")
 	response, err = emit.ClientBuilder(common.HTTPClient).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		Proxies(proxied).
 		POST("https://grok.com/rest/app-chat/conversations/new").
 		JSONHeader().
@@ -60,7 +60,7 @@ func fetch(ctx *gin.Context, proxied, cookie string, request grokRequest) (respo
 	return
 }
 
-func convertRequest(ctx *gin.Context, env *env.Environment, completion model.Completion) (request grokRequest, err error) {
+func convertRequest(ctx *inter.Context, env *env.Environment, completion model.Completion) (request grokRequest, err error) {
 	contentBuffer := new(bytes.Buffer)
 	customInstructions := ""
 
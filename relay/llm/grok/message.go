@@ -2,22 +2,17 @@ package grok
 
 import (
 	"bufio"
+	"chatgpt-adapter/core/common/inited"
+	"chatgpt-adapter/core/gin/inter"
 	"encoding/json"
 	"io"
 	"net/http"
-	"sync"
 	"time"
 
 	"chatgpt-adapter/core/common"
 	"chatgpt-adapter/core/common/vars"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
-	"github.com/gin-gonic/gin"
-	"github.com/iocgo/sdk/env"
-)
-
-const (
-	ginTokens = "__tokens__"
 )
 
 type grokResponse struct {
@@ -80,19 +75,13 @@ func waitMessage(r *http.Response, cancel func(str string) bool) (content string
 	return
 }
 
-func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string) {
+func waitResponse(ctx *inter.Context, r *http.Response, sse bool) (content string) {
 	created := time.Now().Unix()
-	logger.Infof("waitResponse ...")
-	tokens := ctx.GetInt(ginTokens)
-	thinkReason := env.Env.GetBool("server.think_reason")
+	logger.Ctx(ctx).Infof("waitResponse ...")
+	tokens := common.GetGinTokens(ctx)
+	thinkReason := inited.Env().GetBool("server.think_reason")
 	reasoningContent := ""
 
-	onceExec := sync.OnceFunc(func() {
-		if !sse {
-			ctx.Writer.WriteHeader(http.StatusOK)
-		}
-	})
-
 	var (
 		matchers = common.GetGinMatchers(ctx)
 	)
@@ -122,7 +111,7 @@ func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string)
 
 		err = json.Unmarshal(dataBytes, &res)
 		if err != nil {
-			logger.Warn(err)
+			logger.Ctx(ctx).Warn(err)
 			continue
 		}
 
@@ -153,14 +142,13 @@ func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string)
 
 		raw := delta.Token
 		if thinkReason && think == 1 {
-			logger.Debug("----- think raw -----")
-			logger.Debug(reasonContent)
+			logger.Ctx(ctx).Debug("----- think raw -----")
+			logger.Ctx(ctx).Debug(reasonContent)
 			goto label
 		}
 
-		logger.Debug("----- raw -----")
-		logger.Debug(raw)
-		onceExec()
+		logger.Ctx(ctx).Debug("----- raw -----")
+		logger.Ctx(ctx).Debug(raw)
 
 		raw = response.ExecMatchers(matchers, raw, false)
 		if len(raw) == 0 {
@@ -190,12 +178,12 @@ func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string)
 	return
 }
 
-func asError(ctx *gin.Context, err error) (ok bool) {
+func asError(ctx *inter.Context, err error) (ok bool) {
 	if err == nil {
 		return
 	}
 
-	logger.Error(err)
+	logger.Ctx(ctx).Error(err)
 	if response.NotSSEHeader(ctx) {
 		response.Error(ctx, -1, err)
 	}
@@ -2,12 +2,11 @@ package lmsys_chat
 
 import (
 	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/common/inited"
 	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
-	"github.com/gin-gonic/gin"
-	"github.com/iocgo/sdk/env"
 	"golang.org/x/exp/maps"
 )
 
@@ -19,30 +18,28 @@ var (
 		"gemini-2.5-pro":                          "e2d9d353-6dbe-4414-bf87-bd289d523726",
 		"claude-opus-4-20250514":                  "ee116d12-64d6-48a8-88e5-b2d06325cdd2",
 		"claude-3-7-sonnet-20250219-thinking-32k": "be98fcfd-345c-4ae1-9a82-a19123ebf1d2",
-		
+
 		// 新增模型
-		"gpt-5-chat":                              "4b11c78c-08c8-461c-938e-5fc97d56a40d",
-		"gpt-5-high":                              "983bc566-b783-4d28-b24c-3c8b08eb1086",
-		"claude-opus-4-1-20250805":                "96ae95fd-b70d-49c3-91cc-b58c7da1090b",
-		"gpt-5-high-new-system-prompt":            "19ad5f04-38c6-48ae-b826-f7d5bbfd79f7",
-		"claude-opus-4-1-20250805-thinking-16k":   "f1a2eb6f-fc30-4806-9e00-1efd0d73cbc4",
-		"claude-opus-4-20250514-thinking-16k":     "3b5e9593-3dc0-4492-a3da-19784c4bde75",
+		"gpt-5-chat":                            "4b11c78c-08c8-461c-938e-5fc97d56a40d",
+		"gpt-5-high":                            "983bc566-b783-4d28-b24c-3c8b08eb1086",
+		"claude-opus-4-1-20250805":              "96ae95fd-b70d-49c3-91cc-b58c7da1090b",
+		"gpt-5-high-new-system-prompt":          "19ad5f04-38c6-48ae-b826-f7d5bbfd79f7",
+		"claude-opus-4-1-20250805-thinking-16k": "f1a2eb6f-fc30-4806-9e00-1efd0d73cbc4",
+		"claude-opus-4-20250514-thinking-16k":   "3b5e9593-3dc0-4492-a3da-19784c4bde75",
 	}
 )
 
 type api struct {
 	inter.BaseAdapter
-
-	env *env.Environment
 }
 
-func (api *api) Match(ctx *gin.Context, model string) (ok bool, err error) {
-	token := ctx.GetString("token")
+func (api *api) Match(ctx *inter.Context, model string) (ok bool, err error) {
+	token := common.GetGinToken(ctx)
 	if len(model) <= 11 || model[:11] != Model+"/" {
 		return
 	}
 
-	customMap := api.env.GetStringMapString("lmsys-chat.model")
+	customMap := inited.Env().GetStringMapString("lmsys-chat.model")
 	slice := maps.Keys(customMap)
 	modelSlice := maps.Keys(modelMap)
 	for _, mod := range append(slice, modelSlice...) {
@@ -52,7 +49,7 @@ func (api *api) Match(ctx *gin.Context, model string) (ok bool, err error) {
 
 		// 如果设置了密码，检查token是否匹配
 		// 注意：现在token可以为空（使用自动获取的cookie）
-		password := api.env.GetString("server.password")
+		password := inited.Env().GetString("server.password")
 		if password != "" && token != "" && password != token {
 			err = response.UnauthorizedError
 			return
@@ -64,7 +61,7 @@ func (api *api) Match(ctx *gin.Context, model string) (ok bool, err error) {
 }
 
 func (api *api) Models() (result []model.Model) {
-	customMap := api.env.GetStringMapString("lmsys-chat.model")
+	customMap := inited.Env().GetStringMapString("lmsys-chat.model")
 	slice := maps.Keys(customMap)
 	modelSlice := maps.Keys(modelMap)
 
@@ -79,7 +76,17 @@ func (api *api) Models() (result []model.Model) {
 	return
 }
 
-func (api *api) ToolChoice(ctx *gin.Context) (ok bool, err error) {
+// 未知的模型不限制上下文与输出
+func (*api) Capabilities(mod string) *model.Capabilities {
+	caps := &model.Capabilities{
+		Output: []string{model.ModalityText},
+		Tools:  model.ToolsEmulated,
+	}
+	caps.ContextWindow, caps.MaxOutput = model.LimitsOf(mod)
+	return caps
+}
+
+func (api *api) ToolChoice(ctx *inter.Context) (ok bool, err error) {
 	var (
 		completion = common.GetGinCompletion(ctx)
 	)
@@ -90,7 +97,7 @@ func (api *api) ToolChoice(ctx *gin.Context) (ok bool, err error) {
 	return
 }
 
-func (api *api) Completion(ctx *gin.Context) (err error) {
+func (api *api) Completion(ctx *inter.Context) (err error) {
 	var (
 		completion = common.GetGinCompletion(ctx)
 	)
@@ -101,15 +108,15 @@ func (api *api) Completion(ctx *gin.Context) (err error) {
 		response.Error(ctx, -1, err)
 		return
 	}
-	ctx.Set(ginTokens, response.CalcTokens(newMessages))
-	
+	common.SetGinTokens(ctx, response.CalcTokens(newMessages))
+
 	// 获取用户传递的cookie（如果有的话）
 	// 如果没有传递cookie，fetch函数会自动获取
-	cookie := ctx.GetString("token")
-	
-	resp, err := fetch(ctx.Request.Context(), cookie, newMessages, GetModelId(completion.Model))
+	cookie := common.GetGinToken(ctx)
+
+	resp, err := fetch(ctx, cookie, newMessages, GetModelId(completion.Model))
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return
 	}
 
@@ -121,7 +128,7 @@ func (api *api) Completion(ctx *gin.Context) (err error) {
 }
 
 func GetModelId(model string) string {
-	customMap := env.Env.GetStringMapString("lmsys-chat.model")
+	customMap := inited.Env().GetStringMapString("lmsys-chat.model")
 	mod, ok := customMap[model]
 	if ok {
 		return mod
@@ -2,7 +2,9 @@ package lmsys_chat
 
 import (
 	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/common/inited"
 	"chatgpt-adapter/core/logger"
+	"chatgpt-adapter/core/tracer"
 	"context"
 	"errors"
 	"fmt"
@@ -13,7 +15,6 @@ import (
 
 	"github.com/bincooo/emit.io"
 	"github.com/google/uuid"
-	"github.com/iocgo/sdk/env"
 )
 
 const (
@@ -96,9 +97,9 @@ func getValidCookie(ctx context.Context) (string, error) {
 		cookieMutex.RUnlock()
 		// 打印自动cookie的前30个字符
 		if len(cookie) > 30 {
-			logger.Infof("自动cookie: %s...", cookie[:30])
+			logger.Ctx(ctx).Infof("自动cookie: %s...", cookie[:30])
 		} else {
-			logger.Infof("自动cookie: %s", cookie)
+			logger.Ctx(ctx).Infof("自动cookie: %s", cookie)
 		}
 		return cookie, nil
 	}
@@ -118,18 +119,18 @@ func refreshCookie(ctx context.Context) (string, error) {
 		return autoCookie, nil
 	}
 	
-	logger.Info("正在通过 browser-less 获取 lmarena.ai cookie...")
+	logger.Ctx(ctx).Info("正在通过 browser-less 获取 lmarena.ai cookie...")
 	
-	baseUrl := env.Env.GetString("browser-less.reversal")
-	if !env.Env.GetBool("browser-less.enabled") && baseUrl == "" {
+	baseUrl := inited.Env().GetString("browser-less.reversal")
+	if !inited.Env().GetBool("browser-less.enabled") && baseUrl == "" {
 		return "", errors.New("需要启用 browser-less 来自动获取 cookie，请设置 `browser-less.enabled` 或 `browser-less.reversal`")
 	}
 	
 	if baseUrl == "" {
-		baseUrl = "http://127.0.0.1:" + env.Env.GetString("browser-less.port")
+		baseUrl = "http://127.0.0.1:" + inited.Env().GetString("browser-less.port")
 	}
 	
-	logger.Infof("browser-less URL: %s", baseUrl)
+	logger.Ctx(ctx).Infof("browser-less URL: %s", baseUrl)
 	
 	// 调用 browser-less 获取 cookie
 	// 访问 ?mode=direct 页面以触发生成匿名用户 token
@@ -139,9 +140,9 @@ func refreshCookie(ctx context.Context) (string, error) {
 		Header("x-website", "https://lmarena.ai/?mode=direct").
 		DoC(emit.Status(http.StatusOK), emit.IsJSON)
 	if err != nil {
-		logger.Error("browser-less 获取 cookie 失败:", err)
+		logger.Ctx(ctx).Error("browser-less 获取 cookie 失败:", err)
 		if r != nil && emit.IsJSON(r) == nil {
-			logger.Error(emit.TextResponse(r))
+			logger.Ctx(ctx).Error(emit.TextResponse(r))
 		}
 		return "", err
 	}
@@ -149,21 +150,21 @@ func refreshCookie(ctx context.Context) (string, error) {
 	defer r.Body.Close()
 	obj, err := emit.ToMap(r)
 	if err != nil {
-		logger.Error("解析 browser-less 响应失败:", err)
+		logger.Ctx(ctx).Error("解析 browser-less 响应失败:", err)
 		return "", err
 	}
 	
-	logger.Infof("browser-less 响应: %+v", obj)
+	logger.Ctx(ctx).Infof("browser-less 响应: %+v", obj)
 	
 	data, ok := obj["data"].(map[string]interface{})
 	if !ok {
-		logger.Error("browser-less 响应格式错误，没有 data 字段")
+		logger.Ctx(ctx).Error("browser-less 响应格式错误，没有 data 字段")
 		return "", errors.New("browser-less 响应格式错误")
 	}
 	
 	cookie, ok := data["cookie"].(string)
 	if !ok || cookie == "" {
-		logger.Error("browser-less 响应中没有 cookie")
+		logger.Ctx(ctx).Error("browser-less 响应中没有 cookie")
 		return "", errors.New("browser-less 响应中没有 cookie")
 	}
 	
@@ -182,9 +183,9 @@ func refreshCookie(ctx context.Context) (string, error) {
 	
 	// 打印获取到的cookie前30个字符
 	if len(autoCookie) > 30 {
-		logger.Infof("成功获取 lmarena.ai cookie，自动cookie: %s...", autoCookie[:30])
+		logger.Ctx(ctx).Infof("成功获取 lmarena.ai cookie，自动cookie: %s...", autoCookie[:30])
 	} else {
-		logger.Infof("成功获取 lmarena.ai cookie，自动cookie: %s", autoCookie)
+		logger.Ctx(ctx).Infof("成功获取 lmarena.ai cookie，自动cookie: %s", autoCookie)
 	}
 	
 	return autoCookie, nil
@@ -192,14 +193,14 @@ func refreshCookie(ctx context.Context) (string, error) {
 
 func fetch(ctx context.Context, cookie string, messages, modelId string) (response *http.Response, err error) {
 	// 如果没有传入cookie，或者传入的是 EMPTY_KEY 或空格，自动获取
-	logger.Infof("AAAAA 传入的cookie: %s", cookie)
+	logger.Ctx(ctx).Infof("AAAAA 传入的cookie: %s", cookie)
 	if cookie == "" || cookie == "EMPTY_KEY" || cookie == " " || cookie == "  " || cookie == "   " {
 		if cookie == "EMPTY_KEY" {
-			logger.Info("检测到 EMPTY_KEY，按照未传入cookie处理...")
+			logger.Ctx(ctx).Info("检测到 EMPTY_KEY，按照未传入cookie处理...")
 		} else if cookie == " " || cookie == "  " || cookie == "   " {
-			logger.Info("检测到空格cookie，按照未传入cookie处理...")
+			logger.Ctx(ctx).Info("检测到空格cookie，按照未传入cookie处理...")
 		} else {
-			logger.Info("没有传入cookie，尝试自动获取...")
+			logger.Ctx(ctx).Info("没有传入cookie，尝试自动获取...")
 		}
 		cookie, err = getValidCookie(ctx)
 		if err != nil {
@@ -208,9 +209,9 @@ func fetch(ctx context.Context, cookie string, messages, modelId string) (respon
 	} else {
 		// 打印传入的cookie前30个字符
 		if len(cookie) > 30 {
-			logger.Infof("使用传入的cookie: %s...", cookie[:30])
+			logger.Ctx(ctx).Infof("使用传入的cookie: %s...", cookie[:30])
 		} else {
-			logger.Infof("使用传入的cookie: %s", cookie)
+			logger.Ctx(ctx).Infof("使用传入的cookie: %s", cookie)
 		}
 	}
 	
@@ -222,12 +223,12 @@ func fetch(ctx context.Context, cookie string, messages, modelId string) (respon
 	
 	if exists {
 		// 使用重试接口
-		logger.Infof("使用已有会话重试，sessionId: %s", session.SessionId)
+		logger.Ctx(ctx).Infof("使用已有会话重试，sessionId: %s", session.SessionId)
 		return fetchRetry(ctx, cookie, messages, modelId, session)
 	}
 	
 	// 第一次，使用创建接口
-	logger.Info("创建新会话...")
+	logger.Ctx(ctx).Info("创建新会话...")
 	return fetchCreate(ctx, cookie, messages, modelId, cacheKey)
 }
 
@@ -286,9 +287,9 @@ func fetchCreate(ctx context.Context, cookie string, messages, modelId, cacheKey
 		Modality: "chat",
 	}
 
-	logger.Infof("创建会话请求，URL: %s", baseUrl+"/stream/create-evaluation")
+	logger.Ctx(ctx).Infof("创建会话请求，URL: %s", baseUrl+"/stream/create-evaluation")
 	
-	response, err = emit.ClientBuilder(common.HTTPClient).
+	response, err = tracer.Do(ctx, emit.ClientBuilder(common.HTTPClient).
 		Context(ctx).
 		Header("User-Agent", userAgent).
 		Header("Accept-Language", lang).
@@ -300,15 +301,15 @@ func fetchCreate(ctx context.Context, cookie string, messages, modelId, cacheKey
 		Header("Content-Type", "text/plain;charset=UTF-8").
 		Ja3().
 		POST(baseUrl+"/stream/create-evaluation").
-		Body(req).
-		DoC(emit.Status(http.StatusOK), emit.IsSTREAM)
+		Body(req),
+		emit.Status(http.StatusOK), emit.IsSTREAM)
 	
 	// 如果遇到403错误，刷新cookie重试
 	if err != nil {
-		logger.Errorf("创建会话失败: %v", err)
+		logger.Ctx(ctx).Errorf("创建会话失败: %v", err)
 		var busErr emit.Error
 		if errors.As(err, &busErr) && busErr.Code == 403 {
-			logger.Info("遇到403错误，尝试刷新cookie...")
+			logger.Ctx(ctx).Info("遇到403错误，尝试刷新cookie...")
 			newCookie, refreshErr := refreshCookie(ctx)
 			if refreshErr == nil {
 				return fetchCreate(ctx, newCookie, messages, modelId, cacheKey)
@@ -325,7 +326,7 @@ func fetchRetry(ctx context.Context, cookie string, messages, modelId string, se
 	actualModelId := modelId
 	if modelId == "ee116d12-64d6-48a8-88e5-b2d06325cdd2" {
 		actualModelId = "e2d9d353-6dbe-4414-bf87-bd289d523726" // 特殊处理 用这个特殊id重试
-		logger.Infof("特殊处理：将模型ID从 %s 替换为 %s 进行重试", modelId, actualModelId)
+		logger.Ctx(ctx).Infof("特殊处理：将模型ID从 %s 替换为 %s 进行重试", modelId, actualModelId)
 	}
 	
 	// 构建重试请求
@@ -351,9 +352,9 @@ func fetchRetry(ctx context.Context, cookie string, messages, modelId string, se
 	url := fmt.Sprintf("%s/stream/retry-evaluation-session-message/%s/messages/%s", 
 		baseUrl, session.SessionId, session.ModelMessageId)
 	
-	logger.Infof("重试会话请求，URL: %s", url)
+	logger.Ctx(ctx).Infof("重试会话请求，URL: %s", url)
 	
-	response, err = emit.ClientBuilder(common.HTTPClient).
+	response, err = tracer.Do(ctx, emit.ClientBuilder(common.HTTPClient).
 		Context(ctx).
 		Header("User-Agent", userAgent).
 		Header("Accept-Language", lang).
@@ -365,15 +366,15 @@ func fetchRetry(ctx context.Context, cookie string, messages, modelId string, se
 		Header("Content-Type", "text/plain;charset=UTF-8").
 		Ja3().
 		PUT(url).
-		Body(retryReq).
-		DoC(emit.Status(http.StatusOK), emit.IsSTREAM)
+		Body(retryReq),
+		emit.Status(http.StatusOK), emit.IsSTREAM)
 	
 	// 如果遇到403错误，刷新cookie重试
 	if err != nil {
-		logger.Errorf("重试会话失败: %v", err)
+		logger.Ctx(ctx).Errorf("重试会话失败: %v", err)
 		var busErr emit.Error
 		if errors.As(err, &busErr) && busErr.Code == 403 {
-			logger.Info("遇到403错误，尝试刷新cookie...")
+			logger.Ctx(ctx).Info("遇到403错误，尝试刷新cookie...")
 			newCookie, refreshErr := refreshCookie(ctx)
 			if refreshErr == nil {
 				return fetchRetry(ctx, newCookie, messages, modelId, session)
@@ -381,7 +382,7 @@ func fetchRetry(ctx context.Context, cookie string, messages, modelId string, se
 		}
 		// 如果遇到500错误，说明会话已失效，删除缓存并创建新会话
 		if errors.As(err, &busErr) && busErr.Code == 500 {
-			logger.Info("遇到500错误，会话已失效，删除缓存并创建新会话...")
+			logger.Ctx(ctx).Info("遇到500错误，会话已失效，删除缓存并创建新会话...")
 			
 			// 删除失效的缓存
 			cacheKey := getCacheKey(cookie, modelId)
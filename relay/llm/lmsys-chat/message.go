@@ -5,20 +5,17 @@ import (
 	"bytes"
 	"chatgpt-adapter/core/common"
 	"chatgpt-adapter/core/common/vars"
+	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
 	"encoding/json"
-	"github.com/gin-gonic/gin"
 	"io"
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 )
 
-const ginTokens = "__tokens__"
-
 func waitMessage(r *http.Response, cancel func(str string) bool) (content string, err error) {
 
 	defer r.Body.Close()
@@ -67,18 +64,12 @@ func waitMessage(r *http.Response, cancel func(str string) bool) (content string
 	return content, nil
 }
 
-func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string, err error) {
+func waitResponse(ctx *inter.Context, r *http.Response, sse bool) (content string, err error) {
 	created := time.Now().Unix()
-	logger.Infof("waitResponse ...")
-	tokens := ctx.GetInt(ginTokens)
+	logger.Ctx(ctx).Infof("waitResponse ...")
+	tokens := common.GetGinTokens(ctx)
 	reasoningContent := ""
 
-	onceExec := sync.OnceFunc(func() {
-		if !sse {
-			ctx.Writer.WriteHeader(http.StatusOK)
-		}
-	})
-
 	var (
 		matchers = common.GetGinMatchers(ctx)
 	)
@@ -98,8 +89,8 @@ func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string,
 			break
 		}
 
-		logger.Debug("----- raw -----")
-		logger.Debug(string(chunk))
+		logger.Ctx(ctx).Debug("----- raw -----")
+		logger.Ctx(ctx).Debug(string(chunk))
 		if len(chunk) == 0 {
 			continue
 		}
@@ -108,7 +99,7 @@ func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string,
 		if bytes.HasPrefix(chunk, []byte("a0:")) {
 			err = json.Unmarshal(chunk[3:], &raw)
 			if err != nil {
-				logger.Error(err)
+				logger.Ctx(ctx).Error(err)
 				return
 			}
 		}
@@ -117,7 +108,7 @@ func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string,
 			var obj map[string]interface{}
 			err = json.Unmarshal(chunk[3:], &obj)
 			if err != nil {
-				logger.Error(err)
+				logger.Ctx(ctx).Error(err)
 				return
 			}
 
@@ -127,8 +118,6 @@ func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string,
 			}
 		}
 
-		onceExec()
-
 		raw = response.ExecMatchers(matchers, raw, false)
 		if len(raw) == 0 {
 			continue
@@ -156,7 +145,7 @@ func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string,
 	return
 }
 
-func mergeMessages(ctx *gin.Context, completion model.Completion) (newMessages string, err error) {
+func mergeMessages(ctx *inter.Context, completion model.Completion) (newMessages string, err error) {
 	var (
 		messages = completion.Messages
 	)
@@ -2,12 +2,11 @@ package lmsys
 
 import (
 	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/common/inited"
 	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
-	"github.com/gin-gonic/gin"
-	"github.com/iocgo/sdk/env"
 )
 
 var (
@@ -100,23 +99,21 @@ var (
 
 type api struct {
 	inter.BaseAdapter
-
-	env *env.Environment
 }
 
-func (api *api) Match(ctx *gin.Context, model string) (ok bool, err error) {
-	token := ctx.GetString("token")
+func (api *api) Match(ctx *inter.Context, model string) (ok bool, err error) {
+	token := common.GetGinToken(ctx)
 	if len(model) <= 6 || model[:6] != Model+"/" {
 		return
 	}
 
-	slice := api.env.GetStringSlice("lmsys.model")
+	slice := inited.Env().GetStringSlice("lmsys.model")
 	for _, mod := range append(slice, modelSlice...) {
 		if model[6:] != mod {
 			continue
 		}
 
-		password := api.env.GetString("server.password")
+		password := inited.Env().GetString("server.password")
 		if password != "" && password != token {
 			err = response.UnauthorizedError
 			return
@@ -128,7 +125,7 @@ func (api *api) Match(ctx *gin.Context, model string) (ok bool, err error) {
 }
 
 func (api *api) Models() (result []model.Model) {
-	slice := api.env.GetStringSlice("lmsys.model")
+	slice := inited.Env().GetStringSlice("lmsys.model")
 	for _, mod := range append(slice, modelSlice...) {
 		result = append(result, model.Model{
 			Id:      "lmsys/" + mod,
@@ -140,21 +137,31 @@ func (api *api) Models() (result []model.Model) {
 	return
 }
 
-func (api *api) ToolChoice(ctx *gin.Context) (ok bool, err error) {
+// 未知的模型不限制上下文与输出
+func (*api) Capabilities(mod string) *model.Capabilities {
+	caps := &model.Capabilities{
+		Output: []string{model.ModalityText},
+		Tools:  model.ToolsEmulated,
+	}
+	caps.ContextWindow, caps.MaxOutput = model.LimitsOf(mod)
+	return caps
+}
+
+func (api *api) ToolChoice(ctx *inter.Context) (ok bool, err error) {
 	var (
-		proxied    = api.env.GetString("server.proxied")
+		proxied    = inited.Env().GetString("server.proxied")
 		completion = common.GetGinCompletion(ctx)
 	)
 
-	if toolChoice(ctx, api.env, proxied, completion) {
+	if toolChoice(ctx, inited.Env(), proxied, completion) {
 		ok = true
 	}
 	return
 }
 
-func (api *api) Completion(ctx *gin.Context) (err error) {
+func (api *api) Completion(ctx *inter.Context) (err error) {
 	var (
-		proxied    = api.env.GetString("server.proxied")
+		proxied    = inited.Env().GetString("server.proxied")
 		completion = common.GetGinCompletion(ctx)
 	)
 
@@ -164,16 +171,16 @@ func (api *api) Completion(ctx *gin.Context) (err error) {
 		response.Error(ctx, -1, err)
 		return
 	}
-	ctx.Set(ginTokens, response.CalcTokens(newMessages))
-	ch, err := fetch(ctx.Request.Context(), api.env, proxied, newMessages,
+	common.SetGinTokens(ctx, response.CalcTokens(newMessages))
+	ch, err := fetch(ctx, inited.Env(), proxied, newMessages,
 		options{
 			model:       completion.Model,
-			temperature: completion.Temperature,
+			temperature: completion.TemperatureOr(0),
 			topP:        completion.TopP,
 			maxTokens:   completion.MaxTokens,
 		})
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return
 	}
 
@@ -1,6 +1,7 @@
 package lmsys
 
 import (
+	"chatgpt-adapter/core/common/inited"
 	"context"
 	"encoding/json"
 	"errors"
@@ -12,6 +13,7 @@ import (
 
 	"chatgpt-adapter/core/common"
 	"chatgpt-adapter/core/logger"
+	"chatgpt-adapter/core/tracer"
 	"github.com/bincooo/emit.io"
 	"github.com/iocgo/sdk/env"
 )
@@ -80,7 +82,7 @@ func partTwo(ctx context.Context, proxied, cookies, hash string, opts options) e
 	var err error
 	obj["fn_index"] = opts.fn[0] + 1
 	obj["trigger_id"] = opts.fn[1]
-	response, err = emit.ClientBuilder(common.HTTPClient).
+	response, err = tracer.Do(ctx, emit.ClientBuilder(common.HTTPClient).
 		Context(ctx).
 		Proxies(proxied).
 		POST(baseUrl+"/queue/join").
@@ -93,8 +95,8 @@ func partTwo(ctx context.Context, proxied, cookies, hash string, opts options) e
 		Header("Accept-Language", lang).
 		Header("Cache-Control", "no-cache").
 		Header("Priority", "u=1, i").
-		Body(obj).
-		DoS(http.StatusOK)
+		Body(obj),
+		emit.Status(http.StatusOK))
 	if err != nil {
 		ver = ""
 		return err
@@ -106,7 +108,7 @@ func partTwo(ctx context.Context, proxied, cookies, hash string, opts options) e
 	}
 
 	if eventId, ok := obj["event_id"]; ok {
-		logger.Infof("lmsys eventId: %s", eventId)
+		logger.Ctx(ctx).Infof("lmsys eventId: %s", eventId)
 	} else {
 		return errors.New("fetch failed")
 	}
@@ -114,7 +116,7 @@ func partTwo(ctx context.Context, proxied, cookies, hash string, opts options) e
 	cookies = emit.MergeCookies(cookies, emit.GetCookies(response))
 	_ = response.Body.Close()
 
-	response, err = emit.ClientBuilder(common.HTTPClient).
+	response, err = tracer.Do(ctx, emit.ClientBuilder(common.HTTPClient).
 		Context(ctx).
 		Proxies(proxied).
 		Ja3().
@@ -126,8 +128,8 @@ func partTwo(ctx context.Context, proxied, cookies, hash string, opts options) e
 		Header("Referer", baseUrl+"/").
 		Header("Accept-Language", lang).
 		Header("Cache-Control", "no-cache").
-		Header("Priority", "u=1, i").
-		DoS(http.StatusOK)
+		Header("Priority", "u=1, i"),
+		emit.Status(http.StatusOK))
 	if err != nil {
 		return err
 	}
@@ -155,7 +157,7 @@ func partThree(ctx context.Context, proxied, cookies, hash string, opts options)
 		},
 	}
 
-	response, err := emit.ClientBuilder(common.HTTPClient).
+	response, err := tracer.Do(ctx, emit.ClientBuilder(common.HTTPClient).
 		Context(ctx).
 		Proxies(proxied).
 		POST(baseUrl+"/queue/join").
@@ -168,8 +170,8 @@ func partThree(ctx context.Context, proxied, cookies, hash string, opts options)
 		Header("Accept-Language", lang).
 		Header("Cache-Control", "no-cache").
 		Header("Priority", "u=1, i").
-		Body(obj).
-		DoS(http.StatusOK)
+		Body(obj),
+		emit.Status(http.StatusOK))
 	if err != nil {
 		return nil, err
 	}
@@ -181,13 +183,13 @@ func partThree(ctx context.Context, proxied, cookies, hash string, opts options)
 	}
 
 	if eventId, ok := obj["event_id"]; ok {
-		logger.Infof("lmsys eventId: %s", eventId)
+		logger.Ctx(ctx).Infof("lmsys eventId: %s", eventId)
 	} else {
 		return nil, errors.New("fetch failed")
 	}
 
 	cookies = emit.MergeCookies(cookies, emit.GetCookies(response))
-	response, err = emit.ClientBuilder(common.HTTPClient).
+	response, err = tracer.Do(ctx, emit.ClientBuilder(common.HTTPClient).
 		Context(ctx).
 		Proxies(proxied).
 		Ja3().
@@ -199,8 +201,8 @@ func partThree(ctx context.Context, proxied, cookies, hash string, opts options)
 		Header("Referer", baseUrl+"/").
 		Header("Accept-Language", lang).
 		Header("Cache-Control", "no-cache").
-		Header("Priority", "u=1, i").
-		DoS(http.StatusOK)
+		Header("Priority", "u=1, i"),
+		emit.Status(http.StatusOK))
 	if err != nil {
 		return nil, err
 	}
@@ -214,8 +216,8 @@ func partThree(ctx context.Context, proxied, cookies, hash string, opts options)
 	pos := 0
 
 	e.Event("*", func(j emit.JoinEvent) (_ interface{}) {
-		logger.Tracef("--------- ORIGINAL MESSAGE ---------")
-		logger.Tracef("%s", j.InitialBytes)
+		logger.Ctx(ctx).Tracef("--------- ORIGINAL MESSAGE ---------")
+		logger.Ctx(ctx).Tracef("%s", j.InitialBytes)
 		return
 	})
 
@@ -273,7 +275,7 @@ func partThree(ctx context.Context, proxied, cookies, hash string, opts options)
 		defer close(ch)
 		defer response.Body.Close()
 		if err = e.Do(); err != nil {
-			logger.Error(err)
+			logger.Ctx(ctx).Error(err)
 		}
 	}()
 
@@ -304,7 +306,7 @@ func partOne(ctx context.Context, env *env.Environment, proxied string, opts *op
 	cookies := fetchCookies(ctx, proxied)
 	obj["fn_index"] = fn[0]
 	obj["trigger_id"] = fn[1]
-	response, err = emit.ClientBuilder(common.HTTPClient).
+	response, err = tracer.Do(ctx, emit.ClientBuilder(common.HTTPClient).
 		Context(ctx).
 		Proxies(proxied).
 		POST(baseUrl+"/queue/join").
@@ -317,8 +319,8 @@ func partOne(ctx context.Context, env *env.Environment, proxied string, opts *op
 		Header("Accept-Language", lang).
 		Header("Cache-Control", "no-cache").
 		Header("Priority", "u=1, i").
-		Body(obj).
-		DoS(http.StatusOK)
+		Body(obj),
+		emit.Status(http.StatusOK))
 	if err != nil {
 		ver = ""
 		return "", err
@@ -330,7 +332,7 @@ func partOne(ctx context.Context, env *env.Environment, proxied string, opts *op
 	}
 
 	if eventId, ok := obj["event_id"]; ok {
-		logger.Infof("lmsys eventId: %s", eventId)
+		logger.Ctx(ctx).Infof("lmsys eventId: %s", eventId)
 	} else {
 		return "", errors.New("fetch failed")
 	}
@@ -338,7 +340,7 @@ func partOne(ctx context.Context, env *env.Environment, proxied string, opts *op
 	cookies = emit.MergeCookies(cookies, emit.GetCookies(response))
 	_ = response.Body.Close()
 
-	response, err = emit.ClientBuilder(common.HTTPClient).
+	response, err = tracer.Do(ctx, emit.ClientBuilder(common.HTTPClient).
 		Context(ctx).
 		Proxies(proxied).
 		Ja3().
@@ -350,8 +352,8 @@ func partOne(ctx context.Context, env *env.Environment, proxied string, opts *op
 		Header("Referer", baseUrl+"/").
 		Header("Accept-Language", lang).
 		Header("Cache-Control", "no-cache").
-		Header("Priority", "u=1, i").
-		DoS(http.StatusOK)
+		Header("Priority", "u=1, i"),
+		emit.Status(http.StatusOK))
 	if err != nil {
 		return "", err
 	}
@@ -437,7 +439,7 @@ label:
 		return
 	}
 	retry--
-	response, err := emit.ClientBuilder(common.HTTPClient).
+	response, err := tracer.Do(ctx, emit.ClientBuilder(common.HTTPClient).
 		Context(ctx).
 		Proxies(proxied).
 		GET(baseUrl+"/info").
@@ -449,16 +451,16 @@ label:
 		Header("Referer", baseUrl+"/").
 		Header("priority", "u=1, i").
 		Header("cookie", emit.MergeCookies(baseCookies, clearance)).
-		Header("User-Agent", userAgent).
-		DoS(http.StatusOK)
+		Header("User-Agent", userAgent),
+		emit.Status(http.StatusOK))
 	if err != nil {
 		var emitErr emit.Error
 		// 人机验证
 		if errors.As(err, &emitErr) && emitErr.Code == 403 {
-			err = hookCloudflare(env.Env)
+			err = hookCloudflare(inited.Env())
 			goto label
 		}
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return
 	}
 
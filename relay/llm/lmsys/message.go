@@ -3,19 +3,15 @@ package lmsys
 import (
 	"chatgpt-adapter/core/common"
 	"chatgpt-adapter/core/common/vars"
+	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
 	"errors"
-	"github.com/gin-gonic/gin"
-	"net/http"
 	"strings"
-	"sync"
 	"time"
 )
 
-const ginTokens = "__tokens__"
-
 func waitMessage(chatResponse chan string, cancel func(str string) bool) (content string, err error) {
 
 	for {
@@ -42,16 +38,11 @@ func waitMessage(chatResponse chan string, cancel func(str string) bool) (conten
 	return content, nil
 }
 
-func waitResponse(ctx *gin.Context, chatResponse chan string, sse bool) (content string) {
+func waitResponse(ctx *inter.Context, chatResponse chan string, sse bool) (content string) {
 	created := time.Now().Unix()
-	logger.Info("waitResponse ...")
-	tokens := ctx.GetInt(ginTokens)
+	logger.Ctx(ctx).Info("waitResponse ...")
+	tokens := common.GetGinTokens(ctx)
 	matchers := common.GetGinMatchers(ctx)
-	onceExec := sync.OnceFunc(func() {
-		if !sse {
-			ctx.Writer.WriteHeader(http.StatusOK)
-		}
-	})
 
 	for {
 		raw, ok := <-chatResponse
@@ -66,9 +57,9 @@ func waitResponse(ctx *gin.Context, chatResponse chan string, sse bool) (content
 
 		if strings.HasPrefix(raw, "error: ") {
 			err := strings.TrimPrefix(raw, "error: ")
-			logger.Error(err)
+			logger.Ctx(ctx).Error(err)
 			if response.NotSSEHeader(ctx) {
-				logger.Error(err)
+				logger.Ctx(ctx).Error(err)
 				response.Error(ctx, -1, err)
 			}
 			return
@@ -80,9 +71,8 @@ func waitResponse(ctx *gin.Context, chatResponse chan string, sse bool) (content
 			continue
 		}
 
-		logger.Debug("----- raw -----")
-		logger.Debug(raw)
-		onceExec()
+		logger.Ctx(ctx).Debug("----- raw -----")
+		logger.Ctx(ctx).Debug(raw)
 
 		raw = response.ExecMatchers(matchers, raw, false)
 		if len(raw) == 0 {
@@ -112,7 +102,7 @@ func waitResponse(ctx *gin.Context, chatResponse chan string, sse bool) (content
 	return
 }
 
-func mergeMessages(ctx *gin.Context, completion model.Completion) (newMessages string, err error) {
+func mergeMessages(ctx *inter.Context, completion model.Completion) (newMessages string, err error) {
 	var (
 		messages    = completion.Messages
 		specialized = ctx.GetBool("specialized")
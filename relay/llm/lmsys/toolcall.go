@@ -3,27 +3,27 @@ package lmsys
 import (
 	"chatgpt-adapter/core/common/toolcall"
 	"chatgpt-adapter/core/common/vars"
+	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
-	"github.com/gin-gonic/gin"
 	"github.com/iocgo/sdk/env"
 )
 
-func toolChoice(ctx *gin.Context, env *env.Environment, proxies string, completion model.Completion) bool {
-	logger.Info("completeTools ...")
+func toolChoice(ctx *inter.Context, env *env.Environment, proxies string, completion model.Completion) bool {
+	logger.Ctx(ctx).Info("completeTools ...")
 	echo := ctx.GetBool(vars.GinEcho)
 
 	exec, err := toolcall.ToolChoice(ctx, completion, func(message string) (string, error) {
 		if echo {
-			logger.Infof("toolCall message: \n%s", message)
+			logger.Ctx(ctx).Infof("toolCall message: \n%s", message)
 			return "", nil
 		}
 
-		ch, err := fetch(ctx.Request.Context(), env, proxies, message,
+		ch, err := fetch(ctx, env, proxies, message,
 			options{
 				model:       completion.Model,
-				temperature: completion.Temperature,
+				temperature: completion.TemperatureOr(0),
 				topP:        completion.TopP,
 				maxTokens:   completion.MaxTokens,
 			})
@@ -31,11 +31,11 @@ func toolChoice(ctx *gin.Context, env *env.Environment, proxies string, completi
 			return "", err
 		}
 
-		return waitMessage(ch, toolcall.Cancel)
+		return waitMessage(ch, toolcall.CancelOf(ctx))
 	})
 
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		response.Error(ctx, -1, err)
 		return true
 	}
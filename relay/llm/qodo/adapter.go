@@ -2,12 +2,11 @@ package qodo
 
 import (
 	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/common/inited"
 	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
-	"github.com/gin-gonic/gin"
-	"github.com/iocgo/sdk/env"
 )
 
 var (
@@ -16,16 +15,14 @@ var (
 
 type api struct {
 	inter.BaseAdapter
-
-	env *env.Environment
 }
 
-func (api *api) Match(ctx *gin.Context, model string) (ok bool, err error) {
+func (api *api) Match(ctx *inter.Context, model string) (ok bool, err error) {
 	if len(model) <= 5 {
 		return
 	}
 
-	slice := api.env.GetStringSlice("qodo.model")
+	slice := inited.Env().GetStringSlice("qodo.model")
 	for _, mod := range append(slice, []string{
 		"claude-3-5-sonnet",
 		"claude-3-7-sonnet",
@@ -46,7 +43,7 @@ func (api *api) Match(ctx *gin.Context, model string) (ok bool, err error) {
 }
 
 func (api *api) Models() (slice []model.Model) {
-	for _, mod := range append(api.env.GetStringSlice("qodo.model"), []string{
+	for _, mod := range append(inited.Env().GetStringSlice("qodo.model"), []string{
 		"claude-3-5-sonnet",
 		"claude-3-7-sonnet",
 		"gpt-4o",
@@ -67,34 +64,44 @@ func (api *api) Models() (slice []model.Model) {
 	return
 }
 
-func (api *api) ToolChoice(ctx *gin.Context) (ok bool, err error) {
+// 未知的模型不限制上下文与输出
+func (*api) Capabilities(mod string) *model.Capabilities {
+	caps := &model.Capabilities{
+		Output: []string{model.ModalityText},
+		Tools:  model.ToolsEmulated,
+	}
+	caps.ContextWindow, caps.MaxOutput = model.LimitsOf(mod)
+	return caps
+}
+
+func (api *api) ToolChoice(ctx *inter.Context) (ok bool, err error) {
 	var (
-		cookie     = ctx.GetString("token")
-		proxied    = api.env.GetString("server.proxied")
+		cookie     = common.GetGinToken(ctx)
+		proxied    = inited.Env().GetString("server.proxied")
 		completion = common.GetGinCompletion(ctx)
 	)
 
-	if toolChoice(ctx, api.env, cookie, proxied, completion) {
+	if toolChoice(ctx, inited.Env(), cookie, proxied, completion) {
 		ok = true
 	}
 	return
 }
 
-func (api *api) Completion(ctx *gin.Context) (err error) {
+func (api *api) Completion(ctx *inter.Context) (err error) {
 	var (
-		proxied    = api.env.GetString("server.proxied")
+		proxied    = inited.Env().GetString("server.proxied")
 		completion = common.GetGinCompletion(ctx)
 	)
 
-	request, err := convertRequest(ctx, api.env, completion)
+	request, err := convertRequest(ctx, inited.Env(), completion)
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return
 	}
 
 	r, err := fetch(ctx, proxied, request)
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return
 	}
 
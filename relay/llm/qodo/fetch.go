@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"chatgpt-adapter/core/cache"
 	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/common/inited"
+	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
@@ -11,7 +13,6 @@ import (
 	"errors"
 	"fmt"
 	"github.com/bincooo/emit.io"
-	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/iocgo/sdk/env"
 	"net/http"
@@ -45,8 +46,8 @@ type qodoRequest struct {
 	UserContext []interface{} `json:"user_context"`
 }
 
-func fetch(ctx *gin.Context, proxied string, request qodoRequest) (response *http.Response, err error) {
-	token, err := genToken(ctx, env.Env)
+func fetch(ctx *inter.Context, proxied string, request qodoRequest) (response *http.Response, err error) {
+	token, err := genToken(ctx, inited.Env())
 	sessionId := request.SessionId
 	answer := request.Answer
 	request.Answer = nil
@@ -70,7 +71,7 @@ func fetch(ctx *gin.Context, proxied string, request qodoRequest) (response *htt
 	}
 
 	text := emit.TextResponse(response)
-	logger.Debug(text)
+	logger.Ctx(ctx).Debug(text)
 	if !strings.Contains(text, "\"tool_reasoning\":") {
 		err = errors.New("not convert to reasoning")
 		return
@@ -109,7 +110,7 @@ func fetch(ctx *gin.Context, proxied string, request qodoRequest) (response *htt
 	}
 
 	text = emit.TextResponse(response)
-	logger.Debug(text)
+	logger.Ctx(ctx).Debug(text)
 
 	request.Tool = "read_files"
 	request.Answer = answer
@@ -130,7 +131,7 @@ func fetch(ctx *gin.Context, proxied string, request qodoRequest) (response *htt
 	return
 }
 
-func convertRequest(ctx *gin.Context, env *env.Environment, completion model.Completion) (request qodoRequest, err error) {
+func convertRequest(ctx *inter.Context, env *env.Environment, completion model.Completion) (request qodoRequest, err error) {
 	dateStr := time.Now().Format("20060102-")
 	contentBuffer := new(bytes.Buffer)
 	for _, message := range completion.Messages {
@@ -538,8 +539,8 @@ func convertRequest(ctx *gin.Context, env *env.Environment, completion model.Com
 //	return textQuoted[1 : len(textQuoted)-1]
 //}
 
-func genToken(ctx *gin.Context, env *env.Environment) (token string, err error) {
-	cookies := ctx.GetString("token")
+func genToken(ctx *inter.Context, env *env.Environment) (token string, err error) {
+	cookies := common.GetGinToken(ctx)
 	cacheManager := cache.QodoCacheManager()
 	token, err = cacheManager.GetValue(cookies)
 	if token != "" || err != nil {
@@ -553,7 +554,7 @@ func genToken(ctx *gin.Context, env *env.Environment) (token string, err error)
 	}
 
 	r, err := emit.ClientBuilder(common.HTTPClient).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		Proxies(ctx.GetString("proxies")).
 		GET("https://accounts.google.com/o/oauth2/auth").
 		Query("client_id", split[0]+".apps.googleusercontent.com").
@@ -582,7 +583,7 @@ func genToken(ctx *gin.Context, env *env.Environment) (token string, err error)
 	query := u.Query()
 
 	r, err = emit.ClientBuilder(common.HTTPClient).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		Proxies(ctx.GetString("proxies")).
 		GET("https://api.qodo.ai/v1/auth/google-login/Codium.codium").
 		Query("state", query.Get("state")).
@@ -612,7 +613,7 @@ func genToken(ctx *gin.Context, env *env.Environment) (token string, err error)
 	_ = r.Body.Close()
 
 	r, err = emit.ClientBuilder(common.HTTPClient).
-		Context(ctx.Request.Context()).
+		Context(ctx).
 		Proxies(ctx.GetString("proxies")).
 		POST("https://identitytoolkit.googleapis.com/v1/accounts:signInWithIdp").
 		Query("key", env.GetString("qodo.key")).
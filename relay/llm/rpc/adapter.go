@@ -0,0 +1,275 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/common/inited"
+	"chatgpt-adapter/core/common/vars"
+	"chatgpt-adapter/core/gin/inter"
+	"chatgpt-adapter/core/gin/model"
+	"chatgpt-adapter/core/gin/response"
+	"chatgpt-adapter/core/logger"
+	"github.com/iocgo/sdk/env"
+)
+
+// 进程外适配器，通过 stdio JSON-RPC 代理到外部可执行文件
+//
+//	rpc:
+//	  - name: my-backend
+//	    command: ./plugins/my-backend
+//	    args: [ "--verbose" ]
+//	    env: [ "API_KEY=xxx" ]
+//	    health: 30   # 健康检查间隔 s
+//	    timeout: 300 # 单次调用超时 s
+const (
+	ginProcess = "__rpc-process__"
+)
+
+var (
+	processes atomic.Pointer[[]*process]
+)
+
+type api struct {
+	inter.BaseAdapter
+}
+
+func init() {
+	inited.AddInitialized(func(env *env.Environment) {
+		confs, err := parseConfs(env)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		apply(confs)
+	})
+
+	inited.AddReloaded(func(env *env.Environment) (func(), error) {
+		confs, err := parseConfs(env)
+		if err != nil {
+			return nil, err
+		}
+		return func() {
+			apply(confs)
+			logger.Infof("reload rpc plugins: %d", len(confs))
+		}, nil
+	})
+
+	inited.AddExited(func(*env.Environment) {
+		apply(nil)
+	})
+}
+
+func parseConfs(env *env.Environment) (confs []conf, err error) {
+	if err = env.UnmarshalKey("rpc", &confs); err != nil {
+		return nil, fmt.Errorf("rpc: %v", err)
+	}
+
+	names := make(map[string]bool)
+	for i, c := range confs {
+		if c.Command == "" {
+			return nil, fmt.Errorf("rpc[%d].command is required", i)
+		}
+		name := c.Name
+		if name == "" {
+			name = c.Command
+		}
+		if names[name] {
+			return nil, fmt.Errorf("rpc[%d]: duplicate name `%s`", i, name)
+		}
+		names[name] = true
+	}
+	return
+}
+
+// 未变更的进程保持运行，其余停止或新建
+func apply(confs []conf) {
+	var older []*process
+	if p := processes.Load(); p != nil {
+		older = *p
+	}
+
+	next := make([]*process, 0, len(confs))
+	kept := make(map[*process]bool)
+	for _, c := range confs {
+		np := newProcess(c)
+		for _, op := range older {
+			if reflect.DeepEqual(op.conf, np.conf) {
+				np = op
+				kept[op] = true
+				break
+			}
+		}
+		if !kept[np] {
+			go np.supervise()
+		}
+		next = append(next, np)
+	}
+
+	processes.Store(&next)
+	for _, op := range older {
+		if !kept[op] {
+			op.shutdown()
+		}
+	}
+}
+
+// 按插件 models 返回的列表匹配，不在请求路径上调用插件
+func (api *api) Match(ctx *inter.Context, model string) (ok bool, err error) {
+	p := processes.Load()
+	if p == nil {
+		return
+	}
+
+	for _, proc := range *p {
+		models := proc.models.Load()
+		if models == nil || !proc.alive.Load() {
+			continue
+		}
+
+		for _, mod := range *models {
+			if mod.Id == model {
+				ctx.Set(ginProcess, proc)
+				return true, nil
+			}
+		}
+	}
+	return
+}
+
+func (api *api) Models() (slice []model.Model) {
+	p := processes.Load()
+	if p == nil {
+		return
+	}
+	for _, proc := range *p {
+		if models := proc.models.Load(); models != nil && proc.alive.Load() {
+			slice = append(slice, *models...)
+		}
+	}
+	return
+}
+
+// 取插件 models 返回的同名字段
+func (api *api) Capabilities(mod string) *model.Capabilities {
+	p := processes.Load()
+	if p == nil {
+		return nil
+	}
+	for _, proc := range *p {
+		if models := proc.models.Load(); models != nil {
+			for _, m := range *models {
+				if m.Id == mod {
+					return m.Capabilities
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (api *api) Completion(ctx *inter.Context) (err error) {
+	var (
+		proc       = ctx.MustGet(ginProcess).(*process)
+		completion = common.GetGinCompletion(ctx)
+		matchers   = common.GetGinMatchers(ctx)
+		tokens     = common.GetGinTokens(ctx)
+		created    = time.Now().Unix()
+		sse        = completion.Stream
+
+		content          string
+		reasoningContent string
+	)
+
+	timeout, cancel := context.WithTimeout(ctx, time.Duration(proc.Timeout)*time.Second)
+	defer cancel()
+
+	params := map[string]interface{}{
+		"completion": completion,
+		"token":      common.GetGinToken(ctx),
+	}
+	result, err := proc.call(timeout, "completion", params, func(c chunk) bool {
+		if c.ReasoningContent != "" {
+			reasoningContent += c.ReasoningContent
+			if sse {
+				response.ReasonSSEResponse(ctx, proc.Name, "", c.ReasoningContent, created)
+			}
+		}
+
+		raw := response.ExecMatchers(matchers, c.Content, false)
+		if raw == response.EOF {
+			return false
+		}
+		if raw != "" && sse {
+			response.SSEResponse(ctx, proc.Name, raw, created)
+		}
+		content += raw
+		return !ctx.GetBool(vars.GinClose)
+	})
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		if response.NotSSEHeader(ctx) {
+			return
+		}
+		err = nil
+	}
+
+	raw := response.ExecMatchers(matchers, "", true)
+	if raw != "" && sse {
+		response.SSEResponse(ctx, proc.Name, raw, created)
+	}
+	content += raw
+
+	var value struct {
+		Usage map[string]interface{} `json:"usage"`
+	}
+	if len(result) > 0 {
+		_ = json.Unmarshal(result, &value)
+	}
+	if value.Usage == nil {
+		value.Usage = response.CalcUsageTokens(reasoningContent+content, tokens)
+	}
+	ctx.Set(vars.GinCompletionUsage, value.Usage)
+
+	if content == "" && response.NotSSEHeader(ctx) {
+		logger.Ctx(ctx).Error("EMPTY RESPONSE")
+	}
+	if !sse {
+		response.ReasonResponse(ctx, proc.Name, content, reasoningContent)
+	} else {
+		response.SSEResponse(ctx, proc.Name, "[DONE]", created)
+	}
+	return
+}
+
+func (api *api) Generation(ctx *inter.Context) (err error) {
+	return api.passthrough(ctx, "generation", "generation", common.GetGinGeneration(ctx))
+}
+
+func (api *api) Embedding(ctx *inter.Context) (err error) {
+	return api.passthrough(ctx, "embedding", "embed", common.GetGinEmbedding(ctx))
+}
+
+// 插件返回值原样作为响应体
+func (api *api) passthrough(ctx *inter.Context, method, key string, value interface{}) (err error) {
+	proc := ctx.MustGet(ginProcess).(*process)
+	timeout, cancel := context.WithTimeout(ctx, time.Duration(proc.Timeout)*time.Second)
+	defer cancel()
+
+	result, err := proc.call(timeout, method, map[string]interface{}{
+		key:     value,
+		"token": common.GetGinToken(ctx),
+	}, nil)
+	if err != nil {
+		logger.Ctx(ctx).Error(err)
+		return
+	}
+
+	response.Forward(ctx, http.StatusOK, json.RawMessage(result))
+	return
+}
@@ -0,0 +1,13 @@
+package rpc
+
+import (
+	"chatgpt-adapter/core/gin/inter"
+	"github.com/iocgo/sdk/env"
+
+	_ "github.com/iocgo/sdk"
+)
+
+// @Inject(name = "rpc-adapter")
+func New(env *env.Environment) inter.Adapter {
+	return &api{}
+}
@@ -0,0 +1,433 @@
+package rpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"chatgpt-adapter/core/gin/model"
+	"chatgpt-adapter/core/logger"
+)
+
+// 行分隔的 JSON-RPC 2.0，一行一条消息；stdin 为请求，stdout 为响应与通知，stderr 记入日志
+//
+// 宿主 -> 插件 (请求):
+//
+//	initialize {"protocol": 1}                     => {"name": "..."}
+//	models     {}                                  => {"models": [{"id": "...", ...}]}
+//	completion {"completion": {...}, "token": ""}  => {"usage": {...}}，内容通过 chunk 通知推送
+//	generation {"generation": {...}, "token": ""}  => 原样作为响应体
+//	embedding  {"embed": {...}, "token": ""}       => 原样作为响应体
+//	ping       {}                                  => {}
+//
+// 宿主 -> 插件 (通知):
+//
+//	$/cancel   {"id": 1}   客户端断开或匹配器终止输出
+//
+// 插件 -> 宿主 (通知):
+//
+//	chunk      {"id": 1, "content": "...", "reasoning_content": "..."}
+//	log        {"level": "info", "message": "..."}
+const Protocol = 1
+
+var errExited = errors.New("rpc: process exited")
+
+type conf struct {
+	Name    string   `mapstructure:"name"`
+	Command string   `mapstructure:"command"`
+	Args    []string `mapstructure:"args"`
+	Env     []string `mapstructure:"env"`
+	Dir     string   `mapstructure:"dir"`
+	Health  int      `mapstructure:"health"`  // 健康检查间隔 s，默认 30
+	Timeout int      `mapstructure:"timeout"` // 单次调用超时 s，默认 300
+}
+
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Id      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  interface{}     `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("rpc error %d: %s", e.Code, e.Message)
+}
+
+type chunk struct {
+	Id               int64  `json:"id"`
+	Content          string `json:"content"`
+	ReasoningContent string `json:"reasoning_content"`
+}
+
+// 等待中的调用，chunk 与最终响应按序追加到队列；
+// 队列不设上限，读取 stdout 的 goroutine 不会因某个调用消费过慢而阻塞其它调用
+type call struct {
+	mu     sync.Mutex
+	queue  []interface{}
+	notify chan struct{}
+}
+
+func (c *call) push(value interface{}) {
+	c.mu.Lock()
+	c.queue = append(c.queue, value)
+	c.mu.Unlock()
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (c *call) drain() (values []interface{}) {
+	c.mu.Lock()
+	values, c.queue = c.queue, nil
+	c.mu.Unlock()
+	return
+}
+
+type process struct {
+	conf
+
+	mu      sync.Mutex // 保护 stdin、cmd 与 exited
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	exited  chan struct{} // 当前进程退出时关闭
+	seq     atomic.Int64
+	pending sync.Map // int64 => *call
+	alive   atomic.Bool
+	models  atomic.Pointer[[]model.Model]
+
+	stop chan struct{}
+}
+
+func newProcess(c conf) *process {
+	if c.Name == "" {
+		c.Name = c.Command
+	}
+	if c.Health == 0 {
+		c.Health = 30
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 300
+	}
+	return &process{conf: c, stop: make(chan struct{})}
+}
+
+// 守护进程：退出后按指数退避重启，稳定运行 1 分钟后重置退避
+func (p *process) supervise() {
+	backoff := time.Second
+	for {
+		started := time.Now()
+		exited, err := p.start()
+		if err != nil {
+			logger.Errorf("rpc plugin `%s` start failed: %v", p.Name, err)
+		} else {
+			go p.health(exited)
+			<-exited
+		}
+
+		select {
+		case <-p.stop:
+			return
+		default:
+		}
+
+		if time.Since(started) > time.Minute {
+			backoff = time.Second
+		}
+		logger.Warnf("rpc plugin `%s` exited, restart after %s", p.Name, backoff)
+		select {
+		case <-p.stop:
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+func (p *process) start() (exited chan struct{}, err error) {
+	cmd := exec.Command(p.Command, p.Args...)
+	cmd.Dir = p.Dir
+	cmd.Env = append(os.Environ(), p.Env...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return
+	}
+	if err = cmd.Start(); err != nil {
+		return
+	}
+
+	exited = make(chan struct{})
+	p.mu.Lock()
+	p.cmd, p.stdin, p.exited = cmd, stdin, exited
+	p.mu.Unlock()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			logger.Infof("[rpc:%s] %s", p.Name, scanner.Text())
+		}
+	}()
+	go func() {
+		p.read(stdout)
+		// Wait 会关闭管道，需等 stderr 读完
+		wg.Wait()
+		_ = cmd.Wait()
+		p.alive.Store(false)
+		// 先关闭 exited：之后注册的调用由 call 自行检查，之前注册的由 fail 结束
+		close(exited)
+		p.fail()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err = p.call(ctx, "initialize", map[string]interface{}{"protocol": Protocol}, nil); err != nil {
+		p.kill()
+		<-exited
+		return nil, fmt.Errorf("initialize: %v", err)
+	}
+
+	p.alive.Store(true)
+	p.refresh()
+	logger.Infof("rpc plugin `%s` started, pid: %d", p.Name, cmd.Process.Pid)
+	return
+}
+
+// 定期 ping，超时则结束进程交由 supervise 重启
+func (p *process) health(exited chan struct{}) {
+	ticker := time.NewTicker(time.Duration(p.Health) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-exited:
+			return
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		_, err := p.call(ctx, "ping", struct{}{}, nil)
+		cancel()
+		if err != nil {
+			logger.Errorf("rpc plugin `%s` health check failed: %v", p.Name, err)
+			p.kill()
+			return
+		}
+		p.refresh()
+	}
+}
+
+func (p *process) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	result, err := p.call(ctx, "models", struct{}{}, nil)
+	if err != nil {
+		logger.Errorf("rpc plugin `%s` models failed: %v", p.Name, err)
+		return
+	}
+
+	var value struct {
+		Models []model.Model `json:"models"`
+	}
+	if err = json.Unmarshal(result, &value); err != nil {
+		logger.Errorf("rpc plugin `%s` models failed: %v", p.Name, err)
+		return
+	}
+	for i := range value.Models {
+		if value.Models[i].Object == "" {
+			value.Models[i].Object = "model"
+		}
+		if value.Models[i].By == "" {
+			value.Models[i].By = p.Name + "-adapter"
+		}
+	}
+	p.models.Store(&value.Models)
+}
+
+func (p *process) read(stdout io.Reader) {
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 1 {
+			p.dispatch(line)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (p *process) dispatch(line []byte) {
+	var msg struct {
+		message
+		Params json.RawMessage `json:"params,omitempty"`
+	}
+	if err := json.Unmarshal(line, &msg); err != nil {
+		logger.Warnf("[rpc:%s] invalid message: %s", p.Name, line)
+		return
+	}
+
+	switch msg.Method {
+	case "":
+		if msg.Id == nil {
+			return
+		}
+		p.deliver(*msg.Id, &msg.message)
+	case "chunk":
+		var c chunk
+		if err := json.Unmarshal(msg.Params, &c); err != nil {
+			logger.Warnf("[rpc:%s] invalid chunk: %v", p.Name, err)
+			return
+		}
+		p.deliver(c.Id, c)
+	case "log":
+		var l struct {
+			Level   string `json:"level"`
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(msg.Params, &l)
+		switch l.Level {
+		case "debug":
+			logger.Debugf("[rpc:%s] %s", p.Name, l.Message)
+		case "warn":
+			logger.Warnf("[rpc:%s] %s", p.Name, l.Message)
+		case "error":
+			logger.Errorf("[rpc:%s] %s", p.Name, l.Message)
+		default:
+			logger.Infof("[rpc:%s] %s", p.Name, l.Message)
+		}
+	}
+}
+
+func (p *process) deliver(id int64, value interface{}) {
+	v, ok := p.pending.Load(id)
+	if !ok {
+		return
+	}
+	v.(*call).push(value)
+}
+
+// 进程退出，结束全部等待中的调用
+func (p *process) fail() {
+	p.pending.Range(func(key, value interface{}) bool {
+		p.pending.Delete(key)
+		value.(*call).push(errExited)
+		return true
+	})
+}
+
+func (p *process) write(msg message) error {
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stdin == nil {
+		return errExited
+	}
+	_, err = p.stdin.Write(append(data, '\n'))
+	return err
+}
+
+// 发起调用；onChunk 在调用方 goroutine 中执行，返回 false 时取消调用
+func (p *process) call(ctx context.Context, method string, params interface{}, onChunk func(chunk) bool) (json.RawMessage, error) {
+	id := p.seq.Add(1)
+	c := &call{notify: make(chan struct{}, 1)}
+	p.pending.Store(id, c)
+	defer p.pending.Delete(id)
+
+	p.mu.Lock()
+	exited := p.exited
+	p.mu.Unlock()
+	if exited == nil {
+		return nil, errExited
+	}
+	select {
+	case <-exited:
+		return nil, errExited
+	default:
+	}
+
+	if err := p.write(message{Id: &id, Method: method, Params: params}); err != nil {
+		return nil, err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			_ = p.write(message{Method: "$/cancel", Params: map[string]int64{"id": id}})
+			return nil, ctx.Err()
+		case <-c.notify:
+		}
+
+		for _, value := range c.drain() {
+			switch v := value.(type) {
+			case error:
+				return nil, v
+			case chunk:
+				if onChunk != nil && !onChunk(v) {
+					_ = p.write(message{Method: "$/cancel", Params: map[string]int64{"id": id}})
+					return nil, nil
+				}
+			case *message:
+				if v.Error != nil {
+					return nil, v.Error
+				}
+				return v.Result, nil
+			}
+		}
+	}
+}
+
+func (p *process) kill() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cmd != nil && p.cmd.Process != nil {
+		_ = p.cmd.Process.Kill()
+	}
+}
+
+// 停止守护并结束进程，不再重启
+func (p *process) shutdown() {
+	select {
+	case <-p.stop:
+		return
+	default:
+		close(p.stop)
+	}
+
+	p.mu.Lock()
+	if p.stdin != nil {
+		_ = p.stdin.Close()
+	}
+	p.mu.Unlock()
+	p.kill()
+}
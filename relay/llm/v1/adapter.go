@@ -1,8 +1,11 @@
 package v1
 
 import (
+	"fmt"
 	"net/http"
+	"path"
 	"strings"
+	"sync/atomic"
 
 	"chatgpt-adapter/core/common"
 	"chatgpt-adapter/core/common/inited"
@@ -11,46 +14,108 @@ import (
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
 	"github.com/bincooo/emit.io"
-	"github.com/gin-gonic/gin"
 	"github.com/iocgo/sdk/env"
 )
 
 var (
 	Model  = "custom"
-	schema = make([]map[string]interface{}, 0)
+	schema atomic.Pointer[[]map[string]interface{}]
 	key    = "__custom-url__"
 	upKey  = "__custom-proxies__"
 	modKey = "__custom-model__"
 	tcKey  = "__custom-toolCall__"
+
+	protoKey = "__custom-protocol__"
+	verKey   = "__custom-version__"
 )
 
 type api struct {
 	inter.BaseAdapter
-	env *env.Environment
 }
 
 func init() {
 	inited.AddInitialized(func(env *env.Environment) {
-		llm := env.Get("custom-llm")
-		if slice, ok := llm.([]interface{}); ok {
-			for _, it := range slice {
-				item, o := it.(map[string]interface{})
-				if !o {
-					continue
-				}
-				schema = append(schema, item)
-			}
+		slice, err := parseSchema(env)
+		if err != nil {
+			logger.Error(err)
+		}
+		schema.Store(&slice)
+		rediscover(env, slice)
+	})
+
+	inited.AddReloaded(func(env *env.Environment) (func(), error) {
+		slice, err := parseSchema(env)
+		if err != nil {
+			return nil, err
 		}
+		return func() {
+			schema.Store(&slice)
+			rediscover(env, slice)
+			logger.Infof("reload custom-llm: %d", len(slice))
+		}, nil
 	})
+
+	inited.AddExited(func(env *env.Environment) {
+		rediscover(env, nil)
+	})
+}
+
+func parseSchema(env *env.Environment) (schema []map[string]interface{}, err error) {
+	schema = make([]map[string]interface{}, 0)
+	llm := env.Get("custom-llm")
+	slice, ok := llm.([]interface{})
+	if !ok {
+		return
+	}
+
+	prefixes := make(map[string]bool)
+	for i, it := range slice {
+		item, o := it.(map[string]interface{})
+		if !o {
+			continue
+		}
+		prefix, _ := item["prefix"].(string)
+		if prefix == "" {
+			err = fmt.Errorf("custom-llm[%d].prefix is empty", i)
+			continue
+		}
+		if prefixes[prefix] {
+			err = fmt.Errorf("custom-llm[%d].prefix `%s` is duplicated", i, prefix)
+			continue
+		}
+		if protocol, _ := item["protocol"].(string); protocol != "" {
+			if _, o = protocols[protocol]; !o {
+				err = fmt.Errorf("custom-llm[%d].protocol `%s` is not supported", i, protocol)
+				continue
+			}
+		}
+		if err = checkGlobs(i, item); err != nil {
+			continue
+		}
+		prefixes[prefix] = true
+		schema = append(schema, item)
+	}
+	return
 }
 
-func (*api) Match(ctx *gin.Context, model string) (ok bool, _ error) {
-	for _, it := range schema {
+func (*api) Match(ctx *inter.Context, model string) (ok bool, _ error) {
+	slice := schema.Load()
+	if slice == nil {
+		return
+	}
+	for _, it := range *slice {
 		if prefix, o := it["prefix"].(string); o && strings.HasPrefix(model, prefix+"/") {
+			if isTrue(it["strict"]) {
+				if err := available(it, model[len(prefix)+1:]); err != nil {
+					return false, err
+				}
+			}
 			ctx.Set(key, it["reversal"])
-			ctx.Set(upKey, it["proxied"] == "true")
+			ctx.Set(upKey, isTrue(it["proxied"]))
 			ctx.Set(modKey, model[len(prefix)+1:])
-			ctx.Set(tcKey, it["tc"] == "true")
+			ctx.Set(tcKey, isTrue(it["tc"]))
+			ctx.Set(protoKey, it["protocol"])
+			ctx.Set(verKey, it["api-version"])
 			ok = true
 			return
 		}
@@ -58,8 +123,24 @@ func (*api) Match(ctx *gin.Context, model string) (ok bool, _ error) {
 	return
 }
 
+func checkGlobs(i int, item map[string]interface{}) error {
+	for _, k := range []string{"models", "exclude"} {
+		for _, pattern := range globsOf(item[k]) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("custom-llm[%d].%s: %v", i, k, err)
+			}
+		}
+	}
+	return nil
+}
+
+// 兼容 yaml 中的 true 与 "true"
+func isTrue(value interface{}) bool {
+	return value == true || value == "true"
+}
+
 func (*api) Models() []model.Model {
-	return []model.Model{
+	models := []model.Model{
 		{
 			Id:      "custom",
 			Object:  "model",
@@ -67,11 +148,48 @@ func (*api) Models() []model.Model {
 			By:      "custom-adapter",
 		},
 	}
+	if slice := schema.Load(); slice != nil {
+		models = append(models, discoveredModels(*slice)...)
+	}
+	return models
+}
+
+// tc 开启时工具调用由提示词模拟，否则直接转发给上游
+func (*api) Capabilities(mod string) *model.Capabilities {
+	slice := schema.Load()
+	if slice == nil {
+		return nil
+	}
+	for _, it := range *slice {
+		prefix, _ := it["prefix"].(string)
+		if !strings.HasPrefix(mod, prefix+"/") {
+			continue
+		}
+
+		caps := &model.Capabilities{
+			Input:  []string{model.ModalityText, model.ModalityImage},
+			Output: []string{model.ModalityText},
+			Tools:  model.ToolsNative,
+		}
+		if isTrue(it["tc"]) {
+			caps.Tools = model.ToolsEmulated
+		}
+		if p, ok := protocols[stringOf(it["protocol"])]; !ok || p.embed != nil {
+			caps.Output = append(caps.Output, model.ModalityEmbedding)
+		}
+
+		// 配置优先，未配置时按上游模型名查询
+		caps.ContextWindow, caps.MaxOutput = model.LimitsOf(mod[len(prefix)+1:])
+		caps.ContextWindow = intOf(it["context-window"], caps.ContextWindow)
+		caps.MaxOutput = intOf(it["max-output"], caps.MaxOutput)
+		return caps
+	}
+	return nil
 }
 
-func (api *api) ToolChoice(ctx *gin.Context) (ok bool, err error) {
+func (api *api) ToolChoice(ctx *inter.Context) (ok bool, err error) {
 	var (
-		proxies    = api.env.GetString("server.proxied")
+		proxies    = inited.Env().GetString("server.proxied")
 		completion = common.GetGinCompletion(ctx)
 	)
 	if !ctx.GetBool(tcKey) {
@@ -83,57 +201,65 @@ func (api *api) ToolChoice(ctx *gin.Context) (ok bool, err error) {
 	return
 }
 
-func (api *api) Completion(ctx *gin.Context) (err error) {
+func (api *api) Completion(ctx *inter.Context) (err error) {
 	var (
-		cookie     = ctx.GetString("token")
-		proxies    = api.env.GetString("server.proxied")
+		cookie     = common.GetGinToken(ctx)
+		proxies    = inited.Env().GetString("server.proxied")
 		completion = common.GetGinCompletion(ctx)
 	)
 
 	r, err := fetch(ctx, proxies, cookie, completion)
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return
 	}
 
 	defer r.Body.Close()
 	content := waitResponse(ctx, r, completion.Stream)
 	if content == "" && response.NotResponse(ctx) {
-		logger.Error("EMPTY RESPONSE")
+		logger.Ctx(ctx).Error("EMPTY RESPONSE")
 	}
 	return
 }
 
-func (api *api) Embedding(ctx *gin.Context) (err error) {
+func (api *api) Embedding(ctx *inter.Context) (err error) {
 	embedding := common.GetGinEmbedding(ctx)
 	embedding.Model = ctx.GetString(modKey)
 	var (
-		token   = ctx.GetString("token")
-		proxies = api.env.GetString("proxied")
-		baseUrl = ctx.GetString(key)
+		token   = common.GetGinToken(ctx)
+		proxies = inited.Env().GetString("proxied")
 	)
 	if !ctx.GetBool(upKey) {
 		proxies = ""
 	}
 
-	resp, err := emit.ClientBuilder(common.HTTPClient).
+	up, p := upstreamOf(ctx, token)
+	if p.embed == nil {
+		return fmt.Errorf("protocol `%s` does not support embeddings", ctx.GetString(protoKey))
+	}
+
+	builder, err := p.embed(emit.ClientBuilder(common.HTTPClient).
 		Proxies(proxies).
-		Context(ctx).
-		POST(baseUrl+"/embeddings").
-		Header("Authorization", "Bearer "+token).
-		JSONHeader().
-		Body(embedding).DoC(emit.Status(http.StatusOK), emit.IsJSON)
+		Context(ctx), up, embedding)
+	if err != nil {
+		return
+	}
+
+	resp, err := builder.DoC(emit.Status(http.StatusOK), emit.IsJSON)
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return
 	}
 
 	obj, err := emit.ToMap(resp)
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, obj)
+	if p.embedded != nil {
+		obj = p.embedded(obj, embedding)
+	}
+	response.Forward(ctx, http.StatusOK, obj)
 	return
 }
@@ -0,0 +1,225 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"chatgpt-adapter/core/common/toolcall"
+	"chatgpt-adapter/core/gin/model"
+	"github.com/bincooo/emit.io"
+)
+
+// Anthropic Messages API
+//
+//	https://docs.anthropic.com/en/api/messages
+func anthropicChat(builder *emit.Builder, up upstream, completion model.Completion, _ map[string]interface{}) *emit.Builder {
+	version := up.version
+	if version == "" {
+		version = "2023-06-01"
+	}
+
+	var (
+		system   []string
+		messages []map[string]interface{}
+	)
+
+	// 连续同角色的消息合并，工具结果以 user 角色回传
+	push := func(role string, blocks ...interface{}) {
+		if len(blocks) == 0 {
+			return
+		}
+		if n := len(messages); n > 0 && messages[n-1]["role"] == role {
+			messages[n-1]["content"] = append(messages[n-1]["content"].([]interface{}), blocks...)
+			return
+		}
+		messages = append(messages, map[string]interface{}{"role": role, "content": blocks})
+	}
+
+	for _, message := range completion.Messages {
+		switch message.GetString("role") {
+		case "system", "developer":
+			system = append(system, contentText(message))
+		case "tool":
+			push("user", map[string]interface{}{
+				"type":        "tool_result",
+				"tool_use_id": message.GetString("tool_call_id"),
+				"content":     contentText(message),
+			})
+		case "assistant":
+			var blocks []interface{}
+			if text := contentText(message); text != "" {
+				blocks = append(blocks, map[string]interface{}{"type": "text", "text": text})
+			}
+			for _, call := range toolCalls(message) {
+				blocks = append(blocks, map[string]interface{}{
+					"type":  "tool_use",
+					"id":    call.id,
+					"name":  call.name,
+					"input": call.args,
+				})
+			}
+			push("assistant", blocks...)
+		default:
+			push("user", anthropicBlocks(message)...)
+		}
+	}
+
+	obj := map[string]interface{}{
+		"model":      up.model,
+		"messages":   messages,
+		"max_tokens": completion.MaxTokens,
+		"stream":     true,
+	}
+	if len(system) > 0 {
+		obj["system"] = strings.Join(system, "\n\n")
+	}
+	if completion.Temperature != nil {
+		obj["temperature"] = *completion.Temperature
+	}
+	if completion.TopK > 0 {
+		obj["top_k"] = completion.TopK
+	}
+	if len(completion.StopSequences) > 0 {
+		obj["stop_sequences"] = completion.StopSequences
+	}
+
+	if functions := toolFunctions(completion.Tools); len(functions) > 0 {
+		tools := make([]interface{}, 0, len(functions))
+		for _, fn := range functions {
+			schema := fn.GetKeyv("parameters")
+			if schema == nil {
+				schema = model.Keyv[interface{}]{"type": "object"}
+			}
+			tools = append(tools, map[string]interface{}{
+				"name":         fn.GetString("name"),
+				"description":  fn.GetString("description"),
+				"input_schema": schema,
+			})
+		}
+		obj["tools"] = tools
+
+		switch mode, name := toolcall.ChoiceOf(completion); mode {
+		case toolcall.ChoiceAuto:
+			obj["tool_choice"] = map[string]interface{}{"type": "auto"}
+		case toolcall.ChoiceRequired:
+			obj["tool_choice"] = map[string]interface{}{"type": "any"}
+		case toolcall.ChoiceNone:
+			obj["tool_choice"] = map[string]interface{}{"type": "none"}
+		case toolcall.ChoiceFunction:
+			obj["tool_choice"] = map[string]interface{}{"type": "tool", "name": name}
+		}
+	}
+
+	return builder.POST(up.baseUrl+"/messages").
+		Header("x-api-key", up.token).
+		Header("anthropic-version", version).
+		JSONHeader().
+		Body(obj)
+}
+
+func anthropicModels(builder *emit.Builder, up upstream) ([]upstreamModel, error) {
+	version := up.version
+	if version == "" {
+		version = "2023-06-01"
+	}
+	return listModels(builder.GET(up.baseUrl+"/models").
+		Query("limit", "1000").
+		Header("x-api-key", up.token).
+		Header("anthropic-version", version), "data", "id", "created_at")
+}
+
+func anthropicBlocks(message model.Keyv[interface{}]) (blocks []interface{}) {
+	for _, part := range contentParts(message) {
+		switch part["type"] {
+		case "text":
+			blocks = append(blocks, map[string]interface{}{"type": "text", "text": part["text"]})
+		case "image_url":
+			url := imageUrl(part)
+			if mime, data, ok := dataUrl(url); ok {
+				blocks = append(blocks, map[string]interface{}{
+					"type":   "image",
+					"source": map[string]interface{}{"type": "base64", "media_type": mime, "data": data},
+				})
+				continue
+			}
+			blocks = append(blocks, map[string]interface{}{
+				"type":   "image",
+				"source": map[string]interface{}{"type": "url", "url": url},
+			})
+		}
+	}
+	return
+}
+
+func anthropicTranslate(r io.Reader, w *chunkWriter) error {
+	var (
+		prompt     int
+		completion int
+		reason     = "stop"
+	)
+
+	return scanEvents(r, func(data []byte) error {
+		var event struct {
+			Type    string `json:"type"`
+			Message struct {
+				Usage struct {
+					InputTokens int `json:"input_tokens"`
+				} `json:"usage"`
+			} `json:"message"`
+			ContentBlock struct {
+				Type string `json:"type"`
+				Id   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"content_block"`
+			Delta struct {
+				Type        string `json:"type"`
+				Text        string `json:"text"`
+				Thinking    string `json:"thinking"`
+				PartialJson string `json:"partial_json"`
+				StopReason  string `json:"stop_reason"`
+			} `json:"delta"`
+			Usage struct {
+				OutputTokens int `json:"output_tokens"`
+			} `json:"usage"`
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil
+		}
+
+		switch event.Type {
+		case "message_start":
+			prompt = event.Message.Usage.InputTokens
+		case "content_block_start":
+			if event.ContentBlock.Type == "tool_use" {
+				return w.toolCall(event.ContentBlock.Id, event.ContentBlock.Name, "")
+			}
+		case "content_block_delta":
+			switch event.Delta.Type {
+			case "text_delta":
+				return w.content(event.Delta.Text, "")
+			case "thinking_delta":
+				return w.content("", event.Delta.Thinking)
+			case "input_json_delta":
+				return w.toolArgs(event.Delta.PartialJson)
+			}
+		case "message_delta":
+			completion = event.Usage.OutputTokens
+			switch event.Delta.StopReason {
+			case "max_tokens":
+				reason = "length"
+			case "tool_use":
+				reason = "tool_calls"
+			}
+		case "message_stop":
+			return w.finish(reason, prompt, completion)
+		case "error":
+			return errors.New(event.Error.Message)
+		}
+		return nil
+	})
+}
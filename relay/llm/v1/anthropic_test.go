@@ -0,0 +1,98 @@
+package v1
+
+import "testing"
+
+func TestAnthropicRequest(t *testing.T) {
+	for _, c := range []struct {
+		choice string
+		want   string
+	}{
+		{`"auto"`, `{"type":"auto"}`},
+		{`"required"`, `{"type":"any"}`},
+		{`"none"`, `{"type":"none"}`},
+		{`{"type":"function","function":{"name":"get_weather"}}`, `{"type":"tool","name":"get_weather"}`},
+	} {
+		req, _ := roundTrip(t, protoAnthropic, withChoice(c.choice), "text/event-stream", "")
+		assertJSON(t, "tool_choice "+c.choice, req.body["tool_choice"], c.want)
+	}
+
+	req, _ := roundTrip(t, protoAnthropic, chatRequest, "text/event-stream", "")
+	assertEqual(t, "path", req.path, "/messages")
+	assertEqual(t, "x-api-key", req.header.Get("x-api-key"), "secret")
+	assertEqual(t, "anthropic-version", req.header.Get("anthropic-version"), "2023-06-01")
+	assertEqual(t, "model", req.body.GetString("model"), "test-model")
+	assertEqual(t, "system", req.body.GetString("system"), "be brief")
+	assertJSON(t, "max_tokens", req.body["max_tokens"], `256`)
+	assertJSON(t, "stream", req.body["stream"], `true`)
+	assertJSON(t, "messages", req.body["messages"], `[
+		{"role": "user", "content": [
+			{"type": "text", "text": "weather?"},
+			{"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "iVBOR"}}
+		]},
+		{"role": "assistant", "content": [
+			{"type": "tool_use", "id": "call_1", "name": "get_weather", "input": {"city": "Paris"}}
+		]},
+		{"role": "user", "content": [
+			{"type": "tool_result", "tool_use_id": "call_1", "content": "{\"temp\":21}"}
+		]}
+	]`)
+	assertJSON(t, "tools", req.body["tools"], `[{
+		"name": "get_weather",
+		"description": "query weather",
+		"input_schema": {"type": "object", "properties": {"city": {"type": "string"}}}
+	}]`)
+}
+
+func TestAnthropicStream(t *testing.T) {
+	stream := `event: message_start
+data: {"type":"message_start","message":{"usage":{"input_tokens":12}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"thinking"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"hmm"}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Let me "}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"check."}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":2,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":7}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+	_, out := roundTrip(t, protoAnthropic, chatRequest, "text/event-stream", stream)
+	assertEqual(t, "content", out.content, "Let me check.")
+	assertEqual(t, "reasoning", out.reasoning, "hmm")
+	if len(out.calls) != 1 {
+		t.Fatalf("tool calls: got %d, want 1", len(out.calls))
+	}
+	assertEqual(t, "call", out.calls[0], streamedCall{"toolu_1", "get_weather", `{"city":"Paris"}`})
+	assertEqual(t, "finish_reason", out.finish, "tool_calls")
+	assertJSON(t, "usage", out.usage, `{"prompt_tokens":12,"completion_tokens":7,"total_tokens":19}`)
+	assertEqual(t, "[DONE]", out.done, true)
+}
+
+func TestAnthropicStreamError(t *testing.T) {
+	stream := "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n"
+	if _, err := translateStream(protoAnthropic, stream); err == nil || err.Error() != "Overloaded" {
+		t.Errorf("error: got %v, want Overloaded", err)
+	}
+}
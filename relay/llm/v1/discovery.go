@@ -0,0 +1,218 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"chatgpt-adapter/core/common"
+	"chatgpt-adapter/core/gin/model"
+	"chatgpt-adapter/core/logger"
+	"github.com/bincooo/emit.io"
+	"github.com/iocgo/sdk/env"
+)
+
+// 定期查询各 custom-llm 上游的模型列表，以 `{prefix}/{model}` 出现在 /v1/models 中
+//
+//	custom-llm:
+//	  - prefix: openai
+//	    reversal: https://api.openai.com/v1
+//	    token: sk-xxx          # 查询模型列表使用的凭证，为空则不携带
+//	    models: [ "gpt-4*" ]   # 保留的模型，glob，为空则全部
+//	    exclude: [ "*-audio*" ] # 排除的模型，glob
+//	    refresh: 600           # 刷新间隔 s，默认 600，-1 不查询
+//	    strict: true           # 拒绝列表外的模型
+var (
+	discovered atomic.Pointer[sync.Map] // prefix => []upstreamModel
+
+	discoverMu     sync.Mutex
+	discoverCancel context.CancelFunc
+)
+
+// 配置变更后重新开始查询，已有结果保留至新结果返回
+func rediscover(env *env.Environment, slice []map[string]interface{}) {
+	discoverMu.Lock()
+	defer discoverMu.Unlock()
+	if discoverCancel != nil {
+		discoverCancel()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	discoverCancel = cancel
+
+	cache := new(sync.Map)
+	older := discovered.Load()
+	for _, item := range slice {
+		prefix := item["prefix"].(string)
+		if older != nil {
+			if value, ok := older.Load(prefix); ok {
+				cache.Store(prefix, value)
+			}
+		}
+
+		refresh := intOf(item["refresh"], 600)
+		if refresh < 0 {
+			continue
+		}
+		if _, ok := protocolOf(item); !ok {
+			continue
+		}
+		go discover(ctx, env, cache, item, time.Duration(refresh)*time.Second)
+	}
+	discovered.Store(cache)
+}
+
+func discover(ctx context.Context, env *env.Environment, cache *sync.Map, item map[string]interface{}, refresh time.Duration) {
+	prefix := item["prefix"].(string)
+	p, _ := protocolOf(item)
+	proxies := ""
+	if isTrue(item["proxied"]) {
+		proxies = env.GetString("server.proxied")
+	}
+
+	up := upstream{
+		baseUrl: strings.TrimSuffix(fmt.Sprint(item["reversal"]), "/"),
+		version: stringOf(item["api-version"]),
+		token:   stringOf(item["token"]),
+	}
+	for {
+		timeout, cancel := context.WithTimeout(ctx, 30*time.Second)
+		models, err := p.models(emit.ClientBuilder(common.HTTPClient).
+			Proxies(proxies).
+			Context(timeout), up)
+		cancel()
+
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Ctx(ctx).Warnf("custom-llm `%s` list models failed: %v", prefix, err)
+		} else {
+			now := int(time.Now().Unix())
+			for i := range models {
+				if models[i].created == 0 {
+					models[i].created = now
+				}
+			}
+			cache.Store(prefix, models)
+			logger.Ctx(ctx).Infof("custom-llm `%s` discovered %d models", prefix, len(models))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(refresh):
+		}
+	}
+}
+
+func protocolOf(item map[string]interface{}) (p protocol, ok bool) {
+	name := stringOf(item["protocol"])
+	if name == "" {
+		name = protoOpenAI
+	}
+	p = protocols[name]
+	return p, p.models != nil
+}
+
+// 已查询到的模型，经 models、exclude 过滤
+func discoveredOf(item map[string]interface{}) (models []upstreamModel, ok bool) {
+	cache := discovered.Load()
+	if cache == nil {
+		return
+	}
+	value, ok := cache.Load(item["prefix"])
+	if !ok {
+		return
+	}
+	for _, mod := range value.([]upstreamModel) {
+		if allowed(item, mod.id) {
+			models = append(models, mod)
+		}
+	}
+	return
+}
+
+func allowed(item map[string]interface{}, mod string) bool {
+	if include := globsOf(item["models"]); len(include) > 0 && !matchGlobs(include, mod) {
+		return false
+	}
+	return !matchGlobs(globsOf(item["exclude"]), mod)
+}
+
+// strict 模式下校验模型是否可用
+func available(item map[string]interface{}, mod string) error {
+	if !allowed(item, mod) {
+		return fmt.Errorf("model '%s/%s' is not allowed", item["prefix"], mod)
+	}
+
+	models, ok := discoveredOf(item)
+	if !ok || slices.ContainsFunc(models, func(m upstreamModel) bool { return m.id == mod }) {
+		return nil
+	}
+
+	var hint []string
+	for _, m := range models[:min(len(models), 20)] {
+		hint = append(hint, fmt.Sprintf("%s/%s", item["prefix"], m.id))
+	}
+	return fmt.Errorf("model '%s/%s' is not found, available models: [ %s ]", item["prefix"], mod, strings.Join(hint, ", "))
+}
+
+func discoveredModels(slice []map[string]interface{}) (models []model.Model) {
+	for _, item := range slice {
+		values, _ := discoveredOf(item)
+		for _, mod := range values {
+			models = append(models, model.Model{
+				Id:      fmt.Sprintf("%s/%s", item["prefix"], mod.id),
+				Object:  "model",
+				Created: mod.created,
+				By:      "custom-adapter",
+			})
+		}
+	}
+	return
+}
+
+func matchGlobs(patterns []string, mod string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, mod); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func globsOf(value interface{}) (globs []string) {
+	slice, _ := value.([]interface{})
+	for _, it := range slice {
+		if str, ok := it.(string); ok {
+			globs = append(globs, str)
+		}
+	}
+	return
+}
+
+func stringOf(value interface{}) string {
+	str, _ := value.(string)
+	return str
+}
+
+func intOf(value interface{}, defaultValue int) int {
+	switch v := value.(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	case string:
+		if i, err := strconv.Atoi(v); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
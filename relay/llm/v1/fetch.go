@@ -1,6 +1,7 @@
 package v1
 
 import (
+	"chatgpt-adapter/core/gin/inter"
 	"encoding/json"
 	"net/http"
 
@@ -8,14 +9,10 @@ import (
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"github.com/bincooo/emit.io"
-	"github.com/gin-gonic/gin"
 )
 
-func fetch(ctx *gin.Context, proxies, token string, completion model.Completion) (r *http.Response, err error) {
-	var (
-		baseUrl = ctx.GetString(key)
-	)
-
+func fetch(ctx *inter.Context, proxies, token string, completion model.Completion) (r *http.Response, err error) {
+	up, p := upstreamOf(ctx, token)
 	if !ctx.GetBool(upKey) {
 		proxies = ""
 	}
@@ -24,8 +21,9 @@ func fetch(ctx *gin.Context, proxies, token string, completion model.Completion)
 		completion.TopP = 1
 	}
 
-	if completion.Temperature == 0 {
-		completion.Temperature = 0.7
+	if completion.Temperature == nil {
+		temperature := float32(0.7)
+		completion.Temperature = &temperature
 	}
 
 	if completion.MaxTokens == 0 {
@@ -36,7 +34,7 @@ func fetch(ctx *gin.Context, proxies, token string, completion model.Completion)
 	for _, message := range completion.Messages {
 		tokens += response.CalcTokens(message.GetString("content"))
 	}
-	ctx.Set(ginTokens, token)
+	common.SetGinTokens(ctx, tokens)
 
 	completion.Stream = true
 	completion.Model = ctx.GetString(modKey)
@@ -49,14 +47,16 @@ func fetch(ctx *gin.Context, proxies, token string, completion model.Completion)
 		delete(obj, "top_k")
 	}
 
-	r, err = emit.ClientBuilder(common.HTTPClient).
+	builder := emit.ClientBuilder(common.HTTPClient).
 		Proxies(proxies).
-		Context(ctx).
-		POST(baseUrl+"/chat/completions").
-		Header("Authorization", "Bearer "+token).
-		JSONHeader().
-		Body(obj).
-		DoC(emit.Status(http.StatusOK), emit.IsSTREAM)
+		Context(ctx)
+	r, err = p.chat(builder, up, completion, obj).
+		DoC(emit.Status(http.StatusOK), p.accept)
+	if err != nil {
+		return
+	}
+
+	translate(r, p)
 	return
 }
 
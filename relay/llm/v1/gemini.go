@@ -0,0 +1,276 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"chatgpt-adapter/core/common/toolcall"
+	"chatgpt-adapter/core/gin/model"
+	"github.com/bincooo/emit.io"
+)
+
+// Gemini generateContent API
+//
+//	https://ai.google.dev/api/generate-content
+func geminiChat(builder *emit.Builder, up upstream, completion model.Completion, _ map[string]interface{}) *emit.Builder {
+	var (
+		system   []string
+		contents []map[string]interface{}
+		names    = make(map[string]string) // tool_call_id => name
+	)
+
+	// 连续同角色的消息合并
+	push := func(role string, parts ...interface{}) {
+		if len(parts) == 0 {
+			return
+		}
+		if n := len(contents); n > 0 && contents[n-1]["role"] == role {
+			contents[n-1]["parts"] = append(contents[n-1]["parts"].([]interface{}), parts...)
+			return
+		}
+		contents = append(contents, map[string]interface{}{"role": role, "parts": parts})
+	}
+
+	for _, message := range completion.Messages {
+		switch message.GetString("role") {
+		case "system", "developer":
+			system = append(system, contentText(message))
+		case "tool":
+			name := message.GetString("name")
+			if name == "" {
+				name = names[message.GetString("tool_call_id")]
+			}
+			// response 须为对象
+			var result interface{}
+			text := contentText(message)
+			if err := json.Unmarshal([]byte(text), &result); err != nil {
+				result = text
+			}
+			if _, ok := result.(map[string]interface{}); !ok {
+				result = map[string]interface{}{"content": result}
+			}
+			push("user", map[string]interface{}{
+				"functionResponse": map[string]interface{}{"name": name, "response": result},
+			})
+		case "assistant":
+			var parts []interface{}
+			if text := contentText(message); text != "" {
+				parts = append(parts, map[string]interface{}{"text": text})
+			}
+			for _, call := range toolCalls(message) {
+				names[call.id] = call.name
+				parts = append(parts, map[string]interface{}{
+					"functionCall": map[string]interface{}{"name": call.name, "args": call.args},
+				})
+			}
+			push("model", parts...)
+		default:
+			push("user", geminiParts(message)...)
+		}
+	}
+
+	config := map[string]interface{}{
+		"maxOutputTokens": completion.MaxTokens,
+		"topP":            completion.TopP,
+	}
+	if completion.Temperature != nil {
+		config["temperature"] = *completion.Temperature
+	}
+	if completion.TopK > 0 {
+		config["topK"] = completion.TopK
+	}
+	if len(completion.StopSequences) > 0 {
+		config["stopSequences"] = completion.StopSequences
+	}
+
+	obj := map[string]interface{}{
+		"contents":         contents,
+		"generationConfig": config,
+	}
+	if len(system) > 0 {
+		obj["systemInstruction"] = map[string]interface{}{
+			"parts": []interface{}{map[string]interface{}{"text": strings.Join(system, "\n\n")}},
+		}
+	}
+
+	if functions := toolFunctions(completion.Tools); len(functions) > 0 {
+		declarations := make([]interface{}, 0, len(functions))
+		for _, fn := range functions {
+			declaration := map[string]interface{}{
+				"name":        fn.GetString("name"),
+				"description": fn.GetString("description"),
+			}
+			// 无参函数不能携带空的 properties
+			if parameters := fn.GetKeyv("parameters"); len(parameters.GetKeyv("properties")) > 0 {
+				declaration["parameters"] = parameters
+			}
+			declarations = append(declarations, declaration)
+		}
+		obj["tools"] = []interface{}{map[string]interface{}{"functionDeclarations": declarations}}
+
+		var calling map[string]interface{}
+		switch mode, name := toolcall.ChoiceOf(completion); mode {
+		case toolcall.ChoiceAuto:
+			calling = map[string]interface{}{"mode": "AUTO"}
+		case toolcall.ChoiceRequired:
+			calling = map[string]interface{}{"mode": "ANY"}
+		case toolcall.ChoiceNone:
+			calling = map[string]interface{}{"mode": "NONE"}
+		case toolcall.ChoiceFunction:
+			calling = map[string]interface{}{"mode": "ANY", "allowedFunctionNames": []string{name}}
+		}
+		if calling != nil {
+			obj["toolConfig"] = map[string]interface{}{"functionCallingConfig": calling}
+		}
+	}
+
+	return builder.POST(up.baseUrl+"/models/"+up.model+":streamGenerateContent").
+		Query("alt", "sse").
+		Header("x-goog-api-key", up.token).
+		JSONHeader().
+		Body(obj)
+}
+
+func geminiParts(message model.Keyv[interface{}]) (parts []interface{}) {
+	for _, part := range contentParts(message) {
+		switch part["type"] {
+		case "text":
+			parts = append(parts, map[string]interface{}{"text": part["text"]})
+		case "image_url":
+			url := imageUrl(part)
+			if mime, data, ok := dataUrl(url); ok {
+				parts = append(parts, map[string]interface{}{
+					"inlineData": map[string]interface{}{"mimeType": mime, "data": data},
+				})
+				continue
+			}
+			parts = append(parts, map[string]interface{}{
+				"fileData": map[string]interface{}{"mimeType": "image/jpeg", "fileUri": url},
+			})
+		}
+	}
+	return
+}
+
+func geminiTranslate(r io.Reader, w *chunkWriter) error {
+	var (
+		prompt     int
+		completion int
+		reason     = "stop"
+	)
+
+	err := scanEvents(r, func(data []byte) error {
+		var chunk struct {
+			Candidates []struct {
+				Content struct {
+					Parts []struct {
+						Text         string `json:"text"`
+						Thought      bool   `json:"thought"`
+						FunctionCall *struct {
+							Id   string      `json:"id"`
+							Name string      `json:"name"`
+							Args interface{} `json:"args"`
+						} `json:"functionCall"`
+					} `json:"parts"`
+				} `json:"content"`
+				FinishReason string `json:"finishReason"`
+			} `json:"candidates"`
+			UsageMetadata struct {
+				PromptTokenCount     int `json:"promptTokenCount"`
+				CandidatesTokenCount int `json:"candidatesTokenCount"`
+				ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+			} `json:"usageMetadata"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return nil
+		}
+		if chunk.Error != nil {
+			return errors.New(chunk.Error.Message)
+		}
+
+		if usage := chunk.UsageMetadata; usage.PromptTokenCount > 0 {
+			prompt = usage.PromptTokenCount
+			completion = usage.CandidatesTokenCount + usage.ThoughtsTokenCount
+		}
+		if len(chunk.Candidates) == 0 {
+			return nil
+		}
+
+		candidate := chunk.Candidates[0]
+		for _, part := range candidate.Content.Parts {
+			var err error
+			switch {
+			case part.FunctionCall != nil:
+				// gemini 一次返回完整的参数
+				args, _ := json.Marshal(part.FunctionCall.Args)
+				err = w.toolCall(part.FunctionCall.Id, part.FunctionCall.Name, string(args))
+			case part.Thought:
+				err = w.content("", part.Text)
+			default:
+				err = w.content(part.Text, "")
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if candidate.FinishReason == "MAX_TOKENS" {
+			reason = "length"
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return w.finish(reason, prompt, completion)
+}
+
+func geminiEmbed(builder *emit.Builder, up upstream, embed model.Embed) (*emit.Builder, error) {
+	inputs, err := embedInputs(embed)
+	if err != nil {
+		return nil, err
+	}
+
+	requests := make([]interface{}, 0, len(inputs))
+	for _, input := range inputs {
+		request := map[string]interface{}{
+			"model":   "models/" + up.model,
+			"content": map[string]interface{}{"parts": []interface{}{map[string]interface{}{"text": input}}},
+		}
+		if embed.Dimensions > 0 {
+			request["outputDimensionality"] = embed.Dimensions
+		}
+		requests = append(requests, request)
+	}
+
+	return builder.POST(up.baseUrl+"/models/"+up.model+":batchEmbedContents").
+		Header("x-goog-api-key", up.token).
+		JSONHeader().
+		Body(map[string]interface{}{"requests": requests}), nil
+}
+
+func geminiEmbedded(obj map[string]interface{}, embed model.Embed) map[string]interface{} {
+	var vectors []interface{}
+	for _, it := range model.Keyv[interface{}](obj).GetSlice("embeddings") {
+		if value, ok := it.(map[string]interface{}); ok {
+			vectors = append(vectors, value["values"])
+		}
+	}
+	return embeddingsResponse(embed, vectors, 0)
+}
+
+// 列表不含创建时间
+func geminiModels(builder *emit.Builder, up upstream) (models []upstreamModel, err error) {
+	models, err = listModels(builder.GET(up.baseUrl+"/models").
+		Query("pageSize", "1000").
+		Header("x-goog-api-key", up.token), "models", "name", "")
+	for i := range models {
+		models[i].id = strings.TrimPrefix(models[i].id, "models/")
+	}
+	return
+}
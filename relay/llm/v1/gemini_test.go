@@ -0,0 +1,84 @@
+package v1
+
+import "testing"
+
+func TestGeminiRequest(t *testing.T) {
+	for _, c := range []struct {
+		choice string
+		want   string
+	}{
+		{`"auto"`, `{"functionCallingConfig":{"mode":"AUTO"}}`},
+		{`"required"`, `{"functionCallingConfig":{"mode":"ANY"}}`},
+		{`"none"`, `{"functionCallingConfig":{"mode":"NONE"}}`},
+		{`{"type":"function","function":{"name":"get_weather"}}`, `{"functionCallingConfig":{"mode":"ANY","allowedFunctionNames":["get_weather"]}}`},
+	} {
+		req, _ := roundTrip(t, protoGemini, withChoice(c.choice), "text/event-stream", "")
+		assertJSON(t, "toolConfig "+c.choice, req.body["toolConfig"], c.want)
+	}
+
+	req, _ := roundTrip(t, protoGemini, chatRequest, "text/event-stream", "")
+	assertEqual(t, "path", req.path, "/models/test-model:streamGenerateContent")
+	assertEqual(t, "query", req.query, "alt=sse")
+	assertEqual(t, "x-goog-api-key", req.header.Get("x-goog-api-key"), "secret")
+	assertJSON(t, "systemInstruction", req.body["systemInstruction"], `{"parts":[{"text":"be brief"}]}`)
+	assertJSON(t, "generationConfig", req.body["generationConfig"], `{"maxOutputTokens":256,"temperature":0.5,"topP":1}`)
+	// functionResponse 的名称由 tool_call_id 找回，非对象结果包装为 {content}
+	assertJSON(t, "contents", req.body["contents"], `[
+		{"role": "user", "parts": [
+			{"text": "weather?"},
+			{"inlineData": {"mimeType": "image/png", "data": "iVBOR"}}
+		]},
+		{"role": "model", "parts": [
+			{"functionCall": {"name": "get_weather", "args": {"city": "Paris"}}}
+		]},
+		{"role": "user", "parts": [
+			{"functionResponse": {"name": "get_weather", "response": {"temp": 21}}}
+		]}
+	]`)
+	assertJSON(t, "tools", req.body["tools"], `[{"functionDeclarations": [{
+		"name": "get_weather",
+		"description": "query weather",
+		"parameters": {"type": "object", "properties": {"city": {"type": "string"}}}
+	}]}]`)
+}
+
+func TestGeminiStream(t *testing.T) {
+	stream := `data: {"candidates":[{"content":{"role":"model","parts":[{"text":"plan","thought":true}]}}]}
+
+data: {"candidates":[{"content":{"role":"model","parts":[{"text":"Let me "}]}}]}
+
+data: {"candidates":[{"content":{"role":"model","parts":[{"text":"check."},{"functionCall":{"name":"get_weather","args":{"city":"Paris"}}}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":9,"candidatesTokenCount":4,"thoughtsTokenCount":2}}
+
+`
+	_, out := roundTrip(t, protoGemini, chatRequest, "text/event-stream", stream)
+	assertEqual(t, "content", out.content, "Let me check.")
+	assertEqual(t, "reasoning", out.reasoning, "plan")
+	if len(out.calls) != 1 {
+		t.Fatalf("tool calls: got %d, want 1", len(out.calls))
+	}
+	// gemini 不返回调用 id 时自动生成
+	if out.calls[0].id == "" {
+		t.Error("tool call id is empty")
+	}
+	assertEqual(t, "name", out.calls[0].name, "get_weather")
+	assertEqual(t, "args", out.calls[0].args, `{"city":"Paris"}`)
+	assertEqual(t, "finish_reason", out.finish, "tool_calls")
+	assertJSON(t, "usage", out.usage, `{"prompt_tokens":9,"completion_tokens":6,"total_tokens":15}`)
+	assertEqual(t, "[DONE]", out.done, true)
+}
+
+func TestGeminiStreamLength(t *testing.T) {
+	stream := `data: {"candidates":[{"content":{"role":"model","parts":[{"text":"truncated"}]},"finishReason":"MAX_TOKENS"}]}
+
+`
+	_, out := roundTrip(t, protoGemini, chatRequest, "text/event-stream", stream)
+	assertEqual(t, "content", out.content, "truncated")
+	assertEqual(t, "finish_reason", out.finish, "length")
+}
+
+func TestGeminiStreamError(t *testing.T) {
+	stream := "data: {\"error\":{\"code\":429,\"message\":\"Resource exhausted\"}}\n\n"
+	if _, err := translateStream(protoGemini, stream); err == nil || err.Error() != "Resource exhausted" {
+		t.Errorf("error: got %v, want Resource exhausted", err)
+	}
+}
@@ -2,9 +2,9 @@ package v1
 
 import (
 	"bufio"
+	"chatgpt-adapter/core/gin/inter"
 	"encoding/json"
 	"net/http"
-	"sync"
 	"time"
 
 	"chatgpt-adapter/core/common"
@@ -13,11 +13,8 @@ import (
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
-	"github.com/gin-gonic/gin"
 )
 
-const ginTokens = "__tokens__"
-
 func waitMessage(r *http.Response, cancel func(str string) bool) (content string, err error) {
 	defer r.Body.Close()
 
@@ -74,39 +71,34 @@ func waitMessage(r *http.Response, cancel func(str string) bool) (content string
 	return content, nil
 }
 
-func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string) {
+func waitResponse(ctx *inter.Context, r *http.Response, sse bool) (content string) {
 	defer r.Body.Close()
 
 	var (
 		matchers = common.GetGinMatchers(ctx)
 	)
 
-	logger.Info("waitResponse ...")
-	tokens := ctx.GetInt(ginTokens)
+	logger.Ctx(ctx).Info("waitResponse ...")
+	tokens := common.GetGinTokens(ctx)
 	completion := common.GetGinCompletion(ctx)
 	toolId := common.GetGinToolValue(ctx).GetString("id")
 	toolId = toolcall.Query(toolId, completion.Tools)
 	var toolCall map[string]interface{}
 	htc := false
-
-	onceExec := sync.OnceFunc(func() {
-		if !sse {
-			ctx.Writer.WriteHeader(http.StatusOK)
-		}
-	})
+	args := ""
 
 	scanner := bufio.NewScanner(r.Body)
 	for {
 		if !scanner.Scan() {
 			if err := scanner.Err(); err != nil {
-				logger.Error(err)
+				logger.Ctx(ctx).Error(err)
 			}
 			break
 		}
 
 		data := scanner.Text()
-		logger.Tracef("--------- ORIGINAL MESSAGE ---------")
-		logger.Tracef("%s", data)
+		logger.Ctx(ctx).Tracef("--------- ORIGINAL MESSAGE ---------")
+		logger.Ctx(ctx).Tracef("%s", data)
 
 		if len(data) < 6 || data[:6] != "data: " {
 			continue
@@ -119,16 +111,13 @@ func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string)
 				response.Event(ctx, "", raw)
 			}
 			content += raw
-			if htc && !sse {
-				toolCall["args"] = content
-			}
 			break
 		}
 
 		var chat model.Response
 		err := json.Unmarshal([]byte(data), &chat)
 		if err != nil {
-			logger.Error(err.Error())
+			logger.Ctx(ctx).Error(err.Error())
 			continue
 		}
 
@@ -155,7 +144,7 @@ func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string)
 					"args": "",
 				}
 			}
-			content += keyv.GetString("arguments")
+			args += keyv.GetString("arguments")
 			continue
 		}
 
@@ -171,9 +160,8 @@ func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string)
 		}
 
 		raw := choice.Delta.Content
-		logger.Debug("----- raw -----")
-		logger.Debug(raw)
-		onceExec()
+		logger.Ctx(ctx).Debug("----- raw -----")
+		logger.Ctx(ctx).Debug(raw)
 
 		raw = response.ExecMatchers(matchers, raw, false)
 		if len(raw) == 0 {
@@ -199,6 +187,10 @@ func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string)
 		content += raw
 	}
 
+	if htc && toolCall != nil {
+		toolCall["args"] = args
+	}
+
 	if toolCall != nil {
 		if !sse {
 			response.ToolCallResponse(ctx, Model, toolCall["name"].(string), toolCall["args"].(string))
@@ -0,0 +1,186 @@
+package v1
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"chatgpt-adapter/core/common/toolcall"
+	"chatgpt-adapter/core/gin/model"
+	"github.com/bincooo/emit.io"
+)
+
+// Ollama API，流式响应为 NDJSON
+//
+//	https://github.com/ollama/ollama/blob/main/docs/api.md
+func ollamaChat(builder *emit.Builder, up upstream, completion model.Completion, _ map[string]interface{}) *emit.Builder {
+	messages := make([]interface{}, 0, len(completion.Messages))
+	for _, message := range completion.Messages {
+		role := message.GetString("role")
+		if role == "developer" {
+			role = "system"
+		}
+
+		value := map[string]interface{}{"role": role, "content": contentText(message)}
+		var images []string
+		for _, part := range contentParts(message) {
+			if part["type"] != "image_url" {
+				continue
+			}
+			// 仅支持 base64 图片
+			if _, data, ok := dataUrl(imageUrl(part)); ok {
+				images = append(images, data)
+			}
+		}
+		if len(images) > 0 {
+			value["images"] = images
+		}
+
+		var calls []interface{}
+		for _, call := range toolCalls(message) {
+			calls = append(calls, map[string]interface{}{
+				"function": map[string]interface{}{"name": call.name, "arguments": call.args},
+			})
+		}
+		if len(calls) > 0 {
+			value["tool_calls"] = calls
+		}
+		messages = append(messages, value)
+	}
+
+	options := map[string]interface{}{
+		"top_p":       completion.TopP,
+		"num_predict": completion.MaxTokens,
+	}
+	if completion.Temperature != nil {
+		options["temperature"] = *completion.Temperature
+	}
+	if completion.TopK > 0 {
+		options["top_k"] = completion.TopK
+	}
+	if len(completion.StopSequences) > 0 {
+		options["stop"] = completion.StopSequences
+	}
+
+	obj := map[string]interface{}{
+		"model":    up.model,
+		"messages": messages,
+		"stream":   true,
+		"options":  options,
+	}
+	// ollama 不支持 tool_choice：none 不传递工具，指定工具时只传递该工具
+	if len(completion.Tools) > 0 {
+		switch mode, name := toolcall.ChoiceOf(completion); mode {
+		case toolcall.ChoiceNone:
+		case toolcall.ChoiceFunction:
+			for _, t := range completion.Tools {
+				if t.GetKeyv("function").GetString("name") == name {
+					obj["tools"] = []interface{}{t}
+				}
+			}
+		default:
+			obj["tools"] = completion.Tools
+		}
+	}
+
+	builder = builder.POST(up.baseUrl + "/api/chat").
+		JSONHeader().
+		Body(obj)
+	if up.token != "" {
+		builder = builder.Header("Authorization", "Bearer "+up.token)
+	}
+	return builder
+}
+
+func ollamaAccept(r *http.Response) error {
+	if strings.Contains(r.Header.Get("Content-Type"), "application/x-ndjson") {
+		return nil
+	}
+	return emit.IsSTREAM(r)
+}
+
+func ollamaTranslate(r io.Reader, w *chunkWriter) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 8*1024*1024)
+	for scanner.Scan() {
+		var chunk struct {
+			Message struct {
+				Content   string `json:"content"`
+				Thinking  string `json:"thinking"`
+				ToolCalls []struct {
+					Function struct {
+						Name      string      `json:"name"`
+						Arguments interface{} `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+			Done            bool   `json:"done"`
+			DoneReason      string `json:"done_reason"`
+			PromptEvalCount int    `json:"prompt_eval_count"`
+			EvalCount       int    `json:"eval_count"`
+			Error           string `json:"error"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			continue
+		}
+		if chunk.Error != "" {
+			return errors.New(chunk.Error)
+		}
+
+		if err := w.content(chunk.Message.Content, chunk.Message.Thinking); err != nil {
+			return err
+		}
+		for _, call := range chunk.Message.ToolCalls {
+			args, _ := json.Marshal(call.Function.Arguments)
+			if err := w.toolCall("", call.Function.Name, string(args)); err != nil {
+				return err
+			}
+		}
+
+		if chunk.Done {
+			reason := "stop"
+			if chunk.DoneReason == "length" {
+				reason = "length"
+			}
+			return w.finish(reason, chunk.PromptEvalCount, chunk.EvalCount)
+		}
+	}
+	return scanner.Err()
+}
+
+func ollamaEmbed(builder *emit.Builder, up upstream, embed model.Embed) (*emit.Builder, error) {
+	inputs, err := embedInputs(embed)
+	if err != nil {
+		return nil, err
+	}
+
+	obj := map[string]interface{}{"model": up.model, "input": inputs}
+	if embed.Dimensions > 0 {
+		obj["dimensions"] = embed.Dimensions
+	}
+
+	builder = builder.POST(up.baseUrl + "/api/embed").
+		JSONHeader().
+		Body(obj)
+	if up.token != "" {
+		builder = builder.Header("Authorization", "Bearer "+up.token)
+	}
+	return builder, nil
+}
+
+func ollamaEmbedded(obj map[string]interface{}, embed model.Embed) map[string]interface{} {
+	kv := model.Keyv[interface{}](obj)
+	tokens, _ := obj["prompt_eval_count"].(float64)
+	return embeddingsResponse(embed, kv.GetSlice("embeddings"), int(tokens))
+}
+
+func ollamaModels(builder *emit.Builder, up upstream) ([]upstreamModel, error) {
+	builder = builder.GET(up.baseUrl + "/api/tags")
+	if up.token != "" {
+		builder = builder.Header("Authorization", "Bearer "+up.token)
+	}
+	return listModels(builder, "models", "name", "modified_at")
+}
@@ -0,0 +1,55 @@
+package v1
+
+import "testing"
+
+func TestOllamaRequest(t *testing.T) {
+	req, _ := roundTrip(t, protoOllama, chatRequest, "application/x-ndjson", "")
+	assertEqual(t, "path", req.path, "/api/chat")
+	assertEqual(t, "authorization", req.header.Get("Authorization"), "Bearer secret")
+	assertEqual(t, "model", req.body.GetString("model"), "test-model")
+	assertJSON(t, "stream", req.body["stream"], `true`)
+	assertJSON(t, "options", req.body["options"], `{"temperature":0.5,"top_p":1,"num_predict":256}`)
+	// 图片仅保留 base64 数据，工具调用参数为对象
+	assertJSON(t, "messages", req.body["messages"], `[
+		{"role": "system", "content": "be brief"},
+		{"role": "user", "content": "weather?", "images": ["iVBOR"]},
+		{"role": "assistant", "content": "", "tool_calls": [
+			{"function": {"name": "get_weather", "arguments": {"city": "Paris"}}}
+		]},
+		{"role": "tool", "content": "{\"temp\":21}"}
+	]`)
+	assertEqual(t, "tools", len(req.body.GetSlice("tools")), 1)
+
+	// 不支持 tool_choice：none 不传递工具
+	req, _ = roundTrip(t, protoOllama, withChoice(`"none"`), "application/x-ndjson", "")
+	if _, ok := req.body["tools"]; ok {
+		t.Error("tools should be omitted when tool_choice is none")
+	}
+}
+
+func TestOllamaStream(t *testing.T) {
+	stream := `{"model":"test-model","message":{"role":"assistant","content":"","thinking":"hmm"},"done":false}
+{"model":"test-model","message":{"role":"assistant","content":"Let me "},"done":false}
+{"model":"test-model","message":{"role":"assistant","content":"check."},"done":false}
+{"model":"test-model","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"get_weather","arguments":{"city":"Paris"}}}]},"done":false}
+{"model":"test-model","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":11,"eval_count":5}
+`
+	_, out := roundTrip(t, protoOllama, chatRequest, "application/x-ndjson", stream)
+	assertEqual(t, "content", out.content, "Let me check.")
+	assertEqual(t, "reasoning", out.reasoning, "hmm")
+	if len(out.calls) != 1 {
+		t.Fatalf("tool calls: got %d, want 1", len(out.calls))
+	}
+	assertEqual(t, "name", out.calls[0].name, "get_weather")
+	assertEqual(t, "args", out.calls[0].args, `{"city":"Paris"}`)
+	assertEqual(t, "finish_reason", out.finish, "tool_calls")
+	assertJSON(t, "usage", out.usage, `{"prompt_tokens":11,"completion_tokens":5,"total_tokens":16}`)
+	assertEqual(t, "[DONE]", out.done, true)
+}
+
+func TestOllamaStreamError(t *testing.T) {
+	stream := "{\"error\":\"model 'test-model' not found\"}\n"
+	if _, err := translateStream(protoOllama, stream); err == nil || err.Error() != "model 'test-model' not found" {
+		t.Errorf("error: got %v, want model not found", err)
+	}
+}
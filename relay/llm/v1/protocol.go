@@ -0,0 +1,439 @@
+package v1
+
+import (
+	"bufio"
+	"chatgpt-adapter/core/gin/inter"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"chatgpt-adapter/core/gin/model"
+	"github.com/bincooo/emit.io"
+)
+
+// 上游协议，custom-llm[*].protocol，默认 openai
+//
+//	custom-llm:
+//	  - prefix: claude
+//	    reversal: https://api.anthropic.com/v1
+//	    protocol: anthropic # openai | azure | anthropic | gemini | ollama
+//	    api-version: 2023-06-01 # azure 的 api-version / anthropic-version
+const (
+	protoOpenAI    = "openai"
+	protoAzure     = "azure"
+	protoAnthropic = "anthropic"
+	protoGemini    = "gemini"
+	protoOllama    = "ollama"
+)
+
+type upstream struct {
+	baseUrl string
+	token   string
+	model   string
+	version string
+}
+
+type protocol struct {
+	// 构造对话请求，上游始终以流式返回
+	chat func(builder *emit.Builder, up upstream, completion model.Completion, obj map[string]interface{}) *emit.Builder
+	// 响应类型校验
+	accept func(r *http.Response) error
+	// 将上游响应转换为 openai 格式的 SSE，nil 为原样
+	translate func(r io.Reader, w *chunkWriter) error
+
+	// 构造 embeddings 请求，nil 为不支持
+	embed func(builder *emit.Builder, up upstream, embed model.Embed) (*emit.Builder, error)
+	// 将响应转换为 openai 格式，nil 为原样
+	embedded func(obj map[string]interface{}, embed model.Embed) map[string]interface{}
+
+	// 查询上游的模型列表，nil 为不支持
+	models func(builder *emit.Builder, up upstream) ([]upstreamModel, error)
+}
+
+// 上游返回的模型，created 为 0 时取查询时间
+type upstreamModel struct {
+	id      string
+	created int
+}
+
+var protocols = map[string]protocol{
+	protoOpenAI: {
+		chat: func(builder *emit.Builder, up upstream, _ model.Completion, obj map[string]interface{}) *emit.Builder {
+			return builder.POST(up.baseUrl+"/chat/completions").
+				Header("Authorization", "Bearer "+up.token).
+				JSONHeader().
+				Body(obj)
+		},
+		accept: emit.IsSTREAM,
+		embed: func(builder *emit.Builder, up upstream, embed model.Embed) (*emit.Builder, error) {
+			return builder.POST(up.baseUrl+"/embeddings").
+				Header("Authorization", "Bearer "+up.token).
+				JSONHeader().
+				Body(embed), nil
+		},
+		models: func(builder *emit.Builder, up upstream) ([]upstreamModel, error) {
+			return listModels(builder.GET(up.baseUrl+"/models").
+				Header("Authorization", "Bearer "+up.token), "data", "id", "created")
+		},
+	},
+
+	// 按部署名路由：{reversal}/openai/deployments/{model}/...?api-version=
+	// 数据面接口无法列出部署，不支持模型发现
+	protoAzure: {
+		chat: func(builder *emit.Builder, up upstream, _ model.Completion, obj map[string]interface{}) *emit.Builder {
+			return builder.POST(up.deployment("chat/completions")).
+				Header("api-key", up.token).
+				JSONHeader().
+				Body(obj)
+		},
+		accept: emit.IsSTREAM,
+		embed: func(builder *emit.Builder, up upstream, embed model.Embed) (*emit.Builder, error) {
+			return builder.POST(up.deployment("embeddings")).
+				Header("api-key", up.token).
+				JSONHeader().
+				Body(embed), nil
+		},
+	},
+
+	protoAnthropic: {
+		chat:      anthropicChat,
+		accept:    emit.IsSTREAM,
+		translate: anthropicTranslate,
+		models:    anthropicModels,
+	},
+
+	protoGemini: {
+		chat:      geminiChat,
+		accept:    emit.IsSTREAM,
+		translate: geminiTranslate,
+		embed:     geminiEmbed,
+		embedded:  geminiEmbedded,
+		models:    geminiModels,
+	},
+
+	protoOllama: {
+		chat:      ollamaChat,
+		accept:    ollamaAccept,
+		translate: ollamaTranslate,
+		embed:     ollamaEmbed,
+		embedded:  ollamaEmbedded,
+		models:    ollamaModels,
+	},
+}
+
+func (up upstream) deployment(path string) string {
+	version := up.version
+	if version == "" {
+		version = "2024-10-21"
+	}
+	return fmt.Sprintf("%s/openai/deployments/%s/%s?api-version=%s", up.baseUrl, up.model, path, version)
+}
+
+// 取出列表 `key` 中各项的 `field` 字段，`created` 为创建时间字段（unix 秒或 RFC 3339）
+func listModels(builder *emit.Builder, key, field, created string) (models []upstreamModel, err error) {
+	r, err := builder.DoC(emit.Status(http.StatusOK), emit.IsJSON)
+	if err != nil {
+		return
+	}
+
+	obj, err := emit.ToMap(r)
+	if err != nil {
+		return
+	}
+	for _, it := range model.Keyv[interface{}](obj).GetSlice(key) {
+		if value, ok := it.(map[string]interface{}); ok {
+			if id, _ := value[field].(string); id != "" {
+				models = append(models, upstreamModel{id, unixOf(value[created])})
+			}
+		}
+	}
+	return
+}
+
+func unixOf(value interface{}) int {
+	switch v := value.(type) {
+	case float64:
+		return int(v)
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return int(t.Unix())
+		}
+	}
+	return 0
+}
+
+func upstreamOf(ctx *inter.Context, token string) (up upstream, p protocol) {
+	up = upstream{
+		baseUrl: strings.TrimSuffix(ctx.GetString(key), "/"),
+		token:   token,
+		model:   ctx.GetString(modKey),
+		version: ctx.GetString(verKey),
+	}
+	p, ok := protocols[ctx.GetString(protoKey)]
+	if !ok {
+		p = protocols[protoOpenAI]
+	}
+	return
+}
+
+// 在独立 goroutine 中转换响应流，后续的 waitResponse、waitMessage 无需区分协议
+func translate(r *http.Response, p protocol) {
+	if p.translate == nil {
+		return
+	}
+
+	body := r.Body
+	reader, writer := io.Pipe()
+	go func() {
+		defer body.Close()
+		w := &chunkWriter{w: writer, created: time.Now().Unix()}
+		err := p.translate(body, w)
+		if err == nil {
+			err = w.done()
+		}
+		_ = writer.CloseWithError(err)
+	}()
+	r.Body = reader
+}
+
+// 输出 openai 格式的 chat.completion.chunk
+type chunkWriter struct {
+	w       io.Writer
+	created int64
+	calls   int
+	closed  bool
+}
+
+func (c *chunkWriter) write(delta map[string]interface{}, finishReason interface{}, usage map[string]interface{}) error {
+	obj := map[string]interface{}{
+		"id":      fmt.Sprintf("chatcmpl-%d", c.created),
+		"object":  "chat.completion.chunk",
+		"created": c.created,
+		"model":   Model,
+		"choices": []interface{}{
+			map[string]interface{}{"index": 0, "delta": delta, "finish_reason": finishReason},
+		},
+	}
+	if usage != nil {
+		obj["usage"] = usage
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	_, err = c.w.Write([]byte("data: " + string(data) + "\n\n"))
+	return err
+}
+
+func (c *chunkWriter) content(content, reasoning string) error {
+	if content == "" && reasoning == "" {
+		return nil
+	}
+	delta := map[string]interface{}{"role": "assistant", "content": content}
+	if reasoning != "" {
+		delta["reasoning_content"] = reasoning
+	}
+	return c.write(delta, nil, nil)
+}
+
+// 开始新的工具调用，后续参数片段通过 toolArgs 追加
+func (c *chunkWriter) toolCall(id, name, args string) error {
+	if id == "" {
+		id = fmt.Sprintf("call_%d_%d", c.created, c.calls)
+	}
+	c.calls++
+	return c.write(map[string]interface{}{
+		"role": "assistant",
+		"tool_calls": []interface{}{
+			map[string]interface{}{
+				"index":    c.calls - 1,
+				"id":       id,
+				"type":     "function",
+				"function": map[string]interface{}{"name": name, "arguments": args},
+			},
+		},
+	}, nil, nil)
+}
+
+func (c *chunkWriter) toolArgs(args string) error {
+	if args == "" || c.calls == 0 {
+		return nil
+	}
+	return c.write(map[string]interface{}{
+		"role": "assistant",
+		"tool_calls": []interface{}{
+			map[string]interface{}{
+				"index":    c.calls - 1,
+				"function": map[string]interface{}{"arguments": args},
+			},
+		},
+	}, nil, nil)
+}
+
+// 结束输出，prompt/completion 为 0 时由 waitResponse 估算
+func (c *chunkWriter) finish(reason string, prompt, completion int) error {
+	if c.closed {
+		return nil
+	}
+	if c.calls > 0 && reason == "stop" {
+		reason = "tool_calls"
+	}
+
+	var usage map[string]interface{}
+	if prompt > 0 || completion > 0 {
+		usage = map[string]interface{}{
+			"prompt_tokens":     prompt,
+			"completion_tokens": completion,
+			"total_tokens":      prompt + completion,
+		}
+	}
+	if err := c.write(map[string]interface{}{}, reason, usage); err != nil {
+		return err
+	}
+	return c.done()
+}
+
+func (c *chunkWriter) done() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	_, err := c.w.Write([]byte("data: [DONE]\n\n"))
+	return err
+}
+
+// 逐行读取 SSE 中的 data
+func scanEvents(r io.Reader, fn func(data []byte) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 8*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(line[5:])
+		if data == "" || data == "[DONE]" {
+			continue
+		}
+		if err := fn([]byte(data)); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// 消息内容统一为片段列表，字符串视为单个文本片段
+func contentParts(message model.Keyv[interface{}]) (parts []map[string]interface{}) {
+	if message.IsString("content") {
+		if text := message.GetString("content"); text != "" {
+			parts = append(parts, map[string]interface{}{"type": "text", "text": text})
+		}
+		return
+	}
+	for _, it := range message.GetSlice("content") {
+		if part, ok := it.(map[string]interface{}); ok {
+			parts = append(parts, part)
+		}
+	}
+	return
+}
+
+func contentText(message model.Keyv[interface{}]) string {
+	var texts []string
+	for _, part := range contentParts(message) {
+		if part["type"] == "text" {
+			text, _ := part["text"].(string)
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+func imageUrl(part map[string]interface{}) string {
+	obj := model.Keyv[interface{}](part).GetKeyv("image_url")
+	return obj.GetString("url")
+}
+
+// 解析 data:{mime};base64,{data}
+func dataUrl(url string) (mime, data string, ok bool) {
+	if !strings.HasPrefix(url, "data:") {
+		return
+	}
+	head, data, found := strings.Cut(url[5:], ",")
+	if !found || !strings.HasSuffix(head, ";base64") {
+		return
+	}
+	return strings.TrimSuffix(head, ";base64"), data, true
+}
+
+type callValue struct {
+	id   string
+	name string
+	args interface{}
+}
+
+// 助手消息中的工具调用，参数解析为对象
+func toolCalls(message model.Keyv[interface{}]) (calls []callValue) {
+	for _, it := range message.GetSlice("tool_calls") {
+		call, ok := it.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		kv := model.Keyv[interface{}](call)
+		fn := kv.GetKeyv("function")
+		var args interface{} = map[string]interface{}{}
+		if str := fn.GetString("arguments"); str != "" {
+			if err := json.Unmarshal([]byte(str), &args); err != nil {
+				args = map[string]interface{}{}
+			}
+		}
+		calls = append(calls, callValue{kv.GetString("id"), fn.GetString("name"), args})
+	}
+	return
+}
+
+// openai 的 tools 定义
+func toolFunctions(tools []model.Keyv[interface{}]) (functions []model.Keyv[interface{}]) {
+	for _, tool := range tools {
+		if fn := tool.GetKeyv("function"); fn != nil {
+			functions = append(functions, fn)
+		}
+	}
+	return
+}
+
+// 将 embeddings 的 input 统一为字符串列表
+func embedInputs(embed model.Embed) ([]string, error) {
+	switch v := embed.Input.(type) {
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		inputs := make([]string, 0, len(v))
+		for _, it := range v {
+			str, ok := it.(string)
+			if !ok {
+				return nil, fmt.Errorf("embedding input must be string or string array")
+			}
+			inputs = append(inputs, str)
+		}
+		return inputs, nil
+	}
+	return nil, fmt.Errorf("embedding input must be string or string array")
+}
+
+func embeddingsResponse(embed model.Embed, vectors []interface{}, tokens int) map[string]interface{} {
+	data := make([]interface{}, 0, len(vectors))
+	for i, vector := range vectors {
+		data = append(data, map[string]interface{}{"object": "embedding", "index": i, "embedding": vector})
+	}
+	return map[string]interface{}{
+		"object": "list",
+		"data":   data,
+		"model":  embed.Model,
+		"usage":  map[string]interface{}{"prompt_tokens": tokens, "total_tokens": tokens},
+	}
+}
@@ -0,0 +1,223 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"chatgpt-adapter/core/gin/model"
+	"github.com/bincooo/emit.io"
+)
+
+// 上游收到的请求
+type captured struct {
+	path   string
+	query  string
+	header http.Header
+	body   model.Keyv[interface{}]
+}
+
+// 翻译后的 openai 格式输出
+type translated struct {
+	content   string
+	reasoning string
+	calls     []streamedCall
+	finish    string
+	usage     model.Keyv[interface{}]
+	done      bool
+}
+
+type streamedCall struct {
+	id   string
+	name string
+	args string // 各片段拼接后的参数
+}
+
+// 以 httptest 模拟上游：记录请求，返回固定的响应流，再经协议转换为 openai 格式
+func roundTrip(t *testing.T, proto, request, contentType, stream string) (req captured, out translated) {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		req = captured{path: r.URL.Path, query: r.URL.RawQuery, header: r.Header.Clone()}
+		if err := json.Unmarshal(data, &req.body); err != nil {
+			t.Errorf("upstream body is not json: %v", err)
+		}
+		w.Header().Set("Content-Type", contentType)
+		_, _ = io.WriteString(w, stream)
+	}))
+	defer srv.Close()
+
+	var completion model.Completion
+	if err := json.Unmarshal([]byte(request), &completion); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := emit.NewSession("", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := protocols[proto]
+	up := upstream{baseUrl: srv.URL, token: "secret", model: "test-model"}
+	r, err := p.chat(emit.ClientBuilder(session).Context(context.Background()), up, completion, nil).
+		DoC(emit.Status(http.StatusOK), p.accept)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	translate(r, p)
+	defer r.Body.Close()
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return req, parseChunks(t, string(data))
+}
+
+// 不经上游直接转换响应流，返回读取时的错误
+func translateStream(proto, stream string) (string, error) {
+	r := &http.Response{Body: io.NopCloser(strings.NewReader(stream))}
+	translate(r, protocols[proto])
+	defer r.Body.Close()
+	data, err := io.ReadAll(r.Body)
+	return string(data), err
+}
+
+func parseChunks(t *testing.T, data string) (out translated) {
+	t.Helper()
+	for _, line := range strings.Split(data, "\n") {
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		line = line[6:]
+		if line == "[DONE]" {
+			out.done = true
+			continue
+		}
+
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content   string `json:"content"`
+					Reasoning string `json:"reasoning_content"`
+					ToolCalls []struct {
+						Index    int    `json:"index"`
+						Id       string `json:"id"`
+						Function struct {
+							Name      string `json:"name"`
+							Arguments string `json:"arguments"`
+						} `json:"function"`
+					} `json:"tool_calls"`
+				} `json:"delta"`
+				FinishReason *string `json:"finish_reason"`
+			} `json:"choices"`
+			Usage model.Keyv[interface{}] `json:"usage"`
+		}
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			t.Fatalf("invalid chunk `%s`: %v", line, err)
+		}
+
+		choice := chunk.Choices[0]
+		out.content += choice.Delta.Content
+		out.reasoning += choice.Delta.Reasoning
+		for _, call := range choice.Delta.ToolCalls {
+			if call.Index == len(out.calls) {
+				out.calls = append(out.calls, streamedCall{id: call.Id, name: call.Function.Name})
+			}
+			out.calls[call.Index].args += call.Function.Arguments
+		}
+		if choice.FinishReason != nil {
+			out.finish = *choice.FinishReason
+		}
+		if chunk.Usage != nil {
+			out.usage = chunk.Usage
+		}
+	}
+	return
+}
+
+// 按 json 比较，忽略 map 顺序与数字类型的差异
+func assertJSON(t *testing.T, name string, got interface{}, want string) {
+	t.Helper()
+	var g, w interface{}
+	data, _ := json.Marshal(got)
+	_ = json.Unmarshal(data, &g)
+	if err := json.Unmarshal([]byte(want), &w); err != nil {
+		t.Fatal(err)
+	}
+	gd, _ := json.Marshal(g)
+	wd, _ := json.Marshal(w)
+	if string(gd) != string(wd) {
+		t.Errorf("%s:\n got: %s\nwant: %s", name, gd, wd)
+	}
+}
+
+func assertEqual[T comparable](t *testing.T, name string, got, want T) {
+	t.Helper()
+	if got != want {
+		t.Errorf("%s: got %v, want %v", name, got, want)
+	}
+}
+
+// 各协议共用的请求：系统提示、图片、历史工具调用与结果
+const chatRequest = `{
+	"model": "custom/test-model",
+	"max_tokens": 256,
+	"temperature": 0.5,
+	"top_p": 1,
+	"messages": [
+		{"role": "system", "content": "be brief"},
+		{"role": "user", "content": [
+			{"type": "text", "text": "weather?"},
+			{"type": "image_url", "image_url": {"url": "data:image/png;base64,iVBOR"}}
+		]},
+		{"role": "assistant", "content": "", "tool_calls": [
+			{"id": "call_1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}}
+		]},
+		{"role": "tool", "tool_call_id": "call_1", "content": "{\"temp\":21}"}
+	],
+	"tools": [
+		{"type": "function", "function": {
+			"name": "get_weather",
+			"description": "query weather",
+			"parameters": {"type": "object", "properties": {"city": {"type": "string"}}}
+		}}
+	]
+}`
+
+// 替换 chatRequest 中的 tool_choice
+func withChoice(choice string) string {
+	return strings.Replace(chatRequest, `"tools": [`, `"tool_choice": `+choice+`, "tools": [`, 1)
+}
+
+func TestListModels(t *testing.T) {
+	for _, c := range []struct {
+		proto string
+		body  string
+		want  []upstreamModel
+	}{
+		{protoOpenAI, `{"data":[{"id":"gpt-4o","created":1715367049}]}`, []upstreamModel{{"gpt-4o", 1715367049}}},
+		{protoAnthropic, `{"data":[{"id":"claude-3-7","created_at":"2025-02-24T00:00:00Z"}]}`, []upstreamModel{{"claude-3-7", 1740355200}}},
+		{protoGemini, `{"models":[{"name":"models/gemini-2.0-flash"}]}`, []upstreamModel{{"gemini-2.0-flash", 0}}},
+		{protoOllama, `{"models":[{"name":"qwen3:8b","modified_at":"2025-05-01T08:00:00.123456+08:00"}]}`, []upstreamModel{{"qwen3:8b", 1746057600}}},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, c.body)
+		}))
+		session, _ := emit.NewSession("", false, nil)
+		models, err := protocols[c.proto].models(emit.ClientBuilder(session), upstream{baseUrl: srv.URL})
+		srv.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", c.proto, err)
+		}
+		if len(models) != len(c.want) || models[0] != c.want[0] {
+			t.Errorf("%s: got %v, want %v", c.proto, models, c.want)
+		}
+	}
+}
@@ -1,16 +1,17 @@
 package v1
 
 import (
+	"chatgpt-adapter/core/common"
 	"chatgpt-adapter/core/common/toolcall"
+	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
-	"github.com/gin-gonic/gin"
 )
 
-func toolChoice(ctx *gin.Context, proxies string, completion model.Completion) bool {
-	logger.Info("tool choice ...")
-	cookie := ctx.GetString("token")
+func toolChoice(ctx *inter.Context, proxies string, completion model.Completion) bool {
+	logger.Ctx(ctx).Info("tool choice ...")
+	cookie := common.GetGinToken(ctx)
 	exec, err := toolcall.ToolChoice(ctx, completion, func(message string) (string, error) {
 		completion.Stream = true
 		completion.Messages = []model.Keyv[interface{}]{
@@ -25,11 +26,11 @@ func toolChoice(ctx *gin.Context, proxies string, completion model.Completion) b
 			return "", err
 		}
 
-		return waitMessage(r, toolcall.Cancel)
+		return waitMessage(r, toolcall.CancelOf(ctx))
 	})
 
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		response.Error(ctx, -1, err)
 		return true
 	}
@@ -2,13 +2,11 @@ package windsurf
 
 import (
 	"chatgpt-adapter/core/common"
-	"chatgpt-adapter/core/common/vars"
+	"chatgpt-adapter/core/common/inited"
 	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
-	"github.com/gin-gonic/gin"
-	"github.com/iocgo/sdk/env"
 	"strings"
 )
 
@@ -18,11 +16,9 @@ var (
 
 type api struct {
 	inter.BaseAdapter
-
-	env *env.Environment
 }
 
-func (api *api) Match(ctx *gin.Context, model string) (ok bool, err error) {
+func (api *api) Match(ctx *inter.Context, model string) (ok bool, err error) {
 	if len(model) <= 9 || Model+"/" != model[:9] {
 		return
 	}
@@ -31,7 +27,7 @@ func (api *api) Match(ctx *gin.Context, model string) (ok bool, err error) {
 			if strings.HasPrefix(mod, "deepseek") {
 				completion := common.GetGinCompletion(ctx)
 				completion.StopSequences = append(completion.StopSequences, "<codebase_search>", "<write_to_file>", "<open_link>")
-				ctx.Set(vars.GinCompletion, completion)
+				common.SetGinCompletion(ctx, completion)
 			}
 			ok = true
 			return
@@ -52,25 +48,35 @@ func (*api) Models() (slice []model.Model) {
 	return
 }
 
-func (api *api) ToolChoice(ctx *gin.Context) (ok bool, err error) {
+// 未知的模型不限制上下文与输出
+func (*api) Capabilities(mod string) *model.Capabilities {
+	caps := &model.Capabilities{
+		Output: []string{model.ModalityText},
+		Tools:  model.ToolsEmulated,
+	}
+	caps.ContextWindow, caps.MaxOutput = model.LimitsOf(mod)
+	return caps
+}
+
+func (api *api) ToolChoice(ctx *inter.Context) (ok bool, err error) {
 	var (
-		cookie     = ctx.GetString("token")
+		cookie     = common.GetGinToken(ctx)
 		completion = common.GetGinCompletion(ctx)
 	)
 
-	if toolChoice(ctx, api.env, cookie, completion) {
+	if toolChoice(ctx, inited.Env(), cookie, completion) {
 		ok = true
 	}
 	return
 }
 
-func (api *api) Completion(ctx *gin.Context) (err error) {
+func (api *api) Completion(ctx *inter.Context) (err error) {
 	var (
-		cookie     = ctx.GetString("token")
+		cookie     = common.GetGinToken(ctx)
 		completion = common.GetGinCompletion(ctx)
 	)
 
-	token, err := genToken(ctx.Request.Context(), api.env.GetString("server.proxied"), cookie)
+	token, err := genToken(ctx, inited.Env().GetString("server.proxied"), cookie)
 	if err != nil {
 		return
 	}
@@ -80,9 +86,9 @@ func (api *api) Completion(ctx *gin.Context) (err error) {
 		return
 	}
 
-	r, err := fetch(ctx.Request.Context(), api.env, buffer)
+	r, err := fetch(ctx, inited.Env(), buffer)
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return
 	}
 
@@ -2,6 +2,7 @@ package windsurf
 
 import (
 	"bytes"
+	"chatgpt-adapter/core/common/inited"
 	"compress/gzip"
 	"context"
 	"encoding/binary"
@@ -76,9 +77,6 @@ func convertRequest(completion model.Completion, ident, token string) (buffer []
 	if completion.TopP == 0 {
 		completion.TopP = 0.4
 	}
-	if completion.Temperature == 0 {
-		completion.Temperature = 0.4
-	}
 
 	if len(completion.Messages) > 0 && completion.Messages[0].Is("role", "system") {
 		completion.System = completion.Messages[0].GetString("content")
@@ -131,7 +129,7 @@ func convertRequest(completion model.Completion, ident, token string) (buffer []
 			MaxTokens:       uint32(completion.MaxTokens),
 			TopK:            uint32(completion.TopK),
 			TopP:            float64(completion.TopP),
-			Temperature:     float64(completion.Temperature),
+			Temperature:     float64(completion.TemperatureOr(0.4)),
 			UnknownField7:   50,
 			PresencePenalty: 1.0,
 			Stop: []string{
@@ -392,7 +390,7 @@ func genToken(ctx context.Context, proxies, ident string) (token string, err err
 	}
 
 	HTTPClient := common.HTTPClient
-	if !env.Env.GetBool("windsurf.proxied") {
+	if !inited.Env().GetBool("windsurf.proxied") {
 		HTTPClient = common.NopHTTPClient
 		proxies = ""
 	}
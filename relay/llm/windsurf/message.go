@@ -3,13 +3,13 @@ package windsurf
 import (
 	"bufio"
 	"bytes"
+	"chatgpt-adapter/core/common/inited"
+	"chatgpt-adapter/core/gin/inter"
 	"encoding/json"
 	"fmt"
-	"github.com/iocgo/sdk/env"
 	"io"
 	"net/http"
 	"strings"
-	"sync"
 	"time"
 
 	"chatgpt-adapter/core/common"
@@ -17,13 +17,11 @@ import (
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
 	"github.com/bincooo/emit.io"
-	"github.com/gin-gonic/gin"
 	"github.com/golang/protobuf/proto"
 )
 
 const (
-	ginTokens = "__tokens__"
-	thinkTag  = "think: "
+	thinkTag = "think: "
 )
 
 type ChunkErrorWrapper struct {
@@ -141,24 +139,18 @@ func waitMessage(r *http.Response, cancel func(str string) bool) (content string
 	return content, nil
 }
 
-func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string) {
+func waitResponse(ctx *inter.Context, r *http.Response, sse bool) (content string) {
 	defer r.Body.Close()
 	created := time.Now().Unix()
-	logger.Info("waitResponse ...")
+	logger.Ctx(ctx).Info("waitResponse ...")
 	completion := common.GetGinCompletion(ctx)
 	matchers := common.GetGinMatchers(ctx)
-	tokens := ctx.GetInt(ginTokens)
-	thinkReason := env.Env.GetBool("server.think_reason")
+	tokens := common.GetGinTokens(ctx)
+	thinkReason := inited.Env().GetBool("server.think_reason")
 	thinkReason = thinkReason && completion.Model[9:] == "deepseek-reasoner"
 	reasoningContent := ""
 	think := 0
 
-	onceExec := sync.OnceFunc(func() {
-		if !sse {
-			ctx.Writer.WriteHeader(http.StatusOK)
-		}
-	})
-
 	scanner := newScanner(r.Body)
 	for {
 		if !scanner.Scan() {
@@ -199,7 +191,7 @@ func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string)
 			}
 
 			if response.NotSSEHeader(ctx) {
-				logger.Error(err)
+				logger.Ctx(ctx).Error(err)
 				response.Error(ctx, -1, err)
 			}
 			return
@@ -211,8 +203,8 @@ func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string)
 			reasonContent = raw[len(thinkTag):]
 			raw = ""
 			think = 2
-			logger.Debug("----- think raw -----")
-			logger.Debug(reasonContent)
+			logger.Ctx(ctx).Debug("----- think raw -----")
+			logger.Ctx(ctx).Debug(reasonContent)
 			reasoningContent += reasonContent
 			goto label
 
@@ -233,15 +225,14 @@ func waitResponse(ctx *gin.Context, r *http.Response, sse bool) (content string)
 			}
 
 			raw = ""
-			logger.Debug("----- think raw -----")
-			logger.Debug(reasonContent)
+			logger.Ctx(ctx).Debug("----- think raw -----")
+			logger.Ctx(ctx).Debug(reasonContent)
 			reasoningContent += reasonContent
 			goto label
 		}
 
-		logger.Debug("----- raw -----")
-		logger.Debug(raw)
-		onceExec()
+		logger.Ctx(ctx).Debug("----- raw -----")
+		logger.Ctx(ctx).Debug(raw)
 
 		raw = response.ExecMatchers(matchers, raw, false)
 		if len(raw) == 0 {
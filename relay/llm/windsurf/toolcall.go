@@ -3,20 +3,20 @@ package windsurf
 import (
 	"chatgpt-adapter/core/common/toolcall"
 	"chatgpt-adapter/core/common/vars"
+	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
-	"github.com/gin-gonic/gin"
 	"github.com/iocgo/sdk/env"
 )
 
-func toolChoice(ctx *gin.Context, env *env.Environment, cookie string, completion model.Completion) bool {
-	logger.Info("completeTools ...")
+func toolChoice(ctx *inter.Context, env *env.Environment, cookie string, completion model.Completion) bool {
+	logger.Ctx(ctx).Info("completeTools ...")
 	echo := ctx.GetBool(vars.GinEcho)
 
 	exec, err := toolcall.ToolChoice(ctx, completion, func(message string) (string, error) {
 		if echo {
-			logger.Infof("toolCall message: \n%s", message)
+			logger.Ctx(ctx).Infof("toolCall message: \n%s", message)
 			return "", nil
 		}
 
@@ -27,7 +27,7 @@ func toolChoice(ctx *gin.Context, env *env.Environment, cookie string, completio
 			},
 		}
 
-		token, err := genToken(ctx.Request.Context(), env.GetString("server.proxied"), cookie)
+		token, err := genToken(ctx, env.GetString("server.proxied"), cookie)
 		if err != nil {
 			return "", err
 		}
@@ -37,16 +37,16 @@ func toolChoice(ctx *gin.Context, env *env.Environment, cookie string, completio
 			return "", err
 		}
 
-		r, err := fetch(ctx.Request.Context(), env, messageBuffer)
+		r, err := fetch(ctx, env, messageBuffer)
 		if err != nil {
 			return "", err
 		}
 
-		return waitMessage(r, toolcall.Cancel)
+		return waitMessage(r, toolcall.CancelOf(ctx))
 	})
 
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		response.Error(ctx, -1, err)
 		return true
 	}
@@ -1,6 +1,7 @@
 package you
 
 import (
+	"chatgpt-adapter/core/common/inited"
 	"encoding/json"
 	"strings"
 
@@ -10,8 +11,6 @@ import (
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
 	"github.com/bincooo/you.com"
-	"github.com/gin-gonic/gin"
-	"github.com/iocgo/sdk/env"
 )
 
 var (
@@ -20,17 +19,15 @@ var (
 
 type api struct {
 	inter.BaseAdapter
-
-	env *env.Environment
 }
 
-func (api *api) Match(ctx *gin.Context, model string) (ok bool, err error) {
-	token := ctx.GetString("token")
+func (api *api) Match(ctx *inter.Context, model string) (ok bool, err error) {
+	token := common.GetGinToken(ctx)
 	if !strings.HasPrefix(model, "you/") {
 		return
 	}
 
-	slice := api.env.GetStringSlice("you.model")
+	slice := inited.Env().GetStringSlice("you.model")
 	for _, mod := range append(slice, []string{
 		you.GPT_4,
 		you.GPT_4_TURBO,
@@ -48,7 +45,7 @@ func (api *api) Match(ctx *gin.Context, model string) (ok bool, err error) {
 		you.GEMINI_1_5_FLASH,
 	}...) {
 		if model[4:] == mod {
-			password := api.env.GetString("server.password")
+			password := inited.Env().GetString("server.password")
 			if password != "" && password != token {
 				err = response.UnauthorizedError
 				return
@@ -61,7 +58,7 @@ func (api *api) Match(ctx *gin.Context, model string) (ok bool, err error) {
 }
 
 func (api *api) Models() (slice []model.Model) {
-	s := api.env.GetStringSlice("you.model")
+	s := inited.Env().GetStringSlice("you.model")
 	for _, mod := range append(s, []string{
 		you.GPT_4,
 		you.GPT_4_TURBO,
@@ -88,10 +85,20 @@ func (api *api) Models() (slice []model.Model) {
 	return
 }
 
-func (api *api) ToolChoice(ctx *gin.Context) (ok bool, err error) {
+// 未知的模型不限制上下文与输出
+func (*api) Capabilities(mod string) *model.Capabilities {
+	caps := &model.Capabilities{
+		Output: []string{model.ModalityText},
+		Tools:  model.ToolsEmulated,
+	}
+	caps.ContextWindow, caps.MaxOutput = model.LimitsOf(mod)
+	return caps
+}
+
+func (api *api) ToolChoice(ctx *inter.Context) (ok bool, err error) {
 	var (
-		cookie     = ctx.GetString("token")
-		proxied    = api.env.GetString("server.proxied")
+		cookie     = common.GetGinToken(ctx)
+		proxied    = inited.Env().GetString("server.proxied")
 		completion = common.GetGinCompletion(ctx)
 	)
 
@@ -101,11 +108,11 @@ func (api *api) ToolChoice(ctx *gin.Context) (ok bool, err error) {
 	return
 }
 
-func (api *api) Completion(ctx *gin.Context) (err error) {
+func (api *api) Completion(ctx *inter.Context) (err error) {
 	var (
 		proxies    = ctx.GetString("proxies")
 		completion = common.GetGinCompletion(ctx)
-		token      = ctx.GetString("token")
+		token      = common.GetGinToken(ctx)
 	)
 
 	completion.Model = completion.Model[4:]
@@ -119,10 +126,10 @@ func (api *api) Completion(ctx *gin.Context) (err error) {
 	}
 
 	var cancel chan error
-	if api.env.GetBool("you.custom") {
-		err = chat.Custom(ctx.Request.Context(), "custom-"+completion.Model, "", false)
+	if inited.Env().GetBool("you.custom") {
+		err = chat.Custom(ctx, "custom-"+completion.Model, "", false)
 		if err != nil {
-			logger.Error(err)
+			logger.Ctx(ctx).Error(err)
 			response.Error(ctx, -1, err)
 			return
 		}
@@ -132,11 +139,11 @@ func (api *api) Completion(ctx *gin.Context) (err error) {
 	if i := len(chatM); i > 2 && chatM[0] == '[' && chatM[i-1] == ']' {
 		err = json.Unmarshal([]byte(chatM), &chats)
 		if err != nil {
-			logger.Error(err)
+			logger.Ctx(ctx).Error(err)
 		}
 	}
 
-	ch, err := chat.Reply(ctx.Request.Context(), chats, fileMessage, message)
+	ch, err := chat.Reply(ctx, chats, fileMessage, message)
 	if err != nil {
 		return
 	}
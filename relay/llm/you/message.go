@@ -1,11 +1,11 @@
 package you
 
 import (
+	"chatgpt-adapter/core/common/inited"
+	"chatgpt-adapter/core/gin/inter"
 	"errors"
-	"net/http"
 	"net/url"
 	"strings"
-	"sync"
 	"time"
 
 	"chatgpt-adapter/core/common"
@@ -13,12 +13,8 @@ import (
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
-	"github.com/gin-gonic/gin"
-	"github.com/iocgo/sdk/env"
 )
 
-const ginTokens = "__tokens__"
-
 func waitMessage(ch chan string, cancel func(str string) bool) (content string, err error) {
 
 	for {
@@ -48,25 +44,19 @@ func waitMessage(ch chan string, cancel func(str string) bool) (content string,
 	return content, nil
 }
 
-func waitResponse(ctx *gin.Context, cancel chan error, ch chan string, sse bool) (content string) {
+func waitResponse(ctx *inter.Context, cancel chan error, ch chan string, sse bool) (content string) {
 	var (
 		created  = time.Now().Unix()
-		tokens   = ctx.GetInt(ginTokens)
+		tokens   = common.GetGinTokens(ctx)
 		matchers = common.GetGinMatchers(ctx)
 	)
 
-	onceExec := sync.OnceFunc(func() {
-		if !sse {
-			ctx.Writer.WriteHeader(http.StatusOK)
-		}
-	})
-
-	logger.Info("waitResponse ...")
+	logger.Ctx(ctx).Info("waitResponse ...")
 	for {
 		select {
 		case err := <-cancel:
 			if err != nil {
-				logger.Error(err)
+				logger.Ctx(ctx).Error(err)
 				if response.NotSSEHeader(ctx) {
 					response.Error(ctx, -1, err)
 				}
@@ -85,7 +75,7 @@ func waitResponse(ctx *gin.Context, cancel chan error, ch chan string, sse bool)
 			}
 
 			if strings.HasPrefix(message, "error:") {
-				logger.Error(message[6:])
+				logger.Ctx(ctx).Error(message[6:])
 				if response.NotSSEHeader(ctx) {
 					response.Error(ctx, -1, message[6:])
 				}
@@ -97,9 +87,8 @@ func waitResponse(ctx *gin.Context, cancel chan error, ch chan string, sse bool)
 			}
 
 			var raw = message
-			logger.Debug("----- raw -----")
-			logger.Debug(raw)
-			onceExec()
+			logger.Ctx(ctx).Debug("----- raw -----")
+			logger.Ctx(ctx).Debug(raw)
 
 			raw = response.ExecMatchers(matchers, raw, false)
 			if len(raw) == 0 {
@@ -132,14 +121,14 @@ label:
 	return
 }
 
-func mergeMessages(ctx *gin.Context, completion model.Completion) (fileMessage, chat, query string) {
-	query = env.Env.GetString("you.notice")
+func mergeMessages(ctx *inter.Context, completion model.Completion) (fileMessage, chat, query string) {
+	query = inited.Env().GetString("you.notice")
 	tokens := 0
 	var (
 		messages = completion.Messages
 		isC      = response.IsClaude(ctx, completion.Model)
 	)
-	defer func() { ctx.Set(ginTokens, tokens) }()
+	defer func() { common.SetGinTokens(ctx, tokens) }()
 
 	messageL := len(messages)
 	if messageL == 1 {
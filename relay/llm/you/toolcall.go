@@ -4,15 +4,15 @@ import (
 	"chatgpt-adapter/core/common"
 	"chatgpt-adapter/core/common/toolcall"
 	"chatgpt-adapter/core/common/vars"
+	"chatgpt-adapter/core/gin/inter"
 	"chatgpt-adapter/core/gin/model"
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
 	"github.com/bincooo/you.com"
-	"github.com/gin-gonic/gin"
 )
 
-func toolChoice(ctx *gin.Context, cookie, proxies string, completion model.Completion) bool {
-	logger.Infof("completeTools ...")
+func toolChoice(ctx *inter.Context, cookie, proxies string, completion model.Completion) bool {
+	logger.Ctx(ctx).Infof("completeTools ...")
 
 	var (
 		echo = ctx.GetBool(vars.GinEcho)
@@ -20,7 +20,7 @@ func toolChoice(ctx *gin.Context, cookie, proxies string, completion model.Compl
 
 	exec, err := toolcall.ToolChoice(ctx, completion, func(message string) (string, error) {
 		if echo {
-			logger.Infof("toolCall message: \n%s", message)
+			logger.Ctx(ctx).Infof("toolCall message: \n%s", message)
 			return "", nil
 		}
 
@@ -32,16 +32,16 @@ func toolChoice(ctx *gin.Context, cookie, proxies string, completion model.Compl
 			chat.CloudFlare(clearance, ctx.GetString("userAgent"), ctx.GetString("lang"))
 		}
 
-		chatResponse, err := chat.Reply(ctx.Request.Context(), nil, message, "Please review the attached prompt")
+		chatResponse, err := chat.Reply(ctx, nil, message, "Please review the attached prompt")
 		if err != nil {
 			return "", err
 		}
 
-		return waitMessage(chatResponse, toolcall.Cancel)
+		return waitMessage(chatResponse, toolcall.CancelOf(ctx))
 	})
 
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		response.Error(ctx, -1, err)
 		return true
 	}
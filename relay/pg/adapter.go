@@ -18,9 +18,7 @@ import (
 	"chatgpt-adapter/core/gin/response"
 	"chatgpt-adapter/core/logger"
 	"github.com/bincooo/emit.io"
-	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
-	"github.com/iocgo/sdk/env"
 )
 
 type modelPayload struct {
@@ -107,22 +105,21 @@ var (
 
 type pg struct {
 	inter.BaseAdapter
-	env *env.Environment
 }
 
-func (p *pg) Match(ctx *gin.Context, model string) (ok bool, err error) {
-	token := ctx.GetString("token")
+func (p *pg) Match(ctx *inter.Context, model string) (ok bool, err error) {
+	token := common.GetGinToken(ctx)
 	if model == "dall-e-3" {
 		ok, _ = regexp.MatchString(`\w{8,10}-\w{4}-\w{4}-\w{4}-\w{10,15}`, token)
 	}
 	return
 }
 
-func (p *pg) Generation(ctx *gin.Context) (err error) {
+func (p *pg) Generation(ctx *inter.Context) (err error) {
 
 	var (
 		hash       = emit.GioHash()
-		cookie     = ctx.GetString("token")
+		cookie     = common.GetGinToken(ctx)
 		generation = common.GetGinGeneration(ctx)
 	)
 
@@ -153,25 +150,25 @@ func (p *pg) Generation(ctx *gin.Context) (err error) {
 	marshal, _ := json.Marshal(payload)
 	r, err := fetch(ctx, "", cookie, marshal)
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return
 	}
 
 	data, err := io.ReadAll(r.Body)
 	if err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return
 	}
 
 	// {"errorCode":
 	if bytes.HasPrefix(data, []byte("{\"errorCode\":")) {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		return
 	}
 
 	var mc modelCompleted
 	if err = json.Unmarshal(data, &mc); err != nil {
-		logger.Error(err)
+		logger.Ctx(ctx).Error(err)
 		response.Error(ctx, -1, err)
 		return
 	}
@@ -181,7 +178,7 @@ func (p *pg) Generation(ctx *gin.Context) (err error) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
+	response.Forward(ctx, http.StatusOK, map[string]interface{}{
 		"created": time.Now().Unix(),
 		"styles":  models,
 		"data": []map[string]string{
@@ -8,4 +8,4 @@ import (
 )
 
 // @Inject(name = "pg-adapter")
-func New(env *env.Environment) inter.Adapter { return &pg{env: env} }
+func New(env *env.Environment) inter.Adapter { return &pg{} }
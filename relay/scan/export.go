@@ -19,6 +19,7 @@ import (
 	"chatgpt-adapter/relay/llm/lmsys"
 	"chatgpt-adapter/relay/llm/lmsys-chat"
 	"chatgpt-adapter/relay/llm/qodo"
+	"chatgpt-adapter/relay/llm/rpc"
 	"chatgpt-adapter/relay/llm/v1"
 	"chatgpt-adapter/relay/llm/windsurf"
 	"chatgpt-adapter/relay/llm/you"
@@ -96,6 +97,11 @@ func Injects(container *sdk.Container) (err error) {
 		return
 	}
 
+	err = rpc.Injects(container)
+	if err != nil {
+		return
+	}
+
 	err = rejects(container)
 	if err != nil {
 		return